@@ -2,8 +2,13 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
 	"noteapp/internal/server"
 	"noteapp/pkg/logger"
+	"os"
 	"os/signal"
 	"syscall"
 
@@ -14,10 +19,85 @@ func init() {
 	logger.SetLevel(6)
 }
 
+// noteapp                                    - start the server
+// noteapp backup -email user@mail.com [-out backup.json]
+// noteapp restore -email user@mail.com [-in backup.json]
+// noteapp publish -email user@mail.com [-group 1] -out site.zip|site-dir
 func main() {
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
+
+	if len(os.Args) > 1 {
+		if err := command(ctx, os.Args[1], os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			stop()
+			os.Exit(1)
+		}
+		return
+	}
+
 	defer logger.NewLog("main.go", 5, nil, "Stop server", nil)
 
 	server.Start(ctx)
 }
+
+func command(ctx context.Context, name string, args []string) error {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	email := fs.String("email", "", "email of the account")
+
+	switch name {
+	case "backup":
+		out := fs.String("out", "", "backup file, stdout by default")
+		fs.Parse(args)
+		if *email == "" {
+			return fmt.Errorf("backup: -email is required")
+		}
+
+		var w io.Writer = os.Stdout
+		if *out != "" {
+			f, err := os.Create(*out)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			w = f
+		}
+		return server.Backup(ctx, *email, w)
+
+	case "restore":
+		in := fs.String("in", "", "backup file, stdin by default")
+		fs.Parse(args)
+		if *email == "" {
+			return fmt.Errorf("restore: -email is required")
+		}
+
+		var r io.Reader = os.Stdin
+		if *in != "" {
+			f, err := os.Open(*in)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			r = f
+		}
+		report, err := server.Restore(ctx, *email, r)
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+
+	case "publish":
+		group := fs.Int("group", 0, "id of the group, all notes by default")
+		out := fs.String("out", "", "ZIP file or directory of the site")
+		fs.Parse(args)
+		if *email == "" || *out == "" {
+			return fmt.Errorf("publish: -email and -out are required")
+		}
+		return server.Publish(ctx, *email, *group, *out)
+
+	default:
+		return fmt.Errorf("unknown command %q, expected backup, restore or publish", name)
+	}
+}
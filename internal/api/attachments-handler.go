@@ -0,0 +1,380 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"noteapp/internal/repository"
+	"noteapp/internal/service"
+	"noteapp/internal/thumbnail"
+	"noteapp/pkg/logger"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	errFileMissing = errors.New("multipart field \"file\" is missing")
+)
+
+// attachments never change after upload, so browsers may keep them
+// for a year. The cache is private because the files require auth.
+const attachmentCacheControl = "private, max-age=31536000, immutable"
+
+// types which are safe to show in the browser, everything else is downloaded
+var inlineTypes = []string{"image/png", "image/jpeg", "image/gif", "image/webp", "image/bmp",
+	"application/pdf", "text/plain", "audio/", "video/"}
+
+// ATTACHMENTS
+
+// addAttachment expects multipart/form-data with the "file" field,
+// the note is given in the query: /addAttachment?note_id=1
+func (h *Handler) addAttachment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		apiError(w, r, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+
+	data, ok := r.Context().Value(ctxKey{}).(map[string]string)
+	if !ok {
+		logger.NewLog("api - addAttachment()", 2, nil, "Filed to recive contex data", nil)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	email := data["email"]
+	noteIdString := r.URL.Query().Get("note_id")
+	if email == "" || noteIdString == "" {
+		logger.NewLog("api - addAttachment()", 2, nil, "Required fields are missing in r.Context",
+			"email = "+email+" note_id = "+noteIdString)
+		apiError(w, r, http.StatusBadRequest, errRequiredFieldsMissing)
+		return
+	}
+
+	noteID, err := strconv.Atoi(noteIdString)
+	if err != nil {
+		logger.NewLog("api - addAttachment()", 2, err, "Filed to convert string to int", "string = "+noteIdString)
+		apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+		return
+	}
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		logger.NewLog("api - addAttachment()", 2, err, "Filed to read multipart body", nil)
+		apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+		return
+	}
+
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			apiError(w, r, http.StatusBadRequest, errFileMissing)
+			return
+		}
+		if err != nil {
+			logger.NewLog("api - addAttachment()", 2, err, "Filed to read multipart body", nil)
+			apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+			return
+		}
+		if part.FormName() != "file" {
+			part.Close()
+			continue
+		}
+
+		a, err := h.AttachmentsService.AddAttachment(r.Context(), noteID, email, part.FileName(), part)
+		part.Close()
+		if err == repository.ErrInvalidData {
+			apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+			return
+		}
+		if err == service.ErrAccessDenied {
+			apiError(w, r, http.StatusForbidden, service.ErrAccessDenied)
+			return
+		}
+		if err == service.ErrFileTooLarge || err == service.ErrQuotaExceeded {
+			apiError(w, r, http.StatusRequestEntityTooLarge, err)
+			return
+		}
+		if err != nil {
+			apiError(w, r, http.StatusInternalServerError, nil)
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(w).Encode(a); err != nil {
+			logger.NewLog("api - addAttachment()", 2, err, "Filed to encode r.Body", a)
+			return
+		}
+
+		logger.NewLog("api - addAttachment()", 5, nil,
+			"OUT - Attachment added "+time.Now().Format("02.01 15:04:05"), nil)
+		return
+	}
+}
+
+func (h *Handler) delAttachment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		apiError(w, r, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+
+	data, ok := r.Context().Value(ctxKey{}).(map[string]string)
+	if !ok {
+		logger.NewLog("api - delAttachment()", 2, nil, "Filed to recive contex data", nil)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	email := data["email"]
+	idString := r.URL.Query().Get("id")
+	if email == "" || idString == "" {
+		logger.NewLog("api - delAttachment()", 2, nil, "Required fields are missing in r.Context",
+			"email = "+email+" id = "+idString)
+		apiError(w, r, http.StatusBadRequest, errRequiredFieldsMissing)
+		return
+	}
+
+	id, err := strconv.Atoi(idString)
+	if err != nil {
+		logger.NewLog("api - delAttachment()", 2, err, "Filed to convert string to int", "string = "+idString)
+		apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+		return
+	}
+
+	err = h.AttachmentsService.DelAttachment(r.Context(), id, email)
+	if err == repository.ErrInvalidData {
+		apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+		return
+	}
+	if err == service.ErrAccessDenied {
+		apiError(w, r, http.StatusForbidden, service.ErrAccessDenied)
+		return
+	}
+	if err != nil {
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	logger.NewLog("api - delAttachment()", 5, nil,
+		"OUT - Attachment deleted "+time.Now().Format("02.01 15:04:05"), nil)
+}
+
+func (h *Handler) getAttachments(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apiError(w, r, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+
+	data, ok := r.Context().Value(ctxKey{}).(map[string]string)
+	if !ok {
+		logger.NewLog("api - getAttachments()", 2, nil, "Filed to recive contex data", nil)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	email := data["email"]
+	noteIdString := r.URL.Query().Get("note_id")
+	if email == "" || noteIdString == "" {
+		logger.NewLog("api - getAttachments()", 2, nil, "Required fields are missing in r.Context",
+			"email = "+email+" note_id = "+noteIdString)
+		apiError(w, r, http.StatusBadRequest, errRequiredFieldsMissing)
+		return
+	}
+
+	noteID, err := strconv.Atoi(noteIdString)
+	if err != nil {
+		logger.NewLog("api - getAttachments()", 2, err, "Filed to convert string to int", "string = "+noteIdString)
+		apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+		return
+	}
+
+	attachments, err := h.AttachmentsService.GetAttachments(noteID, email)
+	if err == repository.ErrInvalidData {
+		apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+		return
+	}
+	if err == service.ErrAccessDenied {
+		apiError(w, r, http.StatusForbidden, service.ErrAccessDenied)
+		return
+	}
+	if err != nil {
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(attachments); err != nil {
+		logger.NewLog("api - getAttachments()", 2, err, "Filed to encode r.Body", nil)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	logger.NewLog("api - getAttachments()", 5, nil,
+		"OUT - Attachments geted "+time.Now().Format("02.01 15:04:05"), nil)
+}
+
+// getAttachment returns the file itself, the client fetches it with the
+// Authorization header and shows it through a blob url
+func (h *Handler) getAttachment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apiError(w, r, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+
+	data, ok := r.Context().Value(ctxKey{}).(map[string]string)
+	if !ok {
+		logger.NewLog("api - getAttachment()", 2, nil, "Filed to recive contex data", nil)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	email := data["email"]
+	idString := r.URL.Query().Get("id")
+	if email == "" || idString == "" {
+		logger.NewLog("api - getAttachment()", 2, nil, "Required fields are missing in r.Context",
+			"email = "+email+" id = "+idString)
+		apiError(w, r, http.StatusBadRequest, errRequiredFieldsMissing)
+		return
+	}
+
+	id, err := strconv.Atoi(idString)
+	if err != nil {
+		logger.NewLog("api - getAttachment()", 2, err, "Filed to convert string to int", "string = "+idString)
+		apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+		return
+	}
+
+	a, body, err := h.AttachmentsService.GetAttachment(r.Context(), id, email)
+	if err == repository.ErrInvalidData {
+		apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+		return
+	}
+	if err == service.ErrAccessDenied {
+		apiError(w, r, http.StatusForbidden, service.ErrAccessDenied)
+		return
+	}
+	if err != nil {
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+	defer body.Close()
+
+	if notModified(w, r, strconv.Itoa(a.Id)) {
+		return
+	}
+
+	disposition := "attachment"
+	if isInlineType(a.Content_type) {
+		disposition = "inline"
+	}
+
+	w.Header().Set("Content-Type", a.Content_type)
+	w.Header().Set("Content-Length", strconv.FormatInt(a.Size, 10))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": a.Name}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	if _, err := io.Copy(w, body); err != nil {
+		logger.NewLog("api - getAttachment()", 3, err, "Filed to write attachment", a.Id)
+		return
+	}
+
+	logger.NewLog("api - getAttachment()", 5, nil,
+		"OUT - Attachment geted "+time.Now().Format("02.01 15:04:05"), nil)
+}
+
+// getThumbnail returns a preview of an image attachment: /getThumbnail?id=1&size=256,
+// the available sizes are 64, 256 and 1024
+func (h *Handler) getThumbnail(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apiError(w, r, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+
+	data, ok := r.Context().Value(ctxKey{}).(map[string]string)
+	if !ok {
+		logger.NewLog("api - getThumbnail()", 2, nil, "Filed to recive contex data", nil)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	email := data["email"]
+	idString := r.URL.Query().Get("id")
+	sizeString := r.URL.Query().Get("size")
+	if email == "" || idString == "" || sizeString == "" {
+		logger.NewLog("api - getThumbnail()", 2, nil, "Required fields are missing in r.Context",
+			"email = "+email+" id = "+idString+" size = "+sizeString)
+		apiError(w, r, http.StatusBadRequest, errRequiredFieldsMissing)
+		return
+	}
+
+	id, err1 := strconv.Atoi(idString)
+	size, err2 := strconv.Atoi(sizeString)
+	if err1 != nil || err2 != nil {
+		logger.NewLog("api - getThumbnail()", 2, nil, "Filed to convert string to int",
+			"id = "+idString+" size = "+sizeString)
+		apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+		return
+	}
+
+	a, body, err := h.AttachmentsService.GetThumbnail(r.Context(), id, size, email)
+	if err == repository.ErrInvalidData {
+		apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+		return
+	}
+	if err == service.ErrAccessDenied {
+		apiError(w, r, http.StatusForbidden, service.ErrAccessDenied)
+		return
+	}
+	if err == service.ErrNoThumbnail {
+		apiError(w, r, http.StatusNotFound, service.ErrNoThumbnail)
+		return
+	}
+	if err != nil {
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+	defer body.Close()
+
+	if notModified(w, r, strconv.Itoa(a.Id)+"-"+sizeString) {
+		return
+	}
+
+	w.Header().Set("Content-Type", thumbnail.ContentType(a.Content_type))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	if _, err := io.Copy(w, body); err != nil {
+		logger.NewLog("api - getThumbnail()", 3, err, "Filed to write thumbnail", a.Id)
+		return
+	}
+
+	logger.NewLog("api - getThumbnail()", 5, nil,
+		"OUT - Thumbnail geted "+time.Now().Format("02.01 15:04:05"), nil)
+}
+
+// notModified sets the cache headers and answers 304
+// if the client already has this version
+func notModified(w http.ResponseWriter, r *http.Request, tag string) bool {
+	etag := `"` + tag + `"`
+	w.Header().Set("Cache-Control", attachmentCacheControl)
+	w.Header().Set("ETag", etag)
+
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		for _, m := range strings.Split(match, ",") {
+			if m = strings.TrimSpace(m); m == etag || m == "W/"+etag || m == "*" {
+				w.WriteHeader(http.StatusNotModified)
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isInlineType(contentType string) bool {
+	for _, t := range inlineTypes {
+		if strings.HasPrefix(contentType, t) {
+			return true
+		}
+	}
+	return false
+}
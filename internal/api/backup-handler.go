@@ -0,0 +1,124 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"noteapp/internal/repository"
+	"noteapp/internal/service"
+	"noteapp/pkg/logger"
+	"time"
+)
+
+// BACKUP
+
+// backup streams the whole account as JSON, see model.BackupVersion
+func (h *Handler) backup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apiError(w, r, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+
+	data, ok := r.Context().Value(ctxKey{}).(map[string]string)
+	if !ok {
+		logger.NewLog("api - backup()", 2, nil, "Filed to recive contex data", nil)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	email := data["email"]
+	if email == "" {
+		logger.NewLog("api - backup()", 2, nil, "Required fields are missing in r.Contex", "email")
+		apiError(w, r, http.StatusBadRequest, errRequiredFieldsMissing)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition",
+		`attachment; filename="backup-`+time.Now().Format("2006-01-02")+`.json"`)
+
+	tw := &writeTracker{ResponseWriter: w}
+	if err := h.BackupService.Backup(r.Context(), email, tw); err != nil {
+		if !tw.written {
+			w.Header().Del("Content-Disposition")
+			apiError(w, r, http.StatusInternalServerError, nil)
+		}
+		logger.NewLog("api - backup()", 2, err, "Filed to backup account", email)
+		return
+	}
+
+	logger.NewLog("api - backup()", 5, nil,
+		"OUT - Backup geted "+time.Now().Format("02.01 15:04:05"), nil)
+}
+
+// restore expects multipart/form-data with the "file" field holding
+// a backup, the content is added to the account of the user
+func (h *Handler) restore(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		apiError(w, r, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+
+	data, ok := r.Context().Value(ctxKey{}).(map[string]string)
+	if !ok {
+		logger.NewLog("api - restore()", 2, nil, "Filed to recive contex data", nil)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	email := data["email"]
+	if email == "" {
+		logger.NewLog("api - restore()", 2, nil, "Required fields are missing in r.Contex", "email")
+		apiError(w, r, http.StatusBadRequest, errRequiredFieldsMissing)
+		return
+	}
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		logger.NewLog("api - restore()", 2, err, "Filed to read multipart body", nil)
+		apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+		return
+	}
+
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			apiError(w, r, http.StatusBadRequest, errFileMissing)
+			return
+		}
+		if err != nil {
+			logger.NewLog("api - restore()", 2, err, "Filed to read multipart body", nil)
+			apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+			return
+		}
+		if part.FormName() != "file" {
+			part.Close()
+			continue
+		}
+
+		report, err := h.BackupService.Restore(r.Context(), email, part)
+		part.Close()
+		if err == repository.ErrInvalidData {
+			apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+			return
+		}
+		if err == service.ErrFileTooLarge || err == service.ErrQuotaExceeded {
+			apiError(w, r, http.StatusRequestEntityTooLarge, err)
+			return
+		}
+		if err != nil {
+			apiError(w, r, http.StatusInternalServerError, nil)
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(w).Encode(report); err != nil {
+			logger.NewLog("api - restore()", 2, err, "Filed to encode r.Body", report)
+			return
+		}
+
+		logger.NewLog("api - restore()", 5, nil,
+			"OUT - Backup restored "+time.Now().Format("02.01 15:04:05"), nil)
+		return
+	}
+}
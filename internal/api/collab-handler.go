@@ -0,0 +1,123 @@
+package api
+
+import (
+	"net/http"
+	"noteapp/internal/model"
+	"noteapp/internal/repository"
+	"noteapp/internal/service"
+	"noteapp/pkg/logger"
+	"noteapp/pkg/websocket"
+	"strconv"
+	"time"
+)
+
+const (
+	wsPingPeriod = 30 * time.Second
+	wsReadWait   = 2 * wsPingPeriod
+)
+
+// COLLAB
+
+// collabNote - websocket for editing one note by several users at once.
+// Messages are described in internal/collab.
+func (h *Handler) collabNote(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apiError(w, r, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+
+	data, ok := r.Context().Value(ctxKey{}).(map[string]string)
+	if !ok {
+		logger.NewLog("api - collabNote()", 2, nil, "Filed to recive contex data", nil)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	email, ok := data["email"]
+	string_id := r.URL.Query().Get("id")
+	clientID := r.URL.Query().Get("client_id")
+	if !(ok && email != "" && string_id != "" && clientID != "") {
+		logger.NewLog("api - collabNote()", 2, nil, "Required fields are missing in r.Contex", "email="+email+" id="+string_id)
+		apiError(w, r, http.StatusBadRequest, errRequiredFieldsMissing)
+		return
+	}
+
+	id, err := strconv.Atoi(string_id)
+	if err != nil {
+		logger.NewLog("api - collabNote()", 2, err, "Filed to convert string to int", "string = "+string_id)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	client, err := h.CollabService.Join(id, email, clientID)
+	if err == repository.ErrInvalidData || err == model.ErrEncryptedNote {
+		apiError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if err == service.ErrAccessDenied {
+		apiError(w, r, http.StatusForbidden, service.ErrAccessDenied)
+		return
+	}
+	if err != nil {
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	conn, err := websocket.Upgrade(w, r, h.AllowedOrigins)
+	if err == websocket.ErrBadOrigin {
+		client.Leave()
+		logger.NewLog("api - collabNote()", 3, err, "Origin is not allowed", r.Header.Get("Origin"))
+		apiError(w, r, http.StatusForbidden, err)
+		return
+	}
+	if err != nil {
+		client.Leave()
+		logger.NewLog("api - collabNote()", 3, err, "Filed to upgrade connection", nil)
+		apiError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	logger.NewLog("api - collabNote()", 5, nil,
+		"OUT - Collab session joined "+time.Now().Format("02.01 15:04:05"), "email="+email+" id="+string_id)
+
+	// writer, the request context is cancelled on the server shutdown
+	go func() {
+		ticker := time.NewTicker(wsPingPeriod)
+		defer ticker.Stop()
+		defer conn.Close()
+
+		for {
+			select {
+			case msg, ok := <-client.Send:
+				if !ok {
+					conn.WriteMessage(websocket.CloseMessage, nil)
+					return
+				}
+				if err := conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+					client.Leave()
+					return
+				}
+			case <-ticker.C:
+				if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+					client.Leave()
+					return
+				}
+			case <-r.Context().Done():
+				conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway))
+				client.Leave()
+				return
+			}
+		}
+	}()
+
+	// reader
+	defer client.Leave()
+	conn.SetReadTimeout(wsReadWait)
+	for {
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		client.Handle(msg)
+	}
+}
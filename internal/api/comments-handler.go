@@ -0,0 +1,282 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"noteapp/internal/model"
+	"noteapp/internal/repository"
+	"noteapp/internal/service"
+	"noteapp/pkg/logger"
+	"strconv"
+	"time"
+)
+
+// COMMENTS
+
+// addComment adds "text" to "note_id" as a new thread or, with
+// "parent_id", as a reply. "anchor_start" and "anchor_end" - range of
+// characters of the note text the thread is about.
+func (h *Handler) addComment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		apiError(w, r, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+
+	data, ok := r.Context().Value(ctxKey{}).(map[string]string)
+	if !ok {
+		logger.NewLog("api - addComment()", 2, nil, "Filed to recive contex data", nil)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	email := data["email"]
+	if email == "" || data["note_id"] == "" || data["text"] == "" {
+		logger.NewLog("api - addComment()", 2, nil, "Required fields are missing in r.Context", data)
+		apiError(w, r, http.StatusBadRequest, errRequiredFieldsMissing)
+		return
+	}
+
+	c := &model.Comment{Author_email: email, Text: data["text"]}
+	for _, key := range []string{"note_id", "parent_id", "anchor_start", "anchor_end"} {
+		if data[key] == "" {
+			continue
+		}
+		n, err := strconv.Atoi(data[key])
+		if err != nil {
+			logger.NewLog("api - addComment()", 2, err, "Filed to convert string to int", "string = "+data[key])
+			apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+			return
+		}
+		switch key {
+		case "note_id":
+			c.Note_id = n
+		case "parent_id":
+			c.Parent_id = n
+		case "anchor_start":
+			c.Anchor_start = &n
+		case "anchor_end":
+			c.Anchor_end = &n
+		}
+	}
+
+	err := h.CommentsService.AddComment(c)
+	if err == repository.ErrInvalidData || err == model.ErrValidationComment || err == model.ErrEncryptedNote {
+		apiError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if err == service.ErrAccessDenied {
+		apiError(w, r, http.StatusForbidden, service.ErrAccessDenied)
+		return
+	}
+	if err != nil {
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(c); err != nil {
+		logger.NewLog("api - addComment()", 2, err, "Filed to encode r.Body", c.Id)
+		return
+	}
+
+	logger.NewLog("api - addComment()", 5, nil,
+		"OUT - Comment added "+time.Now().Format("02.01 15:04:05"), nil)
+}
+
+func (h *Handler) updateComment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		apiError(w, r, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+
+	data, ok := r.Context().Value(ctxKey{}).(map[string]string)
+	if !ok {
+		logger.NewLog("api - updateComment()", 2, nil, "Filed to recive contex data", nil)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	string_id := data["id"]
+	email := data["email"]
+	if string_id == "" || email == "" || data["text"] == "" {
+		logger.NewLog("api - updateComment()", 2, nil, "Required fields are missing in r.Contex", data)
+		apiError(w, r, http.StatusBadRequest, errRequiredFieldsMissing)
+		return
+	}
+
+	id, err := strconv.Atoi(string_id)
+	if err != nil {
+		logger.NewLog("api - updateComment()", 2, err, "Filed to convert string to int", "string = "+string_id)
+		apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+		return
+	}
+
+	err = h.CommentsService.UpdateComment(id, email, data["text"])
+	if err == repository.ErrInvalidData || err == model.ErrValidationComment {
+		apiError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if err == service.ErrAccessDenied {
+		apiError(w, r, http.StatusForbidden, service.ErrAccessDenied)
+		return
+	}
+	if err != nil {
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	logger.NewLog("api - updateComment()", 5, nil,
+		"OUT - Comment updated "+time.Now().Format("02.01 15:04:05"), nil)
+}
+
+func (h *Handler) delComment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		apiError(w, r, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+
+	data, ok := r.Context().Value(ctxKey{}).(map[string]string)
+	if !ok {
+		logger.NewLog("api - delComment()", 2, nil, "Filed to recive contex data", nil)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	string_id := r.URL.Query().Get("id")
+	email := data["email"]
+	if string_id == "" || email == "" {
+		logger.NewLog("api - delComment()", 2, nil, "Required fields are missing in r.Contex", nil)
+		apiError(w, r, http.StatusBadRequest, errRequiredFieldsMissing)
+		return
+	}
+
+	id, err := strconv.Atoi(string_id)
+	if err != nil {
+		logger.NewLog("api - delComment()", 2, err, "Filed to convert string to int", "string = "+string_id)
+		apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+		return
+	}
+
+	err = h.CommentsService.DelComment(id, email)
+	if err == repository.ErrInvalidData {
+		apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+		return
+	}
+	if err == service.ErrAccessDenied {
+		apiError(w, r, http.StatusForbidden, service.ErrAccessDenied)
+		return
+	}
+	if err != nil {
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	logger.NewLog("api - delComment()", 5, nil,
+		"OUT - Comment deleted "+time.Now().Format("02.01 15:04:05"), nil)
+}
+
+// resolveComment resolves ("resolved" = "true") or reopens the thread "id"
+func (h *Handler) resolveComment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		apiError(w, r, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+
+	data, ok := r.Context().Value(ctxKey{}).(map[string]string)
+	if !ok {
+		logger.NewLog("api - resolveComment()", 2, nil, "Filed to recive contex data", nil)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	string_id := data["id"]
+	email := data["email"]
+	if string_id == "" || email == "" || data["resolved"] == "" {
+		logger.NewLog("api - resolveComment()", 2, nil, "Required fields are missing in r.Contex", data)
+		apiError(w, r, http.StatusBadRequest, errRequiredFieldsMissing)
+		return
+	}
+
+	id, err := strconv.Atoi(string_id)
+	if err != nil {
+		logger.NewLog("api - resolveComment()", 2, err, "Filed to convert string to int", "string = "+string_id)
+		apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+		return
+	}
+	resolved, err := strconv.ParseBool(data["resolved"])
+	if err != nil {
+		logger.NewLog("api - resolveComment()", 2, err, "Filed to parse bool", data["resolved"])
+		apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+		return
+	}
+
+	err = h.CommentsService.ResolveComment(id, email, resolved)
+	if err == repository.ErrInvalidData {
+		apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+		return
+	}
+	if err == service.ErrAccessDenied {
+		apiError(w, r, http.StatusForbidden, service.ErrAccessDenied)
+		return
+	}
+	if err != nil {
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	logger.NewLog("api - resolveComment()", 5, nil,
+		"OUT - Comment resolved "+time.Now().Format("02.01 15:04:05"), nil)
+}
+
+// getComments returns the threads of "note_id" with their replies
+func (h *Handler) getComments(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apiError(w, r, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+
+	data, ok := r.Context().Value(ctxKey{}).(map[string]string)
+	if !ok {
+		logger.NewLog("api - getComments()", 2, nil, "Filed to recive contex data", nil)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	string_id := r.URL.Query().Get("note_id")
+	email := data["email"]
+	if string_id == "" || email == "" {
+		logger.NewLog("api - getComments()", 2, nil, "Required fields are missing in r.Contex", nil)
+		apiError(w, r, http.StatusBadRequest, errRequiredFieldsMissing)
+		return
+	}
+
+	id, err := strconv.Atoi(string_id)
+	if err != nil {
+		logger.NewLog("api - getComments()", 2, err, "Filed to convert string to int", "string = "+string_id)
+		apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+		return
+	}
+
+	list, err := h.CommentsService.GetComments(id, email)
+	if err == repository.ErrInvalidData {
+		apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+		return
+	}
+	if err == service.ErrAccessDenied {
+		apiError(w, r, http.StatusForbidden, service.ErrAccessDenied)
+		return
+	}
+	if err != nil {
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(list); err != nil {
+		logger.NewLog("api - getComments()", 2, err, "Filed to encode r.Body", nil)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	logger.NewLog("api - getComments()", 5, nil,
+		"OUT - Comments geted "+time.Now().Format("02.01 15:04:05"), nil)
+}
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"noteapp/pkg/logger"
+	"time"
+)
+
+const eventsHeartbeat = 15 * time.Second
+
+// EVENTS
+
+// events streams changes of the user notes and groups as Server-Sent Events.
+// A reconnecting client sends Last-Event-ID and receives the missed events first.
+func (h *Handler) events(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apiError(w, r, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+
+	data, ok := r.Context().Value(ctxKey{}).(map[string]string)
+	if !ok {
+		logger.NewLog("api - events()", 2, nil, "Filed to recive contex data", nil)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	email, ok1 := data["email"]
+	if !(ok1 && email != "") {
+		logger.NewLog("api - events()", 2, nil, "Required fields are missing in r.Contex", "email")
+		apiError(w, r, http.StatusBadRequest, errRequiredFieldsMissing)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		logger.NewLog("api - events()", 2, nil, "Streaming is not supported", nil)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	// an id of another process or a broken one gets the resync event
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("lastEventId")
+	}
+
+	ch, missed, cancel := h.EventsService.Subscribe(email, lastEventID)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	for _, e := range missed {
+		if err := writeEvent(w, h.EventsService.EventID(e.Id), e.Type, e); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	logger.NewLog("api - events()", 5, nil,
+		"OUT - Events stream opened "+time.Now().Format("02.01 15:04:05"), email)
+
+	ticker := time.NewTicker(eventsHeartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e, ok := <-ch:
+			if !ok {
+				return
+			}
+			if err := writeEvent(w, h.EventsService.EventID(e.Id), e.Type, e); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-ticker.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, id string, event string, data interface{}) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		logger.NewLog("api - writeEvent()", 2, err, "Filed to marshal event", data)
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", id, event, b)
+	return err
+}
@@ -0,0 +1,135 @@
+package api
+
+import (
+	"net/http"
+	"noteapp/internal/repository"
+	"noteapp/internal/site"
+	"noteapp/pkg/logger"
+	"strconv"
+	"time"
+)
+
+// EXPORT
+
+// writeTracker remembers if anything was sent, after that the status
+// can't be changed and errors are only logged
+type writeTracker struct {
+	http.ResponseWriter
+	written bool
+}
+
+func (w *writeTracker) Write(b []byte) (int, error) {
+	w.written = true
+	return w.ResponseWriter.Write(b)
+}
+
+// exportNotes streams all notes of the user as a ZIP of Markdown files or,
+// with /exportNotes?format=opml, the groups and notes as an OPML outline
+func (h *Handler) exportNotes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apiError(w, r, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+
+	data, ok := r.Context().Value(ctxKey{}).(map[string]string)
+	if !ok {
+		logger.NewLog("api - exportNotes()", 2, nil, "Filed to recive contex data", nil)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	email := data["email"]
+	if email == "" {
+		logger.NewLog("api - exportNotes()", 2, nil, "Required fields are missing in r.Contex", "email")
+		apiError(w, r, http.StatusBadRequest, errRequiredFieldsMissing)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "markdown" && format != "opml" {
+		logger.NewLog("api - exportNotes()", 2, nil, "Unknown export format", format)
+		apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+		return
+	}
+
+	filename := "notes-" + time.Now().Format("2006-01-02")
+	tw := &writeTracker{ResponseWriter: w}
+	var err error
+	if format == "opml" {
+		w.Header().Set("Content-Type", "text/x-opml; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`.opml"`)
+		err = h.ExportService.ExportOPML(email, tw)
+	} else {
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`.zip"`)
+		err = h.ExportService.ExportMarkdown(r.Context(), email, tw)
+	}
+	if err != nil {
+		if !tw.written {
+			w.Header().Del("Content-Type")
+			w.Header().Del("Content-Disposition")
+			apiError(w, r, http.StatusInternalServerError, nil)
+		}
+		// the file is cut, the client sees a broken ZIP
+		logger.NewLog("api - exportNotes()", 2, err, "Filed to export notes", email)
+		return
+	}
+
+	logger.NewLog("api - exportNotes()", 5, nil,
+		"OUT - Notes exported "+time.Now().Format("02.01 15:04:05"), nil)
+}
+
+// publishGroup streams the group as a static HTML site packed to a ZIP:
+// /publishGroup?id=1, without id all notes are published
+func (h *Handler) publishGroup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apiError(w, r, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+
+	data, ok := r.Context().Value(ctxKey{}).(map[string]string)
+	if !ok {
+		logger.NewLog("api - publishGroup()", 2, nil, "Filed to recive contex data", nil)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	email := data["email"]
+	if email == "" {
+		logger.NewLog("api - publishGroup()", 2, nil, "Required fields are missing in r.Contex", "email")
+		apiError(w, r, http.StatusBadRequest, errRequiredFieldsMissing)
+		return
+	}
+
+	id := 0
+	if idString := r.URL.Query().Get("id"); idString != "" {
+		var err error
+		if id, err = strconv.Atoi(idString); err != nil || id < 0 {
+			logger.NewLog("api - publishGroup()", 2, err, "Filed to convert string to int", idString)
+			apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition",
+		`attachment; filename="site-`+time.Now().Format("2006-01-02")+`.zip"`)
+
+	tw := &writeTracker{ResponseWriter: w}
+	if err := h.ExportService.PublishSite(r.Context(), email, id, site.NewZipOutput(tw)); err != nil {
+		if !tw.written {
+			w.Header().Del("Content-Type")
+			w.Header().Del("Content-Disposition")
+			if err == repository.ErrInvalidData {
+				apiError(w, r, http.StatusBadRequest, err)
+			} else {
+				apiError(w, r, http.StatusInternalServerError, nil)
+			}
+		}
+		logger.NewLog("api - publishGroup()", 2, err, "Filed to publish group", id)
+		return
+	}
+
+	logger.NewLog("api - publishGroup()", 5, nil,
+		"OUT - Group published "+time.Now().Format("02.01 15:04:05"), nil)
+}
@@ -0,0 +1,206 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"noteapp/internal/repository"
+	"noteapp/internal/service"
+	"noteapp/pkg/logger"
+	"strconv"
+	"time"
+)
+
+// FAVORITES
+
+// pinNote pins ("pinned" = "true") or unpins the note in its group
+func (h *Handler) pinNote(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		apiError(w, r, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+
+	data, ok := r.Context().Value(ctxKey{}).(map[string]string)
+	if !ok {
+		logger.NewLog("api - pinNote()", 2, nil, "Filed to recive contex data", nil)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	string_id := data["id"]
+	email := data["email"]
+	if string_id == "" || email == "" || data["pinned"] == "" {
+		logger.NewLog("api - pinNote()", 2, nil, "Required fields are missing in r.Contex", data)
+		apiError(w, r, http.StatusBadRequest, errRequiredFieldsMissing)
+		return
+	}
+
+	id, err := strconv.Atoi(string_id)
+	if err != nil {
+		logger.NewLog("api - pinNote()", 2, err, "Filed to convert string to int", "string = "+string_id)
+		apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+		return
+	}
+	pinned, err := strconv.ParseBool(data["pinned"])
+	if err != nil {
+		logger.NewLog("api - pinNote()", 2, err, "Filed to parse bool", data["pinned"])
+		apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+		return
+	}
+
+	err = h.FavoritesService.PinNote(id, email, pinned)
+	if err == repository.ErrInvalidData {
+		apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+		return
+	}
+	if err == service.ErrAccessDenied {
+		apiError(w, r, http.StatusForbidden, service.ErrAccessDenied)
+		return
+	}
+	if err != nil {
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	logger.NewLog("api - pinNote()", 5, nil,
+		"OUT - Note pinned "+time.Now().Format("02.01 15:04:05"), nil)
+}
+
+func (h *Handler) favoriteNote(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		apiError(w, r, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+
+	data, ok := r.Context().Value(ctxKey{}).(map[string]string)
+	if !ok {
+		logger.NewLog("api - favoriteNote()", 2, nil, "Filed to recive contex data", nil)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	string_id := data["id"]
+	email := data["email"]
+	if string_id == "" || email == "" || data["favorite"] == "" {
+		logger.NewLog("api - favoriteNote()", 2, nil, "Required fields are missing in r.Contex", data)
+		apiError(w, r, http.StatusBadRequest, errRequiredFieldsMissing)
+		return
+	}
+
+	id, err := strconv.Atoi(string_id)
+	if err != nil {
+		logger.NewLog("api - favoriteNote()", 2, err, "Filed to convert string to int", "string = "+string_id)
+		apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+		return
+	}
+	favorite, err := strconv.ParseBool(data["favorite"])
+	if err != nil {
+		logger.NewLog("api - favoriteNote()", 2, err, "Filed to parse bool", data["favorite"])
+		apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+		return
+	}
+
+	err = h.FavoritesService.FavoriteNote(id, email, favorite)
+	if err == repository.ErrInvalidData {
+		apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+		return
+	}
+	if err == service.ErrAccessDenied {
+		apiError(w, r, http.StatusForbidden, service.ErrAccessDenied)
+		return
+	}
+	if err != nil {
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	logger.NewLog("api - favoriteNote()", 5, nil,
+		"OUT - Note favorite set "+time.Now().Format("02.01 15:04:05"), nil)
+}
+
+func (h *Handler) favoriteGroup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		apiError(w, r, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+
+	data, ok := r.Context().Value(ctxKey{}).(map[string]string)
+	if !ok {
+		logger.NewLog("api - favoriteGroup()", 2, nil, "Filed to recive contex data", nil)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	string_id := data["id"]
+	email := data["email"]
+	if string_id == "" || email == "" || data["favorite"] == "" {
+		logger.NewLog("api - favoriteGroup()", 2, nil, "Required fields are missing in r.Contex", data)
+		apiError(w, r, http.StatusBadRequest, errRequiredFieldsMissing)
+		return
+	}
+
+	id, err := strconv.Atoi(string_id)
+	if err != nil {
+		logger.NewLog("api - favoriteGroup()", 2, err, "Filed to convert string to int", "string = "+string_id)
+		apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+		return
+	}
+	favorite, err := strconv.ParseBool(data["favorite"])
+	if err != nil {
+		logger.NewLog("api - favoriteGroup()", 2, err, "Filed to parse bool", data["favorite"])
+		apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+		return
+	}
+
+	err = h.FavoritesService.FavoriteGroup(id, email, favorite)
+	if err == repository.ErrInvalidData {
+		apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+		return
+	}
+	if err == service.ErrAccessDenied {
+		apiError(w, r, http.StatusForbidden, service.ErrAccessDenied)
+		return
+	}
+	if err != nil {
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	logger.NewLog("api - favoriteGroup()", 5, nil,
+		"OUT - Group favorite set "+time.Now().Format("02.01 15:04:05"), nil)
+}
+
+func (h *Handler) getFavorites(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apiError(w, r, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+
+	data, ok := r.Context().Value(ctxKey{}).(map[string]string)
+	if !ok {
+		logger.NewLog("api - getFavorites()", 2, nil, "Filed to recive contex data", nil)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	email := data["email"]
+	if email == "" {
+		logger.NewLog("api - getFavorites()", 2, nil, "Required fields are missing in r.Contex", "email")
+		apiError(w, r, http.StatusBadRequest, errRequiredFieldsMissing)
+		return
+	}
+
+	favorites, err := h.FavoritesService.GetFavorites(email)
+	if err != nil {
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(favorites); err != nil {
+		logger.NewLog("api - getFavorites()", 2, err, "Filed to encode r.Body", nil)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	logger.NewLog("api - getFavorites()", 5, nil,
+		"OUT - Favorites geted "+time.Now().Format("02.01 15:04:05"), nil)
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"noteapp/internal/model"
+	"noteapp/internal/repository"
+	"noteapp/pkg/logger"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GRAPH
+
+// getGraph returns nodes and edges of the notes graph:
+// /getGraph?note_id=1&depth=2&types=note,tag&tag=work&group_id=3&limit=300,
+// all params are optional
+func (h *Handler) getGraph(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apiError(w, r, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+
+	data, ok := r.Context().Value(ctxKey{}).(map[string]string)
+	if !ok {
+		logger.NewLog("api - getGraph()", 2, nil, "Filed to recive contex data", nil)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	email, ok1 := data["email"]
+	if !(ok1 && email != "") {
+		logger.NewLog("api - getGraph()", 2, nil, "Required fields are missing in r.Contex", "email")
+		apiError(w, r, http.StatusBadRequest, errRequiredFieldsMissing)
+		return
+	}
+
+	query := r.URL.Query()
+	q := model.GraphQuery{
+		Tag: query.Get("tag"),
+	}
+	if types := query.Get("types"); types != "" {
+		q.Types = strings.Split(types, ",")
+	}
+
+	ints := map[string]*int{
+		"note_id":  &q.Note_id,
+		"depth":    &q.Depth,
+		"group_id": &q.Group_id,
+		"limit":    &q.Limit,
+	}
+	for name, dst := range ints {
+		v := query.Get(name)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			logger.NewLog("api - getGraph()", 2, err, "Filed to convert string to int", name+" = "+v)
+			apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+			return
+		}
+		*dst = n
+	}
+
+	graph, err := h.GraphService.GetGraph(email, q)
+	if err == repository.ErrInvalidData {
+		apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+		return
+	}
+	if err != nil {
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(graph); err != nil {
+		logger.NewLog("api - getGraph()", 2, err, "Filed to encode r.Body", nil)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	logger.NewLog("api - getGraph()", 5, nil,
+		"OUT - Graph geted "+time.Now().Format("02.01 15:04:05"), nil)
+}
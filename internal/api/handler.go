@@ -1,12 +1,17 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"io"
 	"net/http"
+	"noteapp/internal/collab"
+	"noteapp/internal/feed"
 	"noteapp/internal/model"
 	"noteapp/internal/repository"
 	"noteapp/internal/service"
+	"noteapp/internal/site"
 	"noteapp/pkg/logger"
 	"strconv"
 	"time"
@@ -18,6 +23,10 @@ var (
 	errRequiredFieldsMissing = errors.New("required fields are missing or not filled in")
 )
 
+// DefaultMaxBodySize - the largest bodies are notes, their texts are up
+// to 10M characters
+const DefaultMaxBodySize = 32 << 20
+
 type ctxKey struct{}
 
 type UserService interface {
@@ -27,26 +36,200 @@ type UserService interface {
 
 type NotesService interface {
 	// GROUPS
-	AddGroup(email string, nameGroup string, pid int) error
+	AddGroup(email string, nameGroup string, pid int) (int, error)
 	DelGroup(id int, email string) error
 	UpdateGroup(id int, email string, newNameGroup string, pid int) error
 	// NOTES
-	AddNote(email string, title string, group_id int) error
+	AddNote(email string, title string, group_id int) (int, error)
+	AddEncryptedNote(email string, title string, text string, group_id int) (int, error)
 	DelNote(id int, email string) error
 	UpdateNote(data map[string]string) error
 	GetNotesList(email string) (model.NoteList, error)
 	GetNote(id int, email string) (model.Note, error)
+	GetNoteHTML(id int, email string) (model.Note, error)
+	// LINKS
+	GetLinks(id int, email string) ([]model.NoteLink, error)
+	GetBacklinks(id int, email string) ([]model.Backlink, error)
+	// TASKS
+	GetTasks(email string, f model.TaskFilter) ([]model.Task, error)
+	ToggleTask(id int, email string, done bool) error
+}
+
+type SharesService interface {
+	AddShare(share *model.Share) error
+	DelShare(id int, ownerEmail string) error
+	GetShares(ownerEmail string) ([]model.Share, error)
+	GetSharedList(email string) (model.SharedList, error)
+}
+
+type PublicLinksService interface {
+	AddLink(l *model.PublicLink) error
+	DelLink(id int, ownerEmail string) error
+	GetLinks(ownerEmail string) ([]model.PublicLink, error)
+	GetPublicContent(token string, password string, unlock string, noteID int) (model.PublicContent, error)
+	GetFeed(token string, password string, base string) (feed.Feed, error)
+}
+
+type EventsService interface {
+	Subscribe(email string, lastEventID string) (<-chan model.Event, []model.Event, func())
+	EventID(id int64) string
+}
+
+type CollabService interface {
+	Join(noteID int, email string, clientID string) (*collab.Client, error)
+}
+
+type SyncService interface {
+	Pull(email string, cursor int64) (model.SyncPull, error)
+	Push(email string, changes []model.SyncChange) ([]model.SyncResult, error)
+}
+
+type AttachmentsService interface {
+	AddAttachment(ctx context.Context, noteID int, email string, name string, r io.Reader) (*model.Attachment, error)
+	DelAttachment(ctx context.Context, id int, email string) error
+	GetAttachments(noteID int, email string) ([]model.Attachment, error)
+	GetAttachment(ctx context.Context, id int, email string) (*model.Attachment, io.ReadCloser, error)
+	GetThumbnail(ctx context.Context, id int, size int, email string) (*model.Attachment, io.ReadCloser, error)
+}
+
+type GraphService interface {
+	GetGraph(email string, q model.GraphQuery) (model.Graph, error)
+}
+
+type ExportService interface {
+	ExportMarkdown(ctx context.Context, email string, w io.Writer) error
+	ExportOPML(email string, w io.Writer) error
+	PublishSite(ctx context.Context, email string, groupID int, out site.Output) error
+}
+
+type ImportService interface {
+	ImportMarkdown(ctx context.Context, email string, r io.Reader) (*model.ImportReport, error)
+	ImportENEX(ctx context.Context, email string, name string, r io.Reader) (*model.ImportReport, error)
+	ImportOPML(ctx context.Context, email string, name string, r io.Reader) (*model.ImportReport, error)
+}
+
+type TemplatesService interface {
+	AddTemplate(t *model.Template) error
+	UpdateTemplate(t *model.Template) error
+	DelTemplate(id int, email string) error
+	GetTemplates(email string) ([]model.Template, error)
+	AddNoteFromTemplate(email string, templateID int, builtin string, groupID int, title string, now time.Time) (int, error)
+}
+
+type JournalService interface {
+	GetJournal(email string) (model.Journal, error)
+	UpdateJournal(email string, j model.Journal) error
+	GetDailyNote(email string, day time.Time, now time.Time) (model.DailyNote, error)
+}
+
+type RemindersService interface {
+	AddReminder(rem *model.Reminder) error
+	DelReminder(id int, email string) error
+	GetReminders(email string, noteID int) ([]model.Reminder, error)
+	GetNotifications(email string, unread bool) ([]model.Notification, error)
+	ReadNotification(id int, email string) error
+}
+
+type FavoritesService interface {
+	PinNote(id int, email string, pinned bool) error
+	FavoriteNote(id int, email string, favorite bool) error
+	FavoriteGroup(id int, email string, favorite bool) error
+	GetFavorites(email string) (model.Favorites, error)
+}
+
+type RecentService interface {
+	NoteViewed(id int, email string)
+	NoteEdited(id int, email string)
+	GetRecent(email string, edited bool, limit int) ([]model.RecentNote, error)
+}
+
+type CommentsService interface {
+	AddComment(c *model.Comment) error
+	GetComments(noteID int, email string) ([]model.Comment, error)
+	UpdateComment(id int, email string, text string) error
+	DelComment(id int, email string) error
+	ResolveComment(id int, email string, resolved bool) error
+}
+
+type KeysService interface {
+	GetKeys(email string) (model.UserKeys, error)
+	SetKeys(k *model.UserKeys) error
+}
+
+type BackupService interface {
+	Backup(ctx context.Context, email string, w io.Writer) error
+	Restore(ctx context.Context, email string, r io.Reader) (*model.ImportReport, error)
 }
 
 type Handler struct {
-	UserService  UserService
-	NotesService NotesService
+	UserService        UserService
+	NotesService       NotesService
+	SharesService      SharesService
+	PublicLinksService PublicLinksService
+	EventsService      EventsService
+	CollabService      CollabService
+	SyncService        SyncService
+	AttachmentsService AttachmentsService
+	GraphService       GraphService
+	ExportService      ExportService
+	ImportService      ImportService
+	BackupService      BackupService
+	TemplatesService   TemplatesService
+	JournalService     JournalService
+	RemindersService   RemindersService
+	FavoritesService   FavoritesService
+	RecentService      RecentService
+	CommentsService    CommentsService
+	KeysService        KeysService
+
+	// limit of the JSON bodies, file uploads are limited by the services
+	MaxBodySize int64
+	// origins of the pages that may open /ws/note besides the host itself
+	AllowedOrigins []string
 }
 
-func NewHandler(userService UserService, notesService NotesService) *Handler {
+func NewHandler(
+	userService UserService,
+	notesService NotesService,
+	sharesService SharesService,
+	publicLinksService PublicLinksService,
+	eventsService EventsService,
+	collabService CollabService,
+	syncService SyncService,
+	attachmentsService AttachmentsService,
+	graphService GraphService,
+	exportService ExportService,
+	importService ImportService,
+	backupService BackupService,
+	templatesService TemplatesService,
+	journalService JournalService,
+	remindersService RemindersService,
+	favoritesService FavoritesService,
+	recentService RecentService,
+	commentsService CommentsService,
+	keysService KeysService,
+) *Handler {
 	return &Handler{
-		UserService:  userService,
-		NotesService: notesService,
+		UserService:        userService,
+		NotesService:       notesService,
+		SharesService:      sharesService,
+		PublicLinksService: publicLinksService,
+		EventsService:      eventsService,
+		CollabService:      collabService,
+		SyncService:        syncService,
+		AttachmentsService: attachmentsService,
+		GraphService:       graphService,
+		ExportService:      exportService,
+		ImportService:      importService,
+		BackupService:      backupService,
+		TemplatesService:   templatesService,
+		JournalService:     journalService,
+		RemindersService:   remindersService,
+		FavoritesService:   favoritesService,
+		RecentService:      recentService,
+		CommentsService:    commentsService,
+		KeysService:        keysService,
+		MaxBodySize:        DefaultMaxBodySize,
 	}
 }
 
@@ -77,7 +260,7 @@ func (h *Handler) InitHandler() *http.ServeMux {
 
 	router.HandleFunc("/logout", chainMiddleware(
 		h.logOut,
-		middlewareAuth(),
+		middlewareAuth(h.MaxBodySize),
 		middlewareNoCors(),
 		middlewareLogIn()),
 	)
@@ -86,21 +269,21 @@ func (h *Handler) InitHandler() *http.ServeMux {
 
 	// router.HandleFunc("/addGroup", chainMiddleware(
 	// 	h.addGroup,
-	// 	middlewareAuth(),
+	// 	middlewareAuth(h.MaxBodySize),
 	// 	middlewareNoCors(),
 	// 	middlewareLogIn()),
 	// )
 
 	router.HandleFunc("/delGroup", chainMiddleware(
 		h.delGroup,
-		middlewareAuth(),
+		middlewareAuth(h.MaxBodySize),
 		middlewareNoCors(),
 		middlewareLogIn()),
 	)
 
 	router.HandleFunc("/updateGroup", chainMiddleware(
 		h.updateGroup,
-		middlewareAuth(),
+		middlewareAuth(h.MaxBodySize),
 		middlewareNoCors(),
 		middlewareLogIn()),
 	)
@@ -109,35 +292,444 @@ func (h *Handler) InitHandler() *http.ServeMux {
 
 	router.HandleFunc("/addNote", chainMiddleware(
 		h.addNote,
-		middlewareAuth(),
+		middlewareAuth(h.MaxBodySize),
 		middlewareNoCors(),
 		middlewareLogIn()),
 	)
 
 	router.HandleFunc("/delNote", chainMiddleware(
 		h.delNote,
-		middlewareAuth(),
+		middlewareAuth(h.MaxBodySize),
 		middlewareNoCors(),
 		middlewareLogIn()),
 	)
 
 	router.HandleFunc("/updateNote", chainMiddleware(
 		h.updateNote,
-		middlewareAuth(),
+		middlewareAuth(h.MaxBodySize),
 		middlewareNoCors(),
 		middlewareLogIn()),
 	)
 
 	router.HandleFunc("/getNotesList", chainMiddleware(
 		h.getNotesList,
-		middlewareAuth(),
+		middlewareAuth(h.MaxBodySize),
 		middlewareNoCors(),
 		middlewareLogIn()),
 	)
 
 	router.HandleFunc("/getNote", chainMiddleware(
 		h.getNote,
-		middlewareAuth(),
+		middlewareAuth(h.MaxBodySize),
+		middlewareNoCors(),
+		middlewareLogIn()),
+	)
+
+	// LINKS
+
+	router.HandleFunc("/getLinks", chainMiddleware(
+		h.getLinks,
+		middlewareAuth(h.MaxBodySize),
+		middlewareNoCors(),
+		middlewareLogIn()),
+	)
+
+	router.HandleFunc("/getBacklinks", chainMiddleware(
+		h.getBacklinks,
+		middlewareAuth(h.MaxBodySize),
+		middlewareNoCors(),
+		middlewareLogIn()),
+	)
+
+	// GRAPH
+
+	router.HandleFunc("/getGraph", chainMiddleware(
+		h.getGraph,
+		middlewareAuth(h.MaxBodySize),
+		middlewareNoCors(),
+		middlewareLogIn()),
+	)
+
+	// SHARES
+
+	router.HandleFunc("/addShare", chainMiddleware(
+		h.addShare,
+		middlewareAuth(h.MaxBodySize),
+		middlewareNoCors(),
+		middlewareLogIn()),
+	)
+
+	router.HandleFunc("/delShare", chainMiddleware(
+		h.delShare,
+		middlewareAuth(h.MaxBodySize),
+		middlewareNoCors(),
+		middlewareLogIn()),
+	)
+
+	router.HandleFunc("/getShares", chainMiddleware(
+		h.getShares,
+		middlewareAuth(h.MaxBodySize),
+		middlewareNoCors(),
+		middlewareLogIn()),
+	)
+
+	router.HandleFunc("/getSharedList", chainMiddleware(
+		h.getSharedList,
+		middlewareAuth(h.MaxBodySize),
+		middlewareNoCors(),
+		middlewareLogIn()),
+	)
+
+	// PUBLIC LINKS
+
+	router.HandleFunc("/addPublicLink", chainMiddleware(
+		h.addPublicLink,
+		middlewareAuth(h.MaxBodySize),
+		middlewareNoCors(),
+		middlewareLogIn()),
+	)
+
+	router.HandleFunc("/delPublicLink", chainMiddleware(
+		h.delPublicLink,
+		middlewareAuth(h.MaxBodySize),
+		middlewareNoCors(),
+		middlewareLogIn()),
+	)
+
+	router.HandleFunc("/getPublicLinks", chainMiddleware(
+		h.getPublicLinks,
+		middlewareAuth(h.MaxBodySize),
+		middlewareNoCors(),
+		middlewareLogIn()),
+	)
+
+	router.HandleFunc("/public", chainMiddleware(
+		h.getPublic,
+		middlewareNoCors(),
+		middlewareLogIn()),
+	)
+
+	router.HandleFunc("/public/feed", chainMiddleware(
+		h.getPublicFeed,
+		middlewareNoCors(),
+		middlewareLogIn()),
+	)
+
+	// EVENTS
+
+	router.HandleFunc("/events", chainMiddleware(
+		h.events,
+		middlewareAuth(h.MaxBodySize),
+		middlewareQueryToken(),
+		middlewareNoCors(),
+		middlewareLogIn()),
+	)
+
+	// COLLAB
+
+	router.HandleFunc("/ws/note", chainMiddleware(
+		h.collabNote,
+		middlewareAuth(h.MaxBodySize),
+		middlewareQueryToken(),
+		middlewareNoCors(),
+		middlewareLogIn()),
+	)
+
+	// SYNC
+
+	router.HandleFunc("/getChanges", chainMiddleware(
+		h.getChanges,
+		middlewareAuth(h.MaxBodySize),
+		middlewareNoCors(),
+		middlewareLogIn()),
+	)
+
+	router.HandleFunc("/pushChanges", chainMiddleware(
+		h.pushChanges,
+		middlewareAuth(h.MaxBodySize),
+		middlewareNoCors(),
+		middlewareLogIn()),
+	)
+
+	// ATTACHMENTS
+
+	router.HandleFunc("/addAttachment", chainMiddleware(
+		h.addAttachment,
+		middlewareAuth(h.MaxBodySize),
+		middlewareNoCors(),
+		middlewareLogIn()),
+	)
+
+	router.HandleFunc("/delAttachment", chainMiddleware(
+		h.delAttachment,
+		middlewareAuth(h.MaxBodySize),
+		middlewareNoCors(),
+		middlewareLogIn()),
+	)
+
+	router.HandleFunc("/getAttachments", chainMiddleware(
+		h.getAttachments,
+		middlewareAuth(h.MaxBodySize),
+		middlewareNoCors(),
+		middlewareLogIn()),
+	)
+
+	router.HandleFunc("/getAttachment", chainMiddleware(
+		h.getAttachment,
+		middlewareAuth(h.MaxBodySize),
+		middlewareNoCors(),
+		middlewareLogIn()),
+	)
+
+	router.HandleFunc("/getThumbnail", chainMiddleware(
+		h.getThumbnail,
+		middlewareAuth(h.MaxBodySize),
+		middlewareNoCors(),
+		middlewareLogIn()),
+	)
+
+	// EXPORT
+
+	router.HandleFunc("/exportNotes", chainMiddleware(
+		h.exportNotes,
+		middlewareAuth(h.MaxBodySize),
+		middlewareNoCors(),
+		middlewareLogIn()),
+	)
+
+	router.HandleFunc("/publishGroup", chainMiddleware(
+		h.publishGroup,
+		middlewareAuth(h.MaxBodySize),
+		middlewareNoCors(),
+		middlewareLogIn()),
+	)
+
+	// IMPORT
+
+	router.HandleFunc("/importNotes", chainMiddleware(
+		h.importNotes,
+		middlewareAuth(h.MaxBodySize),
+		middlewareNoCors(),
+		middlewareLogIn()),
+	)
+
+	// FAVORITES
+
+	router.HandleFunc("/pinNote", chainMiddleware(
+		h.pinNote,
+		middlewareAuth(h.MaxBodySize),
+		middlewareNoCors(),
+		middlewareLogIn()),
+	)
+
+	router.HandleFunc("/favoriteNote", chainMiddleware(
+		h.favoriteNote,
+		middlewareAuth(h.MaxBodySize),
+		middlewareNoCors(),
+		middlewareLogIn()),
+	)
+
+	router.HandleFunc("/favoriteGroup", chainMiddleware(
+		h.favoriteGroup,
+		middlewareAuth(h.MaxBodySize),
+		middlewareNoCors(),
+		middlewareLogIn()),
+	)
+
+	router.HandleFunc("/getFavorites", chainMiddleware(
+		h.getFavorites,
+		middlewareAuth(h.MaxBodySize),
+		middlewareNoCors(),
+		middlewareLogIn()),
+	)
+
+	// RECENT
+
+	router.HandleFunc("/getRecentNotes", chainMiddleware(
+		h.getRecentNotes,
+		middlewareAuth(h.MaxBodySize),
+		middlewareNoCors(),
+		middlewareLogIn()),
+	)
+
+	// COMMENTS
+
+	router.HandleFunc("/addComment", chainMiddleware(
+		h.addComment,
+		middlewareAuth(h.MaxBodySize),
+		middlewareNoCors(),
+		middlewareLogIn()),
+	)
+
+	router.HandleFunc("/updateComment", chainMiddleware(
+		h.updateComment,
+		middlewareAuth(h.MaxBodySize),
+		middlewareNoCors(),
+		middlewareLogIn()),
+	)
+
+	router.HandleFunc("/delComment", chainMiddleware(
+		h.delComment,
+		middlewareAuth(h.MaxBodySize),
+		middlewareNoCors(),
+		middlewareLogIn()),
+	)
+
+	router.HandleFunc("/resolveComment", chainMiddleware(
+		h.resolveComment,
+		middlewareAuth(h.MaxBodySize),
+		middlewareNoCors(),
+		middlewareLogIn()),
+	)
+
+	router.HandleFunc("/getComments", chainMiddleware(
+		h.getComments,
+		middlewareAuth(h.MaxBodySize),
+		middlewareNoCors(),
+		middlewareLogIn()),
+	)
+
+	// KEYS
+
+	router.HandleFunc("/getKeys", chainMiddleware(
+		h.getKeys,
+		middlewareAuth(h.MaxBodySize),
+		middlewareNoCors(),
+		middlewareLogIn()),
+	)
+
+	router.HandleFunc("/setKeys", chainMiddleware(
+		h.setKeys,
+		middlewareAuth(h.MaxBodySize),
+		middlewareNoCors(),
+		middlewareLogIn()),
+	)
+
+	// TASKS
+
+	router.HandleFunc("/getTasks", chainMiddleware(
+		h.getTasks,
+		middlewareAuth(h.MaxBodySize),
+		middlewareNoCors(),
+		middlewareLogIn()),
+	)
+
+	router.HandleFunc("/toggleTask", chainMiddleware(
+		h.toggleTask,
+		middlewareAuth(h.MaxBodySize),
+		middlewareNoCors(),
+		middlewareLogIn()),
+	)
+
+	// TEMPLATES
+
+	router.HandleFunc("/addTemplate", chainMiddleware(
+		h.addTemplate,
+		middlewareAuth(h.MaxBodySize),
+		middlewareNoCors(),
+		middlewareLogIn()),
+	)
+
+	router.HandleFunc("/updateTemplate", chainMiddleware(
+		h.updateTemplate,
+		middlewareAuth(h.MaxBodySize),
+		middlewareNoCors(),
+		middlewareLogIn()),
+	)
+
+	router.HandleFunc("/delTemplate", chainMiddleware(
+		h.delTemplate,
+		middlewareAuth(h.MaxBodySize),
+		middlewareNoCors(),
+		middlewareLogIn()),
+	)
+
+	router.HandleFunc("/getTemplates", chainMiddleware(
+		h.getTemplates,
+		middlewareAuth(h.MaxBodySize),
+		middlewareNoCors(),
+		middlewareLogIn()),
+	)
+
+	router.HandleFunc("/addNoteFromTemplate", chainMiddleware(
+		h.addNoteFromTemplate,
+		middlewareAuth(h.MaxBodySize),
+		middlewareNoCors(),
+		middlewareLogIn()),
+	)
+
+	// JOURNAL
+
+	router.HandleFunc("/getDailyNote", chainMiddleware(
+		h.getDailyNote,
+		middlewareAuth(h.MaxBodySize),
+		middlewareNoCors(),
+		middlewareLogIn()),
+	)
+
+	router.HandleFunc("/getJournal", chainMiddleware(
+		h.getJournal,
+		middlewareAuth(h.MaxBodySize),
+		middlewareNoCors(),
+		middlewareLogIn()),
+	)
+
+	router.HandleFunc("/updateJournal", chainMiddleware(
+		h.updateJournal,
+		middlewareAuth(h.MaxBodySize),
+		middlewareNoCors(),
+		middlewareLogIn()),
+	)
+
+	// REMINDERS
+
+	router.HandleFunc("/addReminder", chainMiddleware(
+		h.addReminder,
+		middlewareAuth(h.MaxBodySize),
+		middlewareNoCors(),
+		middlewareLogIn()),
+	)
+
+	router.HandleFunc("/delReminder", chainMiddleware(
+		h.delReminder,
+		middlewareAuth(h.MaxBodySize),
+		middlewareNoCors(),
+		middlewareLogIn()),
+	)
+
+	router.HandleFunc("/getReminders", chainMiddleware(
+		h.getReminders,
+		middlewareAuth(h.MaxBodySize),
+		middlewareNoCors(),
+		middlewareLogIn()),
+	)
+
+	router.HandleFunc("/getNotifications", chainMiddleware(
+		h.getNotifications,
+		middlewareAuth(h.MaxBodySize),
+		middlewareNoCors(),
+		middlewareLogIn()),
+	)
+
+	router.HandleFunc("/readNotification", chainMiddleware(
+		h.readNotification,
+		middlewareAuth(h.MaxBodySize),
+		middlewareNoCors(),
+		middlewareLogIn()),
+	)
+
+	// BACKUP
+
+	router.HandleFunc("/backup", chainMiddleware(
+		h.backup,
+		middlewareAuth(h.MaxBodySize),
+		middlewareNoCors(),
+		middlewareLogIn()),
+	)
+
+	router.HandleFunc("/restore", chainMiddleware(
+		h.restore,
+		middlewareAuth(h.MaxBodySize),
 		middlewareNoCors(),
 		middlewareLogIn()),
 	)
@@ -376,13 +968,26 @@ func (h *Handler) addGroup(w http.ResponseWriter, r *http.Request) {
 		pid = pid2
 	}
 
-	err := h.NotesService.AddGroup(email, name, pid)
+	id, err := h.NotesService.AddGroup(email, name, pid)
+	if err == repository.ErrInvalidData {
+		apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+		return
+	}
+	if err == service.ErrAccessDenied {
+		apiError(w, r, http.StatusForbidden, service.ErrAccessDenied)
+		return
+	}
 	if err != nil {
 		apiError(w, r, http.StatusInternalServerError, nil)
 		return
 	}
 
 	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(map[string]int{"id": id}); err != nil {
+		logger.NewLog("api - addGroup()", 2, err, "Filed to encode r.Body", id)
+		return
+	}
+
 	logger.NewLog("api - addGroup()", 5, nil,
 		"OUT - Group added "+time.Now().Format("02.01 15:04:05"), nil)
 }
@@ -421,6 +1026,10 @@ func (h *Handler) delGroup(w http.ResponseWriter, r *http.Request) {
 		apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
 		return
 	}
+	if err == service.ErrAccessDenied {
+		apiError(w, r, http.StatusForbidden, service.ErrAccessDenied)
+		return
+	}
 	if err != nil {
 		apiError(w, r, http.StatusInternalServerError, nil)
 		return
@@ -478,6 +1087,10 @@ func (h *Handler) updateGroup(w http.ResponseWriter, r *http.Request) {
 		apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
 		return
 	}
+	if err == service.ErrAccessDenied {
+		apiError(w, r, http.StatusForbidden, service.ErrAccessDenied)
+		return
+	}
 	if err != nil {
 		apiError(w, r, http.StatusInternalServerError, nil)
 		return
@@ -522,13 +1135,42 @@ func (h *Handler) addNote(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	err = h.NotesService.AddNote(email, title, group_id)
+	// encrypted notes come with their text, both are ciphertext
+	encrypted := false
+	if encrypted_string := data["encrypted"]; encrypted_string != "" {
+		encrypted, err = strconv.ParseBool(encrypted_string)
+		if err != nil {
+			logger.NewLog("api - addNote()", 2, err, "Filed to convert string to bool", "string = "+encrypted_string)
+			apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+			return
+		}
+	}
+
+	var id int
+	if encrypted {
+		id, err = h.NotesService.AddEncryptedNote(email, title, data["text"], group_id)
+	} else {
+		id, err = h.NotesService.AddNote(email, title, group_id)
+	}
+	if err == repository.ErrInvalidData {
+		apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+		return
+	}
+	if err == service.ErrAccessDenied {
+		apiError(w, r, http.StatusForbidden, service.ErrAccessDenied)
+		return
+	}
 	if err != nil {
 		apiError(w, r, http.StatusInternalServerError, nil)
 		return
 	}
 
 	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(map[string]int{"id": id}); err != nil {
+		logger.NewLog("api - addNote()", 2, err, "Filed to encode r.Body", id)
+		return
+	}
+
 	logger.NewLog("api - addNote()", 5, nil,
 		"OUT - Note added "+time.Now().Format("02.01 15:04:05"), nil)
 }
@@ -566,6 +1208,10 @@ func (h *Handler) delNote(w http.ResponseWriter, r *http.Request) {
 		apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
 		return
 	}
+	if err == service.ErrAccessDenied {
+		apiError(w, r, http.StatusForbidden, service.ErrAccessDenied)
+		return
+	}
 	if err != nil {
 		apiError(w, r, http.StatusInternalServerError, nil)
 		return
@@ -626,11 +1272,19 @@ func (h *Handler) updateNote(w http.ResponseWriter, r *http.Request) {
 		apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
 		return
 	}
+	if err == service.ErrAccessDenied {
+		apiError(w, r, http.StatusForbidden, service.ErrAccessDenied)
+		return
+	}
 	if err != nil {
 		apiError(w, r, http.StatusInternalServerError, nil)
 		return
 	}
 
+	if id, err := strconv.Atoi(string_id); err == nil {
+		h.RecentService.NoteEdited(id, email)
+	}
+
 	logger.NewLog("api - updateNote()", 5, nil,
 		"OUT - Note updated "+time.Now().Format("02.01 15:04:05"), nil)
 }
@@ -699,12 +1353,28 @@ func (h *Handler) getNote(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	note, err := h.NotesService.GetNote(id, email)
+	var note model.Note
+	// getNote?id=1&format=html also returns the rendered text
+	if r.URL.Query().Get("format") == "html" {
+		note, err = h.NotesService.GetNoteHTML(id, email)
+	} else {
+		note, err = h.NotesService.GetNote(id, email)
+	}
+	if err == repository.ErrInvalidData {
+		apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+		return
+	}
+	if err == service.ErrAccessDenied {
+		apiError(w, r, http.StatusForbidden, service.ErrAccessDenied)
+		return
+	}
 	if err != nil {
 		apiError(w, r, http.StatusInternalServerError, nil)
 		return
 	}
 
+	h.RecentService.NoteViewed(id, email)
+
 	if err := json.NewEncoder(w).Encode(note); err != nil {
 		logger.NewLog("api - getNote()", 2, err, "Filed to encode r.Body", note)
 		apiError(w, r, http.StatusInternalServerError, nil)
@@ -6,9 +6,12 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"noteapp/internal/model"
+	"noteapp/internal/service"
 	"reflect"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -577,6 +580,738 @@ func TestAddGroup(t *testing.T) {
 	}
 }
 
+// NOTES ACCESS
+func TestUpdateNoteAccess(t *testing.T) {
+
+	type share struct {
+		Owner      string
+		User       string
+		Note_id    int
+		Group_id   int
+		Permission string
+	}
+	type response struct {
+		code     int
+		response map[string]string
+		group_id int
+	}
+
+	// ownerUser: group 1 with note 3, group 2; otherUser: group 4
+	const (
+		owner  = "ownerUser"
+		shared = "sharedUser"
+		other  = "otherUser"
+	)
+
+	testCases := []struct {
+		name    string
+		shares  []share
+		revoke  bool
+		email   string
+		payload map[string]string
+		want    response
+	}{
+		// test 1 owner
+		{
+			name:    "owner edits",
+			email:   owner,
+			payload: map[string]string{"id": "3", "text": "text"},
+			want: response{
+				code:     200,
+				response: map[string]string{},
+				group_id: 1,
+			},
+		},
+		// test 2 edit share
+		{
+			name:    "edit share edits",
+			shares:  []share{{Owner: owner, User: shared, Note_id: 3, Permission: "edit"}},
+			email:   shared,
+			payload: map[string]string{"id": "3", "text": "text"},
+			want: response{
+				code:     200,
+				response: map[string]string{},
+				group_id: 1,
+			},
+		},
+		// test 3 edit share of the parent group
+		{
+			name:    "group edit share edits",
+			shares:  []share{{Owner: owner, User: shared, Group_id: 1, Permission: "edit"}},
+			email:   shared,
+			payload: map[string]string{"id": "3", "text": "text"},
+			want: response{
+				code:     200,
+				response: map[string]string{},
+				group_id: 1,
+			},
+		},
+		// test 4 view share
+		{
+			name:    "view share can not edit",
+			shares:  []share{{Owner: owner, User: shared, Note_id: 3, Permission: "read"}},
+			email:   shared,
+			payload: map[string]string{"id": "3", "text": "text"},
+			want: response{
+				code:     403,
+				response: map[string]string{"error": "access denied"},
+				group_id: 1,
+			},
+		},
+		// test 5 revoked share
+		{
+			name:    "revoked share can not edit",
+			shares:  []share{{Owner: owner, User: shared, Note_id: 3, Permission: "edit"}},
+			revoke:  true,
+			email:   shared,
+			payload: map[string]string{"id": "3", "text": "text"},
+			want: response{
+				code:     403,
+				response: map[string]string{"error": "access denied"},
+				group_id: 1,
+			},
+		},
+		// test 6 not shared
+		{
+			name:    "not shared",
+			email:   other,
+			payload: map[string]string{"id": "3", "text": "text"},
+			want: response{
+				code:     403,
+				response: map[string]string{"error": "access denied"},
+				group_id: 1,
+			},
+		},
+		// test 7 owner moves the note
+		{
+			name:    "owner moves",
+			email:   owner,
+			payload: map[string]string{"id": "3", "group_id": "2"},
+			want: response{
+				code:     200,
+				response: map[string]string{},
+				group_id: 2,
+			},
+		},
+		// test 8 owner moves the note to the root
+		{
+			name:    "owner moves to root",
+			email:   owner,
+			payload: map[string]string{"id": "3", "group_id": "0"},
+			want: response{
+				code:     200,
+				response: map[string]string{},
+				group_id: 0,
+			},
+		},
+		// test 9 edit share moves to a group shared for edit
+		{
+			name: "edit share moves to shared group",
+			shares: []share{
+				{Owner: owner, User: shared, Note_id: 3, Permission: "edit"},
+				{Owner: owner, User: shared, Group_id: 2, Permission: "edit"},
+			},
+			email:   shared,
+			payload: map[string]string{"id": "3", "group_id": "2"},
+			want: response{
+				code:     200,
+				response: map[string]string{},
+				group_id: 2,
+			},
+		},
+		// test 10 edit share moves to a group that is not shared
+		{
+			name:    "edit share moves to not shared group",
+			shares:  []share{{Owner: owner, User: shared, Note_id: 3, Permission: "edit"}},
+			email:   shared,
+			payload: map[string]string{"id": "3", "group_id": "2"},
+			want: response{
+				code:     403,
+				response: map[string]string{"error": "access denied"},
+				group_id: 1,
+			},
+		},
+		// test 11 edit share moves to a group shared for view
+		{
+			name: "edit share moves to view group",
+			shares: []share{
+				{Owner: owner, User: shared, Note_id: 3, Permission: "edit"},
+				{Owner: owner, User: shared, Group_id: 2, Permission: "read"},
+			},
+			email:   shared,
+			payload: map[string]string{"id": "3", "group_id": "2"},
+			want: response{
+				code:     403,
+				response: map[string]string{"error": "access denied"},
+				group_id: 1,
+			},
+		},
+		// test 12 edit share moves to the root
+		{
+			name:    "edit share moves to root",
+			shares:  []share{{Owner: owner, User: shared, Note_id: 3, Permission: "edit"}},
+			email:   shared,
+			payload: map[string]string{"id": "3", "group_id": "0"},
+			want: response{
+				code:     403,
+				response: map[string]string{"error": "access denied"},
+				group_id: 1,
+			},
+		},
+		// test 13 edit share moves to its own group
+		{
+			name:    "edit share moves to own group",
+			shares:  []share{{Owner: owner, User: other, Note_id: 3, Permission: "edit"}},
+			email:   other,
+			payload: map[string]string{"id": "3", "group_id": "4"},
+			want: response{
+				code:     400,
+				response: map[string]string{"error": "incorrect data"},
+				group_id: 1,
+			},
+		},
+		// test 14 owner moves to a group of another user
+		{
+			name:    "owner moves to another user group",
+			shares:  []share{{Owner: other, User: owner, Group_id: 4, Permission: "edit"}},
+			email:   owner,
+			payload: map[string]string{"id": "3", "group_id": "4"},
+			want: response{
+				code:     400,
+				response: map[string]string{"error": "incorrect data"},
+				group_id: 1,
+			},
+		},
+		// test 15 group that does not exist
+		{
+			name:    "move to missing group",
+			email:   owner,
+			payload: map[string]string{"id": "3", "group_id": "100"},
+			want: response{
+				code:     400,
+				response: map[string]string{"error": "incorrect data"},
+				group_id: 1,
+			},
+		},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			handler, repo, _ := NewStubHandler(t)
+			repo.AddGroup(owner, "group", 0)
+			repo.AddGroup(owner, "group2", 0)
+			repo.AddNote(owner, "note", 1)
+			repo.AddGroup(other, "group", 0)
+
+			for _, s := range tcase.shares {
+				payload := map[string]string{"user_email": s.User, "permission": s.Permission}
+				if s.Note_id != 0 {
+					payload["note_id"] = strconv.Itoa(s.Note_id)
+				} else {
+					payload["group_id"] = strconv.Itoa(s.Group_id)
+				}
+
+				rec := HelperStubRequest(t, handler, "POST", "/addShare", s.Owner, payload)
+				if rec.Code != 201 {
+					t.Fatal("Err: share not added, code = " + strconv.Itoa(rec.Code))
+				}
+
+				added := model.Share{}
+				if err := json.NewDecoder(rec.Body).Decode(&added); err != nil {
+					t.Fatal("Decode err: " + err.Error())
+				}
+				if tcase.revoke {
+					rec = HelperStubRequest(t, handler, "DELETE", "/delShare?id="+strconv.Itoa(added.Id), s.Owner, nil)
+					if rec.Code != 200 {
+						t.Fatal("Err: share not deleted, code = " + strconv.Itoa(rec.Code))
+					}
+				}
+			}
+
+			rec := HelperStubRequest(t, handler, "PUT", "/updateNote", tcase.email, tcase.payload)
+
+			note, err := repo.GetNote(3, owner)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tcase.want.group_id, note.Group_id)
+
+			if rec.Body.Len() == 0 && reflect.DeepEqual(tcase.want.response, map[string]string{}) {
+				assert.Equal(t, tcase.want.code, rec.Code)
+				return
+			}
+
+			result := map[string]string{}
+			err = json.NewDecoder(rec.Body).Decode(&result)
+			if err != nil {
+				t.Fatal("Decode err: " + err.Error())
+			}
+
+			assert.Equal(t, tcase.want.code, rec.Code)
+			assert.Equal(t, tcase.want.response, result)
+		})
+	}
+}
+
+// BODY LIMIT
+func TestBodyLimit(t *testing.T) {
+
+	type response struct {
+		code     int
+		response map[string]string
+	}
+
+	testCases := []struct {
+		name string
+		text string
+		want response
+	}{
+		// test 1 under the limit
+		{
+			name: "valid case",
+			text: strings.Repeat("a", 100),
+			want: response{
+				code:     200,
+				response: map[string]string{},
+			},
+		},
+		// test 2 over the limit
+		{
+			name: "body too large",
+			text: strings.Repeat("a", 1000),
+			want: response{
+				code:     413,
+				response: map[string]string{"error": "request body is too large"},
+			},
+		},
+	}
+
+	h, repo, _ := newStubHandler()
+	h.MaxBodySize = 512
+	handler := h.InitHandler()
+	repo.AddNote("existUser", "note", -1)
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			rec := HelperStubRequest(t, handler, "PUT", "/updateNote", "existUser",
+				map[string]string{"id": "1", "text": tcase.text})
+
+			if rec.Body.Len() == 0 && reflect.DeepEqual(tcase.want.response, map[string]string{}) {
+				assert.Equal(t, tcase.want.code, rec.Code)
+				return
+			}
+
+			result := map[string]string{}
+			err := json.NewDecoder(rec.Body).Decode(&result)
+			if err != nil {
+				t.Fatal("Decode err: " + err.Error())
+			}
+
+			assert.Equal(t, tcase.want.code, rec.Code)
+			assert.Equal(t, tcase.want.response, result)
+		})
+	}
+}
+
+func TestQueryToken(t *testing.T) {
+
+	type response struct {
+		code  int
+		email string
+		query string
+	}
+
+	tokens, err := service.MakeRefreshSession("existUser", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the stream routes take the token from the query as /events and
+	// /ws/note do, the others only from the header
+	record := func(w http.ResponseWriter, r *http.Request) {
+		data := r.Context().Value(ctxKey{}).(map[string]string)
+		json.NewEncoder(w).Encode(map[string]string{"email": data["email"], "query": r.URL.RawQuery})
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/stream", chainMiddleware(record, middlewareAuth(512), middlewareQueryToken()))
+	mux.HandleFunc("/other", chainMiddleware(record, middlewareAuth(512)))
+
+	testCases := []struct {
+		name   string
+		url    string
+		header bool
+		want   response
+	}{
+		// test 1
+		{
+			name: "stream with the query token",
+			url:  "/stream?id=1&access_token=" + tokens.AccessToken,
+			want: response{code: 200, email: "existUser", query: "id=1"},
+		},
+		// test 2
+		{
+			name:   "stream with the header",
+			url:    "/stream?id=1",
+			header: true,
+			want:   response{code: 200, email: "existUser", query: "id=1"},
+		},
+		// test 3
+		{
+			name: "stream with a wrong query token",
+			url:  "/stream?access_token=wrong",
+			want: response{code: 500},
+		},
+		// test 4
+		{
+			name: "other route with the query token",
+			url:  "/other?id=1&access_token=" + tokens.AccessToken,
+			want: response{code: 401},
+		},
+		// test 5
+		{
+			name:   "other route with the header",
+			url:    "/other?id=1",
+			header: true,
+			want:   response{code: 200, email: "existUser", query: "id=1"},
+		},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", tcase.url, nil)
+			if tcase.header {
+				req.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+			}
+			mux.ServeHTTP(rec, req)
+
+			assert.Equal(t, tcase.want.code, rec.Code)
+			if tcase.want.code != 200 {
+				return
+			}
+			result := map[string]string{}
+			if err := json.NewDecoder(rec.Body).Decode(&result); err != nil {
+				t.Fatal("Decode err: " + err.Error())
+			}
+			assert.Equal(t, map[string]string{"email": tcase.want.email, "query": tcase.want.query}, result)
+		})
+	}
+
+	// the routes of the api do not take it either
+	handler, _, _ := NewStubHandler(t)
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/getKeys?access_token="+tokens.AccessToken, nil)
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+// KEYS
+func TestKeys(t *testing.T) {
+
+	type response struct {
+		code    int
+		err     string
+		version int
+	}
+
+	const (
+		owner = "ownerUser"
+		other = "otherUser"
+	)
+
+	keys := func(kdf string, salt string, version int) model.UserKeys {
+		return model.UserKeys{
+			Kdf:                  kdf,
+			Kdf_params:           json.RawMessage(`{"m":65536,"t":3,"p":1}`),
+			Salt:                 salt,
+			Wrapped_key:          "d3JhcHBlZA==",
+			Recovery_wrapped_key: "cmVjb3Zlcnk=",
+			Version:              version,
+		}
+	}
+
+	testCases := []struct {
+		name string
+		// version of the stored keys of owner, 0 - none
+		stored  int
+		method  string
+		url     string
+		email   string
+		payload any
+		want    response
+	}{
+		// test 1
+		{
+			name:   "get without keys",
+			method: "GET",
+			url:    "/getKeys",
+			email:  owner,
+			want:   response{code: 404, err: model.ErrNoKeys.Error()},
+		},
+		// test 2
+		{
+			name:   "get keys",
+			stored: 1,
+			method: "GET",
+			url:    "/getKeys",
+			email:  owner,
+			want:   response{code: 200, version: 1},
+		},
+		// test 3 keys of another user
+		{
+			name:   "get keys of another user",
+			stored: 1,
+			method: "GET",
+			url:    "/getKeys",
+			email:  other,
+			want:   response{code: 404, err: model.ErrNoKeys.Error()},
+		},
+		// test 4
+		{
+			name:   "get wrong method",
+			method: "POST",
+			url:    "/getKeys",
+			email:  owner,
+			want:   response{code: 405, err: errMethodNotAllowed.Error()},
+		},
+		// test 5
+		{
+			name:    "set first keys",
+			method:  "PUT",
+			url:     "/setKeys",
+			email:   owner,
+			payload: keys(model.KdfArgon2id, "c2FsdA==", 0),
+			want:    response{code: 200, version: 1},
+		},
+		// test 6
+		{
+			name:    "set new keys",
+			stored:  1,
+			method:  "PUT",
+			url:     "/setKeys",
+			email:   owner,
+			payload: keys(model.KdfPBKDF2, "bmV3IHNhbHQ=", 1),
+			want:    response{code: 200, version: 2},
+		},
+		// test 7 another client has set the first keys
+		{
+			name:    "set first keys twice",
+			stored:  1,
+			method:  "PUT",
+			url:     "/setKeys",
+			email:   owner,
+			payload: keys(model.KdfArgon2id, "c2FsdA==", 0),
+			want:    response{code: 409, err: model.ErrKeysVersion.Error()},
+		},
+		// test 8 another client has changed the keys
+		{
+			name:    "set stale version",
+			stored:  2,
+			method:  "PUT",
+			url:     "/setKeys",
+			email:   owner,
+			payload: keys(model.KdfArgon2id, "c2FsdA==", 1),
+			want:    response{code: 409, err: model.ErrKeysVersion.Error()},
+		},
+		// test 9
+		{
+			name:    "set unknown kdf",
+			method:  "PUT",
+			url:     "/setKeys",
+			email:   owner,
+			payload: keys("scrypt", "c2FsdA==", 0),
+			want:    response{code: 400, err: model.ErrValidationKeys.Error()},
+		},
+		// test 10
+		{
+			name:    "set salt not base64",
+			method:  "PUT",
+			url:     "/setKeys",
+			email:   owner,
+			payload: keys(model.KdfArgon2id, "not base64!", 0),
+			want:    response{code: 400, err: model.ErrValidationKeys.Error()},
+		},
+		// test 11
+		{
+			name:    "set empty salt",
+			method:  "PUT",
+			url:     "/setKeys",
+			email:   owner,
+			payload: keys(model.KdfArgon2id, "", 0),
+			want:    response{code: 400, err: model.ErrValidationKeys.Error()},
+		},
+		// test 12 the salt column is VARCHAR(200)
+		{
+			name:    "set longest salt",
+			method:  "PUT",
+			url:     "/setKeys",
+			email:   owner,
+			payload: keys(model.KdfArgon2id, strings.Repeat("c2Fs", model.MaxSalt/4), 0),
+			want:    response{code: 200, version: 1},
+		},
+		// test 13
+		{
+			name:    "set too long salt",
+			method:  "PUT",
+			url:     "/setKeys",
+			email:   owner,
+			payload: keys(model.KdfArgon2id, strings.Repeat("c2Fs", model.MaxSalt/4+1), 0),
+			want:    response{code: 400, err: model.ErrValidationKeys.Error()},
+		},
+		// test 14
+		{
+			name:    "set invalid body",
+			method:  "PUT",
+			url:     "/setKeys",
+			email:   owner,
+			payload: "keys",
+			want:    response{code: 400, err: "incorrect data"},
+		},
+		// test 15
+		{
+			name:    "set wrong method",
+			method:  "POST",
+			url:     "/setKeys",
+			email:   owner,
+			payload: keys(model.KdfArgon2id, "c2FsdA==", 0),
+			want:    response{code: 405, err: errMethodNotAllowed.Error()},
+		},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			handler, repo, _ := NewStubHandler(t)
+			if tcase.stored != 0 {
+				stored := keys(model.KdfArgon2id, "c2FsdA==", tcase.stored)
+				stored.User_email = owner
+				repo.keys[owner] = stored
+			}
+
+			rec := HelperStubRequest(t, handler, tcase.method, tcase.url, tcase.email, tcase.payload)
+			assert.Equal(t, tcase.want.code, rec.Code)
+
+			if tcase.want.err != "" {
+				result := map[string]string{}
+				if err := json.NewDecoder(rec.Body).Decode(&result); err != nil {
+					t.Fatal("Decode err: " + err.Error())
+				}
+				assert.Equal(t, map[string]string{"error": tcase.want.err}, result)
+				return
+			}
+
+			result := model.UserKeys{}
+			if err := json.NewDecoder(rec.Body).Decode(&result); err != nil {
+				t.Fatal("Decode err: " + err.Error())
+			}
+			assert.Equal(t, tcase.want.version, result.Version)
+			assert.Equal(t, tcase.want.version, repo.keys[owner].Version)
+		})
+	}
+}
+
+// PUBLIC LINKS
+func TestPublicLinkUnlock(t *testing.T) {
+
+	type response struct {
+		code int
+		// the unlock cookie of the link is set
+		cookie bool
+	}
+
+	const (
+		owner = "ownerUser"
+		token = "groupToken"
+	)
+
+	testCases := []struct {
+		name     string
+		password string
+		// unlock cookie sent: "valid", "other link", "forged"
+		cookie string
+		want   response
+	}{
+		// test 1
+		{
+			name:     "password",
+			password: "linkPassword",
+			want:     response{code: 200, cookie: true},
+		},
+		// test 2
+		{
+			name:     "wrong password",
+			password: "wrongPassword",
+			want:     response{code: 401},
+		},
+		// test 3
+		{
+			name: "no password",
+			want: response{code: 401},
+		},
+		// test 4
+		{
+			name:   "unlock cookie",
+			cookie: "valid",
+			want:   response{code: 200},
+		},
+		// test 5
+		{
+			name:   "cookie of another link",
+			cookie: "other link",
+			want:   response{code: 401},
+		},
+		// test 6
+		{
+			name:   "forged cookie",
+			cookie: "forged",
+			want:   response{code: 401},
+		},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			handler, repo, links := NewStubHandler(t)
+			groupID, _ := repo.AddGroup(owner, "group", 0)
+			noteID, _ := repo.AddNote(owner, "note", groupID)
+			link := &model.PublicLink{Id: 1, Token: token, Owner_email: owner, Group_id: groupID, Password: "linkPassword"}
+			if err := link.EncryptPassword(); err != nil {
+				t.Fatal(err)
+			}
+			links.links[token] = link
+
+			url := "/public?token=" + token + "&note_id=" + strconv.Itoa(noteID) + "&format=html"
+			req, _ := http.NewRequest("GET", url, nil)
+			if tcase.password != "" {
+				req.Header.Set("X-Link-Password", tcase.password)
+			}
+			unlock := ""
+			switch tcase.cookie {
+			case "valid":
+				unlock, _ = service.CreateLinkUnlock(token)
+			case "other link":
+				unlock, _ = service.CreateLinkUnlock("otherToken")
+			case "forged":
+				unlock, _ = service.CreateJWTToken(token, time.Now().Add(time.Hour), "forged-key")
+			}
+			if unlock != "" {
+				req.AddCookie(&http.Cookie{Name: "link_" + token, Value: unlock})
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			assert.Equal(t, tcase.want.code, rec.Code)
+
+			cookies := rec.Result().Cookies()
+			assert.Equal(t, tcase.want.cookie, len(cookies) == 1)
+			if tcase.want.cookie {
+				assert.Equal(t, "link_"+token, cookies[0].Name)
+				assert.Equal(t, "/public", cookies[0].Path)
+				assert.True(t, cookies[0].HttpOnly)
+				assert.True(t, service.VerifyLinkUnlock(cookies[0].Value, token))
+			}
+		})
+	}
+}
+
 // 		// test 2 invalid login
 // 		{
 // 			name:   "invalid login",
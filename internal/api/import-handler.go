@@ -0,0 +1,103 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"noteapp/internal/model"
+	"noteapp/internal/repository"
+	"noteapp/internal/service"
+	"noteapp/pkg/logger"
+	"time"
+)
+
+// IMPORT
+
+// importNotes expects multipart/form-data with the "file" field holding
+// a ZIP of Markdown files (an Obsidian vault for example) or, with
+// /importNotes?format=enex, an Evernote export or a ZIP of them and,
+// with /importNotes?format=opml, an OPML outline
+func (h *Handler) importNotes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		apiError(w, r, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+
+	data, ok := r.Context().Value(ctxKey{}).(map[string]string)
+	if !ok {
+		logger.NewLog("api - importNotes()", 2, nil, "Filed to recive contex data", nil)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	email := data["email"]
+	if email == "" {
+		logger.NewLog("api - importNotes()", 2, nil, "Required fields are missing in r.Contex", "email")
+		apiError(w, r, http.StatusBadRequest, errRequiredFieldsMissing)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "markdown" && format != "enex" && format != "opml" {
+		logger.NewLog("api - importNotes()", 2, nil, "Unknown import format", format)
+		apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+		return
+	}
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		logger.NewLog("api - importNotes()", 2, err, "Filed to read multipart body", nil)
+		apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+		return
+	}
+
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			apiError(w, r, http.StatusBadRequest, errFileMissing)
+			return
+		}
+		if err != nil {
+			logger.NewLog("api - importNotes()", 2, err, "Filed to read multipart body", nil)
+			apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+			return
+		}
+		if part.FormName() != "file" {
+			part.Close()
+			continue
+		}
+
+		var report *model.ImportReport
+		switch format {
+		case "enex":
+			report, err = h.ImportService.ImportENEX(r.Context(), email, part.FileName(), part)
+		case "opml":
+			report, err = h.ImportService.ImportOPML(r.Context(), email, part.FileName(), part)
+		default:
+			report, err = h.ImportService.ImportMarkdown(r.Context(), email, part)
+		}
+		part.Close()
+		if err == repository.ErrInvalidData {
+			apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+			return
+		}
+		if err == service.ErrFileTooLarge || err == service.ErrQuotaExceeded {
+			apiError(w, r, http.StatusRequestEntityTooLarge, err)
+			return
+		}
+		if err != nil {
+			apiError(w, r, http.StatusInternalServerError, nil)
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(w).Encode(report); err != nil {
+			logger.NewLog("api - importNotes()", 2, err, "Filed to encode r.Body", report)
+			return
+		}
+
+		logger.NewLog("api - importNotes()", 5, nil,
+			"OUT - Notes imported "+time.Now().Format("02.01 15:04:05"), nil)
+		return
+	}
+}
@@ -0,0 +1,161 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"noteapp/internal/model"
+	"noteapp/internal/repository"
+	"noteapp/internal/service"
+	"noteapp/pkg/logger"
+	"strconv"
+	"time"
+)
+
+// JOURNAL
+
+// getDailyNote returns the note of "date" (today by default), the day and
+// the time of the placeholders are taken in "tz", UTC by default
+func (h *Handler) getDailyNote(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apiError(w, r, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+
+	data, ok := r.Context().Value(ctxKey{}).(map[string]string)
+	if !ok {
+		logger.NewLog("api - getDailyNote()", 2, nil, "Filed to recive contex data", nil)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	email := data["email"]
+	if email == "" {
+		logger.NewLog("api - getDailyNote()", 2, nil, "Required fields are missing in r.Contex", "email")
+		apiError(w, r, http.StatusBadRequest, errRequiredFieldsMissing)
+		return
+	}
+
+	now, err := userTime(r.URL.Query().Get("tz"))
+	if err != nil {
+		logger.NewLog("api - getDailyNote()", 2, err, "Filed to load time zone", r.URL.Query().Get("tz"))
+		apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+		return
+	}
+
+	day := now
+	if date := r.URL.Query().Get("date"); date != "" {
+		if day, err = time.ParseInLocation(model.DateLayout, date, now.Location()); err != nil {
+			logger.NewLog("api - getDailyNote()", 2, err, "Filed to parse date", date)
+			apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+			return
+		}
+	}
+
+	daily, err := h.JournalService.GetDailyNote(email, day, now)
+	if err == repository.ErrInvalidData {
+		apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+		return
+	}
+	if err == service.ErrAccessDenied {
+		apiError(w, r, http.StatusForbidden, service.ErrAccessDenied)
+		return
+	}
+	if err != nil {
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(daily); err != nil {
+		logger.NewLog("api - getDailyNote()", 2, err, "Filed to encode r.Body", daily.Date)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	logger.NewLog("api - getDailyNote()", 5, nil,
+		"OUT - Daily note geted "+time.Now().Format("02.01 15:04:05"), nil)
+}
+
+func (h *Handler) getJournal(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apiError(w, r, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+
+	data, ok := r.Context().Value(ctxKey{}).(map[string]string)
+	if !ok {
+		logger.NewLog("api - getJournal()", 2, nil, "Filed to recive contex data", nil)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	email := data["email"]
+	if email == "" {
+		logger.NewLog("api - getJournal()", 2, nil, "Required fields are missing in r.Contex", "email")
+		apiError(w, r, http.StatusBadRequest, errRequiredFieldsMissing)
+		return
+	}
+
+	j, err := h.JournalService.GetJournal(email)
+	if err != nil {
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(j); err != nil {
+		logger.NewLog("api - getJournal()", 2, err, "Filed to encode r.Body", nil)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	logger.NewLog("api - getJournal()", 5, nil,
+		"OUT - Journal geted "+time.Now().Format("02.01 15:04:05"), nil)
+}
+
+// updateJournal sets "group_id" (0 - the default "Journal" group) and
+// "template_id" or "builtin" of the daily notes
+func (h *Handler) updateJournal(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		apiError(w, r, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+
+	data, ok := r.Context().Value(ctxKey{}).(map[string]string)
+	if !ok {
+		logger.NewLog("api - updateJournal()", 2, nil, "Filed to recive contex data", nil)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	email := data["email"]
+	if email == "" {
+		logger.NewLog("api - updateJournal()", 2, nil, "Required fields are missing in r.Contex", "email")
+		apiError(w, r, http.StatusBadRequest, errRequiredFieldsMissing)
+		return
+	}
+
+	j := model.Journal{Builtin: data["builtin"]}
+	for key, field := range map[string]*int{"group_id": &j.Group_id, "template_id": &j.Template_id} {
+		if data[key] == "" {
+			continue
+		}
+		var err error
+		if *field, err = strconv.Atoi(data[key]); err != nil {
+			logger.NewLog("api - updateJournal()", 2, err, "Filed to convert string to int", "string = "+data[key])
+			apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+			return
+		}
+	}
+
+	err := h.JournalService.UpdateJournal(email, j)
+	if err == repository.ErrInvalidData {
+		apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+		return
+	}
+	if err != nil {
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	logger.NewLog("api - updateJournal()", 5, nil,
+		"OUT - Journal updated "+time.Now().Format("02.01 15:04:05"), nil)
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"noteapp/internal/model"
+	"noteapp/internal/repository"
+	"noteapp/pkg/logger"
+	"time"
+)
+
+// KEYS
+
+// getKeys returns the key material of the encrypted notes, 404 - the
+// user has not set up encryption yet
+func (h *Handler) getKeys(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apiError(w, r, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+
+	data, ok := r.Context().Value(ctxKey{}).(map[string]string)
+	if !ok {
+		logger.NewLog("api - getKeys()", 2, nil, "Filed to recive contex data", nil)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	email := data["email"]
+	if email == "" {
+		logger.NewLog("api - getKeys()", 2, nil, "Required fields are missing in r.Contex", "email")
+		apiError(w, r, http.StatusBadRequest, errRequiredFieldsMissing)
+		return
+	}
+
+	keys, err := h.KeysService.GetKeys(email)
+	if err == model.ErrNoKeys {
+		apiError(w, r, http.StatusNotFound, model.ErrNoKeys)
+		return
+	}
+	if err != nil {
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(keys); err != nil {
+		logger.NewLog("api - getKeys()", 2, err, "Filed to encode r.Body", nil)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	logger.NewLog("api - getKeys()", 5, nil,
+		"OUT - Keys geted "+time.Now().Format("02.01 15:04:05"), nil)
+}
+
+// setKeys stores the key material, the body is model.UserKeys with the
+// version that was read, 0 for the first keys. 409 - another client
+// changed the keys in between.
+func (h *Handler) setKeys(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		apiError(w, r, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+
+	data, ok := r.Context().Value(ctxKey{}).(map[string]string)
+	if !ok {
+		logger.NewLog("api - setKeys()", 2, nil, "Filed to recive contex data", nil)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	email := data["email"]
+	if email == "" {
+		logger.NewLog("api - setKeys()", 2, nil, "Required fields are missing in r.Contex", "email")
+		apiError(w, r, http.StatusBadRequest, errRequiredFieldsMissing)
+		return
+	}
+
+	keys := &model.UserKeys{}
+	if err := json.NewDecoder(r.Body).Decode(keys); err != nil {
+		logger.NewLog("api - setKeys()", 2, err, "Filed to decode r.Body", nil)
+		apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+		return
+	}
+	keys.User_email = email
+
+	err := h.KeysService.SetKeys(keys)
+	if err == model.ErrValidationKeys {
+		apiError(w, r, http.StatusBadRequest, model.ErrValidationKeys)
+		return
+	}
+	if err == model.ErrKeysVersion {
+		apiError(w, r, http.StatusConflict, model.ErrKeysVersion)
+		return
+	}
+	if err != nil {
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(keys); err != nil {
+		logger.NewLog("api - setKeys()", 2, err, "Filed to encode r.Body", nil)
+		return
+	}
+
+	logger.NewLog("api - setKeys()", 5, nil,
+		"OUT - Keys updated "+time.Now().Format("02.01 15:04:05"), nil)
+}
@@ -0,0 +1,117 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"noteapp/internal/repository"
+	"noteapp/internal/service"
+	"noteapp/pkg/logger"
+	"strconv"
+	"time"
+)
+
+// LINKS
+
+func (h *Handler) getLinks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apiError(w, r, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+
+	data, ok := r.Context().Value(ctxKey{}).(map[string]string)
+	if !ok {
+		logger.NewLog("api - getLinks()", 2, nil, "Filed to recive contex data", nil)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	email := data["email"]
+	string_id := r.URL.Query().Get("id")
+	if email == "" || string_id == "" {
+		logger.NewLog("api - getLinks()", 2, nil, "Required fields are missing in r.Contex", "email="+email+" id="+string_id)
+		apiError(w, r, http.StatusBadRequest, errRequiredFieldsMissing)
+		return
+	}
+
+	id, err := strconv.Atoi(string_id)
+	if err != nil {
+		logger.NewLog("api - getLinks()", 2, err, "Filed to convert string to int", "string = "+string_id)
+		apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+		return
+	}
+
+	links, err := h.NotesService.GetLinks(id, email)
+	if err == repository.ErrInvalidData {
+		apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+		return
+	}
+	if err == service.ErrAccessDenied {
+		apiError(w, r, http.StatusForbidden, service.ErrAccessDenied)
+		return
+	}
+	if err != nil {
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(links); err != nil {
+		logger.NewLog("api - getLinks()", 2, err, "Filed to encode r.Body", nil)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	logger.NewLog("api - getLinks()", 5, nil,
+		"OUT - Links geted "+time.Now().Format("02.01 15:04:05"), nil)
+}
+
+func (h *Handler) getBacklinks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apiError(w, r, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+
+	data, ok := r.Context().Value(ctxKey{}).(map[string]string)
+	if !ok {
+		logger.NewLog("api - getBacklinks()", 2, nil, "Filed to recive contex data", nil)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	email := data["email"]
+	string_id := r.URL.Query().Get("id")
+	if email == "" || string_id == "" {
+		logger.NewLog("api - getBacklinks()", 2, nil, "Required fields are missing in r.Contex", "email="+email+" id="+string_id)
+		apiError(w, r, http.StatusBadRequest, errRequiredFieldsMissing)
+		return
+	}
+
+	id, err := strconv.Atoi(string_id)
+	if err != nil {
+		logger.NewLog("api - getBacklinks()", 2, err, "Filed to convert string to int", "string = "+string_id)
+		apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+		return
+	}
+
+	backlinks, err := h.NotesService.GetBacklinks(id, email)
+	if err == repository.ErrInvalidData {
+		apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+		return
+	}
+	if err == service.ErrAccessDenied {
+		apiError(w, r, http.StatusForbidden, service.ErrAccessDenied)
+		return
+	}
+	if err != nil {
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(backlinks); err != nil {
+		logger.NewLog("api - getBacklinks()", 2, err, "Filed to encode r.Body", nil)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	logger.NewLog("api - getBacklinks()", 5, nil,
+		"OUT - Backlinks geted "+time.Now().Format("02.01 15:04:05"), nil)
+}
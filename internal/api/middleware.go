@@ -1,10 +1,13 @@
 package api
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
+	"io"
 	"net/http"
+	"noteapp/internal/repository"
 	"noteapp/internal/service"
 	"noteapp/pkg/logger"
 	"strings"
@@ -14,6 +17,7 @@ import (
 var (
 	errHeaderAuthorizationNotExist = errors.New("expected header authorization")
 	errInvalidHeaderAuthorization  = errors.New("invalid authorization header")
+	errBodyTooLarge                = errors.New("request body is too large")
 )
 
 type Middleware func(http.HandlerFunc) http.HandlerFunc
@@ -41,11 +45,13 @@ func middlewareNoCors() Middleware {
 	}
 }
 
-func middlewareAuth() Middleware {
+// middlewareAuth checks the access token and passes the email and the
+// fields of a JSON body in the context, bodies over maxBody bytes are
+// rejected
+func middlewareAuth(maxBody int64) Middleware {
 	return func(f http.HandlerFunc) http.HandlerFunc {
 		return func(w http.ResponseWriter, r *http.Request) {
 			accessTokenArr, ok := r.Header["Authorization"]
-
 			if !ok {
 				logger.NewLog("api - middlewareAuth()", 2, errHeaderAuthorizationNotExist, "header authorization not exist", nil)
 				apiError(w, r, http.StatusUnauthorized, errHeaderAuthorizationNotExist)
@@ -89,7 +95,24 @@ func middlewareAuth() Middleware {
 
 			m := map[string]string{}
 
-			json.NewDecoder(r.Body).Decode(&m)
+			// body stays readable for handlers with structured requests,
+			// file uploads are streamed by the handler itself
+			if !strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+				body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBody))
+				var maxErr *http.MaxBytesError
+				if errors.As(err, &maxErr) {
+					logger.NewLog("api - middlewareAuth()", 3, err, "Request body is too large", email)
+					apiError(w, r, http.StatusRequestEntityTooLarge, errBodyTooLarge)
+					return
+				}
+				if err != nil {
+					logger.NewLog("api - middlewareAuth()", 3, err, "Filed to read r.Body", email)
+					apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+					return
+				}
+				r.Body = io.NopCloser(bytes.NewReader(body))
+				json.Unmarshal(body, &m)
+			}
 
 			m["email"] = email
 
@@ -100,6 +123,26 @@ func middlewareAuth() Middleware {
 	}
 }
 
+// middlewareQueryToken takes the access token from the access_token query
+// parameter when there is no Authorization header. Browsers can not set
+// headers for EventSource and WebSocket, only their routes use it, so the
+// tokens of the other routes never end up in urls and logs.
+func middlewareQueryToken() Middleware {
+	return func(f http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			query := r.URL.Query()
+			if token := query.Get("access_token"); token != "" {
+				if _, ok := r.Header["Authorization"]; !ok {
+					r.Header.Set("Authorization", "Bearer "+token)
+				}
+				query.Del("access_token")
+				r.URL.RawQuery = query.Encode()
+			}
+			f(w, r)
+		}
+	}
+}
+
 func middlewareLogIn() Middleware {
 	return func(f http.HandlerFunc) http.HandlerFunc {
 		return func(w http.ResponseWriter, r *http.Request) {
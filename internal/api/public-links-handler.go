@@ -0,0 +1,398 @@
+package api
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"net/url"
+	"noteapp/internal/feed"
+	"noteapp/internal/model"
+	"noteapp/internal/repository"
+	"noteapp/internal/service"
+	"noteapp/pkg/logger"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// token func is replaced on every request, see getPublic,
+// rendered is applied only to the HTML already sanitized by the service
+var publicTemplate = template.Must(template.New("public").Funcs(template.FuncMap{
+	"token":    func() string { return "" },
+	"rendered": func(s string) template.HTML { return template.HTML(s) },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>{{if .Note}}{{.Note.Title}}{{else}}{{.Group.Name}}{{end}}</title>
+</head>
+<body>
+{{if .Note}}
+    <h1>{{.Note.Title}}</h1>
+    {{rendered .Note.Html}}
+{{else}}
+    <h1>{{.Group.Name}}</h1>
+    {{template "group" .Group}}
+{{end}}
+</body>
+</html>
+{{define "group"}}
+    <ul>
+    {{range .Notes}}<li><a href="?token={{token}}&note_id={{.Id}}&format=html">{{.Title}}</a></li>{{end}}
+    {{range .Groups}}<li>{{.Name}}{{template "group" .}}</li>{{end}}
+    </ul>
+{{end}}`))
+
+// PUBLIC LINKS
+
+func (h *Handler) addPublicLink(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		apiError(w, r, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+
+	data, ok := r.Context().Value(ctxKey{}).(map[string]string)
+	if !ok {
+		logger.NewLog("api - addPublicLink()", 2, nil, "Filed to recive contex data", nil)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	email := data["email"]
+	noteIdString := data["note_id"]
+	groupIdString := data["group_id"]
+	if email == "" || (noteIdString == "") == (groupIdString == "") {
+		logger.NewLog("api - addPublicLink()", 2, nil, "Required fields are missing in r.Context", data)
+		apiError(w, r, http.StatusBadRequest, errRequiredFieldsMissing)
+		return
+	}
+
+	link := &model.PublicLink{
+		Owner_email: email,
+		Password:    data["password"],
+	}
+
+	var err error
+	// "feed": "true" enables the Atom/RSS feed of a group link
+	if feedString := data["feed"]; feedString != "" {
+		if link.Feed, err = strconv.ParseBool(feedString); err != nil {
+			logger.NewLog("api - addPublicLink()", 2, err, "Filed to parse bool", feedString)
+			apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+			return
+		}
+	}
+
+	if noteIdString != "" {
+		link.Note_id, err = strconv.Atoi(noteIdString)
+	} else {
+		link.Group_id, err = strconv.Atoi(groupIdString)
+	}
+	if err != nil {
+		logger.NewLog("api - addPublicLink()", 2, err, "Filed to convert string to int", data)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	if expires := data["expires_at"]; expires != "" {
+		t, err := time.Parse(time.RFC3339, expires)
+		if err != nil {
+			logger.NewLog("api - addPublicLink()", 2, err, "Filed to parse time", expires)
+			apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+			return
+		}
+		link.Expires_at = &t
+	}
+
+	err = h.PublicLinksService.AddLink(link)
+	if err == repository.ErrInvalidData || err == model.ErrEncryptedNote {
+		apiError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if err == service.ErrAccessDenied {
+		apiError(w, r, http.StatusForbidden, service.ErrAccessDenied)
+		return
+	}
+	if err != nil {
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(link); err != nil {
+		logger.NewLog("api - addPublicLink()", 2, err, "Filed to encode r.Body", link)
+		return
+	}
+
+	logger.NewLog("api - addPublicLink()", 5, nil,
+		"OUT - Public link added "+time.Now().Format("02.01 15:04:05"), nil)
+}
+
+func (h *Handler) delPublicLink(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		apiError(w, r, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+
+	data, ok := r.Context().Value(ctxKey{}).(map[string]string)
+	if !ok {
+		logger.NewLog("api - delPublicLink()", 2, nil, "Filed to recive contex data", nil)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	string_id := r.URL.Query().Get("id")
+	email, ok1 := data["email"]
+	if !(ok1 && string_id != "" && email != "") {
+		logger.NewLog("api - delPublicLink()", 2, nil, "Required fields are missing in r.Contex", nil)
+		apiError(w, r, http.StatusBadRequest, errRequiredFieldsMissing)
+		return
+	}
+
+	id, err := strconv.Atoi(string_id)
+	if err != nil {
+		logger.NewLog("api - delPublicLink()", 2, err, "Filed to convert string to int", "string = "+string_id)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	err = h.PublicLinksService.DelLink(id, email)
+	if err == repository.ErrInvalidData {
+		apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+		return
+	}
+	if err != nil {
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	logger.NewLog("api - delPublicLink()", 5, nil,
+		"OUT - Public link deleted "+time.Now().Format("02.01 15:04:05"), nil)
+}
+
+func (h *Handler) getPublicLinks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apiError(w, r, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+
+	data, ok := r.Context().Value(ctxKey{}).(map[string]string)
+	if !ok {
+		logger.NewLog("api - getPublicLinks()", 2, nil, "Filed to recive contex data", nil)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	email, ok1 := data["email"]
+	if !(ok1 && email != "") {
+		logger.NewLog("api - getPublicLinks()", 2, nil, "Required fields are missing in r.Contex", "email")
+		apiError(w, r, http.StatusBadRequest, errRequiredFieldsMissing)
+		return
+	}
+
+	links, err := h.PublicLinksService.GetLinks(email)
+	if err != nil {
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(links); err != nil {
+		logger.NewLog("api - getPublicLinks()", 2, err, "Filed to encode r.Body", links)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	logger.NewLog("api - getPublicLinks()", 5, nil,
+		"OUT - Public links geted "+time.Now().Format("02.01 15:04:05"), nil)
+}
+
+// getPublic - unauthenticated endpoint, the token itself grants access
+func (h *Handler) getPublic(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apiError(w, r, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	token := query.Get("token")
+	if token == "" {
+		logger.NewLog("api - getPublic()", 2, nil, "Required fields are missing in r.URL", nil)
+		apiError(w, r, http.StatusBadRequest, errRequiredFieldsMissing)
+		return
+	}
+
+	password := r.Header.Get("X-Link-Password")
+	if password == "" {
+		password = query.Get("password")
+	}
+
+	noteID := 0
+	if string_id := query.Get("note_id"); string_id != "" {
+		id, err := strconv.Atoi(string_id)
+		if err != nil {
+			logger.NewLog("api - getPublic()", 2, err, "Filed to convert string to int", "string = "+string_id)
+			apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+			return
+		}
+		noteID = id
+	}
+
+	// the cookie left by a request with the password opens the other notes
+	// of the link, the password is never put in their urls
+	unlock := ""
+	if c, err := r.Cookie(linkUnlockCookie(token)); err == nil {
+		unlock = c.Value
+	}
+
+	content, err := h.PublicLinksService.GetPublicContent(token, password, unlock, noteID)
+	if err == repository.ErrLinkNotFound {
+		apiError(w, r, http.StatusNotFound, repository.ErrLinkNotFound)
+		return
+	}
+	if err == service.ErrLinkExpired {
+		apiError(w, r, http.StatusGone, service.ErrLinkExpired)
+		return
+	}
+	if err == model.ErrLinkPassword {
+		apiError(w, r, http.StatusUnauthorized, model.ErrLinkPassword)
+		return
+	}
+	if err == repository.ErrInvalidData {
+		apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+		return
+	}
+	if err != nil {
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	if password != "" {
+		setLinkUnlock(w, r, token)
+	}
+
+	if query.Get("format") == "html" {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		t := template.Must(publicTemplate.Clone()).Funcs(template.FuncMap{
+			"token": func() string { return token },
+		})
+		if err := t.Execute(w, content); err != nil {
+			logger.NewLog("api - getPublic()", 2, err, "Filed to execute template", nil)
+		}
+	} else if err := json.NewEncoder(w).Encode(content); err != nil {
+		logger.NewLog("api - getPublic()", 2, err, "Filed to encode r.Body", content)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	logger.NewLog("api - getPublic()", 5, nil,
+		"OUT - Public content geted "+time.Now().Format("02.01 15:04:05"), nil)
+}
+
+// getPublicFeed - unauthenticated Atom (default) or RSS feed of a group link
+// with the feed enabled: /public/feed?token=...&format=rss
+func (h *Handler) getPublicFeed(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apiError(w, r, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	token := query.Get("token")
+	if token == "" {
+		logger.NewLog("api - getPublicFeed()", 2, nil, "Required fields are missing in r.URL", nil)
+		apiError(w, r, http.StatusBadRequest, errRequiredFieldsMissing)
+		return
+	}
+
+	format := query.Get("format")
+	if format != "" && format != "atom" && format != "rss" {
+		logger.NewLog("api - getPublicFeed()", 2, nil, "Unknown feed format", format)
+		apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+		return
+	}
+
+	password := r.Header.Get("X-Link-Password")
+	if password == "" {
+		password = query.Get("password")
+	}
+
+	f, err := h.PublicLinksService.GetFeed(token, password, publicURL(r))
+	if err == repository.ErrLinkNotFound {
+		apiError(w, r, http.StatusNotFound, repository.ErrLinkNotFound)
+		return
+	}
+	if err == service.ErrLinkExpired {
+		apiError(w, r, http.StatusGone, service.ErrLinkExpired)
+		return
+	}
+	if err == model.ErrLinkPassword {
+		apiError(w, r, http.StatusUnauthorized, model.ErrLinkPassword)
+		return
+	}
+	if err == repository.ErrInvalidData {
+		apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+		return
+	}
+	if err != nil {
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	// the password is not repeated in the feed
+	f.Self = publicURL(r) + "/feed?token=" + url.QueryEscape(token)
+	if format != "" {
+		f.Self += "&format=" + format
+	}
+	w.Header().Set("Last-Modified", f.Updated.UTC().Format(http.TimeFormat))
+	if format == "rss" {
+		w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+		err = feed.WriteRSS(w, f)
+	} else {
+		w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+		err = feed.WriteAtom(w, f)
+	}
+	if err != nil {
+		logger.NewLog("api - getPublicFeed()", 2, err, "Filed to write feed", nil)
+		return
+	}
+
+	logger.NewLog("api - getPublicFeed()", 5, nil,
+		"OUT - Public feed geted "+time.Now().Format("02.01 15:04:05"), nil)
+}
+
+// linkUnlockCookie - every link has its own cookie, tokens are url-safe
+func linkUnlockCookie(token string) string {
+	return "link_" + token
+}
+
+// setLinkUnlock leaves the signed proof that the visitor gave the password
+// of the link, it is sent only back to /public
+func setLinkUnlock(w http.ResponseWriter, r *http.Request, token string) {
+	unlock, err := service.CreateLinkUnlock(token)
+	if err != nil {
+		logger.NewLog("api - setLinkUnlock()", 2, err, "Filed to create link unlock", nil)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     linkUnlockCookie(token),
+		Value:    unlock,
+		Path:     "/public",
+		MaxAge:   int(service.LinkUnlockTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(publicURL(r), "https"),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// publicURL returns the absolute url of /public as the client sees it,
+// a proxy in front of the server sets X-Forwarded-Proto
+func publicURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto == "http" || proto == "https" {
+		scheme = proto
+	}
+	return scheme + "://" + r.Host + "/public"
+}
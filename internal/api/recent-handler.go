@@ -0,0 +1,71 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"noteapp/internal/repository"
+	"noteapp/pkg/logger"
+	"strconv"
+	"time"
+)
+
+// RECENT
+
+// getRecentNotes returns the notes the user viewed ("kind=viewed", the
+// default) or edited ("kind=edited") last, "limit" - up to 50
+func (h *Handler) getRecentNotes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apiError(w, r, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+
+	data, ok := r.Context().Value(ctxKey{}).(map[string]string)
+	if !ok {
+		logger.NewLog("api - getRecentNotes()", 2, nil, "Filed to recive contex data", nil)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	email := data["email"]
+	if email == "" {
+		logger.NewLog("api - getRecentNotes()", 2, nil, "Required fields are missing in r.Contex", "email")
+		apiError(w, r, http.StatusBadRequest, errRequiredFieldsMissing)
+		return
+	}
+
+	query := r.URL.Query()
+	edited := false
+	switch query.Get("kind") {
+	case "", "viewed":
+	case "edited":
+		edited = true
+	default:
+		apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+		return
+	}
+
+	limit := 10
+	if s := query.Get("limit"); s != "" {
+		var err error
+		if limit, err = strconv.Atoi(s); err != nil || limit <= 0 {
+			logger.NewLog("api - getRecentNotes()", 2, err, "Filed to convert string to int", "string = "+s)
+			apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+			return
+		}
+	}
+
+	list, err := h.RecentService.GetRecent(email, edited, limit)
+	if err != nil {
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(list); err != nil {
+		logger.NewLog("api - getRecentNotes()", 2, err, "Filed to encode r.Body", nil)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	logger.NewLog("api - getRecentNotes()", 5, nil,
+		"OUT - Recent notes geted "+time.Now().Format("02.01 15:04:05"), nil)
+}
@@ -0,0 +1,264 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"noteapp/internal/model"
+	"noteapp/internal/repository"
+	"noteapp/internal/service"
+	"noteapp/pkg/logger"
+	"strconv"
+	"time"
+)
+
+// REMINDERS
+
+// addReminder sets a reminder on "note_id" at "due_at", "remind_at" is
+// the time of the notification, due_at by default. Times are RFC 3339.
+func (h *Handler) addReminder(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		apiError(w, r, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+
+	data, ok := r.Context().Value(ctxKey{}).(map[string]string)
+	if !ok {
+		logger.NewLog("api - addReminder()", 2, nil, "Filed to recive contex data", nil)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	email := data["email"]
+	noteIdString := data["note_id"]
+	if email == "" || noteIdString == "" || data["due_at"] == "" {
+		logger.NewLog("api - addReminder()", 2, nil, "Required fields are missing in r.Context", data)
+		apiError(w, r, http.StatusBadRequest, errRequiredFieldsMissing)
+		return
+	}
+
+	rem := &model.Reminder{User_email: email, Message: data["message"]}
+
+	var err error
+	if rem.Note_id, err = strconv.Atoi(noteIdString); err != nil {
+		logger.NewLog("api - addReminder()", 2, err, "Filed to convert string to int", "string = "+noteIdString)
+		apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+		return
+	}
+	for key, field := range map[string]*time.Time{"due_at": &rem.Due_at, "remind_at": &rem.Remind_at} {
+		if data[key] == "" {
+			continue
+		}
+		if *field, err = time.Parse(time.RFC3339, data[key]); err != nil {
+			logger.NewLog("api - addReminder()", 2, err, "Filed to parse time", data[key])
+			apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+			return
+		}
+	}
+
+	err = h.RemindersService.AddReminder(rem)
+	if err == repository.ErrInvalidData || err == model.ErrValidationReminder {
+		apiError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if err == service.ErrAccessDenied {
+		apiError(w, r, http.StatusForbidden, service.ErrAccessDenied)
+		return
+	}
+	if err != nil {
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(rem); err != nil {
+		logger.NewLog("api - addReminder()", 2, err, "Filed to encode r.Body", rem.Id)
+		return
+	}
+
+	logger.NewLog("api - addReminder()", 5, nil,
+		"OUT - Reminder added "+time.Now().Format("02.01 15:04:05"), nil)
+}
+
+func (h *Handler) delReminder(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		apiError(w, r, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+
+	data, ok := r.Context().Value(ctxKey{}).(map[string]string)
+	if !ok {
+		logger.NewLog("api - delReminder()", 2, nil, "Filed to recive contex data", nil)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	string_id := r.URL.Query().Get("id")
+	email := data["email"]
+	if string_id == "" || email == "" {
+		logger.NewLog("api - delReminder()", 2, nil, "Required fields are missing in r.Contex", nil)
+		apiError(w, r, http.StatusBadRequest, errRequiredFieldsMissing)
+		return
+	}
+
+	id, err := strconv.Atoi(string_id)
+	if err != nil {
+		logger.NewLog("api - delReminder()", 2, err, "Filed to convert string to int", "string = "+string_id)
+		apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+		return
+	}
+
+	err = h.RemindersService.DelReminder(id, email)
+	if err == repository.ErrInvalidData {
+		apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+		return
+	}
+	if err != nil {
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	logger.NewLog("api - delReminder()", 5, nil,
+		"OUT - Reminder deleted "+time.Now().Format("02.01 15:04:05"), nil)
+}
+
+// getReminders returns the reminders on "note_id" or, without it,
+// all reminders of the user which have not fired yet
+func (h *Handler) getReminders(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apiError(w, r, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+
+	data, ok := r.Context().Value(ctxKey{}).(map[string]string)
+	if !ok {
+		logger.NewLog("api - getReminders()", 2, nil, "Filed to recive contex data", nil)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	email := data["email"]
+	if email == "" {
+		logger.NewLog("api - getReminders()", 2, nil, "Required fields are missing in r.Contex", "email")
+		apiError(w, r, http.StatusBadRequest, errRequiredFieldsMissing)
+		return
+	}
+
+	noteID := 0
+	if string_id := r.URL.Query().Get("note_id"); string_id != "" {
+		var err error
+		if noteID, err = strconv.Atoi(string_id); err != nil {
+			logger.NewLog("api - getReminders()", 2, err, "Filed to convert string to int", "string = "+string_id)
+			apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+			return
+		}
+	}
+
+	list, err := h.RemindersService.GetReminders(email, noteID)
+	if err != nil {
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(list); err != nil {
+		logger.NewLog("api - getReminders()", 2, err, "Filed to encode r.Body", nil)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	logger.NewLog("api - getReminders()", 5, nil,
+		"OUT - Reminders geted "+time.Now().Format("02.01 15:04:05"), nil)
+}
+
+// getNotifications returns the in-app notifications, "unread=true" -
+// only the unread ones
+func (h *Handler) getNotifications(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apiError(w, r, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+
+	data, ok := r.Context().Value(ctxKey{}).(map[string]string)
+	if !ok {
+		logger.NewLog("api - getNotifications()", 2, nil, "Filed to recive contex data", nil)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	email := data["email"]
+	if email == "" {
+		logger.NewLog("api - getNotifications()", 2, nil, "Required fields are missing in r.Contex", "email")
+		apiError(w, r, http.StatusBadRequest, errRequiredFieldsMissing)
+		return
+	}
+
+	unread := false
+	if s := r.URL.Query().Get("unread"); s != "" {
+		var err error
+		if unread, err = strconv.ParseBool(s); err != nil {
+			logger.NewLog("api - getNotifications()", 2, err, "Filed to parse bool", s)
+			apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+			return
+		}
+	}
+
+	list, err := h.RemindersService.GetNotifications(email, unread)
+	if err != nil {
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(list); err != nil {
+		logger.NewLog("api - getNotifications()", 2, err, "Filed to encode r.Body", nil)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	logger.NewLog("api - getNotifications()", 5, nil,
+		"OUT - Notifications geted "+time.Now().Format("02.01 15:04:05"), nil)
+}
+
+// readNotification marks the notification "id" as read, without it -
+// all notifications of the user
+func (h *Handler) readNotification(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		apiError(w, r, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+
+	data, ok := r.Context().Value(ctxKey{}).(map[string]string)
+	if !ok {
+		logger.NewLog("api - readNotification()", 2, nil, "Filed to recive contex data", nil)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	email := data["email"]
+	if email == "" {
+		logger.NewLog("api - readNotification()", 2, nil, "Required fields are missing in r.Contex", "email")
+		apiError(w, r, http.StatusBadRequest, errRequiredFieldsMissing)
+		return
+	}
+
+	id := 0
+	if string_id := data["id"]; string_id != "" {
+		var err error
+		if id, err = strconv.Atoi(string_id); err != nil || id <= 0 {
+			logger.NewLog("api - readNotification()", 2, err, "Filed to convert string to int", "string = "+string_id)
+			apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+			return
+		}
+	}
+
+	err := h.RemindersService.ReadNotification(id, email)
+	if err == repository.ErrInvalidData {
+		apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+		return
+	}
+	if err != nil {
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	logger.NewLog("api - readNotification()", 5, nil,
+		"OUT - Notification read "+time.Now().Format("02.01 15:04:05"), nil)
+}
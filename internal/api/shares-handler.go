@@ -0,0 +1,199 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"noteapp/internal/model"
+	"noteapp/internal/repository"
+	"noteapp/internal/service"
+	"noteapp/pkg/logger"
+	"strconv"
+	"time"
+)
+
+// SHARES
+
+func (h *Handler) addShare(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		apiError(w, r, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+
+	data, ok := r.Context().Value(ctxKey{}).(map[string]string)
+	if !ok {
+		logger.NewLog("api - addShare()", 2, nil, "Filed to recive contex data", nil)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	email := data["email"]
+	userEmail := data["user_email"]
+	permission := data["permission"]
+	noteIdString := data["note_id"]
+	groupIdString := data["group_id"]
+	if email == "" || userEmail == "" || permission == "" ||
+		(noteIdString == "") == (groupIdString == "") {
+		logger.NewLog("api - addShare()", 2, nil, "Required fields are missing in r.Context", data)
+		apiError(w, r, http.StatusBadRequest, errRequiredFieldsMissing)
+		return
+	}
+
+	share := &model.Share{
+		Owner_email: email,
+		User_email:  userEmail,
+		Permission:  permission,
+	}
+
+	var err error
+	if noteIdString != "" {
+		share.Note_id, err = strconv.Atoi(noteIdString)
+	} else {
+		share.Group_id, err = strconv.Atoi(groupIdString)
+	}
+	if err != nil {
+		logger.NewLog("api - addShare()", 2, err, "Filed to convert string to int", data)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	err = h.SharesService.AddShare(share)
+	if err == repository.ErrInvalidData ||
+		err == repository.ErrUserNotFound ||
+		err == model.ErrValidationPermission ||
+		err == service.ErrShareYourself {
+
+		apiError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if err == service.ErrAccessDenied {
+		apiError(w, r, http.StatusForbidden, service.ErrAccessDenied)
+		return
+	}
+	if err != nil {
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(share); err != nil {
+		logger.NewLog("api - addShare()", 2, err, "Filed to encode r.Body", share)
+		return
+	}
+
+	logger.NewLog("api - addShare()", 5, nil,
+		"OUT - Share added "+time.Now().Format("02.01 15:04:05"), nil)
+}
+
+func (h *Handler) delShare(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		apiError(w, r, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+
+	data, ok := r.Context().Value(ctxKey{}).(map[string]string)
+	if !ok {
+		logger.NewLog("api - delShare()", 2, nil, "Filed to recive contex data", nil)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	string_id := r.URL.Query().Get("id")
+	email, ok1 := data["email"]
+	if !(ok1 && string_id != "" && email != "") {
+		logger.NewLog("api - delShare()", 2, nil, "Required fields are missing in r.Contex", nil)
+		apiError(w, r, http.StatusBadRequest, errRequiredFieldsMissing)
+		return
+	}
+
+	id, err := strconv.Atoi(string_id)
+	if err != nil {
+		logger.NewLog("api - delShare()", 2, err, "Filed to convert string to int", "string = "+string_id)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	err = h.SharesService.DelShare(id, email)
+	if err == repository.ErrInvalidData {
+		apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+		return
+	}
+	if err != nil {
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	logger.NewLog("api - delShare()", 5, nil,
+		"OUT - Share deleted "+time.Now().Format("02.01 15:04:05"), nil)
+}
+
+func (h *Handler) getShares(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apiError(w, r, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+
+	data, ok := r.Context().Value(ctxKey{}).(map[string]string)
+	if !ok {
+		logger.NewLog("api - getShares()", 2, nil, "Filed to recive contex data", nil)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	email, ok1 := data["email"]
+	if !(ok1 && email != "") {
+		logger.NewLog("api - getShares()", 2, nil, "Required fields are missing in r.Contex", "email")
+		apiError(w, r, http.StatusBadRequest, errRequiredFieldsMissing)
+		return
+	}
+
+	shares, err := h.SharesService.GetShares(email)
+	if err != nil {
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(shares); err != nil {
+		logger.NewLog("api - getShares()", 2, err, "Filed to encode r.Body", shares)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	logger.NewLog("api - getShares()", 5, nil,
+		"OUT - Shares geted "+time.Now().Format("02.01 15:04:05"), nil)
+}
+
+func (h *Handler) getSharedList(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apiError(w, r, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+
+	data, ok := r.Context().Value(ctxKey{}).(map[string]string)
+	if !ok {
+		logger.NewLog("api - getSharedList()", 2, nil, "Filed to recive contex data", nil)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	email, ok1 := data["email"]
+	if !(ok1 && email != "") {
+		logger.NewLog("api - getSharedList()", 2, nil, "Required fields are missing in r.Contex", "email")
+		apiError(w, r, http.StatusBadRequest, errRequiredFieldsMissing)
+		return
+	}
+
+	list, err := h.SharesService.GetSharedList(email)
+	if err != nil {
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(list); err != nil {
+		logger.NewLog("api - getSharedList()", 2, err, "Filed to encode r.Body", list)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	logger.NewLog("api - getSharedList()", 5, nil,
+		"OUT - Shared list geted "+time.Now().Format("02.01 15:04:05"), nil)
+}
@@ -0,0 +1,402 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"noteapp/internal/events"
+	"noteapp/internal/model"
+	"noteapp/internal/repository"
+	"noteapp/internal/service"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// handler on in-memory repositories for the cases the test tables of
+// Postgres do not cover: shares and the access checks of the services
+
+type stubGroup struct {
+	owner string
+	pid   int
+}
+
+type stubRepo struct {
+	mu     sync.Mutex
+	lastID int
+	groups map[int]stubGroup
+	notes  map[int]*model.Note
+	shares map[int]model.Share
+	keys   map[string]model.UserKeys
+}
+
+func newStubRepo() *stubRepo {
+	return &stubRepo{
+		groups: map[int]stubGroup{},
+		notes:  map[int]*model.Note{},
+		shares: map[int]model.Share{},
+		keys:   map[string]model.UserKeys{},
+	}
+}
+
+func (r *stubRepo) nextID() int {
+	r.lastID++
+	return r.lastID
+}
+
+// NOTES
+
+func (r *stubRepo) AddGroup(email string, nameGroup string, pid int) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	id := r.nextID()
+	r.groups[id] = stubGroup{owner: email, pid: pid}
+	return id, nil
+}
+
+func (r *stubRepo) DelGroup(id int, email string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.groups, id)
+	return nil
+}
+
+func (r *stubRepo) UpdateGroup(id int, email string, newNameGroup string, pid int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	g, ok := r.groups[id]
+	if !ok || g.owner != email {
+		return repository.ErrInvalidData
+	}
+	if pid != -1 {
+		g.pid = pid
+	}
+	r.groups[id] = g
+	return nil
+}
+
+func (r *stubRepo) AddNote(email string, title string, group_id int) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	id := r.nextID()
+	r.notes[id] = &model.Note{Id: id, User_email: email, Title: title, Group_id: max(group_id, 0), Version: 1}
+	return id, nil
+}
+
+func (r *stubRepo) DelNote(id int, email string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.notes, id)
+	return nil
+}
+
+// UpdateNote works as the repository does: a group of another user
+// moves the note to the root, a stale version changes nothing
+func (r *stubRepo) UpdateNote(data map[string]string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	id, _ := strconv.Atoi(data["id"])
+	n, ok := r.notes[id]
+	if !ok || n.User_email != data["email"] {
+		return repository.ErrInvalidData
+	}
+	if v, ok := data["version"]; ok && v != strconv.Itoa(n.Version) {
+		return repository.ErrInvalidData
+	}
+	if title, ok := data["title"]; ok {
+		n.Title = title
+	}
+	if text, ok := data["text"]; ok {
+		n.Text = text
+	}
+	if encrypted, ok := data["encrypted"]; ok {
+		n.Encrypted, _ = strconv.ParseBool(encrypted)
+	}
+	if group_id_string, ok := data["group_id"]; ok {
+		group_id, _ := strconv.Atoi(group_id_string)
+		n.Group_id = 0
+		if g, ok := r.groups[group_id]; ok && g.owner == n.User_email {
+			n.Group_id = group_id
+		}
+	}
+	n.Version++
+	return nil
+}
+
+func (r *stubRepo) GetNotesList(email string) (model.NoteList, error) {
+	return model.NoteList{}, nil
+}
+
+func (r *stubRepo) GetNote(id int, email string) (model.Note, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n, ok := r.notes[id]
+	if !ok || n.User_email != email {
+		return model.Note{}, repository.ErrInvalidData
+	}
+	return *n, nil
+}
+
+// ACCESS
+
+func (r *stubRepo) NoteOwner(noteID int) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n, ok := r.notes[noteID]
+	if !ok {
+		return "", repository.ErrInvalidData
+	}
+	return n.User_email, nil
+}
+
+func (r *stubRepo) GroupOwner(groupID int) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	g, ok := r.groups[groupID]
+	if !ok {
+		return "", repository.ErrInvalidData
+	}
+	return g.owner, nil
+}
+
+func (r *stubRepo) NotePermission(noteID int, email string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p := r.permission(email, func(s model.Share) bool { return s.Note_id == noteID })
+	if n, ok := r.notes[noteID]; ok && n.Group_id != 0 && p != model.PermissionEdit {
+		if gp := r.groupPermission(n.Group_id, email); gp != "" {
+			p = gp
+		}
+	}
+	return p, nil
+}
+
+func (r *stubRepo) GroupPermission(groupID int, email string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.groupPermission(groupID, email), nil
+}
+
+// groupPermission returns the strongest share of the group or its parents
+func (r *stubRepo) groupPermission(groupID int, email string) string {
+	p := ""
+	for id := groupID; id != 0; id = r.groups[id].pid {
+		switch r.permission(email, func(s model.Share) bool { return s.Group_id == id }) {
+		case model.PermissionEdit:
+			return model.PermissionEdit
+		case model.PermissionRead:
+			p = model.PermissionRead
+		}
+	}
+	return p
+}
+
+func (r *stubRepo) permission(email string, match func(s model.Share) bool) string {
+	p := ""
+	for _, s := range r.shares {
+		if s.User_email == email && match(s) && p != model.PermissionEdit {
+			p = s.Permission
+		}
+	}
+	return p
+}
+
+// SHARES
+
+func (r *stubRepo) AddShare(s *model.Share) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s.Id = r.nextID()
+	r.shares[s.Id] = *s
+	return nil
+}
+
+func (r *stubRepo) DelShare(id int, ownerEmail string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if s, ok := r.shares[id]; !ok || s.Owner_email != ownerEmail {
+		return repository.ErrInvalidData
+	}
+	delete(r.shares, id)
+	return nil
+}
+
+func (r *stubRepo) GetSharesByOwner(ownerEmail string) ([]model.Share, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	list := []model.Share{}
+	for _, s := range r.shares {
+		if s.Owner_email == ownerEmail {
+			list = append(list, s)
+		}
+	}
+	return list, nil
+}
+
+func (r *stubRepo) GetSharesForUser(email string) ([]model.Share, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	list := []model.Share{}
+	for _, s := range r.shares {
+		if s.User_email == email {
+			list = append(list, s)
+		}
+	}
+	return list, nil
+}
+
+// USERS
+
+func (r *stubRepo) CreateUser(u *model.User) error {
+	return nil
+}
+
+func (r *stubRepo) FindByLogin(email string) (*model.User, error) {
+	return &model.User{Email: email}, nil
+}
+
+// LINKS and TASKS are not stored
+
+func (r *stubRepo) SetLinks(sourceID int, owner string, links []model.NoteLink) error {
+	return nil
+}
+
+func (r *stubRepo) ResolveLinks(owner string, noteID int, title string) error {
+	return nil
+}
+
+func (r *stubRepo) GetLinks(sourceID int) ([]model.NoteLink, error) {
+	return nil, nil
+}
+
+func (r *stubRepo) GetBacklinks(targetID int) ([]model.Backlink, error) {
+	return nil, nil
+}
+
+func (r *stubRepo) GetTitleLinkSources(targetID int) ([]int, error) {
+	return nil, nil
+}
+
+func (r *stubRepo) SetTasks(noteID int, owner string, list []model.Task) error {
+	return nil
+}
+
+func (r *stubRepo) GetTasks(email string, f model.TaskFilter) ([]model.Task, error) {
+	return nil, nil
+}
+
+func (r *stubRepo) GetTask(id int, email string) (model.Task, error) {
+	return model.Task{}, repository.ErrInvalidData
+}
+
+// KEYS work as the repository does: a stale version changes nothing
+
+func (r *stubRepo) GetKeys(email string) (model.UserKeys, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	k, ok := r.keys[email]
+	if !ok {
+		return model.UserKeys{}, sql.ErrNoRows
+	}
+	return k, nil
+}
+
+func (r *stubRepo) SetKeys(k *model.UserKeys) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.keys[k.User_email].Version != k.Version {
+		return model.ErrKeysVersion
+	}
+	k.Version++
+	r.keys[k.User_email] = *k
+	return nil
+}
+
+type stubRecent struct{}
+
+func (stubRecent) NoteViewed(id int, email string) {}
+
+func (stubRecent) NoteEdited(id int, email string) {}
+
+func (stubRecent) GetRecent(email string, edited bool, limit int) ([]model.RecentNote, error) {
+	return nil, nil
+}
+
+// stubLinks keeps the public links by token, the notes are in stubRepo
+type stubLinks struct {
+	service.PublicLinksRepository
+	repo  *stubRepo
+	links map[string]*model.PublicLink
+}
+
+func (r *stubLinks) GetLinkByToken(token string) (*model.PublicLink, error) {
+	l, ok := r.links[token]
+	if !ok {
+		return nil, repository.ErrLinkNotFound
+	}
+	return l, nil
+}
+
+func (r *stubLinks) NoteInGroup(noteID int, groupID int) (bool, error) {
+	r.repo.mu.Lock()
+	defer r.repo.mu.Unlock()
+	n, ok := r.repo.notes[noteID]
+	return ok && n.Group_id == groupID, nil
+}
+
+func (r *stubLinks) IncViews(id int) error {
+	return nil
+}
+
+// NewStubHandler returns the handler with the notes, shares, public links
+// and keys services on in-memory repositories, the other services are not set
+func NewStubHandler(t *testing.T) (*http.ServeMux, *stubRepo, *stubLinks) {
+	t.Helper()
+
+	h, repo, links := newStubHandler()
+	return h.InitHandler(), repo, links
+}
+
+func newStubHandler() (*Handler, *stubRepo, *stubLinks) {
+	repo := newStubRepo()
+	links := &stubLinks{repo: repo, links: map[string]*model.PublicLink{}}
+	noteService := service.NewNotesService(repo, repo, events.NewBus(), repo, repo)
+	sharesService := service.NewSharesService(repo, repo, repo)
+	publicLinksService := service.NewPublicLinksService(links, repo, repo)
+	keysService := service.NewKeysService(repo)
+
+	h := NewHandler(nil, noteService, sharesService, publicLinksService, nil, nil, nil,
+		nil, nil, nil, nil, nil,
+		nil, nil, nil, nil,
+		stubRecent{}, nil, keysService)
+	return h, repo, links
+}
+
+// HelperStubRequest sends the request of the user with payload as the
+// JSON body, nil sends none
+func HelperStubRequest(t *testing.T, handler *http.ServeMux, method string, url string, email string, payload any) *httptest.ResponseRecorder {
+	t.Helper()
+
+	tokens, err := service.MakeRefreshSession(email, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := new(bytes.Buffer)
+	if payload != nil {
+		if err := json.NewEncoder(data).Encode(payload); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, url, data)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+
+	handler.ServeHTTP(rec, req)
+	return rec
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"noteapp/internal/model"
+	"noteapp/internal/repository"
+	"noteapp/internal/service"
+	"noteapp/pkg/logger"
+	"strconv"
+	"time"
+)
+
+// SYNC
+
+func (h *Handler) getChanges(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apiError(w, r, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+
+	data, ok := r.Context().Value(ctxKey{}).(map[string]string)
+	if !ok {
+		logger.NewLog("api - getChanges()", 2, nil, "Filed to recive contex data", nil)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	email, ok1 := data["email"]
+	if !(ok1 && email != "") {
+		logger.NewLog("api - getChanges()", 2, nil, "Required fields are missing in r.Contex", "email")
+		apiError(w, r, http.StatusBadRequest, errRequiredFieldsMissing)
+		return
+	}
+
+	var cursor int64
+	if cursor_string := r.URL.Query().Get("cursor"); cursor_string != "" {
+		c, err := strconv.ParseInt(cursor_string, 10, 64)
+		if err != nil {
+			logger.NewLog("api - getChanges()", 2, err, "Filed to convert string to int", "string = "+cursor_string)
+			apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+			return
+		}
+		cursor = c
+	}
+
+	pull, err := h.SyncService.Pull(email, cursor)
+	if err != nil {
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(pull); err != nil {
+		logger.NewLog("api - getChanges()", 2, err, "Filed to encode r.Body", nil)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	logger.NewLog("api - getChanges()", 5, nil,
+		"OUT - Changes geted "+time.Now().Format("02.01 15:04:05"), nil)
+}
+
+func (h *Handler) pushChanges(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		apiError(w, r, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+
+	data, ok := r.Context().Value(ctxKey{}).(map[string]string)
+	if !ok {
+		logger.NewLog("api - pushChanges()", 2, nil, "Filed to recive contex data", nil)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	email, ok1 := data["email"]
+	if !(ok1 && email != "") {
+		logger.NewLog("api - pushChanges()", 2, nil, "Required fields are missing in r.Contex", "email")
+		apiError(w, r, http.StatusBadRequest, errRequiredFieldsMissing)
+		return
+	}
+
+	reqData := struct {
+		Changes []model.SyncChange `json:"changes"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&reqData); err != nil {
+		logger.NewLog("api - pushChanges()", 2, err, "Filed to decode r.Body", nil)
+		apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+		return
+	}
+
+	results, err := h.SyncService.Push(email, reqData.Changes)
+	if err == service.ErrTooManyItems {
+		apiError(w, r, http.StatusBadRequest, service.ErrTooManyItems)
+		return
+	}
+	if err != nil {
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(map[string][]model.SyncResult{"results": results}); err != nil {
+		logger.NewLog("api - pushChanges()", 2, err, "Filed to encode r.Body", nil)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	logger.NewLog("api - pushChanges()", 5, nil,
+		"OUT - Changes pushed "+time.Now().Format("02.01 15:04:05"), nil)
+}
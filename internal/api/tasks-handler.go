@@ -0,0 +1,132 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"noteapp/internal/model"
+	"noteapp/internal/repository"
+	"noteapp/internal/service"
+	"noteapp/pkg/logger"
+	"strconv"
+	"time"
+)
+
+// TASKS
+
+// getTasks returns the open tasks of the user, filtered by "group_id"
+// with its subgroups and by the due dates "due_from" and "due_to"
+// (2006-01-02, inclusive)
+func (h *Handler) getTasks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apiError(w, r, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+
+	data, ok := r.Context().Value(ctxKey{}).(map[string]string)
+	if !ok {
+		logger.NewLog("api - getTasks()", 2, nil, "Filed to recive contex data", nil)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	email := data["email"]
+	if email == "" {
+		logger.NewLog("api - getTasks()", 2, nil, "Required fields are missing in r.Contex", "email")
+		apiError(w, r, http.StatusBadRequest, errRequiredFieldsMissing)
+		return
+	}
+
+	query := r.URL.Query()
+	f := model.TaskFilter{Due_from: query.Get("due_from"), Due_to: query.Get("due_to")}
+	if s := query.Get("group_id"); s != "" {
+		var err error
+		if f.Group_id, err = strconv.Atoi(s); err != nil {
+			logger.NewLog("api - getTasks()", 2, err, "Filed to convert string to int", "string = "+s)
+			apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+			return
+		}
+	}
+	for _, date := range []string{f.Due_from, f.Due_to} {
+		if date == "" {
+			continue
+		}
+		if _, err := time.Parse(model.DateLayout, date); err != nil {
+			logger.NewLog("api - getTasks()", 2, err, "Filed to parse date", date)
+			apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+			return
+		}
+	}
+
+	list, err := h.NotesService.GetTasks(email, f)
+	if err != nil {
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(list); err != nil {
+		logger.NewLog("api - getTasks()", 2, err, "Filed to encode r.Body", nil)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	logger.NewLog("api - getTasks()", 5, nil,
+		"OUT - Tasks geted "+time.Now().Format("02.01 15:04:05"), nil)
+}
+
+// toggleTask checks ("done" = "true") or unchecks the task "id" in the
+// text of its note. 409 - the note was changed since the tasks were read.
+func (h *Handler) toggleTask(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		apiError(w, r, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+
+	data, ok := r.Context().Value(ctxKey{}).(map[string]string)
+	if !ok {
+		logger.NewLog("api - toggleTask()", 2, nil, "Filed to recive contex data", nil)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	string_id := data["id"]
+	email := data["email"]
+	if string_id == "" || email == "" || data["done"] == "" {
+		logger.NewLog("api - toggleTask()", 2, nil, "Required fields are missing in r.Contex", data)
+		apiError(w, r, http.StatusBadRequest, errRequiredFieldsMissing)
+		return
+	}
+
+	id, err := strconv.Atoi(string_id)
+	if err != nil {
+		logger.NewLog("api - toggleTask()", 2, err, "Filed to convert string to int", "string = "+string_id)
+		apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+		return
+	}
+	done, err := strconv.ParseBool(data["done"])
+	if err != nil {
+		logger.NewLog("api - toggleTask()", 2, err, "Filed to parse bool", data["done"])
+		apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+		return
+	}
+
+	err = h.NotesService.ToggleTask(id, email, done)
+	if err == repository.ErrInvalidData {
+		apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+		return
+	}
+	if err == service.ErrTaskChanged {
+		apiError(w, r, http.StatusConflict, service.ErrTaskChanged)
+		return
+	}
+	if err == service.ErrAccessDenied {
+		apiError(w, r, http.StatusForbidden, service.ErrAccessDenied)
+		return
+	}
+	if err != nil {
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	logger.NewLog("api - toggleTask()", 5, nil,
+		"OUT - Task toggled "+time.Now().Format("02.01 15:04:05"), nil)
+}
@@ -0,0 +1,279 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"noteapp/internal/model"
+	"noteapp/internal/repository"
+	"noteapp/internal/service"
+	"noteapp/pkg/logger"
+	"strconv"
+	"time"
+)
+
+// TEMPLATES
+
+func (h *Handler) addTemplate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		apiError(w, r, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+
+	data, ok := r.Context().Value(ctxKey{}).(map[string]string)
+	if !ok {
+		logger.NewLog("api - addTemplate()", 2, nil, "Filed to recive contex data", nil)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	email := data["email"]
+	if email == "" || data["name"] == "" {
+		logger.NewLog("api - addTemplate()", 2, nil, "Required fields are missing in r.Context", data)
+		apiError(w, r, http.StatusBadRequest, errRequiredFieldsMissing)
+		return
+	}
+
+	t := &model.Template{
+		User_email: email,
+		Name:       data["name"],
+		Title:      data["title"],
+		Text:       data["text"],
+	}
+
+	err := h.TemplatesService.AddTemplate(t)
+	if err == repository.ErrInvalidData || err == model.ErrValidationTemplate {
+		apiError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if err != nil {
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(t); err != nil {
+		logger.NewLog("api - addTemplate()", 2, err, "Filed to encode r.Body", t.Id)
+		return
+	}
+
+	logger.NewLog("api - addTemplate()", 5, nil,
+		"OUT - Template added "+time.Now().Format("02.01 15:04:05"), nil)
+}
+
+func (h *Handler) updateTemplate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		apiError(w, r, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+
+	data, ok := r.Context().Value(ctxKey{}).(map[string]string)
+	if !ok {
+		logger.NewLog("api - updateTemplate()", 2, nil, "Filed to recive contex data", nil)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	string_id := data["id"]
+	email := data["email"]
+	if string_id == "" || email == "" || data["name"] == "" {
+		logger.NewLog("api - updateTemplate()", 2, nil, "Required fields are missing in r.Contex", data)
+		apiError(w, r, http.StatusBadRequest, errRequiredFieldsMissing)
+		return
+	}
+
+	id, err := strconv.Atoi(string_id)
+	if err != nil {
+		logger.NewLog("api - updateTemplate()", 2, err, "Filed to convert string to int", "string = "+string_id)
+		apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+		return
+	}
+
+	t := &model.Template{
+		Id:         id,
+		User_email: email,
+		Name:       data["name"],
+		Title:      data["title"],
+		Text:       data["text"],
+	}
+
+	err = h.TemplatesService.UpdateTemplate(t)
+	if err == repository.ErrInvalidData || err == model.ErrValidationTemplate {
+		apiError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if err != nil {
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(t); err != nil {
+		logger.NewLog("api - updateTemplate()", 2, err, "Filed to encode r.Body", t.Id)
+		return
+	}
+
+	logger.NewLog("api - updateTemplate()", 5, nil,
+		"OUT - Template updated "+time.Now().Format("02.01 15:04:05"), nil)
+}
+
+func (h *Handler) delTemplate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		apiError(w, r, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+
+	data, ok := r.Context().Value(ctxKey{}).(map[string]string)
+	if !ok {
+		logger.NewLog("api - delTemplate()", 2, nil, "Filed to recive contex data", nil)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	string_id := r.URL.Query().Get("id")
+	email := data["email"]
+	if string_id == "" || email == "" {
+		logger.NewLog("api - delTemplate()", 2, nil, "Required fields are missing in r.Contex", nil)
+		apiError(w, r, http.StatusBadRequest, errRequiredFieldsMissing)
+		return
+	}
+
+	id, err := strconv.Atoi(string_id)
+	if err != nil {
+		logger.NewLog("api - delTemplate()", 2, err, "Filed to convert string to int", "string = "+string_id)
+		apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+		return
+	}
+
+	err = h.TemplatesService.DelTemplate(id, email)
+	if err == repository.ErrInvalidData {
+		apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+		return
+	}
+	if err != nil {
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	logger.NewLog("api - delTemplate()", 5, nil,
+		"OUT - Template deleted "+time.Now().Format("02.01 15:04:05"), nil)
+}
+
+// getTemplates returns the built-in templates and the user's ones
+func (h *Handler) getTemplates(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apiError(w, r, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+
+	data, ok := r.Context().Value(ctxKey{}).(map[string]string)
+	if !ok {
+		logger.NewLog("api - getTemplates()", 2, nil, "Filed to recive contex data", nil)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	email := data["email"]
+	if email == "" {
+		logger.NewLog("api - getTemplates()", 2, nil, "Required fields are missing in r.Contex", "email")
+		apiError(w, r, http.StatusBadRequest, errRequiredFieldsMissing)
+		return
+	}
+
+	list, err := h.TemplatesService.GetTemplates(email)
+	if err != nil {
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(list); err != nil {
+		logger.NewLog("api - getTemplates()", 2, err, "Filed to encode r.Body", nil)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	logger.NewLog("api - getTemplates()", 5, nil,
+		"OUT - Templates geted "+time.Now().Format("02.01 15:04:05"), nil)
+}
+
+// addNoteFromTemplate creates a note from "template_id" or a built-in
+// template "builtin" in "group_id". Placeholders get the time of "tz",
+// an IANA zone like "Europe/Berlin", UTC by default.
+func (h *Handler) addNoteFromTemplate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		apiError(w, r, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+
+	data, ok := r.Context().Value(ctxKey{}).(map[string]string)
+	if !ok {
+		logger.NewLog("api - addNoteFromTemplate()", 2, nil, "Filed to recive contex data", nil)
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	email := data["email"]
+	templateString := data["template_id"]
+	builtin := data["builtin"]
+	if email == "" || (templateString == "") == (builtin == "") {
+		logger.NewLog("api - addNoteFromTemplate()", 2, nil, "Required fields are missing in r.Context", data)
+		apiError(w, r, http.StatusBadRequest, errRequiredFieldsMissing)
+		return
+	}
+
+	var err error
+	templateID := 0
+	if templateString != "" {
+		if templateID, err = strconv.Atoi(templateString); err != nil {
+			logger.NewLog("api - addNoteFromTemplate()", 2, err, "Filed to convert string to int", "string = "+templateString)
+			apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+			return
+		}
+	}
+
+	group_id := -1
+	if group_id_string := data["group_id"]; group_id_string != "" {
+		if group_id, err = strconv.Atoi(group_id_string); err != nil {
+			logger.NewLog("api - addNoteFromTemplate()", 2, err, "Filed to convert string to int", "string = "+group_id_string)
+			apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+			return
+		}
+	}
+
+	now, err := userTime(data["tz"])
+	if err != nil {
+		logger.NewLog("api - addNoteFromTemplate()", 2, err, "Filed to load time zone", data["tz"])
+		apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+		return
+	}
+
+	id, err := h.TemplatesService.AddNoteFromTemplate(email, templateID, builtin, group_id, data["title"], now)
+	if err == repository.ErrInvalidData {
+		apiError(w, r, http.StatusBadRequest, repository.ErrInvalidData)
+		return
+	}
+	if err == service.ErrAccessDenied {
+		apiError(w, r, http.StatusForbidden, service.ErrAccessDenied)
+		return
+	}
+	if err != nil {
+		apiError(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(map[string]int{"id": id}); err != nil {
+		logger.NewLog("api - addNoteFromTemplate()", 2, err, "Filed to encode r.Body", id)
+		return
+	}
+
+	logger.NewLog("api - addNoteFromTemplate()", 5, nil,
+		"OUT - Note added from template "+time.Now().Format("02.01 15:04:05"), nil)
+}
+
+// userTime returns the current time in the zone of the user, "" is UTC
+func userTime(tz string) (time.Time, error) {
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Now().In(loc), nil
+}
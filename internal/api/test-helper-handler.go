@@ -4,9 +4,12 @@ import (
 	"database/sql"
 	"encoding/json"
 	"net/http"
+	"noteapp/internal/collab"
 	"noteapp/internal/database"
+	"noteapp/internal/events"
 	"noteapp/internal/repository"
 	"noteapp/internal/service"
+	"noteapp/internal/storage"
 	"os"
 	"testing"
 )
@@ -52,12 +55,57 @@ func NewTestHandler(t *testing.T) (
 	//authRepo := repository.NewTestAuthRepository(db)
 	userRepo := repository.NewTestUserRepository(db)
 	noteRepo := repository.NewTestNotesRepository(db)
+	sharesRepo := repository.NewSharesRepository(db)
+	publicLinksRepo := repository.NewPublicLinksRepository(db)
+	syncRepo := repository.NewSyncRepository(db)
+	linksRepo := repository.NewLinksRepository(db)
+	tasksRepo := repository.NewTasksRepository(db)
+	graphRepo := repository.NewGraphRepository(db)
+	attachmentsRepo := repository.NewAttachmentsRepository(db)
+	importRepo := repository.NewImportRepository(db)
+	backupRepo := repository.NewBackupRepository(db)
+	templatesRepo := repository.NewTemplatesRepository(db)
+	journalRepo := repository.NewJournalRepository(db)
+	remindersRepo := repository.NewRemindersRepository(db)
+	favoritesRepo := repository.NewFavoritesRepository(db)
+	recentRepo := repository.NewRecentRepository(db)
+	commentsRepo := repository.NewCommentsRepository(db)
+	keysRepo := repository.NewKeysRepository(db)
+
+	blobStore, err := storage.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	//authService := service.NewAuthService(authRepo)
+	bus := events.NewBus()
+
 	userService := service.NewUserService(userRepo)
-	noteService := service.NewNotesService(noteRepo)
+	noteService := service.NewNotesService(noteRepo, sharesRepo, bus, linksRepo, tasksRepo)
+	sharesService := service.NewSharesService(sharesRepo, userRepo, noteRepo)
+	publicLinksService := service.NewPublicLinksService(publicLinksRepo, sharesRepo, noteRepo)
+	collabHub := collab.NewHub(noteService)
+	syncService := service.NewSyncService(syncRepo, noteService)
+	graphService := service.NewGraphService(graphRepo)
+	exportService := service.NewExportService(noteRepo, attachmentsRepo, blobStore)
+	attachmentsService := service.NewAttachmentsService(attachmentsRepo, blobStore, noteService, service.AttachmentLimits{
+		MaxFileSize: 1 << 20,
+		MaxUserSize: 4 << 20,
+	})
+	importService := service.NewImportService(importRepo, noteService, attachmentsService)
+	backupService := service.NewBackupService(backupRepo, importService)
+	templatesService := service.NewTemplatesService(templatesRepo, noteService)
+	journalService := service.NewJournalService(journalRepo, noteService, templatesService)
+	remindersService := service.NewRemindersService(remindersRepo, noteService, bus, nil)
+	favoritesService := service.NewFavoritesService(favoritesRepo, noteService)
+	recentService := service.NewRecentService(recentRepo)
+	commentsService := service.NewCommentsService(commentsRepo, noteService)
+	keysService := service.NewKeysService(keysRepo)
 
-	h := NewHandler(userService, noteService)
+	h := NewHandler(userService, noteService, sharesService, publicLinksService, bus, collabHub, syncService,
+		attachmentsService, graphService, exportService, importService, backupService,
+		templatesService, journalService, remindersService, favoritesService,
+		recentService, commentsService, keysService)
 	handler := h.InitHandler()
 	return handler, create, teardown, db
 }
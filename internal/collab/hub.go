@@ -0,0 +1,520 @@
+package collab
+
+import (
+	"encoding/json"
+	"errors"
+	"noteapp/internal/model"
+	"noteapp/pkg/logger"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// saveDelay - merged text is written to the database after this pause in typing
+	saveDelay = 2 * time.Second
+	// closeDelay - session is kept in memory after the last client left,
+	// so reconnecting clients can still send their pending operations
+	closeDelay = time.Minute
+	// historySize - how many operations are kept for late clients
+	historySize = 1000
+	sendSize    = 128
+	// saveRetries - failed saves are retried this many times before
+	// the buffered edits are given up
+	saveRetries = 5
+)
+
+var (
+	ErrResync = errors.New("revision is too old, reload the note")
+)
+
+type NoteStore interface {
+	GetNotePermission(id int, email string) (string, error)
+	GetNote(id int, email string) (model.Note, error)
+	UpdateNote(data map[string]string) error
+}
+
+// Hub - live editing sessions, one per note
+type Hub struct {
+	store    NoteStore
+	mu       sync.Mutex
+	sessions map[int]*session
+}
+
+func NewHub(store NoteStore) *Hub {
+	return &Hub{
+		store:    store,
+		sessions: map[int]*session{},
+	}
+}
+
+// Join connects the user to the note session. clientID is generated
+// by the client and stays the same between reconnects.
+func (h *Hub) Join(noteID int, email string, clientID string) (*Client, error) {
+	permission, err := h.store.GetNotePermission(noteID, email)
+	if err != nil {
+		return nil, err
+	}
+
+	h.mu.Lock()
+	s, ok := h.sessions[noteID]
+	if !ok {
+		note, err := h.store.GetNote(noteID, email)
+		if err != nil {
+			h.mu.Unlock()
+			return nil, err
+		}
+		// operations can not be merged on ciphertext
+		if note.Encrypted {
+			h.mu.Unlock()
+			return nil, model.ErrEncryptedNote
+		}
+		s = &session{
+			hub:     h,
+			noteID:  noteID,
+			text:    []rune(note.Text),
+			saved:   []rune(note.Text),
+			version: note.Version,
+			clients: map[*Client]struct{}{},
+			acks:    map[string]ack{},
+		}
+		h.sessions[noteID] = s
+	}
+
+	c := &Client{
+		ID:       clientID,
+		Email:    email,
+		ReadOnly: permission != model.PermissionEdit,
+		Send:     make(chan []byte, sendSize),
+		session:  s,
+	}
+	// joined under h.mu, so the session can not be closed in between
+	s.join(c)
+	h.mu.Unlock()
+	return c, nil
+}
+
+// Flush saves the buffered edits of all sessions, it is called on
+// shutdown before the database is closed
+func (h *Hub) Flush() {
+	h.mu.Lock()
+	sessions := make([]*session, 0, len(h.sessions))
+	for _, s := range h.sessions {
+		sessions = append(sessions, s)
+	}
+	h.mu.Unlock()
+
+	for _, s := range sessions {
+		if !s.save() {
+			logger.NewLog("collab - Flush()", 2, nil, "Edits are lost, the note can not be saved", strconv.Itoa(s.noteID))
+		}
+	}
+}
+
+func (h *Hub) closeSession(s *session) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.clients) != 0 || h.sessions[s.noteID] != s {
+		return
+	}
+	delete(h.sessions, s.noteID)
+	s.closed = true
+}
+
+// Message - all messages of the protocol, Type defines the used fields
+type Message struct {
+	Type      string         `json:"type"`
+	Rev       int            `json:"rev,omitempty"`
+	Seq       int            `json:"seq,omitempty"`
+	Ops       *TextOperation `json:"ops,omitempty"`
+	Text      *string        `json:"text,omitempty"`
+	Pos       *int           `json:"pos,omitempty"`
+	End       *int           `json:"end,omitempty"`
+	Client_id string         `json:"client_id,omitempty"`
+	User      string         `json:"user,omitempty"`
+	Users     []Presence     `json:"users,omitempty"`
+	Read_only bool           `json:"read_only,omitempty"`
+	Error     string         `json:"error,omitempty"`
+}
+
+type Presence struct {
+	Client_id string `json:"client_id"`
+	User      string `json:"user"`
+	Pos       int    `json:"pos"`
+	End       int    `json:"end"`
+}
+
+type Client struct {
+	ID       string
+	Email    string
+	ReadOnly bool
+	Send     chan []byte
+	session  *session
+	pos, end int
+	once     sync.Once
+}
+
+// Handle processes one message received from the client
+func (c *Client) Handle(data []byte) {
+	m := Message{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		c.sendError(ErrInvalidOperation)
+		return
+	}
+
+	switch m.Type {
+	case "op":
+		if c.ReadOnly {
+			c.sendError(errors.New("note is read only"))
+			return
+		}
+		if m.Ops == nil {
+			c.sendError(ErrInvalidOperation)
+			return
+		}
+		c.session.submit(c, m.Rev, m.Seq, m.Ops)
+	case "cursor":
+		if m.Pos == nil {
+			c.sendError(ErrInvalidOperation)
+			return
+		}
+		end := *m.Pos
+		if m.End != nil {
+			end = *m.End
+		}
+		c.session.cursor(c, *m.Pos, end)
+	default:
+		c.sendError(errors.New("unknown message type"))
+	}
+}
+
+// Leave disconnects the client, Send is closed
+func (c *Client) Leave() {
+	c.once.Do(func() {
+		c.session.leave(c)
+	})
+}
+
+func (c *Client) sendError(err error) {
+	c.session.mu.Lock()
+	defer c.session.mu.Unlock()
+	c.session.send(c, Message{Type: "error", Error: err.Error()})
+}
+
+type ack struct {
+	seq int
+	rev int
+}
+
+type session struct {
+	hub     *Hub
+	noteID  int
+	mu      sync.Mutex
+	text    []rune
+	saved   []rune // text of the note at version
+	version int    // version of the note the text is based on
+	rev     int
+	history []*TextOperation // operations from revision rev-len(history) to rev
+	clients map[*Client]struct{}
+	acks    map[string]ack // the last applied operation of every client
+	editor  string         // the last user who changed the text
+	dirty   bool
+	saving  *time.Timer
+	closing *time.Timer
+	closed  bool
+	// saves go one at a time, failures counts the failed ones in a row
+	saveMu   sync.Mutex
+	failures int
+}
+
+func (s *session) join(c *Client) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closing != nil {
+		s.closing.Stop()
+		s.closing = nil
+	}
+	s.clients[c] = struct{}{}
+
+	s.sendInit(c)
+	if a, ok := s.acks[c.ID]; ok {
+		// the client learns which of its operations were applied before reconnect
+		s.send(c, Message{Type: "ack", Rev: a.rev, Seq: a.seq})
+	}
+	s.broadcast(c, Message{Type: "join", Client_id: c.ID, User: c.Email})
+}
+
+func (s *session) leave(c *Client) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.clients[c]; ok {
+		delete(s.clients, c)
+		close(c.Send)
+	}
+	s.broadcast(nil, Message{Type: "leave", Client_id: c.ID, User: c.Email})
+
+	if len(s.clients) == 0 && s.closing == nil {
+		s.closing = time.AfterFunc(closeDelay, s.close)
+	}
+}
+
+// close saves the text and drops the session. The session is kept while
+// the save fails, so the buffered edits are not lost on a short outage.
+func (s *session) close() {
+	saved := s.save()
+
+	s.mu.Lock()
+	retry := !saved && s.failures <= saveRetries && len(s.clients) == 0 && !s.closed
+	if retry {
+		s.closing = time.AfterFunc(saveDelay, s.close)
+	}
+	s.mu.Unlock()
+	if retry {
+		return
+	}
+
+	if !saved {
+		logger.NewLog("collab - close()", 2, nil, "Edits are lost, the note can not be saved", strconv.Itoa(s.noteID))
+	}
+	s.hub.closeSession(s)
+}
+
+// submit applies the client operation made on revision rev
+func (s *session) submit(c *Client, rev int, seq int, op *TextOperation) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// operation resent after reconnect and already applied
+	if a, ok := s.acks[c.ID]; ok && seq != 0 && seq <= a.seq {
+		s.send(c, Message{Type: "ack", Rev: a.rev, Seq: seq})
+		return
+	}
+
+	start := s.rev - len(s.history)
+	if rev < start || rev > s.rev {
+		s.send(c, Message{Type: "error", Error: ErrResync.Error()})
+		return
+	}
+
+	var err error
+	for _, concurrent := range s.history[rev-start:] {
+		op, _, err = Transform(op, concurrent)
+		if err != nil {
+			break
+		}
+	}
+	var text []rune
+	if err == nil {
+		text, err = op.Apply(s.text)
+	}
+	if err != nil {
+		logger.NewLog("collab - submit()", 3, err, "Filed to apply operation", strconv.Itoa(s.noteID))
+		s.send(c, Message{Type: "error", Error: err.Error()})
+		return
+	}
+
+	s.text = text
+	s.rev++
+	s.history = append(s.history, op)
+	if len(s.history) > historySize {
+		s.history = s.history[len(s.history)-historySize:]
+	}
+	s.acks[c.ID] = ack{seq: seq, rev: s.rev}
+	s.editor = c.Email
+
+	for other := range s.clients {
+		other.pos = TransformIndex(op, other.pos)
+		other.end = TransformIndex(op, other.end)
+	}
+
+	s.send(c, Message{Type: "ack", Rev: s.rev, Seq: seq})
+	s.broadcast(c, Message{Type: "op", Rev: s.rev, Ops: op, Client_id: c.ID, User: c.Email})
+
+	s.dirty = true
+	if s.saving == nil {
+		s.saving = time.AfterFunc(saveDelay, func() { s.save() })
+	}
+}
+
+func (s *session) cursor(c *Client, pos int, end int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c.pos, c.end = pos, end
+	s.broadcast(c, Message{Type: "cursor", Client_id: c.ID, User: c.Email, Pos: &pos, End: &end})
+}
+
+// save writes the merged text through the notes service, only if the
+// note was not changed since the session read it. The edits of the
+// session are rebased onto a note changed elsewhere (an update, a sync
+// push, a renamed link) and saved again. A failed save is retried.
+// false - the text is not saved yet.
+func (s *session) save() bool {
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+
+	s.mu.Lock()
+	if s.saving != nil {
+		s.saving.Stop()
+		s.saving = nil
+	}
+	if !s.dirty || s.closed {
+		s.mu.Unlock()
+		return true
+	}
+	s.dirty = false
+	version := s.version
+	text := append([]rune{}, s.text...)
+	data := map[string]string{
+		"id":      strconv.Itoa(s.noteID),
+		"email":   s.editor,
+		"text":    string(text),
+		"version": strconv.Itoa(version),
+	}
+	s.mu.Unlock()
+
+	err := s.hub.store.UpdateNote(data)
+	if err == nil {
+		s.mu.Lock()
+		// every update of the note increments its version
+		s.version = version + 1
+		s.saved = text
+		s.failures = 0
+		s.mu.Unlock()
+		return true
+	}
+
+	note, getErr := s.hub.store.GetNote(s.noteID, data["email"])
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if getErr == nil && note.Version != version {
+		if note.Encrypted {
+			s.reload(note)
+			return true
+		}
+		if rebaseErr := s.rebase(note); rebaseErr != nil {
+			logger.NewLog("collab - save()", 2, rebaseErr, "Filed to rebase edits, reloaded", data["id"])
+			s.reload(note)
+			return true
+		}
+		logger.NewLog("collab - save()", 3, err, "Note was changed outside of the session, edits rebased", data["id"])
+		if !s.dirty {
+			return true
+		}
+		if s.saving == nil && !s.closed {
+			s.saving = time.AfterFunc(saveDelay, func() { s.save() })
+		}
+		return false
+	}
+
+	logger.NewLog("collab - save()", 2, err, "Filed to save note", data["id"])
+	s.dirty = true
+	s.failures++
+	if s.failures <= saveRetries && s.saving == nil && !s.closed {
+		s.saving = time.AfterFunc(saveDelay, func() { s.save() })
+	}
+	return false
+}
+
+// rebase applies the change of the note made outside of the session on
+// top of the edits of the session, s.mu must be held. The clients get
+// the change as an operation, so their own pending operations are
+// transformed as usual.
+func (s *session) rebase(note model.Note) error {
+	local := Diff(s.saved, s.text)
+	remote := Diff(s.saved, []rune(note.Text))
+	_, op, err := Transform(local, remote)
+	if err != nil {
+		return err
+	}
+	text, err := op.Apply(s.text)
+	if err != nil {
+		return err
+	}
+
+	s.text = text
+	s.saved = []rune(note.Text)
+	s.version = note.Version
+	s.rev++
+	s.history = append(s.history, op)
+	if len(s.history) > historySize {
+		s.history = s.history[len(s.history)-historySize:]
+	}
+	s.dirty = string(text) != note.Text
+	s.failures = 0
+
+	for c := range s.clients {
+		c.pos = TransformIndex(op, c.pos)
+		c.end = TransformIndex(op, c.end)
+	}
+	s.broadcast(nil, Message{Type: "op", Rev: s.rev, Ops: op})
+	return nil
+}
+
+// reload replaces the text with the saved note, s.mu must be held.
+// Operations made on the old text can not be transformed, the history
+// is dropped and every client gets the new text.
+func (s *session) reload(note model.Note) {
+	s.text = []rune(note.Text)
+	s.saved = []rune(note.Text)
+	s.version = note.Version
+	s.rev++
+	s.history = nil
+	s.acks = map[string]ack{}
+	s.dirty = false
+	s.failures = 0
+
+	for c := range s.clients {
+		// the ciphertext can not be edited together
+		if note.Encrypted {
+			s.send(c, Message{Type: "error", Error: model.ErrEncryptedNote.Error()})
+			delete(s.clients, c)
+			close(c.Send)
+			continue
+		}
+		s.sendInit(c)
+	}
+}
+
+// sendInit sends the whole state of the session, s.mu must be held
+func (s *session) sendInit(c *Client) {
+	users := []Presence{}
+	for other := range s.clients {
+		users = append(users, Presence{Client_id: other.ID, User: other.Email, Pos: other.pos, End: other.end})
+	}
+	text := string(s.text)
+	s.send(c, Message{Type: "init", Rev: s.rev, Text: &text, Client_id: c.ID, Users: users, Read_only: c.ReadOnly})
+}
+
+// send puts the message into the client queue, s.mu must be held.
+// A client that does not read its messages is disconnected.
+func (s *session) send(c *Client, m Message) {
+	b, err := json.Marshal(m)
+	if err != nil {
+		logger.NewLog("collab - send()", 2, err, "Filed to marshal message", m.Type)
+		return
+	}
+	if _, ok := s.clients[c]; !ok {
+		return
+	}
+	select {
+	case c.Send <- b:
+	default:
+		delete(s.clients, c)
+		close(c.Send)
+	}
+}
+
+func (s *session) broadcast(except *Client, m Message) {
+	for c := range s.clients {
+		if c != except {
+			s.send(c, m)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package collab
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+
+	"noteapp/internal/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var errStore = errors.New("store is down")
+
+// fakeStore - one note, updates with an old version fail as in the
+// notes repository
+type fakeStore struct {
+	mu   sync.Mutex
+	note model.Note
+	down bool
+}
+
+func (f *fakeStore) GetNotePermission(id int, email string) (string, error) {
+	return model.PermissionEdit, nil
+}
+
+func (f *fakeStore) GetNote(id int, email string) (model.Note, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.note, nil
+}
+
+func (f *fakeStore) UpdateNote(data map[string]string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.down {
+		return errStore
+	}
+	if data["version"] != strconv.Itoa(f.note.Version) {
+		return errors.New("invalid data")
+	}
+	f.note.Text = data["text"]
+	f.note.Version++
+	return nil
+}
+
+func (f *fakeStore) edit(text string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.note.Text = text
+	f.note.Version++
+}
+
+func joinNote(t *testing.T, store *fakeStore) (*Hub, *Client) {
+	t.Helper()
+
+	h := NewHub(store)
+	c, err := h.Join(1, "user@mail.com", "c1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-c.Send // init
+	return h, c
+}
+
+func lastMessage(c *Client) Message {
+	m := Message{}
+	for {
+		select {
+		case b := <-c.Send:
+			json.Unmarshal(b, &m)
+		default:
+			return m
+		}
+	}
+}
+
+func TestSessionSave(t *testing.T) {
+	testCases := []struct {
+		name     string
+		down     bool
+		saved    bool
+		want     string
+		wantText string // text of the session after save
+	}{
+		{
+			name:     "saved with the version",
+			saved:    true,
+			want:     "hello world",
+			wantText: "hello world",
+		},
+		{
+			name:     "failed save keeps the edits",
+			down:     true,
+			want:     "hello",
+			wantText: "hello world",
+		},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			store := &fakeStore{note: model.Note{Id: 1, Text: "hello", Version: 3}}
+			_, c := joinNote(t, store)
+			c.session.submit(c, 0, 1, op(t, `[5, " world"]`))
+			lastMessage(c)
+
+			store.down = tcase.down
+
+			assert.Equal(t, tcase.saved, c.session.save())
+			assert.Equal(t, tcase.want, store.note.Text)
+
+			c.session.mu.Lock()
+			assert.Equal(t, tcase.wantText, string(c.session.text))
+			assert.Equal(t, !tcase.saved, c.session.dirty)
+			if tcase.down {
+				assert.NotNil(t, c.session.saving, "failed save is retried")
+				c.session.saving.Stop()
+			}
+			c.session.mu.Unlock()
+		})
+	}
+}
+
+func TestSessionRebase(t *testing.T) {
+	testCases := []struct {
+		name string
+		// the note changed outside of the session, the session has
+		// made "hello" into "hello world"
+		edit string
+		// text after the rebase
+		want string
+		// the client sends "!" at the end of "hello world" before it
+		// gets the change
+		wantPending string
+	}{
+		{
+			name:        "change before the edits",
+			edit:        "Hello",
+			want:        "Hello world",
+			wantPending: "Hello world!",
+		},
+		{
+			name:        "change after the edits",
+			edit:        "hello.",
+			want:        "hello world.",
+			wantPending: "hello world!.",
+		},
+		{
+			name:        "replaced text",
+			edit:        "renamed [[link]]",
+			want:        "renamed [[link]] world",
+			wantPending: "renamed [[link]] world!",
+		},
+		{
+			name:        "deleted text",
+			edit:        "",
+			want:        " world",
+			wantPending: " world!",
+		},
+		{
+			name:        "title changed",
+			edit:        "hello",
+			want:        "hello world",
+			wantPending: "hello world!",
+		},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			store := &fakeStore{note: model.Note{Id: 1, Text: "hello", Version: 3}}
+			_, c := joinNote(t, store)
+			c.session.submit(c, 0, 1, op(t, `[5, " world"]`))
+			lastMessage(c)
+			store.edit(tcase.edit)
+
+			// the edits are not lost, they are saved on the next try
+			assert.False(t, c.session.save())
+			assert.Equal(t, tcase.edit, store.note.Text)
+
+			c.session.mu.Lock()
+			assert.Equal(t, tcase.want, string(c.session.text))
+			assert.True(t, c.session.dirty)
+			assert.NotNil(t, c.session.saving, "rebased text is saved")
+			c.session.saving.Stop()
+			c.session.saving = nil
+			c.session.mu.Unlock()
+
+			// the client gets the change as an operation on its text
+			m := lastMessage(c)
+			assert.Equal(t, "op", m.Type)
+			assert.Equal(t, 2, m.Rev)
+			text, err := m.Ops.Apply([]rune("hello world"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tcase.want, string(text))
+
+			// an operation made before the change is transformed
+			c.session.submit(c, 1, 2, op(t, `[11, "!"]`))
+			assert.Equal(t, "ack", lastMessage(c).Type)
+
+			assert.True(t, c.session.save())
+			assert.Equal(t, tcase.wantPending, store.note.Text)
+			assert.Equal(t, 5, store.note.Version)
+		})
+	}
+}
+
+func TestSessionRetryAfterFailure(t *testing.T) {
+	store := &fakeStore{note: model.Note{Id: 1, Text: "hello", Version: 3}}
+	_, c := joinNote(t, store)
+	c.session.submit(c, 0, 1, op(t, `[5, "!"]`))
+
+	store.down = true
+	assert.False(t, c.session.save())
+	store.down = false
+	assert.True(t, c.session.save())
+	assert.Equal(t, "hello!", store.note.Text)
+	assert.Equal(t, 4, store.note.Version)
+
+	// the next save goes with the new version
+	c.session.submit(c, 1, 2, op(t, `[6, "?"]`))
+	assert.True(t, c.session.save())
+	assert.Equal(t, "hello!?", store.note.Text)
+}
+
+func TestHubFlush(t *testing.T) {
+	store := &fakeStore{note: model.Note{Id: 1, Text: "hello", Version: 3}}
+	h, c := joinNote(t, store)
+	c.session.submit(c, 0, 1, op(t, `[5, "!"]`))
+
+	h.Flush()
+	assert.Equal(t, "hello!", store.note.Text)
+	assert.Equal(t, 4, store.note.Version)
+
+	c.session.mu.Lock()
+	assert.False(t, c.session.dirty)
+	assert.Nil(t, c.session.saving, "the delayed save is stopped")
+	c.session.mu.Unlock()
+}
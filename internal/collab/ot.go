@@ -0,0 +1,289 @@
+package collab
+
+import (
+	"encoding/json"
+	"errors"
+	"unicode/utf8"
+)
+
+// MaxLen - length limit of a document and of every operation in code
+// points, counts from clients are checked against it before any sum
+const MaxLen = 10 << 20
+
+var (
+	ErrInvalidOperation = errors.New("invalid operation")
+	ErrLengthMismatch   = errors.New("operation length does not match the document")
+)
+
+// component - one step of the operation:
+// n > 0 retain n chars, n < 0 delete -n chars, ins != "" insert the string.
+// All lengths are counted in unicode code points.
+type component struct {
+	n   int
+	ins string
+}
+
+func (c component) isRetain() bool { return c.ins == "" && c.n > 0 }
+func (c component) isDelete() bool { return c.ins == "" && c.n < 0 }
+func (c component) isInsert() bool { return c.ins != "" }
+
+// TextOperation - operational transformation of a plain text,
+// the same format as ot.js uses: [5, "abc", -2] means
+// retain 5 chars, insert "abc", delete 2 chars.
+type TextOperation struct {
+	ops       []component
+	BaseLen   int
+	TargetLen int
+}
+
+func (o *TextOperation) Retain(n int) *TextOperation {
+	if n <= 0 {
+		return o
+	}
+	o.BaseLen += n
+	o.TargetLen += n
+	if l := len(o.ops); l > 0 && o.ops[l-1].isRetain() {
+		o.ops[l-1].n += n
+		return o
+	}
+	o.ops = append(o.ops, component{n: n})
+	return o
+}
+
+func (o *TextOperation) Insert(s string) *TextOperation {
+	if s == "" {
+		return o
+	}
+	o.TargetLen += utf8.RuneCountInString(s)
+	l := len(o.ops)
+	switch {
+	case l > 0 && o.ops[l-1].isInsert():
+		o.ops[l-1].ins += s
+	case l > 0 && o.ops[l-1].isDelete():
+		// insert always goes before delete, so equal operations
+		// have the same representation
+		if l > 1 && o.ops[l-2].isInsert() {
+			o.ops[l-2].ins += s
+		} else {
+			o.ops = append(o.ops, o.ops[l-1])
+			o.ops[l-1] = component{ins: s}
+		}
+	default:
+		o.ops = append(o.ops, component{ins: s})
+	}
+	return o
+}
+
+func (o *TextOperation) Delete(n int) *TextOperation {
+	if n <= 0 {
+		return o
+	}
+	o.BaseLen += n
+	if l := len(o.ops); l > 0 && o.ops[l-1].isDelete() {
+		o.ops[l-1].n -= n
+		return o
+	}
+	o.ops = append(o.ops, component{n: -n})
+	return o
+}
+
+// Apply applies the operation to the document
+func (o *TextOperation) Apply(doc []rune) ([]rune, error) {
+	if len(doc) != o.BaseLen {
+		return nil, ErrLengthMismatch
+	}
+
+	res := make([]rune, 0, min(o.TargetLen, MaxLen))
+	pos := 0
+	for _, c := range o.ops {
+		// lengths are checked on decode, but a broken operation
+		// must not take the session down
+		switch {
+		case c.isRetain():
+			if c.n > len(doc)-pos {
+				return nil, ErrLengthMismatch
+			}
+			res = append(res, doc[pos:pos+c.n]...)
+			pos += c.n
+		case c.isInsert():
+			res = append(res, []rune(c.ins)...)
+		default:
+			if -c.n > len(doc)-pos {
+				return nil, ErrLengthMismatch
+			}
+			pos -= c.n
+		}
+	}
+	if pos != len(doc) {
+		return nil, ErrLengthMismatch
+	}
+	return res, nil
+}
+
+// Diff returns the operation that turns a into b, the changed part between
+// the common prefix and suffix is deleted and inserted again
+func Diff(a []rune, b []rune) *TextOperation {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	o := &TextOperation{}
+	o.Retain(prefix)
+	o.Insert(string(b[prefix : len(b)-suffix]))
+	o.Delete(len(a) - prefix - suffix)
+	o.Retain(suffix)
+	return o
+}
+
+// Transform takes two concurrent operations a and b made on the same document
+// and returns a' and b' so that apply(apply(doc, a), b') == apply(apply(doc, b), a').
+// On the same position inserts of a go first.
+func Transform(a *TextOperation, b *TextOperation) (*TextOperation, *TextOperation, error) {
+	if a.BaseLen != b.BaseLen {
+		return nil, nil, ErrLengthMismatch
+	}
+
+	ap := &TextOperation{}
+	bp := &TextOperation{}
+
+	ops1, ops2 := a.ops, b.ops
+	i1, i2 := 0, 0
+	var op1, op2 *component
+	next := func(ops []component, i *int) *component {
+		if *i >= len(ops) {
+			return nil
+		}
+		c := ops[*i]
+		*i++
+		return &c
+	}
+	op1 = next(ops1, &i1)
+	op2 = next(ops2, &i2)
+
+	for op1 != nil || op2 != nil {
+		if op1 != nil && op1.isInsert() {
+			ap.Insert(op1.ins)
+			bp.Retain(utf8.RuneCountInString(op1.ins))
+			op1 = next(ops1, &i1)
+			continue
+		}
+		if op2 != nil && op2.isInsert() {
+			ap.Retain(utf8.RuneCountInString(op2.ins))
+			bp.Insert(op2.ins)
+			op2 = next(ops2, &i2)
+			continue
+		}
+		if op1 == nil || op2 == nil {
+			return nil, nil, ErrInvalidOperation
+		}
+
+		switch {
+		case op1.isRetain() && op2.isRetain():
+			minl := min(op1.n, op2.n)
+			ap.Retain(minl)
+			bp.Retain(minl)
+			op1.n -= minl
+			op2.n -= minl
+
+		case op1.isDelete() && op2.isDelete():
+			// both delete the same chars
+			minl := min(-op1.n, -op2.n)
+			op1.n += minl
+			op2.n += minl
+
+		case op1.isDelete() && op2.isRetain():
+			minl := min(-op1.n, op2.n)
+			ap.Delete(minl)
+			op1.n += minl
+			op2.n -= minl
+
+		case op1.isRetain() && op2.isDelete():
+			minl := min(op1.n, -op2.n)
+			bp.Delete(minl)
+			op1.n -= minl
+			op2.n += minl
+		}
+
+		if op1.n == 0 {
+			op1 = next(ops1, &i1)
+		}
+		if op2.n == 0 {
+			op2 = next(ops2, &i2)
+		}
+	}
+
+	return ap, bp, nil
+}
+
+// TransformIndex moves a cursor position through the operation
+func TransformIndex(o *TextOperation, pos int) int {
+	newPos := pos
+	for _, c := range o.ops {
+		switch {
+		case c.isRetain():
+			pos -= c.n
+		case c.isInsert():
+			newPos += utf8.RuneCountInString(c.ins)
+		default:
+			newPos -= min(pos, -c.n)
+			pos += c.n
+		}
+		if pos < 0 {
+			break
+		}
+	}
+	return newPos
+}
+
+func (o *TextOperation) MarshalJSON() ([]byte, error) {
+	res := make([]interface{}, 0, len(o.ops))
+	for _, c := range o.ops {
+		if c.isInsert() {
+			res = append(res, c.ins)
+		} else {
+			res = append(res, c.n)
+		}
+	}
+	return json.Marshal(res)
+}
+
+func (o *TextOperation) UnmarshalJSON(data []byte) error {
+	raw := []interface{}{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	*o = TextOperation{}
+	for _, v := range raw {
+		switch c := v.(type) {
+		case float64:
+			// the range is checked before the conversion, huge counts
+			// would overflow the lengths
+			if c == 0 || c > MaxLen || c < -MaxLen || float64(int(c)) != c {
+				return ErrInvalidOperation
+			}
+			n := int(c)
+			if n > 0 {
+				o.Retain(n)
+			} else {
+				o.Delete(-n)
+			}
+		case string:
+			if c == "" || len(c) > 4*MaxLen {
+				return ErrInvalidOperation
+			}
+			o.Insert(c)
+		default:
+			return ErrInvalidOperation
+		}
+		if o.BaseLen > MaxLen || o.TargetLen > MaxLen {
+			return ErrInvalidOperation
+		}
+	}
+	return nil
+}
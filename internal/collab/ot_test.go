@@ -0,0 +1,274 @@
+package collab
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func op(t *testing.T, s string) *TextOperation {
+	t.Helper()
+
+	o := &TextOperation{}
+	if err := json.Unmarshal([]byte(s), o); err != nil {
+		t.Fatal(err)
+	}
+	return o
+}
+
+func TestApply(t *testing.T) {
+	testCases := []struct {
+		name string
+		doc  string
+		op   string
+		want string
+		err  error
+	}{
+		{
+			name: "insert",
+			doc:  "hello",
+			op:   `[5, " world"]`,
+			want: "hello world",
+		},
+		{
+			name: "delete and insert unicode",
+			doc:  "привет мир",
+			op:   `[7, -3, "всем"]`,
+			want: "привет всем",
+		},
+		{
+			name: "wrong length",
+			doc:  "hello",
+			op:   `[3, "a"]`,
+			err:  ErrLengthMismatch,
+		},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			res, err := op(t, tcase.op).Apply([]rune(tcase.doc))
+			assert.Equal(t, tcase.err, err)
+			if err == nil {
+				assert.Equal(t, tcase.want, string(res))
+			}
+		})
+	}
+}
+
+func TestTransform(t *testing.T) {
+	testCases := []struct {
+		name string
+		doc  string
+		a    string
+		b    string
+		want string
+	}{
+		{
+			name: "inserts at the same position",
+			doc:  "abc",
+			a:    `[1, "X", 2]`,
+			b:    `[1, "Y", 2]`,
+			want: "aXYbc",
+		},
+		{
+			name: "insert inside deleted range",
+			doc:  "abcdef",
+			a:    `[2, "X", 4]`,
+			b:    `[1, -4, 1]`,
+			want: "aXf",
+		},
+		{
+			name: "overlapping deletes",
+			doc:  "abcdef",
+			a:    `[1, -3, 2]`,
+			b:    `[2, -3, 1]`,
+			want: "af",
+		},
+		{
+			name: "independent changes",
+			doc:  "hello world",
+			a:    `["Oh, ", 11]`,
+			b:    `[6, -5, "there"]`,
+			want: "Oh, hello there",
+		},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			a, b := op(t, tcase.a), op(t, tcase.b)
+
+			ap, bp, err := Transform(a, b)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			doc := []rune(tcase.doc)
+			left, err := a.Apply(doc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			left, err = bp.Apply(left)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			right, err := b.Apply(doc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			right, err = ap.Apply(right)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, tcase.want, string(left))
+			assert.Equal(t, tcase.want, string(right))
+		})
+	}
+}
+
+func TestDiff(t *testing.T) {
+	testCases := []struct {
+		name string
+		a    string
+		b    string
+		want string
+	}{
+		{name: "same", a: "hello", b: "hello", want: `[5]`},
+		{name: "insert", a: "hello", b: "hello world", want: `[5," world"]`},
+		{name: "delete", a: "hello world", b: "world", want: `[-6,5]`},
+		{name: "replace in the middle", a: "привет мир", b: "привет всем мир", want: `[7,"всем ",3]`},
+		{name: "from empty", a: "", b: "abc", want: `["abc"]`},
+		{name: "to empty", a: "abc", b: "", want: `[-3]`},
+		{name: "repeated chars", a: "aaa", b: "aaaa", want: `[3,"a"]`},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			o := Diff([]rune(tcase.a), []rune(tcase.b))
+			b, err := json.Marshal(o)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tcase.want, string(b))
+
+			res, err := o.Apply([]rune(tcase.a))
+			assert.NoError(t, err)
+			assert.Equal(t, tcase.b, string(res))
+		})
+	}
+}
+
+func TestTransformIndex(t *testing.T) {
+	o := op(t, `[2, "XY", -2, 3]`)
+
+	assert.Equal(t, 1, TransformIndex(o, 1))
+	assert.Equal(t, 4, TransformIndex(o, 3))
+	assert.Equal(t, 5, TransformIndex(o, 5))
+}
+
+func TestMarshalJSON(t *testing.T) {
+	b, err := json.Marshal(op(t, `[1, 2, "a", "b", -1, -1]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, `[3,"ab",-2]`, string(b))
+}
+
+func TestUnmarshalHostile(t *testing.T) {
+	testCases := []struct {
+		name string
+		op   string
+	}{
+		{
+			name: "lengths overflow to the document length",
+			op:   `[4611686018427387904, "x", 4611686018427387904, "y", 4611686018427387904, "z", 4611686018427387904]`,
+		},
+		{
+			name: "count over the limit",
+			op:   `[10485761]`,
+		},
+		{
+			name: "delete over the limit",
+			op:   `[-10485761]`,
+		},
+		{
+			name: "sum over the limit",
+			op:   `[10485760, "a", 1]`,
+		},
+		{
+			name: "count out of int range",
+			op:   `[1e300]`,
+		},
+		{
+			name: "fraction",
+			op:   `[1.5]`,
+		},
+		{
+			name: "zero",
+			op:   `[0]`,
+		},
+		{
+			name: "empty insert",
+			op:   `[""]`,
+		},
+		{
+			name: "object",
+			op:   `[{}]`,
+		},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			o := &TextOperation{}
+			assert.Equal(t, ErrInvalidOperation, json.Unmarshal([]byte(tcase.op), o))
+		})
+	}
+}
+
+func TestApplyOutOfBounds(t *testing.T) {
+	testCases := []struct {
+		name string
+		op   *TextOperation
+	}{
+		{
+			name: "retain past the end",
+			op:   &TextOperation{ops: []component{{n: 10}}, BaseLen: 5},
+		},
+		{
+			name: "delete past the end",
+			op:   &TextOperation{ops: []component{{n: 2}, {n: -10}}, BaseLen: 5},
+		},
+		{
+			name: "document not consumed",
+			op:   &TextOperation{ops: []component{{n: 2}}, BaseLen: 5},
+		},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			_, err := tcase.op.Apply([]rune("hello"))
+			assert.Equal(t, ErrLengthMismatch, err)
+		})
+	}
+}
+
+// FuzzApply - whatever the client sends, decode and Apply return
+// an error instead of a panic
+func FuzzApply(f *testing.F) {
+	f.Add("hello", `[5, " world"]`)
+	f.Add("hello", `[4611686018427387904, "x", 4611686018427387904, "y", 4611686018427387904, "z", 4611686018427387904]`)
+	f.Add("привет", `[-3, "a", 3]`)
+
+	f.Fuzz(func(t *testing.T, doc string, s string) {
+		o := &TextOperation{}
+		if err := json.Unmarshal([]byte(s), o); err != nil {
+			return
+		}
+		res, err := o.Apply([]rune(doc))
+		if err == nil {
+			assert.Equal(t, o.TargetLen, len(res))
+		}
+	})
+}
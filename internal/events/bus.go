@@ -0,0 +1,184 @@
+package events
+
+import (
+	"context"
+	"noteapp/internal/model"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	historySize = 256
+	chanSize    = 64
+	// historyIdle - the history of a user without subscribers is dropped
+	// after this time without events
+	historyIdle = time.Hour
+)
+
+// Bus - in-process publish/subscribe of user events.
+// The last events of every user are kept in memory, so a client
+// can resume the stream by Last-Event-ID after a reconnect.
+// Idle users are dropped by RunCleanup.
+type Bus struct {
+	mu sync.Mutex
+	// ids start over in every process, the epoch tells them apart
+	epoch   string
+	lastID  int64
+	history map[string][]model.Event
+	// id of the last event dropped from the user history
+	trimmed map[string]int64
+	// time of the last event or unsubscribe of the user
+	active map[string]time.Time
+	// lastID at the last cleanup, events up to it may be dropped
+	// for the users without history
+	evicted int64
+	subs    map[string]map[chan model.Event]struct{}
+}
+
+func NewBus() *Bus {
+	return &Bus{
+		epoch:   strconv.FormatInt(time.Now().UnixNano(), 36),
+		history: map[string][]model.Event{},
+		trimmed: map[string]int64{},
+		active:  map[string]time.Time{},
+		subs:    map[string]map[chan model.Event]struct{}{},
+	}
+}
+
+func (b *Bus) Publish(email string, e model.Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastID++
+	e.Id = b.lastID
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	h, ok := b.history[email]
+	if !ok {
+		// the user may have had a history before the cleanup
+		b.trimmed[email] = b.evicted
+	}
+	h = append(h, e)
+	if len(h) > historySize {
+		b.trimmed[email] = h[len(h)-historySize-1].Id
+		h = h[len(h)-historySize:]
+	}
+	b.history[email] = h
+	b.active[email] = time.Now()
+
+	for ch := range b.subs[email] {
+		select {
+		case ch <- e:
+		default:
+			// slow client - drop it, it will reconnect with Last-Event-ID
+			delete(b.subs[email], ch)
+			close(ch)
+		}
+	}
+}
+
+// EventID returns the id of the event for the client: the epoch of the
+// bus and the number of the event
+func (b *Bus) EventID(id int64) string {
+	return b.epoch + "-" + strconv.FormatInt(id, 10)
+}
+
+// parseEventID returns the number of the event made by EventID of this
+// bus, false - the id is broken or of another process
+func (b *Bus) parseEventID(eventID string) (int64, bool) {
+	epoch, number, ok := strings.Cut(eventID, "-")
+	if !ok || epoch != b.epoch {
+		return 0, false
+	}
+	id, err := strconv.ParseInt(number, 10, 64)
+	return id, err == nil
+}
+
+// Subscribe returns a channel with new events of the user and the events
+// published after lastEventID, an id made before a restart gets the resync
+// event. The channel is closed by cancel or when the subscriber does not
+// keep up with the stream.
+func (b *Bus) Subscribe(email string, lastEventID string) (<-chan model.Event, []model.Event, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	missed := []model.Event{}
+	if lastEventID != "" {
+		lastID, ok := b.parseEventID(lastEventID)
+		trimmed, found := b.trimmed[email]
+		if !found {
+			trimmed = b.evicted
+		}
+		if !ok || lastID > b.lastID || lastID < trimmed {
+			missed = append(missed, model.Event{Id: b.lastID, Type: model.EventResync, Time: time.Now()})
+		} else {
+			for _, e := range b.history[email] {
+				if e.Id > lastID {
+					missed = append(missed, e)
+				}
+			}
+		}
+	}
+
+	ch := make(chan model.Event, chanSize)
+	if b.subs[email] == nil {
+		b.subs[email] = map[chan model.Event]struct{}{}
+	}
+	b.subs[email][ch] = struct{}{}
+
+	cancel := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subs[email][ch]; ok {
+			delete(b.subs[email], ch)
+			close(ch)
+		}
+		if len(b.subs[email]) == 0 {
+			delete(b.subs, email)
+		}
+		b.active[email] = time.Now()
+	}
+
+	return ch, missed, cancel
+}
+
+// RunCleanup drops the users idle for historyIdle every interval
+// until ctx is done
+func (b *Bus) RunCleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		b.cleanup(time.Now().Add(-historyIdle))
+	}
+}
+
+// cleanup drops the history of the users without subscribers who were
+// last active before the time. Their clients get a resync event if they
+// come back with an older Last-Event-ID.
+func (b *Bus) cleanup(before time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	dropped := false
+	for email, at := range b.active {
+		if len(b.subs[email]) == 0 && at.Before(before) {
+			delete(b.history, email)
+			delete(b.trimmed, email)
+			delete(b.active, email)
+			dropped = true
+		}
+	}
+	if dropped {
+		b.evicted = b.lastID
+	}
+}
@@ -0,0 +1,232 @@
+package events
+
+import (
+	"noteapp/internal/model"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBusPublishSubscribe(t *testing.T) {
+	bus := NewBus()
+
+	ch, missed, cancel := bus.Subscribe("user@mail.ru", "")
+	defer cancel()
+	assert.Empty(t, missed)
+
+	bus.Publish("user@mail.ru", model.Event{Type: model.EventNoteCreated, Note_id: 1})
+	bus.Publish("other@mail.ru", model.Event{Type: model.EventNoteCreated, Note_id: 2})
+
+	e := <-ch
+	assert.Equal(t, int64(1), e.Id)
+	assert.Equal(t, 1, e.Note_id)
+	assert.Len(t, ch, 0)
+}
+
+func TestBusResume(t *testing.T) {
+	testCases := []struct {
+		name    string
+		publish int
+		lastID  int64
+		// Last-Event-ID not made by the bus
+		eventID string
+		want    []string
+	}{
+		{
+			name:    "missed events",
+			publish: 3,
+			lastID:  1,
+			want:    []string{model.EventNoteUpdated, model.EventNoteUpdated},
+		},
+		{
+			name:    "nothing missed",
+			publish: 3,
+			lastID:  3,
+			want:    []string{},
+		},
+		{
+			name:    "unknown id after restart",
+			publish: 1,
+			lastID:  10,
+			want:    []string{model.EventResync},
+		},
+		{
+			name:    "history trimmed",
+			publish: historySize + 2,
+			lastID:  1,
+			want:    []string{model.EventResync},
+		},
+		{
+			name:    "id of another process",
+			publish: 3,
+			eventID: "kx9c2-1",
+			want:    []string{model.EventResync},
+		},
+		{
+			name:    "id without epoch",
+			publish: 3,
+			eventID: "1",
+			want:    []string{model.EventResync},
+		},
+		{
+			name:    "broken id",
+			publish: 3,
+			eventID: "-x",
+			want:    []string{model.EventResync},
+		},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			bus := NewBus()
+			for i := 0; i < tcase.publish; i++ {
+				bus.Publish("user@mail.ru", model.Event{Type: model.EventNoteUpdated})
+			}
+
+			eventID := tcase.eventID
+			if eventID == "" {
+				eventID = bus.EventID(tcase.lastID)
+			}
+			_, missed, cancel := bus.Subscribe("user@mail.ru", eventID)
+			defer cancel()
+
+			types := []string{}
+			for _, e := range missed {
+				types = append(types, e.Type)
+			}
+			assert.Equal(t, tcase.want, types)
+		})
+	}
+}
+
+func TestBusRestart(t *testing.T) {
+	before := NewBus()
+	before.Publish("user@mail.ru", model.Event{Type: model.EventNoteUpdated})
+	lastEventID := before.EventID(1)
+
+	// the new process has published the same ids again
+	bus := NewBus()
+	for i := 0; i < 3; i++ {
+		bus.Publish("user@mail.ru", model.Event{Type: model.EventNoteUpdated})
+	}
+
+	_, missed, cancel := bus.Subscribe("user@mail.ru", lastEventID)
+	defer cancel()
+	assert.Len(t, missed, 1)
+	assert.Equal(t, model.EventResync, missed[0].Type)
+	assert.Equal(t, int64(3), missed[0].Id)
+}
+
+func TestBusSlowSubscriber(t *testing.T) {
+	bus := NewBus()
+
+	ch, _, cancel := bus.Subscribe("user@mail.ru", "")
+	defer cancel()
+
+	for i := 0; i < chanSize+1; i++ {
+		bus.Publish("user@mail.ru", model.Event{Type: model.EventNoteUpdated})
+	}
+
+	count := 0
+	for range ch {
+		count++
+	}
+	assert.Equal(t, chanSize, count)
+}
+
+func TestBusCleanup(t *testing.T) {
+	testCases := []struct {
+		name       string
+		publish    int
+		subscribed bool
+		// users active before now+idle are dropped
+		idle time.Duration
+		// events published after the cleanup
+		after      int
+		lastID     int64
+		wantUsers  int
+		wantMissed []string
+	}{
+		{
+			name:       "idle user dropped",
+			publish:    3,
+			idle:       time.Minute,
+			lastID:     3,
+			wantMissed: []string{},
+		},
+		{
+			name:       "user with subscriber kept",
+			publish:    3,
+			subscribed: true,
+			idle:       time.Minute,
+			lastID:     1,
+			wantUsers:  1,
+			wantMissed: []string{model.EventNoteUpdated, model.EventNoteUpdated},
+		},
+		{
+			name:       "active user kept",
+			publish:    3,
+			idle:       -time.Minute,
+			lastID:     1,
+			wantUsers:  1,
+			wantMissed: []string{model.EventNoteUpdated, model.EventNoteUpdated},
+		},
+		{
+			name:       "resume from dropped history",
+			publish:    3,
+			idle:       time.Minute,
+			lastID:     1,
+			wantMissed: []string{model.EventResync},
+		},
+		{
+			name:       "resume from dropped history with new events",
+			publish:    3,
+			idle:       time.Minute,
+			after:      2,
+			lastID:     1,
+			wantUsers:  1,
+			wantMissed: []string{model.EventResync},
+		},
+		{
+			name:       "resume after the cleanup",
+			publish:    3,
+			idle:       time.Minute,
+			after:      2,
+			lastID:     4,
+			wantUsers:  1,
+			wantMissed: []string{model.EventNoteUpdated},
+		},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			bus := NewBus()
+			if tcase.subscribed {
+				_, _, cancel := bus.Subscribe("user@mail.ru", "")
+				defer cancel()
+			}
+			for i := 0; i < tcase.publish; i++ {
+				bus.Publish("user@mail.ru", model.Event{Type: model.EventNoteUpdated})
+			}
+
+			bus.cleanup(time.Now().Add(tcase.idle))
+
+			for i := 0; i < tcase.after; i++ {
+				bus.Publish("user@mail.ru", model.Event{Type: model.EventNoteUpdated})
+			}
+			assert.Len(t, bus.history, tcase.wantUsers)
+			assert.Len(t, bus.trimmed, tcase.wantUsers)
+			assert.Len(t, bus.active, tcase.wantUsers)
+
+			_, missed, cancel := bus.Subscribe("user@mail.ru", bus.EventID(tcase.lastID))
+			defer cancel()
+
+			types := []string{}
+			for _, e := range missed {
+				types = append(types, e.Type)
+			}
+			assert.Equal(t, tcase.wantMissed, types)
+		})
+	}
+}
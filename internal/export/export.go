@@ -0,0 +1,152 @@
+package export
+
+import (
+	"archive/zip"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"noteapp/internal/model"
+)
+
+// maxName - limit of a file or directory name in bytes, most file systems
+// allow 255 and a suffix like " (2).md" has to fit as well
+const maxName = 200
+
+// Archive writes notes to a ZIP as Markdown files, entries are streamed
+// to the underlying writer one by one
+type Archive struct {
+	zw   *zip.Writer
+	used map[string]bool
+}
+
+func NewArchive(w io.Writer) *Archive {
+	return &Archive{
+		zw:   zip.NewWriter(w),
+		used: map[string]bool{},
+	}
+}
+
+// Dir reserves a directory for the group inside parent ("" is the root)
+// and returns its path
+func (a *Archive) Dir(parent string, name string) string {
+	return a.unique(parent, CleanName(name, "Untitled"), "")
+}
+
+// Path reserves a file name inside dir, taken names get a " (2)" suffix
+func (a *Archive) Path(dir string, name string) string {
+	ext := path.Ext(name)
+	if len(ext) > 10 || ext == name {
+		ext = ""
+	}
+	return a.unique(dir, CleanName(strings.TrimSuffix(name, ext), "file"), CleanName(ext, ""))
+}
+
+// AddNote writes the note to dir as "<title>.md" with YAML front matter,
+// attachments are paths relative to the note file. An encrypted note is
+// written as its ciphertext to "Encrypted <id>.md".
+func (a *Archive) AddNote(dir string, note model.Note, tags []string, attachments []string) error {
+	name := CleanName(note.Title, "Untitled")
+	if note.Encrypted {
+		name = "Encrypted " + strconv.Itoa(note.Id)
+	}
+	w, err := a.create(a.Path(dir, name+".md"), note.Updated_at)
+	if err != nil {
+		return err
+	}
+	if _, err = io.WriteString(w, FrontMatter(note, tags, attachments)); err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, note.Text)
+	return err
+}
+
+// AddFile copies r to the archive under the given path
+func (a *Archive) AddFile(name string, modified time.Time, r io.Reader) error {
+	w, err := a.create(name, modified)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, r)
+	return err
+}
+
+// Close writes the ZIP central directory, the underlying writer is not closed
+func (a *Archive) Close() error {
+	return a.zw.Close()
+}
+
+func (a *Archive) create(name string, modified time.Time) (io.Writer, error) {
+	return a.zw.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: modified,
+	})
+}
+
+func (a *Archive) unique(dir string, name string, ext string) string {
+	p := path.Join(dir, name+ext)
+	for i := 2; a.used[strings.ToLower(p)]; i++ {
+		p = path.Join(dir, name+" ("+strconv.Itoa(i)+")"+ext)
+	}
+	a.used[strings.ToLower(p)] = true
+	return p
+}
+
+// FrontMatter returns the YAML header of the exported note
+func FrontMatter(note model.Note, tags []string, attachments []string) string {
+	var b strings.Builder
+	b.WriteString("---\n")
+	b.WriteString("id: " + strconv.Itoa(note.Id) + "\n")
+	b.WriteString("title: " + strconv.Quote(note.Title) + "\n")
+	b.WriteString("created: " + note.Created_at.UTC().Format(time.RFC3339) + "\n")
+	b.WriteString("updated: " + note.Updated_at.UTC().Format(time.RFC3339) + "\n")
+	if note.Encrypted {
+		b.WriteString("encrypted: true\n")
+	}
+	writeList(&b, "tags", tags)
+	writeList(&b, "attachments", attachments)
+	b.WriteString("---\n\n")
+	return b.String()
+}
+
+func writeList(b *strings.Builder, key string, values []string) {
+	if len(values) == 0 {
+		return
+	}
+	b.WriteString(key + ":\n")
+	for _, v := range values {
+		b.WriteString("  - " + strconv.Quote(v) + "\n")
+	}
+}
+
+// CleanName makes the name safe for a path element on common file systems,
+// an empty result is replaced with def
+func CleanName(name string, def string) string {
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r < 32 || r == 127:
+			return -1
+		case strings.ContainsRune(`/\:*?"<>|`, r):
+			return '_'
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+	// "." and ".." are not names, trailing dots are dropped by Windows
+	name = strings.TrimRight(name, ". ")
+
+	if len(name) > maxName {
+		cut := maxName
+		for cut > 0 && name[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		name = name[:cut]
+	}
+	if name == "" {
+		return def
+	}
+	return name
+}
@@ -0,0 +1,123 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"noteapp/internal/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCleanName(t *testing.T) {
+	testCases := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "plain", in: "Meeting notes", want: "Meeting notes"},
+		{name: "separators", in: `a/b\c:d`, want: "a_b_c_d"},
+		{name: "dots", in: "..", want: "def"},
+		{name: "trailing dot", in: "end. ", want: "end"},
+		{name: "control", in: "a\x00b\nc", want: "abc"},
+		{name: "empty", in: "", want: "def"},
+		{name: "long", in: strings.Repeat("я", 150), want: strings.Repeat("я", 100)},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			assert.Equal(t, tcase.want, CleanName(tcase.in, "def"))
+		})
+	}
+}
+
+func TestArchive(t *testing.T) {
+	buf := &bytes.Buffer{}
+	a := NewArchive(buf)
+
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	note := model.Note{Id: 1, Title: `Plan "A"`, Text: "text #work", Created_at: created, Updated_at: created}
+	encrypted := model.Note{Id: 7, Title: "Y2lw/aGVy", Text: "dGV4dA==", Encrypted: true, Created_at: created, Updated_at: created}
+
+	dir := a.Dir("", "Work")
+	assert.Equal(t, "Work", dir)
+	assert.Equal(t, "work (2)", a.Dir("", "work"))
+
+	assert.NoError(t, a.AddNote(dir, note, []string{"work"}, []string{"attachments/a.png"}))
+	assert.NoError(t, a.AddNote(dir, note, nil, nil))
+	assert.NoError(t, a.AddNote(dir, encrypted, nil, nil))
+	assert.Equal(t, "Work/attachments/a.png", a.Path(dir+"/attachments", "a.png"))
+	assert.Equal(t, "Work/attachments/a (2).png", a.Path(dir+"/attachments", "a.png"))
+	assert.NoError(t, a.AddFile("Work/attachments/a.png", created, strings.NewReader("png")))
+	assert.NoError(t, a.Close())
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	assert.NoError(t, err)
+
+	files := map[string]string{}
+	for _, f := range zr.File {
+		r, err := f.Open()
+		assert.NoError(t, err)
+		b, _ := io.ReadAll(r)
+		files[f.Name] = string(b)
+	}
+
+	assert.Equal(t, "---\n"+
+		"id: 1\n"+
+		"title: \"Plan \\\"A\\\"\"\n"+
+		"created: 2024-01-02T03:04:05Z\n"+
+		"updated: 2024-01-02T03:04:05Z\n"+
+		"tags:\n  - \"work\"\n"+
+		"attachments:\n  - \"attachments/a.png\"\n"+
+		"---\n\ntext #work", files["Work/Plan _A_.md"])
+	assert.Contains(t, files, "Work/Plan _A_ (2).md")
+	assert.Equal(t, "---\n"+
+		"id: 7\n"+
+		"title: \"Y2lw/aGVy\"\n"+
+		"created: 2024-01-02T03:04:05Z\n"+
+		"updated: 2024-01-02T03:04:05Z\n"+
+		"encrypted: true\n"+
+		"---\n\ndGV4dA==", files["Work/Encrypted 7.md"])
+	assert.Equal(t, "png", files["Work/attachments/a.png"])
+}
+
+func TestWriteOPML(t *testing.T) {
+	list := model.NoteList{
+		Notes: []model.NoteElement{{Id: 1, Title: "Inbox"}, {Id: 3, Title: "Y2lwaGVy", Encrypted: true}},
+		Groups: []model.GroupElement{{
+			Id:     1,
+			Name:   "Work",
+			Notes:  []model.NoteElement{{Id: 2, Title: "Plan"}},
+			Groups: &[]model.GroupElement{{Id: 2, Name: "Empty"}},
+		}},
+	}
+	texts := map[int]string{1: "a & b\nnext"}
+	read := []int{}
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, WriteOPML(buf, "Notes", list, func(id int) (string, error) {
+		read = append(read, id)
+		return texts[id], nil
+	}))
+	assert.Equal(t, []int{1, 3, 2}, read)
+
+	out := buf.String()
+	assert.True(t, strings.HasPrefix(out, `<?xml version="1.0" encoding="UTF-8"?>`))
+	assert.Contains(t, out, `<opml version="2.0">`)
+	assert.Contains(t, out, `<title>Notes</title>`)
+	assert.Contains(t, out, `<outline text="Inbox" _note="a &amp; b&#xA;next"></outline>`)
+	assert.Contains(t, out, `<outline text="Y2lwaGVy" _note="" _encrypted="true"></outline>`)
+	assert.Contains(t, out, `<outline text="Work">`+"\n"+
+		`      <outline text="Plan" _note=""></outline>`+"\n"+
+		`      <outline text="Empty"></outline>`+"\n"+
+		`    </outline>`)
+
+	errRead := errors.New("read failed")
+	err := WriteOPML(&bytes.Buffer{}, "Notes", list, func(id int) (string, error) { return "", errRead })
+	assert.Equal(t, errRead, err)
+}
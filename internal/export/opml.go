@@ -0,0 +1,104 @@
+package export
+
+import (
+	"encoding/xml"
+	"io"
+	"time"
+
+	"noteapp/internal/model"
+)
+
+// OPML 2.0 document: groups are outlines with children and notes are
+// leaf outlines with the text in "_note" as outliners do. Title and text
+// of an encrypted note are its ciphertext, marked with "_encrypted".
+
+type opmlHead struct {
+	Title       string `xml:"title"`
+	DateCreated string `xml:"dateCreated"`
+}
+
+type opmlOutline struct {
+	Text string `xml:"text,attr"`
+	// set for notes only, even if the text is empty
+	Note      *string `xml:"_note,attr"`
+	Encrypted bool    `xml:"_encrypted,attr,omitempty"`
+}
+
+// TextFunc returns the text of the note
+type TextFunc func(id int) (string, error)
+
+// WriteOPML writes the notes tree as an OPML outline, the order of the
+// list is kept: notes of a group go before its subgroups. Texts are
+// read with text one note at a time and written right away.
+func WriteOPML(w io.Writer, title string, list model.NoteList, text TextFunc) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+
+	root := xml.StartElement{
+		Name: xml.Name{Local: "opml"},
+		Attr: []xml.Attr{{Name: xml.Name{Local: "version"}, Value: "2.0"}},
+	}
+	body := xml.StartElement{Name: xml.Name{Local: "body"}}
+	head := opmlHead{Title: title, DateCreated: time.Now().UTC().Format(time.RFC1123Z)}
+
+	if err := enc.EncodeToken(root); err != nil {
+		return err
+	}
+	if err := enc.EncodeElement(head, xml.StartElement{Name: xml.Name{Local: "head"}}); err != nil {
+		return err
+	}
+	if err := enc.EncodeToken(body); err != nil {
+		return err
+	}
+	if err := writeOutlines(enc, list.Notes, list.Groups, text); err != nil {
+		return err
+	}
+	if err := enc.EncodeToken(body.End()); err != nil {
+		return err
+	}
+	if err := enc.EncodeToken(root.End()); err != nil {
+		return err
+	}
+	if err := enc.Flush(); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+var outlineName = xml.Name{Local: "outline"}
+
+func writeOutlines(enc *xml.Encoder, notes []model.NoteElement, groups []model.GroupElement, text TextFunc) error {
+	for _, n := range notes {
+		t, err := text(n.Id)
+		if err != nil {
+			return err
+		}
+		if err = enc.EncodeElement(opmlOutline{Text: n.Title, Note: &t, Encrypted: n.Encrypted}, xml.StartElement{Name: outlineName}); err != nil {
+			return err
+		}
+	}
+	for _, g := range groups {
+		start := xml.StartElement{
+			Name: outlineName,
+			Attr: []xml.Attr{{Name: xml.Name{Local: "text"}, Value: g.Name}},
+		}
+		if err := enc.EncodeToken(start); err != nil {
+			return err
+		}
+		var sub []model.GroupElement
+		if g.Groups != nil {
+			sub = *g.Groups
+		}
+		if err := writeOutlines(enc, g.Notes, sub, text); err != nil {
+			return err
+		}
+		if err := enc.EncodeToken(start.End()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
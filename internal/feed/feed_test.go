@@ -0,0 +1,55 @@
+package feed
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testFeed() Feed {
+	published := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	updated := time.Date(2024, 2, 3, 4, 5, 6, 0, time.UTC)
+	return Feed{
+		Title:   "Changelog",
+		Link:    "https://notes.example.com/public?token=t&format=html",
+		Self:    "https://notes.example.com/public/feed?token=t",
+		Updated: updated,
+		Entries: []Entry{{
+			Id:        "https://notes.example.com/public?token=t&note_id=1&format=html",
+			Title:     "v1.2 <beta>",
+			Link:      "https://notes.example.com/public?token=t&note_id=1&format=html",
+			Html:      "<p>Fixed &amp; shipped</p>",
+			Published: published,
+			Updated:   updated,
+		}},
+	}
+}
+
+func TestWriteAtom(t *testing.T) {
+	buf := &bytes.Buffer{}
+	assert.NoError(t, WriteAtom(buf, testFeed()))
+
+	out := buf.String()
+	assert.Contains(t, out, `<feed xmlns="http://www.w3.org/2005/Atom">`)
+	assert.Contains(t, out, `<id>https://notes.example.com/public/feed?token=t</id>`)
+	assert.Contains(t, out, `<link href="https://notes.example.com/public/feed?token=t" rel="self" type="application/atom+xml"></link>`)
+	assert.Contains(t, out, `<updated>2024-02-03T04:05:06Z</updated>`)
+	assert.Contains(t, out, `<title>v1.2 &lt;beta&gt;</title>`)
+	assert.Contains(t, out, `<published>2024-01-02T03:04:05Z</published>`)
+	assert.Contains(t, out, `<content type="html">&lt;p&gt;Fixed &amp;amp; shipped&lt;/p&gt;</content>`)
+}
+
+func TestWriteRSS(t *testing.T) {
+	buf := &bytes.Buffer{}
+	assert.NoError(t, WriteRSS(buf, testFeed()))
+
+	out := buf.String()
+	assert.Contains(t, out, `<rss version="2.0" xmlns:atom="http://www.w3.org/2005/Atom">`)
+	assert.Contains(t, out, `<atom:link href="https://notes.example.com/public/feed?token=t" rel="self" type="application/rss+xml"></atom:link>`)
+	assert.Contains(t, out, `<lastBuildDate>Sat, 03 Feb 2024 04:05:06 +0000</lastBuildDate>`)
+	assert.Contains(t, out, `<guid isPermaLink="false">https://notes.example.com/public?token=t&amp;note_id=1&amp;format=html#2024-02-03T04:05:06Z</guid>`)
+	assert.Contains(t, out, `<pubDate>Sat, 03 Feb 2024 04:05:06 +0000</pubDate>`)
+	assert.Contains(t, out, `<description>&lt;p&gt;Fixed &amp;amp; shipped&lt;/p&gt;</description>`)
+}
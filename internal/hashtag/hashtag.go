@@ -0,0 +1,86 @@
+package hashtag
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxLen - longer tags are ignored
+const MaxLen = 100
+
+// Parse returns unique #tags of the text in lower case and in order of
+// appearance. A tag starts after a space or at the line start, must
+// contain a letter and may be nested: #work/project-x. Headings ("# Title"),
+// url fragments and code are not tags.
+func Parse(text string) []string {
+	tags := []string{}
+	seen := map[string]bool{}
+
+	fence := ""
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case fence != "":
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+			continue
+		case strings.HasPrefix(trimmed, "```"):
+			fence = "```"
+			continue
+		case strings.HasPrefix(trimmed, "~~~"):
+			fence = "~~~"
+			continue
+		}
+
+		for _, tag := range parseLine(line) {
+			if !seen[tag] {
+				seen[tag] = true
+				tags = append(tags, tag)
+			}
+		}
+	}
+	return tags
+}
+
+func parseLine(line string) []string {
+	tags := []string{}
+	inCode := false
+	prev := ' '
+
+	for i := 0; i < len(line); {
+		r, size := utf8.DecodeRuneInString(line[i:])
+		if r == '`' {
+			inCode = !inCode
+		}
+		if inCode || r != '#' || !(unicode.IsSpace(prev) || prev == '(') {
+			prev = r
+			i += size
+			continue
+		}
+
+		end := i + 1
+		letter := false
+		for end < len(line) {
+			c, s := utf8.DecodeRuneInString(line[end:])
+			if !isTagRune(c) {
+				break
+			}
+			letter = letter || unicode.IsLetter(c)
+			end += s
+		}
+
+		tag := strings.Trim(line[i+1:end], "/-")
+		if letter && tag != "" && len(tag) <= MaxLen {
+			tags = append(tags, strings.ToLower(tag))
+		}
+		prev = '#'
+		i = max(end, i+1)
+	}
+	return tags
+}
+
+func isTagRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-' || r == '/'
+}
@@ -0,0 +1,37 @@
+package hashtag
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{
+			name: "simple and nested",
+			text: "#Work meeting about #work/project-x (#идеи)",
+			want: []string{"work", "work/project-x", "идеи"},
+		},
+		{
+			name: "not tags",
+			text: "# Heading\nhttp://site.ru/page#anchor #123 issue#5 `#code`",
+			want: []string{},
+		},
+		{
+			name: "code block",
+			text: "```\n#include <stdio.h>\n```\n#c",
+			want: []string{"c"},
+		},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			assert.Equal(t, tcase.want, Parse(tcase.text))
+		})
+	}
+}
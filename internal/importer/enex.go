@@ -0,0 +1,223 @@
+package importer
+
+import (
+	"archive/zip"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"io"
+	"mime"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"noteapp/internal/model"
+)
+
+const enexDate = "20060102T150405Z"
+
+type enexNote struct {
+	Title     string         `xml:"title"`
+	Content   string         `xml:"content"`
+	Created   string         `xml:"created"`
+	Updated   string         `xml:"updated"`
+	Tags      []string       `xml:"tag"`
+	Resources []enexResource `xml:"resource"`
+}
+
+type enexResource struct {
+	Data     string `xml:"data"`
+	Mime     string `xml:"mime"`
+	FileName string `xml:"resource-attributes>file-name"`
+}
+
+// Spool keeps decoded resources out of memory until they are stored,
+// usually it is a temp file
+type Spool struct {
+	w    SpoolFile
+	size int64
+}
+
+type SpoolFile interface {
+	io.Writer
+	io.ReaderAt
+}
+
+func NewSpool(w SpoolFile) *Spool {
+	return &Spool{w: w}
+}
+
+func (s *Spool) add(data []byte) (func() (io.ReadCloser, error), error) {
+	if _, err := s.w.Write(data); err != nil {
+		return nil, err
+	}
+	offset, size := s.size, int64(len(data))
+	s.size += size
+	return func() (io.ReadCloser, error) {
+		return io.NopCloser(io.NewSectionReader(s.w, offset, size)), nil
+	}, nil
+}
+
+// ReadENEX reads an Evernote export. The notebook becomes a folder, ENML
+// content is converted to Markdown, Evernote tags are added as #tags and
+// resources become assets. Notes are decoded one at a time, resources go
+// to the spool.
+func ReadENEX(r io.Reader, notebook string, spool *Spool) (*Folder, []model.ImportError) {
+	errs := []model.ImportError{}
+	folder := &Folder{Name: truncate(notebook)}
+	if folder.Name == "" {
+		folder.Name = "Evernote"
+	}
+
+	d := xml.NewDecoder(r)
+	d.Strict = false
+	d.Entity = xml.HTMLEntity
+
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			errs = append(errs, fileError(notebook, "broken ENEX: "+err.Error()))
+			break
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "note" {
+			continue
+		}
+
+		en := enexNote{}
+		if err = d.DecodeElement(&en, &start); err != nil {
+			errs = append(errs, fileError(notebook, "broken ENEX: "+err.Error()))
+			break
+		}
+
+		n, noteErrs := readENEXNote(en, notebook, spool)
+		errs = append(errs, noteErrs...)
+		if n != nil {
+			folder.Notes = append(folder.Notes, n)
+		}
+	}
+	return folder, errs
+}
+
+func readENEXNote(en enexNote, notebook string, spool *Spool) (*Note, []model.ImportError) {
+	errs := []model.ImportError{}
+	n := &Note{
+		Title:      truncate(cleanText(en.Title)),
+		Created_at: time.Now(),
+		Updated_at: time.Now(),
+	}
+	if n.Title == "" {
+		n.Title = untitled
+	}
+	n.Source = notebook + ": " + n.Title
+
+	if t, err := time.Parse(enexDate, strings.TrimSpace(en.Created)); err == nil {
+		n.Created_at = t
+		n.Updated_at = t
+	}
+	if t, err := time.Parse(enexDate, strings.TrimSpace(en.Updated)); err == nil {
+		n.Updated_at = t
+	}
+
+	// en-media refers to resources by the MD5 of their data
+	resources := map[string]*Asset{}
+	order := []*Asset{}
+	for i, res := range en.Resources {
+		data, err := io.ReadAll(base64.NewDecoder(base64.StdEncoding, strings.NewReader(stripSpaces(res.Data))))
+		if err != nil {
+			errs = append(errs, fileError(n.Source, "broken resource: "+err.Error()))
+			continue
+		}
+		sum := md5.Sum(data)
+
+		a := &Asset{
+			Source: n.Source + ": " + res.FileName,
+			Name:   resourceName(res, i),
+			Size:   int64(len(data)),
+		}
+		if a.Open, err = spool.add(data); err != nil {
+			errs = append(errs, fileError(n.Source, "failed to spool resource"))
+			continue
+		}
+		resources[hex.EncodeToString(sum[:])] = a
+		order = append(order, a)
+	}
+
+	text, err := enmlToMarkdown(cleanText(en.Content), n, resources)
+	if err != nil {
+		errs = append(errs, fileError(n.Source, "broken content: "+err.Error()))
+	}
+
+	// resources which are not shown in the content are not lost
+	var b strings.Builder
+	for _, a := range order {
+		if !slices.Contains(n.Assets, a) {
+			b.WriteString("\n- [" + escapeText(a.Name) + "](<" + n.addAsset(a) + ">)")
+		}
+	}
+	if b.Len() > 0 {
+		text = strings.TrimRight(text, "\n") + "\n\n" + b.String()[1:] + "\n"
+	}
+
+	n.Text = withTags(text, en.Tags)
+	if len(n.Text) > MaxText {
+		errs = append(errs, fileError(n.Source, "text is too long"))
+		return nil, errs
+	}
+	return n, errs
+}
+
+func resourceName(res enexResource, i int) string {
+	name := strings.TrimSpace(strings.ReplaceAll(res.FileName, "\\", "/"))
+	if j := strings.LastIndex(name, "/"); j != -1 {
+		name = name[j+1:]
+	}
+	if name != "" {
+		return name
+	}
+
+	name = "resource-" + strconv.Itoa(i+1)
+	if exts, _ := mime.ExtensionsByType(res.Mime); len(exts) > 0 {
+		name += exts[0]
+	}
+	return name
+}
+
+func stripSpaces(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ' ' || r == '\n' || r == '\r' || r == '\t' {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// ReadENEXArchive reads a ZIP of Evernote exports, every .enex file
+// is a notebook
+func ReadENEXArchive(zr *zip.Reader, spool *Spool) (*Folder, []model.ImportError) {
+	root := &Folder{}
+	errs := []model.ImportError{}
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() || skipped(f.Name) || !strings.EqualFold(path.Ext(f.Name), ".enex") {
+			continue
+		}
+
+		r, err := f.Open()
+		if err != nil {
+			errs = append(errs, fileError(f.Name, "failed to read file"))
+			continue
+		}
+		folder, folderErrs := ReadENEX(r, strings.TrimSuffix(path.Base(f.Name), path.Ext(f.Name)), spool)
+		r.Close()
+
+		root.Folders = append(root.Folders, folder)
+		errs = append(errs, folderErrs...)
+	}
+	return root, errs
+}
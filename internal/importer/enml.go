@@ -0,0 +1,503 @@
+package importer
+
+import (
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// node - element or text (empty name) of parsed ENML
+type node struct {
+	name     string
+	attrs    map[string]string
+	text     string
+	children []*node
+}
+
+var blockElements = map[string]bool{
+	"div": true, "p": true, "pre": true, "blockquote": true, "li": true, "tr": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"ul": true, "ol": true, "table": true, "hr": true, "section": true, "article": true,
+}
+
+// enmlToMarkdown converts the note content (XHTML with en-* elements)
+// to Markdown, en-media become references to the resources. The content
+// read before an error is returned as well.
+func enmlToMarkdown(content string, n *Note, resources map[string]*Asset) (string, error) {
+	root, err := parseENML(content)
+	w := &mdWriter{note: n, resources: resources, lineStart: true}
+	w.children(root)
+	return strings.TrimSpace(w.b.String()) + "\n", err
+}
+
+func parseENML(content string) (*node, error) {
+	d := xml.NewDecoder(strings.NewReader(content))
+	d.Strict = false
+	d.AutoClose = xml.HTMLAutoClose
+	d.Entity = xml.HTMLEntity
+
+	root := &node{name: "root"}
+	stack := []*node{root}
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return root, nil
+		}
+		if err != nil {
+			return root, err
+		}
+
+		top := stack[len(stack)-1]
+		switch t := tok.(type) {
+		case xml.StartElement:
+			el := &node{name: strings.ToLower(t.Name.Local), attrs: map[string]string{}}
+			for _, a := range t.Attr {
+				el.attrs[strings.ToLower(a.Name.Local)] = a.Value
+			}
+			top.children = append(top.children, el)
+			stack = append(stack, el)
+		case xml.EndElement:
+			// unbalanced tags close everything up to the matching one
+			name := strings.ToLower(t.Name.Local)
+			for i := len(stack) - 1; i > 0; i-- {
+				if stack[i].name == name {
+					stack = stack[:i]
+					break
+				}
+			}
+		case xml.CharData:
+			top.children = append(top.children, &node{text: string(t)})
+		}
+	}
+}
+
+type mdWriter struct {
+	b strings.Builder
+	// written at the start of every line: quotes and list indents
+	prefix string
+	// newlines to write before the next text, 2 makes a paragraph
+	breaks    int
+	hardBreak bool
+	lineStart bool
+	// a list marker is written and the item has no content yet
+	afterMarker bool
+	lastSpace   bool
+	lists       []int
+
+	note      *Note
+	resources map[string]*Asset
+}
+
+func (w *mdWriter) gap(n int) {
+	if w.b.Len() > 0 && !w.afterMarker {
+		w.breaks = max(w.breaks, n)
+	}
+}
+
+func (w *mdWriter) blockGap() int {
+	if len(w.lists) > 0 {
+		return 1
+	}
+	return 2
+}
+
+func (w *mdWriter) atLineStart() bool {
+	return w.breaks > 0 || w.lineStart || w.afterMarker
+}
+
+func (w *mdWriter) flush() {
+	if w.breaks > 0 {
+		if w.breaks == 1 && w.hardBreak {
+			w.b.WriteString("\\")
+		}
+		for i := 0; i < w.breaks; i++ {
+			w.b.WriteString("\n")
+			if i < w.breaks-1 {
+				w.b.WriteString(strings.TrimRight(w.prefix, " "))
+			}
+		}
+		w.breaks = 0
+		w.lineStart = true
+	}
+	w.hardBreak = false
+	if w.lineStart {
+		w.b.WriteString(w.prefix)
+		w.lineStart = false
+	}
+}
+
+// write adds Markdown as is
+func (w *mdWriter) write(s string) {
+	if s == "" {
+		return
+	}
+	w.flush()
+	w.b.WriteString(s)
+	w.afterMarker = false
+	w.lastSpace = strings.HasSuffix(s, " ")
+}
+
+// text adds plain text, HTML whitespace rules apply
+func (w *mdWriter) text(s string) {
+	s = collapseSpaces(s)
+
+	if w.atLineStart() || w.lastSpace {
+		s = strings.TrimLeft(s, " ")
+	}
+	if s == "" {
+		return
+	}
+
+	escaped := escapeText(s)
+	if w.atLineStart() {
+		escaped = escapeLineStart(escaped)
+	}
+	w.write(escaped)
+}
+
+func (w *mdWriter) children(n *node) {
+	for _, c := range n.children {
+		w.node(c)
+	}
+}
+
+func (w *mdWriter) node(n *node) {
+	switch n.name {
+	case "":
+		w.text(n.text)
+
+	case "div", "p", "section", "article", "header", "footer":
+		if strings.Contains(n.attrs["style"], "-en-codeblock") {
+			w.codeBlock(textContent(n))
+			return
+		}
+		w.gap(w.blockGap())
+		w.children(n)
+		w.gap(w.blockGap())
+
+	case "br":
+		if !w.atLineStart() {
+			w.hardBreak = true
+			w.gap(1)
+		}
+
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		level, _ := strconv.Atoi(n.name[1:])
+		w.gap(2)
+		if s := w.inline(n); s != "" {
+			w.write(strings.Repeat("#", level) + " " + s)
+		}
+		w.gap(2)
+
+	case "b", "strong":
+		w.wrap("**", n)
+	case "i", "em":
+		w.wrap("*", n)
+	case "s", "strike", "del":
+		w.wrap("~~", n)
+
+	case "code":
+		code := strings.ReplaceAll(textContent(n), "\n", " ")
+		if strings.TrimSpace(code) == "" {
+			return
+		}
+		ticks := "`"
+		for strings.Contains(code, ticks) {
+			ticks += "`"
+		}
+		if strings.HasPrefix(code, "`") || strings.HasSuffix(code, "`") {
+			code = " " + code + " "
+		}
+		w.write(ticks + code + ticks)
+
+	case "pre":
+		w.codeBlock(textContent(n))
+
+	case "a":
+		label := w.inline(n)
+		href := cleanURL(n.attrs["href"])
+		if href == "" || label == "" {
+			w.write(label)
+			return
+		}
+		w.write("[" + label + "](<" + href + ">)")
+
+	case "img":
+		src := cleanURL(n.attrs["src"])
+		if src == "" || strings.HasPrefix(src, "data:") {
+			return
+		}
+		w.write("![" + escapeText(n.attrs["alt"]) + "](<" + src + ">)")
+
+	case "en-media":
+		a := w.resources[strings.ToLower(n.attrs["hash"])]
+		if a == nil {
+			return
+		}
+		link := "[" + escapeText(a.Name) + "](<" + w.note.addAsset(a) + ">)"
+		if strings.HasPrefix(n.attrs["type"], "image/") {
+			link = "!" + link
+		}
+		w.write(link)
+
+	case "en-todo":
+		mark := "[ ] "
+		if n.attrs["checked"] == "true" {
+			mark = "[x] "
+		}
+		if !w.afterMarker && w.atLineStart() {
+			mark = "- " + mark
+		}
+		w.write(mark)
+
+	case "en-crypt":
+		w.write("*\\[encrypted content\\]*")
+
+	case "hr":
+		w.gap(2)
+		w.write("---")
+		w.gap(2)
+
+	case "blockquote":
+		w.gap(2)
+		prefix := w.prefix
+		w.prefix += "> "
+		w.children(n)
+		w.prefix = prefix
+		w.gap(2)
+
+	case "ul", "ol":
+		w.gap(w.blockGap())
+		start := 0
+		if n.name == "ol" {
+			start = 1
+		}
+		w.lists = append(w.lists, start)
+		w.children(n)
+		w.lists = w.lists[:len(w.lists)-1]
+		w.gap(w.blockGap())
+
+	case "li":
+		marker := "- "
+		if len(w.lists) > 0 && w.lists[len(w.lists)-1] > 0 {
+			marker = strconv.Itoa(w.lists[len(w.lists)-1]) + ". "
+			w.lists[len(w.lists)-1]++
+		}
+		w.gap(1)
+		w.write(marker)
+		w.afterMarker = true
+		prefix := w.prefix
+		w.prefix += strings.Repeat(" ", len(marker))
+		w.children(n)
+		w.prefix = prefix
+		w.afterMarker = false
+		w.gap(1)
+
+	case "table":
+		w.table(n)
+
+	case "script", "style", "title", "head", "object", "embed":
+		// not content
+
+	default:
+		w.children(n)
+	}
+}
+
+// inline renders the children of n to a single line
+func (w *mdWriter) inline(n *node) string {
+	return strings.TrimSpace(w.inlineRaw(n))
+}
+
+func (w *mdWriter) inlineRaw(n *node) string {
+	sub := &mdWriter{note: w.note, resources: w.resources}
+	sub.children(n)
+	s := strings.ReplaceAll(sub.b.String(), "\\\n", " ")
+	return strings.ReplaceAll(s, "\n", " ")
+}
+
+// wrap adds emphasis, the markers must touch the text: "a **b** c"
+func (w *mdWriter) wrap(marker string, n *node) {
+	raw := w.inlineRaw(n)
+	s := strings.TrimSpace(raw)
+	if s == "" {
+		w.text(raw)
+		return
+	}
+	if strings.HasPrefix(raw, " ") {
+		w.text(" ")
+	}
+	w.write(marker + s + marker)
+	if strings.HasSuffix(raw, " ") {
+		w.text(" ")
+	}
+}
+
+func (w *mdWriter) codeBlock(code string) {
+	code = strings.Trim(strings.ReplaceAll(code, "\u00a0", " "), "\n")
+	if strings.TrimSpace(code) == "" {
+		return
+	}
+	fence := "```"
+	for strings.Contains(code, fence) {
+		fence += "`"
+	}
+
+	w.gap(2)
+	w.write(fence)
+	for _, line := range strings.Split(code, "\n") {
+		w.breaks = 1
+		w.flush()
+		w.b.WriteString(line)
+	}
+	w.breaks = 1
+	w.write(fence)
+	w.gap(2)
+}
+
+func (w *mdWriter) table(n *node) {
+	rows := [][]string{}
+	cols := 0
+	var collect func(n *node)
+	collect = func(n *node) {
+		for _, c := range n.children {
+			switch c.name {
+			case "tr":
+				row := []string{}
+				for _, cell := range c.children {
+					if cell.name == "td" || cell.name == "th" {
+						row = append(row, strings.ReplaceAll(w.inline(cell), "|", "\\|"))
+					}
+				}
+				cols = max(cols, len(row))
+				rows = append(rows, row)
+			case "table":
+				// nested tables are flattened
+				collect(c)
+			default:
+				if c.name != "" {
+					collect(c)
+				}
+			}
+		}
+	}
+	collect(n)
+	if cols == 0 {
+		return
+	}
+
+	w.gap(2)
+	for i, row := range rows {
+		for len(row) < cols {
+			row = append(row, "")
+		}
+		w.breaks = max(w.breaks, min(i, 1))
+		w.write("| " + strings.Join(row, " | ") + " |")
+		if i == 0 {
+			w.breaks = 1
+			w.write("|" + strings.Repeat(" --- |", cols))
+		}
+	}
+	w.gap(2)
+}
+
+// textContent returns the text of n, lines of block elements kept
+func textContent(n *node) string {
+	var b strings.Builder
+	var walk func(n *node)
+	walk = func(n *node) {
+		switch {
+		case n.name == "":
+			b.WriteString(n.text)
+			return
+		case n.name == "br":
+			b.WriteString("\n")
+			return
+		}
+		for _, c := range n.children {
+			walk(c)
+		}
+		if blockElements[n.name] && b.Len() > 0 && !strings.HasSuffix(b.String(), "\n") {
+			b.WriteString("\n")
+		}
+	}
+	walk(n)
+	return b.String()
+}
+
+func collapseSpaces(s string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range s {
+		if unicode.IsSpace(r) {
+			space = true
+			continue
+		}
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		b.WriteRune(r)
+	}
+	if space {
+		b.WriteByte(' ')
+	}
+	return b.String()
+}
+
+// escapeText makes the text literal for Markdown, only characters
+// which may start inline syntax are escaped to keep the text readable
+func escapeText(s string) string {
+	var b strings.Builder
+	prev := ' '
+	runes := []rune(s)
+	for i, r := range runes {
+		escape := strings.ContainsRune("\\`*[]<~", r)
+		// snake_case is not emphasis
+		if r == '_' {
+			next := ' '
+			if i+1 < len(runes) {
+				next = runes[i+1]
+			}
+			escape = !isWordRune(prev) || !isWordRune(next)
+		}
+		if escape {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+		prev = r
+	}
+	return b.String()
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// escapeLineStart keeps the line from becoming a heading, quote, list or rule
+func escapeLineStart(s string) string {
+	if s == "" {
+		return s
+	}
+	if strings.ContainsRune("#>-+=", rune(s[0])) {
+		return "\\" + s
+	}
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	if i > 0 && i < len(s) && (s[i] == '.' || s[i] == ')') {
+		return s[:i] + "\\" + s[i:]
+	}
+	return s
+}
+
+func cleanURL(u string) string {
+	u = strings.TrimSpace(u)
+	if strings.ContainsAny(u, "<>\n") {
+		return ""
+	}
+	return u
+}
@@ -0,0 +1,120 @@
+package importer
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// splitFrontMatter separates the YAML header delimited by "---" lines.
+// Only the subset used by Obsidian and static site generators is
+// understood: "key: value", "key: [a, b]" and lists of "- item" lines.
+func splitFrontMatter(text string) (map[string][]string, string) {
+	text = strings.TrimPrefix(text, "\ufeff")
+	head, rest, ok := strings.Cut(text, "\n")
+	if !ok || strings.TrimSpace(head) != "---" {
+		return nil, text
+	}
+
+	fields := map[string][]string{}
+	key := ""
+	offset := len(head) + 1
+	for _, line := range strings.SplitAfter(rest, "\n") {
+		offset += len(line)
+		trimmed := strings.TrimSpace(line)
+
+		if trimmed == "---" || trimmed == "..." {
+			return fields, strings.TrimLeft(text[offset:], "\r\n")
+		}
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		if item, ok := strings.CutPrefix(trimmed, "- "); ok && key != "" {
+			fields[key] = append(fields[key], unquote(item))
+			continue
+		}
+
+		k, v, ok := strings.Cut(trimmed, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(k))
+		v = strings.TrimSpace(v)
+		switch {
+		case v == "":
+			fields[key] = nil
+		case strings.HasPrefix(v, "[") && strings.HasSuffix(v, "]"):
+			for _, item := range strings.Split(v[1:len(v)-1], ",") {
+				if item = unquote(item); item != "" {
+					fields[key] = append(fields[key], item)
+				}
+			}
+		default:
+			fields[key] = []string{unquote(v)}
+		}
+	}
+
+	// no closing line, it was not a header
+	return nil, text
+}
+
+func unquote(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) < 2 {
+		return s
+	}
+	switch {
+	case s[0] == '"' && s[len(s)-1] == '"':
+		if u, err := strconv.Unquote(s); err == nil {
+			return u
+		}
+		return s[1 : len(s)-1]
+	case s[0] == '\'' && s[len(s)-1] == '\'':
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'")
+	}
+	return s
+}
+
+// first returns the first value of the first present key
+func first(fields map[string][]string, keys ...string) string {
+	for _, k := range keys {
+		if v := fields[k]; len(v) > 0 {
+			return v[0]
+		}
+	}
+	return ""
+}
+
+func parseDate(s string) (time.Time, bool) {
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// tagsOf returns the tags of the header, "tags: a, b" and "tags: a b"
+// are accepted as well as lists
+func tagsOf(fields map[string][]string) []string {
+	tags := []string{}
+	for _, key := range []string{"tags", "tag"} {
+		for _, v := range fields[key] {
+			for _, t := range strings.FieldsFunc(v, func(r rune) bool { return r == ',' || r == ' ' }) {
+				if t = strings.TrimPrefix(strings.TrimSpace(t), "#"); t != "" {
+					tags = append(tags, t)
+				}
+			}
+		}
+	}
+	return tags
+}
@@ -0,0 +1,118 @@
+package importer
+
+import (
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"noteapp/internal/model"
+)
+
+const (
+	// MaxName - length limit of groups.name and notes.title
+	MaxName = 100
+	// MaxText - length limit of notes.text
+	MaxText = 10 << 20
+
+	untitled = "Untitled"
+)
+
+// Folder - group to create with its notes, the root folder of the tree
+// is not created itself
+type Folder struct {
+	Name    string
+	Folders []*Folder
+	Notes   []*Note
+}
+
+// Note - note to create. Text refers to the assets with placeholders,
+// see Resolve.
+type Note struct {
+	// file the note comes from, used in errors
+	Source     string
+	Title      string
+	Text       string
+	Created_at time.Time
+	Updated_at time.Time
+	// files referenced by the text, the same asset may belong to several notes
+	Assets []*Asset
+}
+
+// Asset - file to store as an attachment
+type Asset struct {
+	Source string
+	Name   string
+	Size   int64
+	Open   func() (io.ReadCloser, error)
+}
+
+// placeholders are built from NUL, which can't appear in notes.text
+const placeholderMark = "\x00"
+
+// addAsset returns the placeholder of the asset inside the note text
+func (n *Note) addAsset(a *Asset) string {
+	i := 0
+	for i < len(n.Assets) && n.Assets[i] != a {
+		i++
+	}
+	if i == len(n.Assets) {
+		n.Assets = append(n.Assets, a)
+	}
+	return placeholderMark + strconv.Itoa(i) + placeholderMark
+}
+
+// Resolve returns the text with asset placeholders replaced by url(asset),
+// an empty url leaves the asset name
+func (n *Note) Resolve(url func(a *Asset) string) string {
+	parts := strings.Split(n.Text, placeholderMark)
+	// placeholders are at odd positions
+	for i := 1; i < len(parts); i += 2 {
+		idx, err := strconv.Atoi(parts[i])
+		if err != nil || idx >= len(n.Assets) {
+			parts[i] = ""
+			continue
+		}
+		a := n.Assets[idx]
+		if u := url(a); u != "" {
+			parts[i] = u
+		} else {
+			parts[i] = a.Name
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+// Walk calls fn for every note of the tree
+func (f *Folder) Walk(fn func(n *Note)) {
+	for _, n := range f.Notes {
+		fn(n)
+	}
+	for _, sub := range f.Folders {
+		sub.Walk(fn)
+	}
+}
+
+// cleanText makes the text storable: valid UTF-8 without NUL
+func cleanText(text string) string {
+	text = strings.ToValidUTF8(text, "\uFFFD")
+	return strings.ReplaceAll(text, "\x00", "")
+}
+
+// truncate cuts the name to MaxName bytes without breaking a rune
+func truncate(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) <= MaxName {
+		return s
+	}
+	cut := MaxName
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return strings.TrimSpace(s[:cut])
+}
+
+func fileError(file string, msg string) model.ImportError {
+	return model.ImportError{File: file, Error: msg}
+}
@@ -0,0 +1,278 @@
+package importer
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func makeZip(t *testing.T, files map[string]string) *zip.Reader {
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		assert.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, zw.Close())
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	assert.NoError(t, err)
+	return zr
+}
+
+func TestSplitFrontMatter(t *testing.T) {
+	testCases := []struct {
+		name   string
+		text   string
+		fields map[string][]string
+		body   string
+	}{
+		{
+			name: "none",
+			text: "# Title\ntext",
+			body: "# Title\ntext",
+		},
+		{
+			name: "scalars and lists",
+			text: "---\ntitle: \"Plan: A\"\ntags: [work, 'it''s']\naliases:\n  - one\n  - two\n---\n\nbody",
+			fields: map[string][]string{
+				"title":   {"Plan: A"},
+				"tags":    {"work", "it's"},
+				"aliases": {"one", "two"},
+			},
+			body: "body",
+		},
+		{
+			name: "not closed",
+			text: "---\ntitle: x\nbody",
+			body: "---\ntitle: x\nbody",
+		},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			fields, body := splitFrontMatter(tcase.text)
+			if tcase.fields == nil {
+				assert.Nil(t, fields)
+			} else {
+				assert.Equal(t, tcase.fields, fields)
+			}
+			assert.Equal(t, tcase.body, body)
+		})
+	}
+}
+
+func TestReadMarkdown(t *testing.T) {
+	zr := makeZip(t, map[string]string{
+		"Vault/.obsidian/app.json":       "{}",
+		"Vault/Inbox.md":                 "---\ntitle: My Inbox\ntags: [todo]\ncreated: 2024-01-02\n---\nsee [[Projects/Plan#Goals|plan]] and [[Missing]]",
+		"Vault/Projects/Plan.md":         "![[diagram.png|300]] and ![photo](../img/a%20b.jpg) [back](../Inbox.md)\n`[[Inbox]]`",
+		"Vault/Projects/diagram.png":     "png",
+		"Vault/img/a b.jpg":              "jpg",
+		"Vault/Projects/Deep/Empty.md":   "",
+		"Vault/Projects/Deep/Broken.txt": "not referenced",
+	})
+
+	root, errs := ReadMarkdown(zr)
+	assert.Empty(t, errs)
+
+	assert.Len(t, root.Folders, 1)
+	vault := root.Folders[0]
+	assert.Equal(t, "Vault", vault.Name)
+	assert.Len(t, vault.Notes, 1)
+	assert.Len(t, vault.Folders, 1)
+	projects := vault.Folders[0]
+	assert.Equal(t, "Projects", projects.Name)
+	assert.Equal(t, "Deep", projects.Folders[0].Name)
+	assert.Equal(t, "Empty", projects.Folders[0].Notes[0].Title)
+
+	inbox := vault.Notes[0]
+	assert.Equal(t, "My Inbox", inbox.Title)
+	assert.Equal(t, time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), inbox.Created_at)
+	assert.Equal(t, "see [[Plan#Goals|plan]] and [[Missing]]\n\n#todo\n", inbox.Text)
+
+	plan := projects.Notes[0]
+	assert.Len(t, plan.Assets, 2)
+	// Markdown references are converted before wiki links
+	assert.Equal(t, "a b.jpg", plan.Assets[0].Name)
+	assert.Equal(t, "diagram.png", plan.Assets[1].Name)
+
+	text := plan.Resolve(func(a *Asset) string {
+		if a.Name == "diagram.png" {
+			return "/getAttachment?id=1"
+		}
+		return ""
+	})
+	assert.Equal(t, "![diagram.png](</getAttachment?id=1>) and ![photo](<a b.jpg>) [[My Inbox|back]]\n`[[Inbox]]`", text)
+}
+
+func TestReadMarkdownLimits(t *testing.T) {
+	defer func(files int, total int64) { maxFiles, maxTotalText = files, total }(maxFiles, maxTotalText)
+
+	testCases := []struct {
+		name      string
+		maxFiles  int
+		maxTotal  int64
+		wantNotes []string
+		wantErr   string
+	}{
+		{
+			name:      "within limits",
+			maxFiles:  10,
+			maxTotal:  100,
+			wantNotes: []string{"a", "b", "c"},
+		},
+		{
+			name:      "too many files",
+			maxFiles:  2,
+			maxTotal:  100,
+			wantNotes: []string{"a", "b"},
+			wantErr:   "c.md: too many files in the archive, the rest is skipped",
+		},
+		{
+			name:      "texts over the budget",
+			maxFiles:  10,
+			maxTotal:  25,
+			wantNotes: []string{"a", "b"},
+			wantErr:   "c.md: archive is too large, the rest is skipped",
+		},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			maxFiles, maxTotalText = tcase.maxFiles, tcase.maxTotal
+			zr := makeZip(t, map[string]string{
+				"a.md": strings.Repeat("a", 10),
+				"b.md": strings.Repeat("b", 10),
+				"c.md": strings.Repeat("c", 10),
+			})
+
+			root, errs := ReadMarkdown(zr)
+
+			titles := []string{}
+			for _, n := range root.Notes {
+				titles = append(titles, n.Title)
+			}
+			assert.Equal(t, tcase.wantNotes, titles)
+			if tcase.wantErr == "" {
+				assert.Empty(t, errs)
+				return
+			}
+			assert.Len(t, errs, 1)
+			assert.Equal(t, tcase.wantErr, errs[0].File+": "+errs[0].Error)
+		})
+	}
+}
+
+func TestReadENEX(t *testing.T) {
+	png := "\x89PNG\r\n\x1a\nimage"
+	sum := md5.Sum([]byte(png))
+	content := `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE en-note SYSTEM "http://xml.evernote.com/pub/enml2.dtd">
+<en-note><h1>Plan</h1><div>Some <b>bold </b>and <i>em</i>&nbsp;text, 5 * 2</div>` +
+		`<div><en-todo checked="true"/>done</div><div><en-todo/>todo</div>` +
+		`<ul><li>one<ul><li>two</li></ul></li></ul><ol><li>first</li><li>second</li></ol>` +
+		`<div>line<br/>next</div><div><a href="https://example.com">link</a></div>` +
+		`<table><tr><td>a</td><td>b|c</td></tr><tr><td>1</td><td>2</td></tr></table>` +
+		`<en-media hash="` + hex.EncodeToString(sum[:]) + `" type="image/png"/></en-note>`
+
+	enex := `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE en-export SYSTEM "http://xml.evernote.com/pub/evernote-export3.dtd">
+<en-export application="Evernote" version="10">
+<note><title>Plan &amp; goals</title>
+<created>20200102T030405Z</created><updated>20210102T030405Z</updated>
+<tag>work</tag><tag>big plans</tag>
+<content><![CDATA[` + content + `]]></content>
+<resource><data encoding="base64">
+` + base64.StdEncoding.EncodeToString([]byte(png)) + `
+</data><mime>image/png</mime><resource-attributes><file-name>photo.png</file-name></resource-attributes></resource>
+<resource><data encoding="base64">` + base64.StdEncoding.EncodeToString([]byte("pdf")) + `</data><mime>application/pdf</mime></resource>
+</note>
+<note><title></title><content><![CDATA[<en-note>broken <b>content</en-note>]]></content></note>
+</en-export>`
+
+	spoolFile, err := os.CreateTemp(t.TempDir(), "spool")
+	assert.NoError(t, err)
+	defer spoolFile.Close()
+
+	folder, errs := ReadENEX(strings.NewReader(enex), "Work", NewSpool(spoolFile))
+	assert.Empty(t, errs)
+	assert.Equal(t, "Work", folder.Name)
+	assert.Len(t, folder.Notes, 2)
+
+	n := folder.Notes[0]
+	assert.Equal(t, "Plan & goals", n.Title)
+	assert.Equal(t, time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), n.Created_at)
+	assert.Equal(t, time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC), n.Updated_at)
+	assert.Len(t, n.Assets, 2)
+	assert.Equal(t, "photo.png", n.Assets[0].Name)
+	assert.Equal(t, "resource-2.pdf", n.Assets[1].Name)
+
+	r, err := n.Assets[0].Open()
+	assert.NoError(t, err)
+	data, _ := io.ReadAll(r)
+	assert.Equal(t, png, string(data))
+
+	text := n.Resolve(func(a *Asset) string { return "/a/" + a.Name })
+	assert.Equal(t, "# Plan\n\n"+
+		"Some **bold** and *em* text, 5 \\* 2\n\n"+
+		"- [x] done\n\n"+
+		"- [ ] todo\n\n"+
+		"- one\n  - two\n\n"+
+		"1. first\n2. second\n\n"+
+		"line\\\nnext\n\n"+
+		"[link](<https://example.com>)\n\n"+
+		"| a | b\\|c |\n| --- | --- |\n| 1 | 2 |\n\n"+
+		"![photo.png](</a/photo.png>)\n\n"+
+		"- [resource-2.pdf](</a/resource-2.pdf>)\n\n"+
+		"#work #big-plans\n", text)
+
+	assert.Equal(t, "Untitled", folder.Notes[1].Title)
+	assert.Equal(t, "broken **content**\n", folder.Notes[1].Text)
+}
+
+func TestReadOPML(t *testing.T) {
+	doc := `<?xml version="1.0" encoding="UTF-8"?>
+<opml version="2.0"><head><title>Outline</title></head><body>
+<outline text="Inbox" _note="a &amp; b&#xA;next" created="Tue, 02 Jan 2024 03:04:05 +0000"/>
+<outline text="Work" _note="about work">
+  <outline text="Plan"/>
+  <outline text="" title="Ideas"><outline text="One"/></outline>
+</outline>
+</body></opml>`
+
+	root, errs := ReadOPML(strings.NewReader(doc), "outline.opml")
+	assert.Empty(t, errs)
+
+	assert.Len(t, root.Notes, 1)
+	assert.Equal(t, "Inbox", root.Notes[0].Title)
+	assert.Equal(t, "a & b\nnext", root.Notes[0].Text)
+	assert.Equal(t, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), root.Notes[0].Created_at.UTC())
+
+	assert.Len(t, root.Folders, 1)
+	work := root.Folders[0]
+	assert.Equal(t, "Work", work.Name)
+	assert.Len(t, work.Notes, 2)
+	assert.Equal(t, "Work", work.Notes[0].Title)
+	assert.Equal(t, "about work", work.Notes[0].Text)
+	assert.Equal(t, "Plan", work.Notes[1].Title)
+	assert.Equal(t, "outline.opml/Work/Plan", work.Notes[1].Source)
+
+	assert.Len(t, work.Folders, 1)
+	assert.Equal(t, "Ideas", work.Folders[0].Name)
+	assert.Equal(t, "One", work.Folders[0].Notes[0].Title)
+
+	_, errs = ReadOPML(strings.NewReader("<opml><body><outline"), "broken.opml")
+	assert.Len(t, errs, 1)
+}
@@ -0,0 +1,369 @@
+package importer
+
+import (
+	"archive/zip"
+	"io"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"noteapp/internal/hashtag"
+	"noteapp/internal/model"
+	"noteapp/internal/wikilink"
+)
+
+// ![alt](path "title"), [text](path.md) and the same with <path>
+var mdLinkRe = regexp.MustCompile(`(!?)\[([^\]\n]*)\]\((?:<([^>\n]+)>|([^)\s]+))(?:\s+"[^"\n]*")?\)`)
+
+// limits of one archive: the texts of all notes are kept in memory until
+// the links are converted, MaxText alone lets a small ZIP expand to gigabytes
+var (
+	maxFiles     = 10000
+	maxTotalText = int64(100 << 20)
+)
+
+// vault - files of the archive indexed the way Obsidian resolves links:
+// by the full path or, for unique names, by the name alone
+type vault struct {
+	notes       map[string]*Note
+	notesByKey  map[string]*Note
+	assets      map[string]*Asset
+	assetsByKey map[string]*Asset
+}
+
+// ReadMarkdown reads a ZIP of Markdown files, an Obsidian vault for example.
+// Directories become folders and .md files notes. The YAML front matter
+// gives titles, tags and dates, [[wiki links]] by file path are turned into
+// links by title and local files referenced by notes become their assets.
+// Files which can't be read are reported and skipped, so are the files
+// over the limits of the archive.
+func ReadMarkdown(zr *zip.Reader) (*Folder, []model.ImportError) {
+	errs := []model.ImportError{}
+	root := &Folder{}
+	folders := map[string]*Folder{"": root}
+	v := &vault{
+		notes:       map[string]*Note{},
+		notesByKey:  map[string]*Note{},
+		assets:      map[string]*Asset{},
+		assetsByKey: map[string]*Asset{},
+	}
+
+	files := make([]*zip.File, 0, len(zr.File))
+	for _, f := range zr.File {
+		if !f.FileInfo().IsDir() && !skipped(f.Name) {
+			files = append(files, f)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	if len(files) > maxFiles {
+		errs = append(errs, fileError(files[maxFiles].Name, "too many files in the archive, the rest is skipped"))
+		files = files[:maxFiles]
+	}
+	// the size in the header is not trusted, the read bytes are counted
+	budget := maxTotalText
+
+	// the text is converted after all files are known
+	bodies := map[*Note]string{}
+	paths := map[*Note]string{}
+
+	for _, f := range files {
+		name := path.Clean(strings.ReplaceAll(f.Name, "\\", "/"))
+		if !utf8.ValidString(name) {
+			errs = append(errs, fileError(f.Name, "file name is not UTF-8"))
+			continue
+		}
+
+		if !isMarkdown(name) {
+			a := &Asset{Source: name, Name: path.Base(name), Size: int64(f.UncompressedSize64), Open: f.Open}
+			v.assets[strings.ToLower(name)] = a
+			addUnique(v.assetsByKey, strings.ToLower(path.Base(name)), a)
+			continue
+		}
+
+		if f.UncompressedSize64 > MaxText {
+			errs = append(errs, fileError(name, "file is too large"))
+			continue
+		}
+		text, err := readFile(f, min(MaxText, budget))
+		if err != nil {
+			errs = append(errs, fileError(name, "failed to read file"))
+			continue
+		}
+		if int64(len(text)) > budget {
+			errs = append(errs, fileError(name, "archive is too large, the rest is skipped"))
+			break
+		}
+		budget -= int64(len(text))
+
+		fields, body := splitFrontMatter(cleanText(text))
+		n := &Note{
+			Source:     name,
+			Title:      truncate(first(fields, "title")),
+			Created_at: modified(f.Modified),
+			Updated_at: modified(f.Modified),
+		}
+		if n.Title == "" {
+			n.Title = truncate(strings.TrimSuffix(path.Base(name), path.Ext(name)))
+		}
+		if n.Title == "" {
+			n.Title = untitled
+		}
+		if t, ok := parseDate(first(fields, "created", "date")); ok {
+			n.Created_at = t
+			n.Updated_at = t
+		}
+		if t, ok := parseDate(first(fields, "updated", "modified", "lastmod")); ok {
+			n.Updated_at = t
+		}
+		bodies[n] = withTags(body, tagsOf(fields))
+		paths[n] = name
+
+		key := strings.ToLower(strings.TrimSuffix(name, path.Ext(name)))
+		v.notes[key] = n
+		addUnique(v.notesByKey, path.Base(key), n)
+
+		dir := folderOf(folders, path.Dir(name))
+		dir.Notes = append(dir.Notes, n)
+	}
+
+	root.Walk(func(n *Note) {
+		n.Text = v.convert(n, path.Dir(paths[n]), bodies[n])
+		if len(n.Text) > MaxText {
+			errs = append(errs, fileError(n.Source, "text is too long, cut"))
+			n.Text = n.Text[:MaxText]
+			for len(n.Text) > 0 && !utf8.ValidString(n.Text) {
+				n.Text = n.Text[:len(n.Text)-1]
+			}
+		}
+	})
+
+	return root, errs
+}
+
+// convert rewrites local references of the text: images and files become
+// assets, links to .md files become [[wiki links]] by title
+func (v *vault) convert(n *Note, dir string, text string) string {
+	text = replaceOutsideCode(text, mdLinkRe, func(m []string) string {
+		label, target := m[2], m[3]+m[4]
+		if isExternal(target) {
+			return m[0]
+		}
+		if unescaped, err := url.PathUnescape(target); err == nil {
+			target = unescaped
+		}
+		target, _, _ = strings.Cut(target, "#")
+
+		if isMarkdown(target) {
+			if linked := v.findNote(dir, target); linked != nil {
+				return "[[" + linked.Title + aliasOf(label, linked.Title) + "]]"
+			}
+			return m[0]
+		}
+		if a := v.findAsset(dir, target); a != nil {
+			return m[1] + "[" + label + "](<" + n.addAsset(a) + ">)"
+		}
+		return m[0]
+	})
+
+	var b strings.Builder
+	last := 0
+	for _, l := range wikilink.Parse(text) {
+		if l.Title == "" {
+			continue
+		}
+		start := l.Start
+		embed := start > 0 && text[start-1] == '!'
+		if embed {
+			start--
+		}
+
+		var repl string
+		if a := v.findAsset(dir, l.Title); a != nil && !isMarkdown(l.Title) {
+			label := l.Alias
+			if label == "" || isSize(label) {
+				label = a.Name
+			}
+			repl = "[" + label + "](<" + n.addAsset(a) + ">)"
+			if embed {
+				repl = "!" + repl
+			}
+		} else if linked := v.findNote(dir, l.Title); linked != nil {
+			repl = "[[" + linked.Title
+			if l.Heading != "" {
+				repl += "#" + l.Heading
+			}
+			repl += aliasOf(l.Alias, linked.Title) + "]]"
+		} else {
+			continue
+		}
+
+		b.WriteString(text[last:start])
+		b.WriteString(repl)
+		last = l.End
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// findNote resolves the target like Obsidian: from the vault root, relative
+// to the note and at last by the unique file name
+func (v *vault) findNote(dir string, target string) *Note {
+	key := strings.ToLower(strings.TrimSuffix(target, path.Ext(target)))
+	if !isMarkdown(target) {
+		key = strings.ToLower(target)
+	}
+	if n := v.notes[path.Clean(key)]; n != nil {
+		return n
+	}
+	if n := v.notes[strings.ToLower(path.Join(dir, key))]; n != nil {
+		return n
+	}
+	return v.notesByKey[path.Base(key)]
+}
+
+func (v *vault) findAsset(dir string, target string) *Asset {
+	key := strings.ToLower(target)
+	if a := v.assets[strings.ToLower(path.Join(dir, key))]; a != nil {
+		return a
+	}
+	if a := v.assets[path.Clean(key)]; a != nil {
+		return a
+	}
+	return v.assetsByKey[path.Base(key)]
+}
+
+// addUnique indexes the value by the key, names seen twice are ambiguous
+// and map to nil
+func addUnique[T any](m map[string]*T, key string, val *T) {
+	if _, ok := m[key]; ok {
+		m[key] = nil
+		return
+	}
+	m[key] = val
+}
+
+func folderOf(folders map[string]*Folder, dir string) *Folder {
+	if dir == "." {
+		dir = ""
+	}
+	if f, ok := folders[dir]; ok {
+		return f
+	}
+	parent := folderOf(folders, path.Dir(dir))
+	f := &Folder{Name: truncate(path.Base(dir))}
+	if f.Name == "" {
+		f.Name = untitled
+	}
+	parent.Folders = append(parent.Folders, f)
+	folders[dir] = f
+	return f
+}
+
+// withTags appends tags of the front matter missing in the text as #tags
+func withTags(text string, tags []string) string {
+	present := map[string]bool{}
+	for _, t := range hashtag.Parse(text) {
+		present[t] = true
+	}
+
+	line := ""
+	for _, t := range tags {
+		t = strings.ReplaceAll(t, " ", "-")
+		if present[strings.ToLower(t)] || len(hashtag.Parse("#"+t)) == 0 {
+			continue
+		}
+		present[strings.ToLower(t)] = true
+		line += " #" + t
+	}
+	if line == "" {
+		return text
+	}
+	return strings.TrimRight(text, "\n") + "\n\n" + line[1:] + "\n"
+}
+
+// replaceOutsideCode is ReplaceAllStringSubmatchFunc which keeps fenced
+// code blocks and inline code as they are
+func replaceOutsideCode(text string, re *regexp.Regexp, fn func(m []string) string) string {
+	var b strings.Builder
+	fence := ""
+	for _, line := range strings.SplitAfter(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case fence != "":
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+		case strings.HasPrefix(trimmed, "```"):
+			fence = "```"
+		case strings.HasPrefix(trimmed, "~~~"):
+			fence = "~~~"
+		default:
+			// odd parts of the split are inside backticks
+			parts := strings.Split(line, "`")
+			for i := 0; i < len(parts); i += 2 {
+				parts[i] = re.ReplaceAllStringFunc(parts[i], func(s string) string {
+					return fn(re.FindStringSubmatch(s))
+				})
+			}
+			line = strings.Join(parts, "`")
+		}
+		b.WriteString(line)
+	}
+	return b.String()
+}
+
+// readFile reads at most limit+1 bytes, so a longer file is seen
+func readFile(f *zip.File, limit int64) (string, error) {
+	r, err := f.Open()
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	b, err := io.ReadAll(io.LimitReader(r, limit+1))
+	return string(b), err
+}
+
+// skipped - hidden files, Obsidian settings and macOS archive garbage
+func skipped(name string) bool {
+	for _, elem := range strings.Split(name, "/") {
+		if strings.HasPrefix(elem, ".") || elem == "__MACOSX" {
+			return true
+		}
+	}
+	return false
+}
+
+func isMarkdown(name string) bool {
+	ext := strings.ToLower(path.Ext(name))
+	return ext == ".md" || ext == ".markdown"
+}
+
+func isExternal(target string) bool {
+	// "https://", "mailto:" and other schemes
+	return strings.Contains(target, ":") || strings.HasPrefix(target, "/") || strings.HasPrefix(target, "#")
+}
+
+// isSize - Obsidian uses the alias of an embedded image as its size: ![[a.png|300]]
+func isSize(s string) bool {
+	return strings.Trim(s, "0123456789x") == ""
+}
+
+func aliasOf(label string, title string) string {
+	if label == "" || label == title {
+		return ""
+	}
+	return "|" + label
+}
+
+// modified returns the time of the file, archivers may leave it empty
+func modified(t time.Time) time.Time {
+	if t.IsZero() || t.Year() < 1990 {
+		return time.Now()
+	}
+	return t
+}
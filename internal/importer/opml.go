@@ -0,0 +1,96 @@
+package importer
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+	"time"
+
+	"noteapp/internal/model"
+)
+
+type opmlOutline struct {
+	Text     string        `xml:"text,attr"`
+	Title    string        `xml:"title,attr"`
+	Note     string        `xml:"_note,attr"`
+	Created  string        `xml:"created,attr"`
+	Outlines []opmlOutline `xml:"outline"`
+}
+
+// ReadOPML reads an OPML outline: outlines with children become folders,
+// leaf outlines become notes with the "_note" attribute as the text. An
+// outline with both children and "_note" becomes a folder with a note
+// of the same title inside.
+func ReadOPML(r io.Reader, name string) (*Folder, []model.ImportError) {
+	errs := []model.ImportError{}
+	root := &Folder{}
+
+	doc := struct {
+		Outlines []opmlOutline `xml:"body>outline"`
+	}{}
+	d := xml.NewDecoder(r)
+	d.Strict = false
+	d.Entity = xml.HTMLEntity
+	if err := d.Decode(&doc); err != nil {
+		errs = append(errs, fileError(name, "broken OPML: "+err.Error()))
+		return root, errs
+	}
+
+	var walk func(f *Folder, list []opmlOutline, path string)
+	walk = func(f *Folder, list []opmlOutline, path string) {
+		for _, o := range list {
+			title := truncate(cleanText(o.Text))
+			if title == "" {
+				title = truncate(cleanText(o.Title))
+			}
+			if title == "" {
+				title = untitled
+			}
+			source := path + "/" + title
+
+			if len(o.Outlines) == 0 {
+				if n := readOPMLNote(o, title, source, &errs); n != nil {
+					f.Notes = append(f.Notes, n)
+				}
+				continue
+			}
+
+			sub := &Folder{Name: title}
+			if o.Note != "" {
+				if n := readOPMLNote(o, title, source, &errs); n != nil {
+					sub.Notes = append(sub.Notes, n)
+				}
+			}
+			walk(sub, o.Outlines, source)
+			f.Folders = append(f.Folders, sub)
+		}
+	}
+	walk(root, doc.Outlines, name)
+	return root, errs
+}
+
+func readOPMLNote(o opmlOutline, title string, source string, errs *[]model.ImportError) *Note {
+	text := cleanText(o.Note)
+	if len(text) > MaxText {
+		*errs = append(*errs, fileError(source, "text is too long"))
+		return nil
+	}
+
+	n := &Note{
+		Source:     source,
+		Title:      title,
+		Text:       text,
+		Created_at: time.Now(),
+		Updated_at: time.Now(),
+	}
+	// OPML dates are RFC 822, the year may have two digits
+	created := strings.TrimSpace(o.Created)
+	for _, layout := range []string{time.RFC1123Z, time.RFC1123, time.RFC822Z, time.RFC822} {
+		if t, err := time.Parse(layout, created); err == nil {
+			n.Created_at = t
+			n.Updated_at = t
+			break
+		}
+	}
+	return n
+}
@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+// thumbnails state of an attachment
+const (
+	ThumbnailsNone    = "none"
+	ThumbnailsPending = "pending"
+	ThumbnailsReady   = "ready"
+	ThumbnailsFailed  = "failed"
+)
+
+type Attachment struct {
+	Id           int       `json:"id"`
+	Owner_email  string    `json:"-"`
+	Note_id      int       `json:"note_id"`
+	Blob_key     string    `json:"-"`
+	Name         string    `json:"name"`
+	Content_type string    `json:"content_type"`
+	Size         int64     `json:"size"`
+	Thumbnails   string    `json:"thumbnails"`
+	Created_at   time.Time `json:"created_at"`
+}
@@ -0,0 +1,143 @@
+package model
+
+import "time"
+
+// BackupVersion - version of the backup schema, restore accepts
+// this one and older. 2 added the sections from "templates" to "pins".
+const BackupVersion = 2
+
+// Backup sections are written in this order, restore relies on it:
+//
+//	{"version": 2, "created_at": ..., "user": {...}, "keys": {...}, "groups": [...],
+//	 "notes": [...], "shares": [...], "public_links": [...], "templates": [...],
+//	 "journal": {...}, "journal_days": [...], "comments": [...], "reminders": [...],
+//	 "notifications": [...], "favorites": [...], "pins": [...], "attachments": [...]}
+//
+// Ids are the ids of the source instance, restore remaps them. "keys" are
+// the UserKeys of the encrypted notes, null if the user has none, the same
+// for the "journal" settings.
+
+type BackupUser struct {
+	Email string `json:"email"`
+}
+
+type BackupGroup struct {
+	Id         int       `json:"id"`
+	Pid        int       `json:"pid,omitempty"`
+	Name       string    `json:"name"`
+	Created_at time.Time `json:"created_at"`
+	Updated_at time.Time `json:"updated_at"`
+}
+
+type BackupNote struct {
+	Id       int    `json:"id"`
+	Group_id int    `json:"group_id,omitempty"`
+	Title    string `json:"title"`
+	Text     string `json:"text"`
+	// title and text are ciphertext, they are restored as they are
+	Encrypted  bool      `json:"encrypted,omitempty"`
+	Created_at time.Time `json:"created_at"`
+	Updated_at time.Time `json:"updated_at"`
+}
+
+type BackupShare struct {
+	User_email string    `json:"user_email"`
+	Note_id    int       `json:"note_id,omitempty"`
+	Group_id   int       `json:"group_id,omitempty"`
+	Permission string    `json:"permission"`
+	Created_at time.Time `json:"created_at"`
+}
+
+// BackupPublicLink - tokens are not kept, restored links get new ones
+type BackupPublicLink struct {
+	Note_id  int `json:"note_id,omitempty"`
+	Group_id int `json:"group_id,omitempty"`
+	// bcrypt hash
+	Password   string     `json:"password,omitempty"`
+	Expires_at *time.Time `json:"expires_at,omitempty"`
+	Feed       bool       `json:"feed,omitempty"`
+	Views      int        `json:"views"`
+	Created_at time.Time  `json:"created_at"`
+}
+
+// BackupAttachment - the content is inline, base64 in JSON
+type BackupAttachment struct {
+	Id           int       `json:"id"`
+	Note_id      int       `json:"note_id"`
+	Name         string    `json:"name"`
+	Content_type string    `json:"content_type"`
+	Created_at   time.Time `json:"created_at"`
+	Data         []byte    `json:"data"`
+}
+
+type BackupTemplate struct {
+	Id         int       `json:"id"`
+	Name       string    `json:"name"`
+	Title      string    `json:"title"`
+	Text       string    `json:"text"`
+	Created_at time.Time `json:"created_at"`
+	Updated_at time.Time `json:"updated_at"`
+}
+
+// BackupJournal - settings of the journal, a restore keeps the settings
+// the account already has
+type BackupJournal struct {
+	Group_id    int    `json:"group_id,omitempty"`
+	Template_id int    `json:"template_id,omitempty"`
+	Builtin     string `json:"builtin"`
+}
+
+type BackupJournalDay struct {
+	// YYYY-MM-DD
+	Day     string `json:"day"`
+	Note_id int    `json:"note_id"`
+}
+
+// BackupComment - comments on the notes of the user, replies go after
+// their parents
+type BackupComment struct {
+	Id           int        `json:"id"`
+	Note_id      int        `json:"note_id"`
+	Parent_id    int        `json:"parent_id,omitempty"`
+	Author_email string     `json:"author_email"`
+	Text         string     `json:"text"`
+	Anchor_start *int       `json:"anchor_start,omitempty"`
+	Anchor_end   *int       `json:"anchor_end,omitempty"`
+	Anchor_text  string     `json:"anchor_text,omitempty"`
+	Resolved     bool       `json:"resolved,omitempty"`
+	Resolved_by  string     `json:"resolved_by,omitempty"`
+	Resolved_at  *time.Time `json:"resolved_at,omitempty"`
+	Created_at   time.Time  `json:"created_at"`
+	Updated_at   time.Time  `json:"updated_at"`
+}
+
+type BackupReminder struct {
+	Id         int        `json:"id"`
+	Note_id    int        `json:"note_id"`
+	Message    string     `json:"message"`
+	Due_at     time.Time  `json:"due_at"`
+	Remind_at  time.Time  `json:"remind_at"`
+	Fired_at   *time.Time `json:"fired_at,omitempty"`
+	Created_at time.Time  `json:"created_at"`
+}
+
+type BackupNotification struct {
+	Note_id     int       `json:"note_id"`
+	Reminder_id int       `json:"reminder_id,omitempty"`
+	Title       string    `json:"title"`
+	Message     string    `json:"message"`
+	Due_at      time.Time `json:"due_at"`
+	Read        bool      `json:"read,omitempty"`
+	Created_at  time.Time `json:"created_at"`
+}
+
+type BackupFavorite struct {
+	Note_id    int       `json:"note_id,omitempty"`
+	Group_id   int       `json:"group_id,omitempty"`
+	Created_at time.Time `json:"created_at"`
+}
+
+type BackupPin struct {
+	Note_id    int       `json:"note_id"`
+	Created_at time.Time `json:"created_at"`
+}
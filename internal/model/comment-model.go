@@ -0,0 +1,39 @@
+package model
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrValidationComment = errors.New("invalid comment, text is required, max text = 10000, anchor must be a range of the note text")
+)
+
+// Comment - comment on a note. Comments without Parent_id start threads,
+// only threads are anchored and resolved. The anchor is a range of
+// characters of the note text at the time of the comment, Anchor_text
+// is the quoted text, so clients can find it after the note changes.
+type Comment struct {
+	Id           int        `json:"id"`
+	Note_id      int        `json:"note_id"`
+	Parent_id    int        `json:"parent_id,omitempty"`
+	Author_email string     `json:"author_email"`
+	Text         string     `json:"text"`
+	Anchor_start *int       `json:"anchor_start,omitempty"`
+	Anchor_end   *int       `json:"anchor_end,omitempty"`
+	Anchor_text  string     `json:"anchor_text,omitempty"`
+	Resolved     bool       `json:"resolved"`
+	Resolved_by  string     `json:"resolved_by,omitempty"`
+	Resolved_at  *time.Time `json:"resolved_at,omitempty"`
+	Created_at   time.Time  `json:"created_at"`
+	Updated_at   time.Time  `json:"updated_at"`
+	// thread: replies in order of creation
+	Replies []Comment `json:"replies,omitempty"`
+}
+
+func (c *Comment) Validate() error {
+	if c.Text == "" || len([]rune(c.Text)) > 10000 {
+		return ErrValidationComment
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package model
+
+import "time"
+
+const (
+	EventNoteCreated  = "note.created"
+	EventNoteUpdated  = "note.updated"
+	EventNoteDeleted  = "note.deleted"
+	EventGroupCreated = "group.created"
+	EventGroupUpdated = "group.updated"
+	EventGroupMoved   = "group.moved"
+	EventGroupDeleted = "group.deleted"
+	// EventReminder - a reminder of the note fired, Title is the note title
+	EventReminder = "reminder.fired"
+	// EventResync - events were lost (too old Last-Event-ID or server restart),
+	// the client has to reload the whole list
+	EventResync = "resync"
+)
+
+type Event struct {
+	Id       int64     `json:"id"`
+	Type     string    `json:"type"`
+	Note_id  int       `json:"note_id,omitempty"`
+	Group_id int       `json:"group_id,omitempty"`
+	Pid      int       `json:"pid,omitempty"`
+	Title    string    `json:"title,omitempty"`
+	Actor    string    `json:"actor,omitempty"`
+	Time     time.Time `json:"time"`
+}
@@ -0,0 +1,28 @@
+package model
+
+import "time"
+
+// Favorites - notes and groups marked by the user, own and shared ones,
+// the last marked first
+type Favorites struct {
+	Notes  []FavoriteNote  `json:"notes"`
+	Groups []FavoriteGroup `json:"groups"`
+}
+
+type FavoriteNote struct {
+	Note_id     int       `json:"note_id"`
+	Title       string    `json:"note_title"`
+	Group_id    int       `json:"group_id,omitempty"`
+	Owner_email string    `json:"owner_email"`
+	Pinned      bool      `json:"pinned"`
+	Encrypted   bool      `json:"encrypted"`
+	Created_at  time.Time `json:"created_at"`
+}
+
+type FavoriteGroup struct {
+	Group_id    int       `json:"group_id"`
+	Name        string    `json:"group_name"`
+	Pid         int       `json:"pid,omitempty"`
+	Owner_email string    `json:"owner_email"`
+	Created_at  time.Time `json:"created_at"`
+}
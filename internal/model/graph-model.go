@@ -0,0 +1,57 @@
+package model
+
+// node and edge types of the notes graph
+const (
+	GraphNote  = "note"
+	GraphGroup = "group"
+	GraphTag   = "tag"
+
+	GraphEdgeLink     = "link"
+	GraphEdgeContains = "contains"
+	GraphEdgeTagged   = "tagged"
+)
+
+// GraphNode - id is "<type>:<id or tag>", e.g. "note:12", "tag:work"
+type GraphNode struct {
+	Id     string `json:"id"`
+	Type   string `json:"type"`
+	Label  string `json:"label"`
+	Degree int    `json:"degree"`
+	// label of a note node is the ciphertext of its title
+	Encrypted bool `json:"encrypted,omitempty"`
+}
+
+type GraphEdge struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Type   string `json:"type"`
+}
+
+type Graph struct {
+	Nodes     []GraphNode `json:"nodes"`
+	Edges     []GraphEdge `json:"edges"`
+	Truncated bool        `json:"truncated"`
+}
+
+// GraphQuery - Note_id and Depth select the neighborhood of the note,
+// Tag and Group_id leave only matching notes
+type GraphQuery struct {
+	Note_id  int
+	Depth    int
+	Types    []string
+	Tag      string
+	Group_id int
+	Limit    int
+}
+
+type GraphLink struct {
+	Source_id int
+	Target_id int
+}
+
+// NoteTags - #tags parsed from the text of the note when it had Version
+type NoteTags struct {
+	Note_id int
+	Version int
+	Tags    []string
+}
@@ -0,0 +1,21 @@
+package model
+
+// ImportError - a file of the archive which was skipped or imported partially
+type ImportError struct {
+	File  string `json:"file"`
+	Error string `json:"error"`
+}
+
+// ImportReport - result of an import or a restore, shares and public
+// links are restored from backups only
+type ImportReport struct {
+	Groups       int           `json:"groups"`
+	Notes        int           `json:"notes"`
+	Attachments  int           `json:"attachments"`
+	Shares       int           `json:"shares,omitempty"`
+	Public_links int           `json:"public_links,omitempty"`
+	Templates    int           `json:"templates,omitempty"`
+	Comments     int           `json:"comments,omitempty"`
+	Reminders    int           `json:"reminders,omitempty"`
+	Errors       []ImportError `json:"errors"`
+}
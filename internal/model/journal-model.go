@@ -0,0 +1,22 @@
+package model
+
+// DateLayout - format of the days of the journal
+const DateLayout = "2006-01-02"
+
+// Journal - settings of the daily notes. Group_id 0 - the "Journal" group
+// at the root, created on first use. Notes are made from the template
+// Template_id or, if it is 0, from the built-in template Builtin.
+type Journal struct {
+	Group_id    int    `json:"group_id"`
+	Template_id int    `json:"template_id"`
+	Builtin     string `json:"builtin"`
+}
+
+// DailyNote - note of the day with the nearest days that have notes,
+// "" - there is no such day
+type DailyNote struct {
+	Date     string `json:"date"`
+	Note     Note   `json:"note"`
+	Previous string `json:"previous"`
+	Next     string `json:"next"`
+}
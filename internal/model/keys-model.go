@@ -0,0 +1,75 @@
+package model
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+const (
+	KdfArgon2id = "argon2id"
+	KdfPBKDF2   = "pbkdf2-sha256"
+
+	// MaxEncryptedTitle - length limit of the ciphertext of a title
+	MaxEncryptedTitle = 1000
+	// MaxSalt and MaxWrappedKey - length limits of the columns of user_keys
+	MaxSalt       = 200
+	MaxWrappedKey = 2000
+)
+
+var (
+	ErrValidationKeys = errors.New("invalid keys, kdf must be argon2id or pbkdf2-sha256, kdf_params a JSON object, salt and keys base64")
+	ErrKeysVersion    = errors.New("keys were changed by another client, reload them")
+	ErrNoKeys         = errors.New("encryption is not set up")
+	// ErrEncryptedNote - the feature needs the text the server can not read
+	ErrEncryptedNote = errors.New("the note is end-to-end encrypted")
+)
+
+// UserKeys - key material of the end-to-end encrypted notes. The client
+// derives a key from the passphrase of the user with Kdf, Kdf_params and
+// Salt, and unwraps Wrapped_key with it, Recovery_wrapped_key is the same
+// key wrapped by the recovery key. The server stores them as they are.
+// Version is the version the client has read, 0 - there were no keys.
+type UserKeys struct {
+	User_email           string          `json:"-"`
+	Kdf                  string          `json:"kdf"`
+	Kdf_params           json.RawMessage `json:"kdf_params"`
+	Salt                 string          `json:"salt"`
+	Wrapped_key          string          `json:"wrapped_key"`
+	Recovery_wrapped_key string          `json:"recovery_wrapped_key"`
+	Version              int             `json:"version"`
+	Updated_at           time.Time       `json:"updated_at"`
+}
+
+func (k *UserKeys) Validate() error {
+	if k.Kdf != KdfArgon2id && k.Kdf != KdfPBKDF2 {
+		return ErrValidationKeys
+	}
+
+	params := map[string]any{}
+	if len(k.Kdf_params) > 500 || json.Unmarshal(k.Kdf_params, &params) != nil {
+		return ErrValidationKeys
+	}
+
+	fields := []struct {
+		value string
+		max   int
+	}{
+		{k.Salt, MaxSalt},
+		{k.Wrapped_key, MaxWrappedKey},
+		{k.Recovery_wrapped_key, MaxWrappedKey},
+	}
+	for _, f := range fields {
+		if len(f.value) > f.max {
+			return ErrValidationKeys
+		}
+		if _, err := base64.StdEncoding.DecodeString(f.value); err != nil {
+			return ErrValidationKeys
+		}
+	}
+	if k.Salt == "" || k.Wrapped_key == "" {
+		return ErrValidationKeys
+	}
+	return nil
+}
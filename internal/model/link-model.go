@@ -0,0 +1,21 @@
+package model
+
+// NoteLink - [[wiki link]] from one note to another
+type NoteLink struct {
+	Id           int    `json:"id"`
+	Source_id    int    `json:"source_id"`
+	Target_id    int    `json:"target_id,omitempty"`
+	Target_title string `json:"target_title,omitempty"`
+	Title        string `json:"title,omitempty"`
+	Ref_id       int    `json:"ref_id,omitempty"`
+	Heading      string `json:"heading,omitempty"`
+	Alias        string `json:"alias,omitempty"`
+	Broken       bool   `json:"broken"`
+}
+
+// Backlink - note which links to the requested one
+type Backlink struct {
+	Note_id  int    `json:"note_id"`
+	Title    string `json:"title"`
+	Group_id int    `json:"group_id"`
+}
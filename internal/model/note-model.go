@@ -1,16 +1,26 @@
 package model
 
+import "time"
+
 type NoteElement struct {
 	Id    int    `json:"note_id"`
 	Title string `json:"note_title"`
 	Text  string `json:"note_text"`
+	// marks of the user who reads the list
+	Pinned   bool `json:"pinned"`
+	Favorite bool `json:"favorite"`
+	// number of comments on the note
+	Comments int `json:"comments"`
+	// title and text are ciphertext of the client
+	Encrypted bool `json:"encrypted"`
 }
 
 type GroupElement struct {
-	Id     int             `json:"group_id"`
-	Name   string          `json:"group_name"`
-	Groups *[]GroupElement `json:"groups"`
-	Notes  []NoteElement   `json:"notes"`
+	Id       int             `json:"group_id"`
+	Name     string          `json:"group_name"`
+	Favorite bool            `json:"favorite"`
+	Groups   *[]GroupElement `json:"groups"`
+	Notes    []NoteElement   `json:"notes"`
 }
 
 type NoteList struct {
@@ -19,15 +29,28 @@ type NoteList struct {
 }
 
 type Note struct {
-	Id         int    `json:"id"`
-	User_email string `json:"user_email"`
-	Title      string `json:"title"`
-	Text       string `json:"text"`
-	Group_id   int    `json:"group_id"`
+	Id         int       `json:"id"`
+	User_email string    `json:"user_email"`
+	Title      string    `json:"title"`
+	Text       string    `json:"text"`
+	Group_id   int       `json:"group_id"`
+	Created_at time.Time `json:"created_at"`
+	Updated_at time.Time `json:"updated_at"`
+	Version    int       `json:"version"`
+	Comments   int       `json:"comments"`
+	// title and text are ciphertext of the client, the server
+	// does not index or render them
+	Encrypted bool `json:"encrypted"`
+	// rendered text, only when requested with format=html
+	Html string `json:"html,omitempty"`
 }
 
 type Group struct {
-	Id         int    `json:"id"`
-	Name       string `json:"name"`
-	User_email string `json:"user_email"`
+	Id         int       `json:"id"`
+	Name       string    `json:"name"`
+	User_email string    `json:"user_email"`
+	Pid        int       `json:"pid"`
+	Created_at time.Time `json:"created_at"`
+	Updated_at time.Time `json:"updated_at"`
+	Version    int       `json:"version"`
 }
@@ -0,0 +1,60 @@
+package model
+
+import (
+	"errors"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrLinkPassword = errors.New("wrong link password")
+)
+
+type PublicLink struct {
+	Id          int        `json:"id"`
+	Token       string     `json:"token"`
+	Owner_email string     `json:"-"`
+	Note_id     int        `json:"note_id,omitempty"`
+	Group_id    int        `json:"group_id,omitempty"`
+	Password    string     `json:"-"`
+	Protected   bool       `json:"protected"`
+	Expires_at  *time.Time `json:"expires_at,omitempty"`
+	// group links only: the subtree is available as an Atom/RSS feed
+	Feed       bool      `json:"feed"`
+	Views      int       `json:"views"`
+	Created_at time.Time `json:"created_at"`
+}
+
+func (l *PublicLink) EncryptPassword() error {
+	if l.Password == "" {
+		return nil
+	}
+	data, err := bcrypt.GenerateFromPassword([]byte(l.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	l.Password = string(data)
+	l.Protected = true
+	return nil
+}
+
+func (l *PublicLink) ComparePassword(password string) error {
+	if l.Password == "" {
+		return nil
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(l.Password), []byte(password)); err != nil {
+		return ErrLinkPassword
+	}
+	return nil
+}
+
+func (l *PublicLink) Expired() bool {
+	return l.Expires_at != nil && time.Now().After(*l.Expires_at)
+}
+
+// PublicContent - what an unauthenticated visitor receives by a public link
+type PublicContent struct {
+	Note  *Note         `json:"note,omitempty"`
+	Group *GroupElement `json:"group,omitempty"`
+}
@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+// NoteActivity - the last view and edit of the note by the user,
+// zero - none
+type NoteActivity struct {
+	User_email string
+	Note_id    int
+	Viewed_at  time.Time
+	Edited_at  time.Time
+}
+
+// RecentNote - note of the recently viewed or edited list, At is the time
+// of the last view or edit
+type RecentNote struct {
+	Note_id     int       `json:"note_id"`
+	Title       string    `json:"note_title"`
+	Group_id    int       `json:"group_id,omitempty"`
+	Owner_email string    `json:"owner_email"`
+	Encrypted   bool      `json:"encrypted"`
+	At          time.Time `json:"at"`
+}
@@ -0,0 +1,48 @@
+package model
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrValidationReminder = errors.New("invalid reminder, max message = 500, remind_at must not be after due_at")
+)
+
+// Reminder - due date of a note for one user, the scheduler notifies the
+// user at Remind_at (Due_at by default) and sets Fired_at
+type Reminder struct {
+	Id         int        `json:"id"`
+	Note_id    int        `json:"note_id"`
+	User_email string     `json:"-"`
+	Title      string     `json:"title"`
+	Message    string     `json:"message"`
+	Due_at     time.Time  `json:"due_at"`
+	Remind_at  time.Time  `json:"remind_at"`
+	Fired_at   *time.Time `json:"fired_at,omitempty"`
+	Created_at time.Time  `json:"created_at"`
+}
+
+func (r *Reminder) Validate() error {
+	if r.Remind_at.IsZero() {
+		r.Remind_at = r.Due_at
+	}
+	if r.Due_at.IsZero() || r.Remind_at.After(r.Due_at) || len(r.Message) > 500 {
+		return ErrValidationReminder
+	}
+	return nil
+}
+
+// Notification - fired reminder, it is kept in the in-app list of the user
+// and sent to the other notifiers
+type Notification struct {
+	Id          int       `json:"id"`
+	User_email  string    `json:"user_email"`
+	Note_id     int       `json:"note_id"`
+	Reminder_id int       `json:"reminder_id,omitempty"`
+	Title       string    `json:"title"`
+	Message     string    `json:"message"`
+	Due_at      time.Time `json:"due_at"`
+	Read        bool      `json:"read"`
+	Created_at  time.Time `json:"created_at"`
+}
@@ -0,0 +1,45 @@
+package model
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	PermissionRead = "read"
+	PermissionEdit = "edit"
+)
+
+var (
+	ErrValidationPermission = errors.New("invalid permission, expected read or edit")
+)
+
+type Share struct {
+	Id          int       `json:"id"`
+	Owner_email string    `json:"owner_email"`
+	User_email  string    `json:"user_email"`
+	Note_id     int       `json:"note_id,omitempty"`
+	Group_id    int       `json:"group_id,omitempty"`
+	Permission  string    `json:"permission"`
+	Created_at  time.Time `json:"created_at"`
+}
+
+func (s *Share) ValidatePermission() error {
+	if s.Permission != PermissionRead && s.Permission != PermissionEdit {
+		return ErrValidationPermission
+	}
+	return nil
+}
+
+// SharedElement - a note or a whole group subtree shared with the current user
+type SharedElement struct {
+	Share_id    int           `json:"share_id"`
+	Owner_email string        `json:"owner_email"`
+	Permission  string        `json:"permission"`
+	Note        *NoteElement  `json:"note,omitempty"`
+	Group       *GroupElement `json:"group,omitempty"`
+}
+
+type SharedList struct {
+	Elements []SharedElement `json:"shared"`
+}
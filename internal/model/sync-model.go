@@ -0,0 +1,69 @@
+package model
+
+const (
+	EntityNote  = "note"
+	EntityGroup = "group"
+
+	ActionUpsert = "upsert"
+	ActionDelete = "delete"
+
+	ActionCreate = "create"
+	ActionUpdate = "update"
+
+	SyncApplied  = "applied"
+	SyncConflict = "conflict"
+	SyncError    = "error"
+)
+
+// Change - record of the change log, cursor of the sync is the Id
+type Change struct {
+	Id        int64  `json:"id"`
+	Entity    string `json:"entity"`
+	Entity_id int    `json:"entity_id"`
+	Action    string `json:"action"`
+}
+
+// SyncPull - state of everything changed after the client cursor
+// Resync - the changes after the cursor are pruned, the client drops its
+// copy and pulls again from cursor 0.
+type SyncPull struct {
+	Cursor         int64   `json:"cursor"`
+	Has_more       bool    `json:"has_more"`
+	Resync         bool    `json:"resync"`
+	Notes          []Note  `json:"notes"`
+	Groups         []Group `json:"groups"`
+	Deleted_notes  []int   `json:"deleted_notes"`
+	Deleted_groups []int   `json:"deleted_groups"`
+}
+
+// SyncChange - change made by the client offline.
+// Client_id identifies the change in the results, objects created in the
+// same batch can be referenced by it in Group_client_id and Pid_client_id.
+// Version is the version of the object the client has changed.
+type SyncChange struct {
+	Client_id       string  `json:"client_id"`
+	Entity          string  `json:"entity"`
+	Action          string  `json:"action"`
+	Id              int     `json:"id"`
+	Version         int     `json:"version"`
+	Title           *string `json:"title"`
+	Text            *string `json:"text"`
+	Encrypted       *bool   `json:"encrypted"`
+	Group_id        *int    `json:"group_id"`
+	Group_client_id string  `json:"group_client_id"`
+	Name            *string `json:"name"`
+	Pid             *int    `json:"pid"`
+	Pid_client_id   string  `json:"pid_client_id"`
+}
+
+// SyncResult - result of one client change. On conflict Note or Group
+// holds the server state, both empty means the object was deleted on the server.
+type SyncResult struct {
+	Client_id string `json:"client_id"`
+	Entity    string `json:"entity"`
+	Id        int    `json:"id"`
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+	Note      *Note  `json:"note,omitempty"`
+	Group     *Group `json:"group,omitempty"`
+}
@@ -0,0 +1,23 @@
+package model
+
+// Task - "- [ ] text" item of a note, Line is the line in the note text
+// from 1. Due is a date in DateLayout, "" - no due date.
+type Task struct {
+	Id         int    `json:"id"`
+	Note_id    int    `json:"note_id"`
+	Note_title string `json:"note_title"`
+	Group_id   int    `json:"group_id,omitempty"`
+	Line       int    `json:"line"`
+	Text       string `json:"text"`
+	Done       bool   `json:"done"`
+	Due        string `json:"due,omitempty"`
+}
+
+// TaskFilter - filter of the open tasks. Group_id 0 - all notes, other
+// groups include their subgroups. Due dates are in DateLayout, "" - no
+// limit, with a limit tasks without a due date are left out.
+type TaskFilter struct {
+	Group_id int
+	Due_from string
+	Due_to   string
+}
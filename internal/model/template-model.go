@@ -0,0 +1,31 @@
+package model
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrValidationTemplate = errors.New("invalid template, name is required, max name and title = 100, max text = 10MB")
+)
+
+// Template - blueprint of a note, title and text may contain placeholders
+// like {{date}}, see templates.Expand. Built-in templates have no id and
+// are selected by Builtin.
+type Template struct {
+	Id         int       `json:"id,omitempty"`
+	User_email string    `json:"-"`
+	Builtin    string    `json:"builtin,omitempty"`
+	Name       string    `json:"name"`
+	Title      string    `json:"title"`
+	Text       string    `json:"text"`
+	Created_at time.Time `json:"created_at"`
+	Updated_at time.Time `json:"updated_at"`
+}
+
+func (t *Template) Validate() error {
+	if t.Name == "" || len(t.Name) > 100 || len(t.Title) > 100 || len(t.Text) > 10<<20 {
+		return ErrValidationTemplate
+	}
+	return nil
+}
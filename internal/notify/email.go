@@ -0,0 +1,79 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"noteapp/internal/model"
+)
+
+type EmailConfig struct {
+	// "" - email notifications are off
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	From     string `json:"from"`
+}
+
+// Email sends the reminder to the address of the user by SMTP, the
+// connection is upgraded by STARTTLS when the server supports it
+type Email struct {
+	config EmailConfig
+}
+
+func NewEmail(config EmailConfig) *Email {
+	if config.Port == 0 {
+		config.Port = 587
+	}
+	return &Email{config: config}
+}
+
+func (e *Email) Notify(ctx context.Context, n model.Notification) error {
+	addr := net.JoinHostPort(e.config.Host, strconv.Itoa(e.config.Port))
+	var auth smtp.Auth
+	if e.config.Username != "" {
+		auth = smtp.PlainAuth("", e.config.Username, e.config.Password, e.config.Host)
+	}
+
+	// smtp.SendMail knows nothing about contexts
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(addr, auth, e.config.From, []string{n.User_email}, message(e.config.From, n))
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// message builds the mail, header values are single lines
+func message(from string, n model.Notification) []byte {
+	oneLine := strings.NewReplacer("\r", " ", "\n", " ")
+
+	b := &bytes.Buffer{}
+	fmt.Fprintf(b, "From: %s\r\n", oneLine.Replace(from))
+	fmt.Fprintf(b, "To: %s\r\n", oneLine.Replace(n.User_email))
+	fmt.Fprintf(b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", "Reminder: "+oneLine.Replace(n.Title)))
+	fmt.Fprintf(b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+
+	fmt.Fprintf(b, "%s\r\nDue: %s\r\n", n.Title, n.Due_at.UTC().Format("2006-01-02 15:04 MST"))
+	if n.Message != "" {
+		b.WriteString("\r\n")
+		b.WriteString(strings.ReplaceAll(strings.ReplaceAll(n.Message, "\r\n", "\n"), "\n", "\r\n"))
+		b.WriteString("\r\n")
+	}
+	return b.Bytes()
+}
@@ -0,0 +1,13 @@
+package notify
+
+import (
+	"context"
+
+	"noteapp/internal/model"
+)
+
+// Notifier delivers fired reminders outside the app. The in-app list is
+// kept by the reminders service itself.
+type Notifier interface {
+	Notify(ctx context.Context, n model.Notification) error
+}
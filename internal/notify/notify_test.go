@@ -0,0 +1,81 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"noteapp/internal/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebhook(t *testing.T) {
+	n := model.Notification{
+		Id:         7,
+		User_email: "user@mail.com",
+		Note_id:    3,
+		Title:      "Release",
+		Due_at:     time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+	}
+
+	testCases := []struct {
+		name    string
+		secret  string
+		status  int
+		wantErr bool
+	}{
+		{name: "signed", secret: "s3cret", status: http.StatusOK},
+		{name: "unsigned", status: http.StatusNoContent},
+		{name: "failed", status: http.StatusInternalServerError, wantErr: true},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			var got model.Notification
+			var signature string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				json.Unmarshal(body, &got)
+				signature = r.Header.Get(SignatureHeader)
+				if tcase.secret != "" {
+					assert.Equal(t, Sign(tcase.secret, body), signature)
+				}
+				w.WriteHeader(tcase.status)
+			}))
+			defer srv.Close()
+
+			err := NewWebhook(WebhookConfig{URL: srv.URL, Secret: tcase.secret}).Notify(context.Background(), n)
+			if tcase.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, n.Id, got.Id)
+			assert.Equal(t, n.Title, got.Title)
+			assert.Equal(t, tcase.secret != "", signature != "")
+		})
+	}
+}
+
+func TestMessage(t *testing.T) {
+	n := model.Notification{
+		User_email: "user@mail.com",
+		Title:      "Pay rent\r\nBcc: spam@mail.com",
+		Message:    "first\nsecond",
+		Due_at:     time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+	}
+
+	msg := string(message("app@mail.com", n))
+	header, body, _ := strings.Cut(msg, "\r\n\r\n")
+
+	assert.NotContains(t, header, "\r\nBcc:")
+	assert.Contains(t, header, "To: user@mail.com\r\n")
+	assert.Contains(t, body, "Due: 2024-05-01 12:00 UTC\r\n")
+	assert.Contains(t, body, "first\r\nsecond\r\n")
+}
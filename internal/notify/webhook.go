@@ -0,0 +1,74 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"noteapp/internal/model"
+)
+
+// SignatureHeader - HMAC-SHA256 of the body with the secret of the hook,
+// "sha256=<hex>"
+const SignatureHeader = "X-Noteapp-Signature"
+
+type WebhookConfig struct {
+	// "" - webhook notifications are off
+	URL    string `json:"url"`
+	Secret string `json:"secret"`
+}
+
+// Webhook posts the notification as JSON
+type Webhook struct {
+	config WebhookConfig
+	client *http.Client
+}
+
+func NewWebhook(config WebhookConfig) *Webhook {
+	return &Webhook{
+		config: config,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (h *Webhook) Notify(ctx context.Context, n model.Notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if h.config.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(h.config.Secret, body))
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook: unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// Sign returns the value of SignatureHeader for the body
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
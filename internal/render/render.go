@@ -0,0 +1,109 @@
+package render
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"noteapp/internal/wikilink"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/renderer/html"
+)
+
+// LinkResolver returns the url of the note a [[wiki link]] points to,
+// an empty string marks the link as broken
+type LinkResolver func(l wikilink.Link) string
+
+// goldmark and bluemonday are safe for concurrent use
+var (
+	markdown = goldmark.New(
+		goldmark.WithExtensions(extension.GFM),
+		// raw HTML is allowed here and cleaned by the policy afterwards
+		goldmark.WithRendererOptions(html.WithUnsafe()),
+	)
+	policy = newPolicy()
+)
+
+func newPolicy() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	// fenced code: <code class="language-go">
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w+#.-]+$`)).OnElements("code")
+	// task lists: <input checked="" disabled="" type="checkbox">
+	p.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	p.AllowAttrs("checked", "disabled").OnElements("input")
+	// table columns alignment
+	p.AllowAttrs("align").Matching(regexp.MustCompile(`^(left|center|right)$`)).OnElements("th", "td")
+	p.AllowStyles("text-align").MatchingEnum("left", "center", "right").OnElements("th", "td")
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^wikilink(-broken)?$`)).OnElements("a", "span")
+	return p
+}
+
+// HTML converts the note Markdown (CommonMark with GFM tables, task lists,
+// strikethrough and autolinks) to sanitized HTML, safe to put into a page.
+// Wiki links are turned into links with resolve, a nil resolve leaves
+// them as plain text.
+func HTML(text string, resolve LinkResolver) (string, error) {
+	buf := &bytes.Buffer{}
+	if err := markdown.Convert([]byte(replaceWikiLinks(text, resolve)), buf); err != nil {
+		return "", err
+	}
+	return policy.Sanitize(buf.String()), nil
+}
+
+func replaceWikiLinks(text string, resolve LinkResolver) string {
+	links := wikilink.Parse(text)
+	if len(links) == 0 {
+		return text
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, l := range links {
+		b.WriteString(text[last:l.Start])
+		last = l.End
+
+		label := escape(linkLabel(l))
+		if resolve == nil {
+			b.WriteString(label)
+			continue
+		}
+
+		url := resolve(l)
+		if url == "" || strings.ContainsAny(url, "<>\n") {
+			b.WriteString(`<span class="wikilink-broken">` + label + `</span>`)
+			continue
+		}
+		b.WriteString(`[` + label + `](<` + url + `>)`)
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+func linkLabel(l wikilink.Link) string {
+	switch {
+	case l.Alias != "":
+		return l.Alias
+	case l.Title != "" && l.Heading != "":
+		return l.Title + " > " + l.Heading
+	case l.Title != "":
+		return l.Title
+	}
+	return "note " + strconv.Itoa(l.Id)
+}
+
+// escape makes the text literal for Markdown, any ASCII punctuation
+// may be escaped with a backslash
+func escape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r < 128 && strings.ContainsRune("\\`*_{}[]()#+-.!|<>&~\"'", r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
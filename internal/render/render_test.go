@@ -0,0 +1,75 @@
+package render
+
+import (
+	"strconv"
+	"testing"
+
+	"noteapp/internal/wikilink"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTML(t *testing.T) {
+	resolve := func(l wikilink.Link) string {
+		if l.Title == "Known" || l.Id == 7 {
+			return "/getNote?id=" + strconv.Itoa(max(l.Id, 1)) + "&format=html"
+		}
+		return ""
+	}
+
+	testCases := []struct {
+		name     string
+		text     string
+		contains []string
+		excludes []string
+	}{
+		{
+			name:     "commonmark",
+			text:     "# Title\n\nsome **bold** and *em*",
+			contains: []string{"<h1>Title</h1>", "<strong>bold</strong>", "<em>em</em>"},
+		},
+		{
+			name:     "gfm table",
+			text:     "| a | b |\n|:--|--:|\n| 1 | 2 |",
+			contains: []string{"<table>", "<th", "<td", ">2</td>"},
+		},
+		{
+			name:     "task list",
+			text:     "- [x] done\n- [ ] todo",
+			contains: []string{`checked="" disabled="" type="checkbox"`, `disabled="" type="checkbox"`},
+		},
+		{
+			name:     "fenced code",
+			text:     "```go\nfmt.Println(\"<b>\")\n```",
+			contains: []string{`<code class="language-go">`, "&lt;b&gt;"},
+		},
+		{
+			name:     "xss",
+			text:     "<script>alert(1)</script>\n\n[x](javascript:alert(1)) <img src=x onerror=alert(1)>",
+			excludes: []string{"<script", "javascript:", "onerror"},
+		},
+		{
+			name: "wiki links",
+			text: "[[Known]], [[Missing]], [[note:7|seven]] and `[[code]]`",
+			contains: []string{
+				`<a href="/getNote?id=1&amp;format=html" rel="nofollow">Known</a>`,
+				`<span class="wikilink-broken">Missing</span>`,
+				`>seven</a>`,
+				`<code>[[code]]</code>`,
+			},
+		},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			got, err := HTML(tcase.text, resolve)
+			assert.NoError(t, err)
+			for _, s := range tcase.contains {
+				assert.Contains(t, got, s)
+			}
+			for _, s := range tcase.excludes {
+				assert.NotContains(t, got, s)
+			}
+		})
+	}
+}
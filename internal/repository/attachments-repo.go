@@ -0,0 +1,197 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"noteapp/internal/model"
+)
+
+var (
+	ErrQuotaExceeded = errors.New("attachments quota exceeded")
+)
+
+type AttachmentsRepository struct {
+	db *sql.DB
+}
+
+func NewAttachmentsRepository(db *sql.DB) *AttachmentsRepository {
+	return &AttachmentsRepository{
+		db: db,
+	}
+}
+
+// AddAttachment stores the attachment if the files of the owner stay
+// within quota bytes. The row of the user is locked, so parallel uploads
+// are counted one after another.
+func (r *AttachmentsRepository) AddAttachment(a *model.Attachment, quota int64) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = checkQuota(tx, a.Owner_email, quota-a.Size); err != nil {
+		return err
+	}
+
+	err = tx.QueryRow(
+		`INSERT INTO attachments(owner_email, note_id, blob_key, name, content_type, size, thumbnails)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at`,
+		a.Owner_email, a.Note_id, a.Blob_key, a.Name, a.Content_type, a.Size, a.Thumbnails,
+	).Scan(&a.Id, &a.Created_at)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *AttachmentsRepository) DelAttachment(id int) error {
+	res, err := r.db.Exec("DELETE FROM attachments WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrInvalidData
+	}
+	return nil
+}
+
+func (r *AttachmentsRepository) GetAttachment(id int) (*model.Attachment, error) {
+	a, err := scanAttachment(r.db.QueryRow(
+		`SELECT id, owner_email, COALESCE(note_id, 0), blob_key, name, content_type, size, thumbnails, created_at
+		FROM attachments WHERE id = $1`,
+		id,
+	))
+	if err == sql.ErrNoRows {
+		return nil, ErrInvalidData
+	}
+	return a, err
+}
+
+func (r *AttachmentsRepository) GetAttachments(noteID int) ([]model.Attachment, error) {
+	return r.queryAttachments(
+		`SELECT id, owner_email, COALESCE(note_id, 0), blob_key, name, content_type, size, thumbnails, created_at
+		FROM attachments WHERE note_id = $1 ORDER BY id ASC`,
+		noteID,
+	)
+}
+
+// GetOrphans returns attachments whose note was deleted
+func (r *AttachmentsRepository) GetOrphans(limit int) ([]model.Attachment, error) {
+	return r.queryAttachments(
+		`SELECT id, owner_email, 0, blob_key, name, content_type, size, thumbnails, created_at
+		FROM attachments WHERE note_id IS NULL ORDER BY id ASC LIMIT $1`,
+		limit,
+	)
+}
+
+func (r *AttachmentsRepository) SetThumbnails(id int, state string) error {
+	res, err := r.db.Exec("UPDATE attachments SET thumbnails = $1 WHERE id = $2", state, id)
+	if err != nil {
+		return err
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrInvalidData
+	}
+	return nil
+}
+
+// GetPendingThumbnails returns ids of attachments waiting for thumbnails
+func (r *AttachmentsRepository) GetPendingThumbnails(limit int) ([]int, error) {
+	res, err := r.db.Query(
+		"SELECT id FROM attachments WHERE thumbnails = 'pending' ORDER BY id ASC LIMIT $1",
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+
+	ids := []int{}
+	for res.Next() {
+		var id int
+		if err = res.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	if err = res.Err(); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// UsedSpace returns the total size of attachments stored by the user
+func (r *AttachmentsRepository) UsedSpace(email string) (int64, error) {
+	var size int64
+	err := r.db.QueryRow(
+		"SELECT COALESCE(SUM(size), 0) FROM attachments WHERE owner_email = $1",
+		email,
+	).Scan(&size)
+	return size, err
+}
+
+// checkQuota locks the user till the end of tx and checks that the
+// attachments of the user take no more than quota bytes
+func checkQuota(tx *sql.Tx, email string, quota int64) error {
+	if _, err := tx.Exec("SELECT 1 FROM users WHERE email = $1 FOR UPDATE", email); err != nil {
+		return err
+	}
+
+	var used int64
+	err := tx.QueryRow(
+		"SELECT COALESCE(SUM(size), 0) FROM attachments WHERE owner_email = $1",
+		email,
+	).Scan(&used)
+	if err != nil {
+		return err
+	}
+	if used > quota {
+		return ErrQuotaExceeded
+	}
+	return nil
+}
+
+func (r *AttachmentsRepository) queryAttachments(query string, args ...interface{}) ([]model.Attachment, error) {
+	res, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+
+	attachments := []model.Attachment{}
+	for res.Next() {
+		a, err := scanAttachment(res)
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, *a)
+	}
+
+	if err = res.Err(); err != nil {
+		return nil, err
+	}
+	return attachments, nil
+}
+
+func scanAttachment(row rowScanner) (*model.Attachment, error) {
+	a := &model.Attachment{}
+	err := row.Scan(&a.Id, &a.Owner_email, &a.Note_id, &a.Blob_key, &a.Name, &a.Content_type, &a.Size, &a.Thumbnails, &a.Created_at)
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
@@ -0,0 +1,335 @@
+package repository
+
+import (
+	"database/sql"
+	"noteapp/internal/model"
+)
+
+// BackupRepository reads everything of the account for a backup,
+// restore goes through ImportTx
+type BackupRepository struct {
+	db *sql.DB
+}
+
+func NewBackupRepository(db *sql.DB) *BackupRepository {
+	return &BackupRepository{
+		db: db,
+	}
+}
+
+func (r *BackupRepository) GetGroups(email string) ([]model.BackupGroup, error) {
+	rows, err := r.db.Query(
+		`SELECT id, COALESCE(pid, 0), name, created_at, updated_at
+		FROM groups WHERE user_email = $1 ORDER BY id ASC`,
+		email,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := []model.BackupGroup{}
+	for rows.Next() {
+		g := model.BackupGroup{}
+		if err := rows.Scan(&g.Id, &g.Pid, &g.Name, &g.Created_at, &g.Updated_at); err != nil {
+			return nil, err
+		}
+		groups = append(groups, g)
+	}
+	return groups, rows.Err()
+}
+
+// EachNote calls fn for every note of the user, one row in memory at a time
+func (r *BackupRepository) EachNote(email string, fn func(n model.BackupNote) error) error {
+	rows, err := r.db.Query(
+		`SELECT id, COALESCE(group_id, 0), title, COALESCE(text, ''), encrypted, created_at, updated_at
+		FROM notes WHERE user_email = $1 ORDER BY id ASC`,
+		email,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		n := model.BackupNote{}
+		if err := rows.Scan(&n.Id, &n.Group_id, &n.Title, &n.Text, &n.Encrypted, &n.Created_at, &n.Updated_at); err != nil {
+			return err
+		}
+		if err := fn(n); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (r *BackupRepository) GetShares(email string) ([]model.BackupShare, error) {
+	rows, err := r.db.Query(
+		`SELECT user_email, COALESCE(note_id, 0), COALESCE(group_id, 0), permission, created_at
+		FROM shares WHERE owner_email = $1 ORDER BY id ASC`,
+		email,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	shares := []model.BackupShare{}
+	for rows.Next() {
+		s := model.BackupShare{}
+		if err := rows.Scan(&s.User_email, &s.Note_id, &s.Group_id, &s.Permission, &s.Created_at); err != nil {
+			return nil, err
+		}
+		shares = append(shares, s)
+	}
+	return shares, rows.Err()
+}
+
+// GetKeys returns the keys of the encrypted notes, nil if there are none
+func (r *BackupRepository) GetKeys(email string) (*model.UserKeys, error) {
+	k, err := NewKeysRepository(r.db).GetKeys(email)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &k, nil
+}
+
+func (r *BackupRepository) GetPublicLinks(email string) ([]model.BackupPublicLink, error) {
+	rows, err := r.db.Query(
+		`SELECT COALESCE(note_id, 0), COALESCE(group_id, 0), COALESCE(password, ''), expires_at, feed, views, created_at
+		FROM public_links WHERE owner_email = $1 ORDER BY id ASC`,
+		email,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	links := []model.BackupPublicLink{}
+	for rows.Next() {
+		l := model.BackupPublicLink{}
+		if err := rows.Scan(&l.Note_id, &l.Group_id, &l.Password, &l.Expires_at, &l.Feed, &l.Views, &l.Created_at); err != nil {
+			return nil, err
+		}
+		links = append(links, l)
+	}
+	return links, rows.Err()
+}
+
+// GetAttachments returns the attachments of the user's notes, orphans
+// waiting for the cleanup are left out
+func (r *BackupRepository) GetAttachments(email string) ([]model.Attachment, error) {
+	rows, err := r.db.Query(
+		`SELECT id, owner_email, COALESCE(note_id, 0), blob_key, name, content_type, size, thumbnails, created_at
+		FROM attachments WHERE owner_email = $1 AND note_id IS NOT NULL ORDER BY id ASC`,
+		email,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attachments := []model.Attachment{}
+	for rows.Next() {
+		a, err := scanAttachment(rows)
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, *a)
+	}
+	return attachments, rows.Err()
+}
+
+func (r *BackupRepository) GetTemplates(email string) ([]model.BackupTemplate, error) {
+	rows, err := r.db.Query(
+		`SELECT id, name, title, text, created_at, updated_at
+		FROM templates WHERE user_email = $1 ORDER BY id ASC`,
+		email,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []model.BackupTemplate{}
+	for rows.Next() {
+		t := model.BackupTemplate{}
+		if err := rows.Scan(&t.Id, &t.Name, &t.Title, &t.Text, &t.Created_at, &t.Updated_at); err != nil {
+			return nil, err
+		}
+		list = append(list, t)
+	}
+	return list, rows.Err()
+}
+
+// GetJournal returns the journal settings, nil if the user has not set them
+func (r *BackupRepository) GetJournal(email string) (*model.BackupJournal, error) {
+	j := &model.BackupJournal{}
+	err := r.db.QueryRow(
+		`SELECT COALESCE(group_id, 0), COALESCE(template_id, 0), builtin
+		FROM journal_settings WHERE user_email = $1`,
+		email,
+	).Scan(&j.Group_id, &j.Template_id, &j.Builtin)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+func (r *BackupRepository) GetJournalDays(email string) ([]model.BackupJournalDay, error) {
+	rows, err := r.db.Query(
+		`SELECT to_char(day, 'YYYY-MM-DD'), note_id
+		FROM journal_days WHERE user_email = $1 ORDER BY day ASC`,
+		email,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	days := []model.BackupJournalDay{}
+	for rows.Next() {
+		d := model.BackupJournalDay{}
+		if err := rows.Scan(&d.Day, &d.Note_id); err != nil {
+			return nil, err
+		}
+		days = append(days, d)
+	}
+	return days, rows.Err()
+}
+
+// GetComments returns the comments of all authors on the notes of the
+// user, parents go before their replies
+func (r *BackupRepository) GetComments(email string) ([]model.BackupComment, error) {
+	rows, err := r.db.Query(
+		`SELECT id, note_id, COALESCE(parent_id, 0), author_email, text, anchor_start, anchor_end, anchor_text,
+			resolved, COALESCE(resolved_by, ''), resolved_at, created_at, updated_at
+		FROM comments
+		WHERE note_id IN (SELECT id FROM notes WHERE user_email = $1)
+		ORDER BY id ASC`,
+		email,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []model.BackupComment{}
+	for rows.Next() {
+		c := model.BackupComment{}
+		err := rows.Scan(&c.Id, &c.Note_id, &c.Parent_id, &c.Author_email, &c.Text, &c.Anchor_start, &c.Anchor_end, &c.Anchor_text,
+			&c.Resolved, &c.Resolved_by, &c.Resolved_at, &c.Created_at, &c.Updated_at)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, c)
+	}
+	return comments, rows.Err()
+}
+
+// GetReminders returns the reminders of the user on the user's notes,
+// reminders on shared notes go with the notes of their owners
+func (r *BackupRepository) GetReminders(email string) ([]model.BackupReminder, error) {
+	rows, err := r.db.Query(
+		`SELECT id, note_id, message, due_at, remind_at, fired_at, created_at
+		FROM reminders
+		WHERE user_email = $1 AND note_id IN (SELECT id FROM notes WHERE user_email = $1)
+		ORDER BY id ASC`,
+		email,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reminders := []model.BackupReminder{}
+	for rows.Next() {
+		rem := model.BackupReminder{}
+		if err := rows.Scan(&rem.Id, &rem.Note_id, &rem.Message, &rem.Due_at, &rem.Remind_at, &rem.Fired_at, &rem.Created_at); err != nil {
+			return nil, err
+		}
+		reminders = append(reminders, rem)
+	}
+	return reminders, rows.Err()
+}
+
+func (r *BackupRepository) GetNotifications(email string) ([]model.BackupNotification, error) {
+	rows, err := r.db.Query(
+		`SELECT note_id, COALESCE(reminder_id, 0), title, message, due_at, read, created_at
+		FROM notifications
+		WHERE user_email = $1 AND note_id IN (SELECT id FROM notes WHERE user_email = $1)
+		ORDER BY id ASC`,
+		email,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := []model.BackupNotification{}
+	for rows.Next() {
+		n := model.BackupNotification{}
+		if err := rows.Scan(&n.Note_id, &n.Reminder_id, &n.Title, &n.Message, &n.Due_at, &n.Read, &n.Created_at); err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, n)
+	}
+	return notifications, rows.Err()
+}
+
+// GetFavorites returns the favorite notes and groups of the user among
+// the user's own ones
+func (r *BackupRepository) GetFavorites(email string) ([]model.BackupFavorite, error) {
+	rows, err := r.db.Query(
+		`SELECT COALESCE(note_id, 0), COALESCE(group_id, 0), created_at
+		FROM favorites
+		WHERE user_email = $1 AND (note_id IN (SELECT id FROM notes WHERE user_email = $1)
+			OR group_id IN (SELECT id FROM groups WHERE user_email = $1))
+		ORDER BY id ASC`,
+		email,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	favorites := []model.BackupFavorite{}
+	for rows.Next() {
+		f := model.BackupFavorite{}
+		if err := rows.Scan(&f.Note_id, &f.Group_id, &f.Created_at); err != nil {
+			return nil, err
+		}
+		favorites = append(favorites, f)
+	}
+	return favorites, rows.Err()
+}
+
+func (r *BackupRepository) GetPins(email string) ([]model.BackupPin, error) {
+	rows, err := r.db.Query(
+		`SELECT note_id, created_at
+		FROM pins
+		WHERE user_email = $1 AND note_id IN (SELECT id FROM notes WHERE user_email = $1)
+		ORDER BY created_at ASC`,
+		email,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pins := []model.BackupPin{}
+	for rows.Next() {
+		p := model.BackupPin{}
+		if err := rows.Scan(&p.Note_id, &p.Created_at); err != nil {
+			return nil, err
+		}
+		pins = append(pins, p)
+	}
+	return pins, rows.Err()
+}
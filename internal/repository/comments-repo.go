@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"database/sql"
+	"noteapp/internal/model"
+)
+
+type CommentsRepository struct {
+	db *sql.DB
+}
+
+func NewCommentsRepository(db *sql.DB) *CommentsRepository {
+	return &CommentsRepository{
+		db: db,
+	}
+}
+
+const commentColumns = `id, note_id, COALESCE(parent_id, 0), author_email, text, anchor_start, anchor_end,
+	anchor_text, resolved, COALESCE(resolved_by, ''), resolved_at, created_at, updated_at`
+
+// AddComment returns ErrInvalidData if the parent is not a thread
+// of the same note
+func (r *CommentsRepository) AddComment(c *model.Comment) error {
+	err := r.db.QueryRow(
+		`INSERT INTO comments(note_id, parent_id, author_email, text, anchor_start, anchor_end, anchor_text)
+		SELECT $1, NULLIF($2, 0), $3, $4, $5, $6, $7
+		WHERE $2 = 0 OR EXISTS (SELECT 1 FROM comments WHERE id = $2 AND note_id = $1 AND parent_id IS NULL)
+		RETURNING id, created_at, updated_at`,
+		c.Note_id, c.Parent_id, c.Author_email, c.Text, c.Anchor_start, c.Anchor_end, c.Anchor_text,
+	).Scan(&c.Id, &c.Created_at, &c.Updated_at)
+	if err == sql.ErrNoRows {
+		return ErrInvalidData
+	}
+	return err
+}
+
+func (r *CommentsRepository) GetComment(id int) (model.Comment, error) {
+	c, err := scanComment(r.db.QueryRow("SELECT "+commentColumns+" FROM comments WHERE id = $1", id))
+	if err == sql.ErrNoRows {
+		return c, ErrInvalidData
+	}
+	return c, err
+}
+
+// GetComments returns the comments of the note in order of creation
+func (r *CommentsRepository) GetComments(noteID int) ([]model.Comment, error) {
+	rows, err := r.db.Query("SELECT "+commentColumns+" FROM comments WHERE note_id = $1 ORDER BY id ASC", noteID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []model.Comment{}
+	for rows.Next() {
+		c, err := scanComment(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, c)
+	}
+	return list, rows.Err()
+}
+
+func (r *CommentsRepository) UpdateComment(id int, text string) error {
+	return r.exec("UPDATE comments SET text = $2, updated_at = now() WHERE id = $1", id, text)
+}
+
+// DelComment deletes the comment, a thread is deleted with its replies
+func (r *CommentsRepository) DelComment(id int) error {
+	return r.exec("DELETE FROM comments WHERE id = $1", id)
+}
+
+func (r *CommentsRepository) ResolveComment(id int, email string, resolved bool) error {
+	if resolved {
+		return r.exec(
+			`UPDATE comments SET resolved = true, resolved_by = $2, resolved_at = now()
+			WHERE id = $1 AND parent_id IS NULL`,
+			id, email,
+		)
+	}
+	return r.exec(
+		`UPDATE comments SET resolved = false, resolved_by = NULL, resolved_at = NULL
+		WHERE id = $1 AND parent_id IS NULL`,
+		id,
+	)
+}
+
+func (r *CommentsRepository) exec(query string, args ...interface{}) error {
+	res, err := r.db.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrInvalidData
+	}
+	return nil
+}
+
+func scanComment(row interface{ Scan(dest ...any) error }) (model.Comment, error) {
+	c := model.Comment{}
+	var start, end sql.NullInt64
+	var resolvedAt sql.NullTime
+	err := row.Scan(&c.Id, &c.Note_id, &c.Parent_id, &c.Author_email, &c.Text, &start, &end,
+		&c.Anchor_text, &c.Resolved, &c.Resolved_by, &resolvedAt, &c.Created_at, &c.Updated_at)
+	if start.Valid && end.Valid {
+		s, e := int(start.Int64), int(end.Int64)
+		c.Anchor_start, c.Anchor_end = &s, &e
+	}
+	if resolvedAt.Valid {
+		c.Resolved_at = &resolvedAt.Time
+	}
+	return c, err
+}
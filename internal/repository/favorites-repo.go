@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"database/sql"
+	"noteapp/internal/model"
+)
+
+type FavoritesRepository struct {
+	db *sql.DB
+}
+
+func NewFavoritesRepository(db *sql.DB) *FavoritesRepository {
+	return &FavoritesRepository{
+		db: db,
+	}
+}
+
+// SetPin pins or unpins the note for the user, repeated calls change nothing
+func (r *FavoritesRepository) SetPin(email string, noteID int, pinned bool) error {
+	var err error
+	if pinned {
+		_, err = r.db.Exec(
+			"INSERT INTO pins(user_email, note_id) VALUES ($1, $2) ON CONFLICT DO NOTHING",
+			email, noteID,
+		)
+	} else {
+		_, err = r.db.Exec("DELETE FROM pins WHERE user_email = $1 AND note_id = $2", email, noteID)
+	}
+	return err
+}
+
+// SetFavorite marks the note or the group (the other id is 0) as
+// a favorite of the user or removes the mark
+func (r *FavoritesRepository) SetFavorite(email string, noteID int, groupID int, favorite bool) error {
+	var err error
+	if favorite {
+		_, err = r.db.Exec(
+			`INSERT INTO favorites(user_email, note_id, group_id) VALUES ($1, NULLIF($2, 0), NULLIF($3, 0))
+			ON CONFLICT DO NOTHING`,
+			email, noteID, groupID,
+		)
+	} else {
+		_, err = r.db.Exec(
+			"DELETE FROM favorites WHERE user_email = $1 AND (note_id = $2 OR group_id = $3)",
+			email, noteID, groupID,
+		)
+	}
+	return err
+}
+
+func (r *FavoritesRepository) GetFavorites(email string) (model.Favorites, error) {
+	favorites := model.Favorites{Notes: []model.FavoriteNote{}, Groups: []model.FavoriteGroup{}}
+
+	rows, err := r.db.Query(
+		`SELECT n.id, n.title, COALESCE(n.group_id, 0), n.user_email, p.note_id IS NOT NULL, n.encrypted, f.created_at
+		FROM favorites f
+			JOIN notes n ON n.id = f.note_id
+			LEFT JOIN pins p ON p.note_id = n.id AND p.user_email = f.user_email
+		WHERE f.user_email = $1
+		ORDER BY f.created_at DESC, f.id DESC`,
+		email,
+	)
+	if err != nil {
+		return favorites, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		n := model.FavoriteNote{}
+		if err := rows.Scan(&n.Note_id, &n.Title, &n.Group_id, &n.Owner_email, &n.Pinned, &n.Encrypted, &n.Created_at); err != nil {
+			return favorites, err
+		}
+		favorites.Notes = append(favorites.Notes, n)
+	}
+	if err = rows.Err(); err != nil {
+		return favorites, err
+	}
+
+	rows, err = r.db.Query(
+		`SELECT g.id, g.name, COALESCE(g.pid, 0), g.user_email, f.created_at
+		FROM favorites f
+			JOIN groups g ON g.id = f.group_id
+		WHERE f.user_email = $1
+		ORDER BY f.created_at DESC, f.id DESC`,
+		email,
+	)
+	if err != nil {
+		return favorites, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		g := model.FavoriteGroup{}
+		if err := rows.Scan(&g.Group_id, &g.Name, &g.Pid, &g.Owner_email, &g.Created_at); err != nil {
+			return favorites, err
+		}
+		favorites.Groups = append(favorites.Groups, g)
+	}
+	return favorites, rows.Err()
+}
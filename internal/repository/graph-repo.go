@@ -0,0 +1,179 @@
+package repository
+
+import (
+	"database/sql"
+	"noteapp/internal/model"
+
+	"github.com/lib/pq"
+)
+
+type GraphRepository struct {
+	db *sql.DB
+}
+
+func NewGraphRepository(db *sql.DB) *GraphRepository {
+	return &GraphRepository{
+		db: db,
+	}
+}
+
+func (r *GraphRepository) GetGroups(email string) ([]model.Group, error) {
+	res, err := r.db.Query(
+		"SELECT id, name, COALESCE(pid, 0) FROM groups WHERE user_email = $1 ORDER BY id ASC",
+		email,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+
+	groups := []model.Group{}
+	for res.Next() {
+		g := model.Group{User_email: email}
+		if err = res.Scan(&g.Id, &g.Name, &g.Pid); err != nil {
+			return nil, err
+		}
+		groups = append(groups, g)
+	}
+
+	if err = res.Err(); err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+// GetNotes returns the notes of the user without their texts
+func (r *GraphRepository) GetNotes(email string) ([]model.Note, error) {
+	res, err := r.db.Query(
+		"SELECT id, title, COALESCE(group_id, 0), encrypted, version FROM notes WHERE user_email = $1 ORDER BY id ASC",
+		email,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+
+	notes := []model.Note{}
+	for res.Next() {
+		n := model.Note{User_email: email}
+		if err = res.Scan(&n.Id, &n.Title, &n.Group_id, &n.Encrypted, &n.Version); err != nil {
+			return nil, err
+		}
+		notes = append(notes, n)
+	}
+
+	if err = res.Err(); err != nil {
+		return nil, err
+	}
+	return notes, nil
+}
+
+// GetTexts returns the texts of the notes of the user by their ids
+func (r *GraphRepository) GetTexts(email string, ids []int) (map[int]string, error) {
+	res, err := r.db.Query(
+		"SELECT id, COALESCE(text, '') FROM notes WHERE user_email = $1 AND id = ANY($2)",
+		email, pq.Array(ids),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+
+	texts := map[int]string{}
+	for res.Next() {
+		var id int
+		var text string
+		if err = res.Scan(&id, &text); err != nil {
+			return nil, err
+		}
+		texts[id] = text
+	}
+
+	if err = res.Err(); err != nil {
+		return nil, err
+	}
+	return texts, nil
+}
+
+// GetTags returns the stored #tags of the notes of the user
+func (r *GraphRepository) GetTags(email string) ([]model.NoteTags, error) {
+	res, err := r.db.Query(
+		`SELECT t.note_id, t.version, t.tags
+		FROM note_tags t
+			JOIN notes n ON n.id = t.note_id
+		WHERE n.user_email = $1`,
+		email,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+
+	list := []model.NoteTags{}
+	for res.Next() {
+		t := model.NoteTags{}
+		if err = res.Scan(&t.Note_id, &t.Version, pq.Array(&t.Tags)); err != nil {
+			return nil, err
+		}
+		list = append(list, t)
+	}
+
+	if err = res.Err(); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// SetTags stores the #tags of the notes, notes deleted in the meantime
+// are skipped
+func (r *GraphRepository) SetTags(list []model.NoteTags) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, t := range list {
+		_, err = tx.Exec(
+			`INSERT INTO note_tags(note_id, version, tags)
+			SELECT id, $2, $3 FROM notes WHERE id = $1
+			ON CONFLICT (note_id) DO UPDATE
+			SET version = EXCLUDED.version, tags = EXCLUDED.tags`,
+			t.Note_id, t.Version, pq.Array(t.Tags),
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetLinks returns resolved links between notes of the user
+func (r *GraphRepository) GetLinks(email string) ([]model.GraphLink, error) {
+	res, err := r.db.Query(
+		`SELECT DISTINCT l.source_id, l.target_id
+		FROM note_links l
+			JOIN notes n ON n.id = l.source_id
+		WHERE n.user_email = $1 AND l.target_id IS NOT NULL`,
+		email,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+
+	links := []model.GraphLink{}
+	for res.Next() {
+		l := model.GraphLink{}
+		if err = res.Scan(&l.Source_id, &l.Target_id); err != nil {
+			return nil, err
+		}
+		links = append(links, l)
+	}
+
+	if err = res.Err(); err != nil {
+		return nil, err
+	}
+	return links, nil
+}
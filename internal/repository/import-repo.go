@@ -0,0 +1,299 @@
+package repository
+
+import (
+	"database/sql"
+	"noteapp/internal/model"
+)
+
+type ImportRepository struct {
+	db *sql.DB
+}
+
+func NewImportRepository(db *sql.DB) *ImportRepository {
+	return &ImportRepository{
+		db: db,
+	}
+}
+
+// ImportTx - an import in progress, nothing is visible to other
+// requests until Commit
+type ImportTx struct {
+	tx *sql.Tx
+}
+
+func (r *ImportRepository) Begin() (*ImportTx, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &ImportTx{tx: tx}, nil
+}
+
+func (t *ImportTx) Commit() error {
+	return t.tx.Commit()
+}
+
+func (t *ImportTx) Rollback() error {
+	return t.tx.Rollback()
+}
+
+// AddGroup creates the group, pid = 0 makes a root group
+func (t *ImportTx) AddGroup(email string, name string, pid int) (int, error) {
+	var id int
+	err := t.tx.QueryRow(
+		"INSERT INTO groups(user_email, name, pid) VALUES ($1, $2, $3) RETURNING id",
+		email, name, nullID(pid),
+	).Scan(&id)
+	return id, err
+}
+
+// AddNote creates the note with its text and dates, Group_id = 0 puts
+// the note to the root
+func (t *ImportTx) AddNote(n *model.Note) error {
+	return t.tx.QueryRow(
+		`INSERT INTO notes(user_email, title, text, group_id, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, version`,
+		n.User_email, n.Title, n.Text, nullID(n.Group_id), n.Created_at, n.Updated_at,
+	).Scan(&n.Id, &n.Version)
+}
+
+// AddAttachment stores the attachment row, Note_id = 0 leaves it unlinked
+// until SetAttachmentNote
+func (t *ImportTx) AddAttachment(a *model.Attachment) error {
+	return t.tx.QueryRow(
+		`INSERT INTO attachments(owner_email, note_id, blob_key, name, content_type, size, thumbnails)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at`,
+		a.Owner_email, nullID(a.Note_id), a.Blob_key, a.Name, a.Content_type, a.Size, a.Thumbnails,
+	).Scan(&a.Id, &a.Created_at)
+}
+
+// CheckQuota fails if the attachments of the user, the ones added in
+// the import too, take more than quota bytes. The user stays locked
+// until the import ends.
+func (t *ImportTx) CheckQuota(email string, quota int64) error {
+	return checkQuota(t.tx, email, quota)
+}
+
+func (t *ImportTx) SetAttachmentNote(id int, noteID int) error {
+	_, err := t.tx.Exec("UPDATE attachments SET note_id = $1 WHERE id = $2", noteID, id)
+	return err
+}
+
+// RESTORE
+
+// restored rows keep ids taken from their sequences in advance,
+// so references in texts can be rewritten before the insert
+var sequences = map[string]string{
+	"groups":      "groups_id_seq",
+	"notes":       "notes_id_seq",
+	"attachments": "attachments_id_seq",
+}
+
+// NextIDs reserves n ids of the table
+func (t *ImportTx) NextIDs(table string, n int) ([]int, error) {
+	seq, ok := sequences[table]
+	if !ok {
+		return nil, ErrInvalidData
+	}
+
+	rows, err := t.tx.Query("SELECT nextval($1) FROM generate_series(1, $2)", seq, n)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make([]int, 0, n)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func (t *ImportTx) UserExists(email string) (bool, error) {
+	var exists bool
+	err := t.tx.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE email = $1)", email).Scan(&exists)
+	return exists, err
+}
+
+// RestoreGroup inserts the group with its reserved id, the parent
+// must be restored first
+func (t *ImportTx) RestoreGroup(email string, g model.BackupGroup) error {
+	_, err := t.tx.Exec(
+		`INSERT INTO groups(id, user_email, name, pid, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		g.Id, email, g.Name, nullID(g.Pid), g.Created_at, g.Updated_at,
+	)
+	return err
+}
+
+func (t *ImportTx) RestoreNote(email string, n model.BackupNote) error {
+	_, err := t.tx.Exec(
+		`INSERT INTO notes(id, user_email, title, text, group_id, encrypted, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		n.Id, email, n.Title, n.Text, nullID(n.Group_id), n.Encrypted, n.Created_at, n.Updated_at,
+	)
+	return err
+}
+
+// RestoreKeys sets the keys of the encrypted notes, existing keys of the
+// user are kept. false - the user has other keys.
+func (t *ImportTx) RestoreKeys(email string, k model.UserKeys) (bool, error) {
+	res, err := t.tx.Exec(
+		`INSERT INTO user_keys(user_email, kdf, kdf_params, salt, wrapped_key, recovery_wrapped_key)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (user_email) DO UPDATE SET user_email = EXCLUDED.user_email
+		WHERE user_keys.wrapped_key = EXCLUDED.wrapped_key`,
+		email, k.Kdf, string(k.Kdf_params), k.Salt, k.Wrapped_key, k.Recovery_wrapped_key,
+	)
+	if err != nil {
+		return false, err
+	}
+	count, err := res.RowsAffected()
+	return count == 1, err
+}
+
+func (t *ImportTx) RestoreAttachment(a *model.Attachment) error {
+	_, err := t.tx.Exec(
+		`INSERT INTO attachments(id, owner_email, note_id, blob_key, name, content_type, size, thumbnails, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		a.Id, a.Owner_email, nullID(a.Note_id), a.Blob_key, a.Name, a.Content_type, a.Size, a.Thumbnails, a.Created_at,
+	)
+	return err
+}
+
+func (t *ImportTx) RestoreShare(email string, s model.BackupShare) error {
+	_, err := t.tx.Exec(
+		`INSERT INTO shares(owner_email, user_email, note_id, group_id, permission, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT DO NOTHING`,
+		email, s.User_email, nullID(s.Note_id), nullID(s.Group_id), s.Permission, s.Created_at,
+	)
+	return err
+}
+
+func (t *ImportTx) RestorePublicLink(email string, token string, l model.BackupPublicLink) error {
+	var password any
+	if l.Password != "" {
+		password = l.Password
+	}
+	_, err := t.tx.Exec(
+		`INSERT INTO public_links(token, owner_email, note_id, group_id, password, expires_at, feed, views, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		token, email, nullID(l.Note_id), nullID(l.Group_id), password, l.Expires_at, l.Feed && l.Group_id != 0, l.Views, l.Created_at,
+	)
+	return err
+}
+
+// RestoreTemplate inserts the template and returns its id,
+// 0 - the user has a template with this name
+func (t *ImportTx) RestoreTemplate(email string, tm model.BackupTemplate) (int, error) {
+	var id int
+	err := t.tx.QueryRow(
+		`INSERT INTO templates(user_email, name, title, text, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (user_email, name) DO NOTHING
+		RETURNING id`,
+		email, tm.Name, tm.Title, tm.Text, tm.Created_at, tm.Updated_at,
+	).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return id, err
+}
+
+// RestoreJournal sets the journal settings, the settings the user
+// already has are kept
+func (t *ImportTx) RestoreJournal(email string, j model.BackupJournal) error {
+	_, err := t.tx.Exec(
+		`INSERT INTO journal_settings(user_email, group_id, template_id, builtin)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_email) DO NOTHING`,
+		email, nullID(j.Group_id), nullID(j.Template_id), j.Builtin,
+	)
+	return err
+}
+
+// RestoreJournalDay links the note to the day, a day which has a note
+// keeps it
+func (t *ImportTx) RestoreJournalDay(email string, d model.BackupJournalDay) error {
+	_, err := t.tx.Exec(
+		`INSERT INTO journal_days(user_email, day, note_id)
+		VALUES ($1, $2, $3)
+		ON CONFLICT DO NOTHING`,
+		email, d.Day, d.Note_id,
+	)
+	return err
+}
+
+// RestoreComment inserts the comment and returns its id, the parent
+// must be restored first
+func (t *ImportTx) RestoreComment(c model.BackupComment) (int, error) {
+	var resolvedBy any
+	if c.Resolved_by != "" {
+		resolvedBy = c.Resolved_by
+	}
+	var id int
+	err := t.tx.QueryRow(
+		`INSERT INTO comments(note_id, parent_id, author_email, text, anchor_start, anchor_end, anchor_text,
+			resolved, resolved_by, resolved_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		RETURNING id`,
+		c.Note_id, nullID(c.Parent_id), c.Author_email, c.Text, c.Anchor_start, c.Anchor_end, c.Anchor_text,
+		c.Resolved, resolvedBy, c.Resolved_at, c.Created_at, c.Updated_at,
+	).Scan(&id)
+	return id, err
+}
+
+// RestoreReminder inserts the reminder and returns its id
+func (t *ImportTx) RestoreReminder(email string, rem model.BackupReminder) (int, error) {
+	var id int
+	err := t.tx.QueryRow(
+		`INSERT INTO reminders(note_id, user_email, message, due_at, remind_at, fired_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id`,
+		rem.Note_id, email, rem.Message, rem.Due_at, rem.Remind_at, rem.Fired_at, rem.Created_at,
+	).Scan(&id)
+	return id, err
+}
+
+func (t *ImportTx) RestoreNotification(email string, n model.BackupNotification) error {
+	_, err := t.tx.Exec(
+		`INSERT INTO notifications(user_email, note_id, reminder_id, title, message, due_at, read, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		email, n.Note_id, nullID(n.Reminder_id), n.Title, n.Message, n.Due_at, n.Read, n.Created_at,
+	)
+	return err
+}
+
+func (t *ImportTx) RestoreFavorite(email string, f model.BackupFavorite) error {
+	_, err := t.tx.Exec(
+		`INSERT INTO favorites(user_email, note_id, group_id, created_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT DO NOTHING`,
+		email, nullID(f.Note_id), nullID(f.Group_id), f.Created_at,
+	)
+	return err
+}
+
+func (t *ImportTx) RestorePin(email string, p model.BackupPin) error {
+	_, err := t.tx.Exec(
+		`INSERT INTO pins(user_email, note_id, created_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT DO NOTHING`,
+		email, p.Note_id, p.Created_at,
+	)
+	return err
+}
+
+func nullID(id int) any {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"database/sql"
+	"noteapp/internal/model"
+)
+
+type JournalRepository struct {
+	db *sql.DB
+}
+
+func NewJournalRepository(db *sql.DB) *JournalRepository {
+	return &JournalRepository{
+		db: db,
+	}
+}
+
+// GetJournal returns the settings of the user, defaults if there are none
+func (r *JournalRepository) GetJournal(email string) (model.Journal, error) {
+	j := model.Journal{Builtin: "daily"}
+	var groupID, templateID sql.NullInt64
+	err := r.db.QueryRow(
+		"SELECT group_id, template_id, builtin FROM journal_settings WHERE user_email = $1",
+		email,
+	).Scan(&groupID, &templateID, &j.Builtin)
+	if err == sql.ErrNoRows {
+		return j, nil
+	}
+	j.Group_id = int(groupID.Int64)
+	j.Template_id = int(templateID.Int64)
+	return j, err
+}
+
+// SetJournal returns ErrInvalidData if the group or the template
+// is not the user's
+func (r *JournalRepository) SetJournal(email string, j model.Journal) error {
+	err := r.db.QueryRow(
+		`INSERT INTO journal_settings(user_email, group_id, template_id, builtin)
+		SELECT $1, NULLIF($2, 0), NULLIF($3, 0), $4
+		WHERE ($2 = 0 OR EXISTS (SELECT 1 FROM groups WHERE id = $2 AND user_email = $1))
+			AND ($3 = 0 OR EXISTS (SELECT 1 FROM templates WHERE id = $3 AND user_email = $1))
+		ON CONFLICT (user_email) DO UPDATE
+		SET group_id = EXCLUDED.group_id, template_id = EXCLUDED.template_id, builtin = EXCLUDED.builtin
+		RETURNING user_email`,
+		email, j.Group_id, j.Template_id, j.Builtin,
+	).Scan(&email)
+	if err == sql.ErrNoRows {
+		return ErrInvalidData
+	}
+	return err
+}
+
+// FindGroup returns the first group of the user with the name inside pid
+// (0 - at the root), 0 if there is none
+func (r *JournalRepository) FindGroup(email string, name string, pid int) (int, error) {
+	var id int
+	err := r.db.QueryRow(
+		`SELECT id FROM groups
+		WHERE user_email = $1 AND name = $2 AND pid IS NOT DISTINCT FROM NULLIF($3, 0)
+		ORDER BY id LIMIT 1`,
+		email, name, pid,
+	).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return id, err
+}
+
+// GetDay returns the note of the day, 0 if there is none
+func (r *JournalRepository) GetDay(email string, day string) (int, error) {
+	var id int
+	err := r.db.QueryRow(
+		"SELECT note_id FROM journal_days WHERE user_email = $1 AND day = $2",
+		email, day,
+	).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return id, err
+}
+
+// AddDay returns the note of the day, if the day already has one it is
+// kept and returned instead
+func (r *JournalRepository) AddDay(email string, day string, noteID int) (int, error) {
+	err := r.db.QueryRow(
+		`INSERT INTO journal_days(user_email, day, note_id) VALUES ($1, $2, $3)
+		ON CONFLICT (user_email, day) DO NOTHING
+		RETURNING note_id`,
+		email, day, noteID,
+	).Scan(&noteID)
+	if err == sql.ErrNoRows {
+		return r.GetDay(email, day)
+	}
+	return noteID, err
+}
+
+// Neighbours returns the nearest days before and after the day that have
+// notes, "" - there is no such day
+func (r *JournalRepository) Neighbours(email string, day string) (string, string, error) {
+	var prev, next sql.NullString
+	err := r.db.QueryRow(
+		`SELECT
+			(SELECT to_char(max(day), 'YYYY-MM-DD') FROM journal_days WHERE user_email = $1 AND day < $2),
+			(SELECT to_char(min(day), 'YYYY-MM-DD') FROM journal_days WHERE user_email = $1 AND day > $2)`,
+		email, day,
+	).Scan(&prev, &next)
+	return prev.String, next.String, err
+}
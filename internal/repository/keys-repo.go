@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"noteapp/internal/model"
+)
+
+type KeysRepository struct {
+	db *sql.DB
+}
+
+func NewKeysRepository(db *sql.DB) *KeysRepository {
+	return &KeysRepository{
+		db: db,
+	}
+}
+
+// GetKeys returns the keys of the user, sql.ErrNoRows if there are none
+func (r *KeysRepository) GetKeys(email string) (model.UserKeys, error) {
+	k := model.UserKeys{User_email: email}
+	var params string
+	err := r.db.QueryRow(
+		`SELECT kdf, kdf_params, salt, wrapped_key, recovery_wrapped_key, version, updated_at
+		FROM user_keys WHERE user_email = $1`,
+		email,
+	).Scan(&k.Kdf, &params, &k.Salt, &k.Wrapped_key, &k.Recovery_wrapped_key, &k.Version, &k.Updated_at)
+	k.Kdf_params = json.RawMessage(params)
+	return k, err
+}
+
+// SetKeys stores the keys if they were not changed after k.Version was
+// read, version 0 creates them. k gets the new version.
+func (r *KeysRepository) SetKeys(k *model.UserKeys) error {
+	var err error
+	if k.Version == 0 {
+		err = r.db.QueryRow(
+			`INSERT INTO user_keys(user_email, kdf, kdf_params, salt, wrapped_key, recovery_wrapped_key)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (user_email) DO NOTHING
+			RETURNING version, updated_at`,
+			k.User_email, k.Kdf, string(k.Kdf_params), k.Salt, k.Wrapped_key, k.Recovery_wrapped_key,
+		).Scan(&k.Version, &k.Updated_at)
+	} else {
+		err = r.db.QueryRow(
+			`UPDATE user_keys
+			SET kdf = $2, kdf_params = $3, salt = $4, wrapped_key = $5, recovery_wrapped_key = $6,
+				version = version + 1, updated_at = now()
+			WHERE user_email = $1 AND version = $7
+			RETURNING version, updated_at`,
+			k.User_email, k.Kdf, string(k.Kdf_params), k.Salt, k.Wrapped_key, k.Recovery_wrapped_key, k.Version,
+		).Scan(&k.Version, &k.Updated_at)
+	}
+	if err == sql.ErrNoRows {
+		return model.ErrKeysVersion
+	}
+	return err
+}
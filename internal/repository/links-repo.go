@@ -0,0 +1,153 @@
+package repository
+
+import (
+	"database/sql"
+	"noteapp/internal/model"
+)
+
+type LinksRepository struct {
+	db *sql.DB
+}
+
+func NewLinksRepository(db *sql.DB) *LinksRepository {
+	return &LinksRepository{
+		db: db,
+	}
+}
+
+// SetLinks replaces the links of the note. Targets are looked up among
+// the notes of the owner: by id or by title, the oldest note wins.
+func (r *LinksRepository) SetLinks(sourceID int, owner string, links []model.NoteLink) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec("DELETE FROM note_links WHERE source_id = $1", sourceID); err != nil {
+		return err
+	}
+
+	for _, l := range links {
+		var refID interface{}
+		if l.Ref_id != 0 {
+			refID = l.Ref_id
+		}
+
+		_, err = tx.Exec(
+			`INSERT INTO note_links(source_id, target_id, title, ref_id, heading, alias)
+			VALUES ($1,
+				CASE WHEN $3::int IS NOT NULL
+					THEN (SELECT id FROM notes WHERE id = $3::int AND user_email = $2)
+					ELSE (SELECT id FROM notes WHERE user_email = $2 AND lower(title) = lower($4) ORDER BY id ASC LIMIT 1)
+				END,
+				$4, $3::int, $5, $6)`,
+			sourceID, owner, refID, l.Title, l.Heading, l.Alias,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ResolveLinks points broken links with the title to the note
+func (r *LinksRepository) ResolveLinks(owner string, noteID int, title string) error {
+	_, err := r.db.Exec(
+		`UPDATE note_links SET target_id = $1
+		WHERE target_id IS NULL AND title <> '' AND lower(title) = lower($2)
+			AND source_id IN (SELECT id FROM notes WHERE user_email = $3)`,
+		noteID, title, owner,
+	)
+	return err
+}
+
+// GetLinks returns links from the note with the current titles of targets
+func (r *LinksRepository) GetLinks(sourceID int) ([]model.NoteLink, error) {
+	res, err := r.db.Query(
+		`SELECT l.id, l.source_id, COALESCE(l.target_id, 0), COALESCE(n.title, ''),
+			l.title, COALESCE(l.ref_id, 0), l.heading, l.alias
+		FROM note_links l
+			LEFT JOIN notes n ON n.id = l.target_id
+		WHERE l.source_id = $1
+		ORDER BY l.id ASC`,
+		sourceID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+
+	links := []model.NoteLink{}
+	for res.Next() {
+		l := model.NoteLink{}
+		if err = res.Scan(&l.Id, &l.Source_id, &l.Target_id, &l.Target_title,
+			&l.Title, &l.Ref_id, &l.Heading, &l.Alias); err != nil {
+			return nil, err
+		}
+		l.Broken = l.Target_id == 0
+		links = append(links, l)
+	}
+
+	if err = res.Err(); err != nil {
+		return nil, err
+	}
+	return links, nil
+}
+
+// GetBacklinks returns notes which link to the note
+func (r *LinksRepository) GetBacklinks(targetID int) ([]model.Backlink, error) {
+	res, err := r.db.Query(
+		`SELECT DISTINCT n.id, n.title, COALESCE(n.group_id, 0)
+		FROM note_links l
+			JOIN notes n ON n.id = l.source_id
+		WHERE l.target_id = $1
+		ORDER BY n.id ASC`,
+		targetID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+
+	backlinks := []model.Backlink{}
+	for res.Next() {
+		b := model.Backlink{}
+		if err = res.Scan(&b.Note_id, &b.Title, &b.Group_id); err != nil {
+			return nil, err
+		}
+		backlinks = append(backlinks, b)
+	}
+
+	if err = res.Err(); err != nil {
+		return nil, err
+	}
+	return backlinks, nil
+}
+
+// GetTitleLinkSources returns notes which link to the note by its title
+func (r *LinksRepository) GetTitleLinkSources(targetID int) ([]int, error) {
+	res, err := r.db.Query(
+		"SELECT DISTINCT source_id FROM note_links WHERE target_id = $1 AND title <> '' ORDER BY source_id ASC",
+		targetID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+
+	ids := []int{}
+	for res.Next() {
+		var id int
+		if err = res.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	if err = res.Err(); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
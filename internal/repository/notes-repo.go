@@ -6,7 +6,9 @@ import (
 	"fmt"
 	"noteapp/internal/model"
 	"noteapp/pkg/logger"
+	"sort"
 	"strconv"
+	"time"
 )
 
 var (
@@ -27,29 +29,21 @@ func NewNotesRepository(db *sql.DB) *NotesRepository {
 
 // GROUPS
 
-func (r *NotesRepository) AddGroup(email string, nameGroup string, pid int) error {
+func (r *NotesRepository) AddGroup(email string, nameGroup string, pid int) (int, error) {
 
-	var res sql.Result
+	var id int
 	var err error
 
 	if pid == 0 {
-		res, err = r.db.Exec("INSERT INTO groups(user_email, name, pid) VALUES ($1, $2, $3)", email, nameGroup, nil)
+		err = r.db.QueryRow("INSERT INTO groups(user_email, name, pid) VALUES ($1, $2, $3) RETURNING id", email, nameGroup, nil).Scan(&id)
 	} else {
-		res, err = r.db.Exec("INSERT INTO groups(user_email, name, pid) VALUES ($1, $2, $3)", email, nameGroup, pid)
-	}
-
-	if err != nil {
-		return err
+		err = r.db.QueryRow("INSERT INTO groups(user_email, name, pid) VALUES ($1, $2, $3) RETURNING id", email, nameGroup, pid).Scan(&id)
 	}
 
-	count, err := res.RowsAffected()
-	if err != nil {
-		return err
-	}
-	if count == 0 {
-		return ErrInvalidData
+	if err == sql.ErrNoRows {
+		return 0, ErrInvalidData
 	}
-	return nil
+	return id, err
 }
 
 func (r *NotesRepository) DelGroup(id int, email string) error {
@@ -99,16 +93,18 @@ func (r *NotesRepository) UpdateGroup(id int, email string, newNameGroup string,
 
 // NOTES
 
-func (r *NotesRepository) AddNote(email string, title string, group_id int) error {
+func (r *NotesRepository) AddNote(email string, title string, group_id int) (int, error) {
+	var id int
+	var err error
 	if group_id == -1 {
-		_, err := r.db.Exec("INSERT INTO notes(user_email, title) VALUES ($1, $2)", email, title)
-		return err
+		err = r.db.QueryRow("INSERT INTO notes(user_email, title) VALUES ($1, $2) RETURNING id", email, title).Scan(&id)
 	} else {
-		_, err := r.db.Exec(`INSERT INTO notes(user_email, title, group_id) 
-							VALUES ($1, $2, (SELECT id as group_id FROM groups WHERE id = $3 AND user_email = $4))`,
-			email, title, group_id, email)
-		return err
+		err = r.db.QueryRow(`INSERT INTO notes(user_email, title, group_id) 
+							VALUES ($1, $2, (SELECT id as group_id FROM groups WHERE id = $3 AND user_email = $4))
+							RETURNING id`,
+			email, title, group_id, email).Scan(&id)
 	}
+	return id, err
 }
 
 func (r *NotesRepository) DelNote(id int, email string) error {
@@ -162,7 +158,7 @@ func (r *NotesRepository) GetNotesList(email string) (model.NoteList, error) {
 		`WITH RECURSIVE r AS (
 			SELECT id, pid, name, 1 AS level
 			FROM groups
-			WHERE user_email = $1 AND pid = 0 
+			WHERE user_email = $1 AND pid IS NULL
 
 			UNION
 
@@ -176,13 +172,23 @@ func (r *NotesRepository) GetNotesList(email string) (model.NoteList, error) {
 			COALESCE(r.name, '') AS group_name,
 			COALESCE(r.pid, 0) AS group_pid,
 			COALESCE(r.level, 1) AS group_level,
+			gf.id IS NOT NULL AS group_favorite,
 			COALESCE(notes.id, 0) AS notes_id,
 			COALESCE(notes.title, '') AS notes_title,
-			COALESCE(notes.text, '') AS notes_text
+			p.note_id IS NOT NULL AS notes_pinned,
+			p.created_at AS notes_pinned_at,
+			nf.id IS NOT NULL AS notes_favorite,
+			COALESCE(c.count, 0) AS notes_comments,
+			COALESCE(notes.encrypted, false) AS notes_encrypted
 		FROM r 
-			FULL OUTER JOIN notes
+			FULL OUTER JOIN (SELECT * FROM notes WHERE user_email = $1) notes
 				ON notes.group_id = r.id
-		ORDER BY group_level ASC, group_pid ASC, group_id ASC;`,
+			LEFT JOIN favorites gf ON gf.group_id = r.id AND gf.user_email = $1
+			LEFT JOIN favorites nf ON nf.note_id = notes.id AND nf.user_email = $1
+			LEFT JOIN pins p ON p.note_id = notes.id AND p.user_email = $1
+			LEFT JOIN (SELECT note_id, count(*) AS count FROM comments GROUP BY note_id) c
+				ON c.note_id = notes.id
+		ORDER BY group_level ASC, group_pid ASC, group_id ASC, notes.id ASC;`,
 		email,
 	)
 
@@ -195,12 +201,18 @@ func (r *NotesRepository) GetNotesList(email string) (model.NoteList, error) {
 	defer res.Close()
 
 	resRow := struct {
-		group_id    int
-		group_name  string
-		group_pid   int
-		group_level int
-		notes_id    int
-		notes_title string
+		group_id        int
+		group_name      string
+		group_pid       int
+		group_level     int
+		group_favorite  bool
+		notes_id        int
+		notes_title     string
+		notes_pinned    bool
+		notes_pinned_at sql.NullTime
+		notes_favorite  bool
+		notes_comments  int
+		notes_encrypted bool
 	}{}
 
 	gPid := 0
@@ -214,6 +226,8 @@ func (r *NotesRepository) GetNotesList(email string) (model.NoteList, error) {
 	mGroups := map[int]model.GroupElement{}
 	defer clear(mGroups)
 
+	pinnedAt := map[int]time.Time{}
+
 	for res.Next() {
 
 		if err := res.Scan(
@@ -221,16 +235,29 @@ func (r *NotesRepository) GetNotesList(email string) (model.NoteList, error) {
 			&resRow.group_name,
 			&resRow.group_pid,
 			&resRow.group_level,
+			&resRow.group_favorite,
 			&resRow.notes_id,
 			&resRow.notes_title,
+			&resRow.notes_pinned,
+			&resRow.notes_pinned_at,
+			&resRow.notes_favorite,
+			&resRow.notes_comments,
+			&resRow.notes_encrypted,
 		); err != nil {
 			return model.NoteList{}, err
 		}
+		if resRow.notes_pinned {
+			pinnedAt[resRow.notes_id] = resRow.notes_pinned_at.Time
+		}
 
 		if resRow.group_id == 0 {
 			notes = append(notes, model.NoteElement{
-				Id:    resRow.notes_id,
-				Title: resRow.notes_title,
+				Id:        resRow.notes_id,
+				Title:     resRow.notes_title,
+				Pinned:    resRow.notes_pinned,
+				Favorite:  resRow.notes_favorite,
+				Comments:  resRow.notes_comments,
+				Encrypted: resRow.notes_encrypted,
 			})
 			continue
 		} else {
@@ -253,6 +280,7 @@ func (r *NotesRepository) GetNotesList(email string) (model.NoteList, error) {
 				curGrp = model.GroupElement{}
 				curGrp.Id = resRow.group_id
 				curGrp.Name = resRow.group_name
+				curGrp.Favorite = resRow.group_favorite
 				curGrp.Groups = &[]model.GroupElement{}
 				curGrp.Notes = []model.NoteElement{}
 
@@ -262,8 +290,12 @@ func (r *NotesRepository) GetNotesList(email string) (model.NoteList, error) {
 
 			if resRow.notes_id != 0 {
 				curGrp.Notes = append(curGrp.Notes, model.NoteElement{
-					Id:    resRow.notes_id,
-					Title: resRow.notes_title,
+					Id:        resRow.notes_id,
+					Title:     resRow.notes_title,
+					Pinned:    resRow.notes_pinned,
+					Favorite:  resRow.notes_favorite,
+					Comments:  resRow.notes_comments,
+					Encrypted: resRow.notes_encrypted,
 				})
 			}
 		}
@@ -277,10 +309,38 @@ func (r *NotesRepository) GetNotesList(email string) (model.NoteList, error) {
 		return model.NoteList{}, err
 	}
 
-	return model.NoteList{
+	list := model.NoteList{
 		Groups: groups,
 		Notes:  notes,
-	}, nil
+	}
+	orderPinned(&list, pinnedAt)
+	return list, nil
+}
+
+// orderPinned puts the pinned notes of every group first, the last pinned
+// on top, the other notes keep their order
+func orderPinned(list *model.NoteList, pinnedAt map[int]time.Time) {
+	if len(pinnedAt) == 0 {
+		return
+	}
+	root := model.GroupElement{Notes: list.Notes, Groups: &list.Groups}
+	orderGroupPinned(&root, pinnedAt)
+}
+
+func orderGroupPinned(g *model.GroupElement, pinnedAt map[int]time.Time) {
+	sort.SliceStable(g.Notes, func(i, j int) bool {
+		a, aPinned := pinnedAt[g.Notes[i].Id]
+		b, bPinned := pinnedAt[g.Notes[j].Id]
+		if aPinned != bPinned {
+			return aPinned
+		}
+		return a.After(b)
+	})
+	if g.Groups != nil {
+		for i := range *g.Groups {
+			orderGroupPinned(&(*g.Groups)[i], pinnedAt)
+		}
+	}
 }
 
 func (r *NotesRepository) GetNote(id int, email string) (model.Note, error) {
@@ -288,10 +348,13 @@ func (r *NotesRepository) GetNote(id int, email string) (model.Note, error) {
 	var note model.Note
 
 	err := r.db.QueryRow(
-		"SELECT id, user_email, title, text, COALESCE(group_id,0) FROM notes WHERE id = $1 AND user_email = $2",
+		`SELECT id, user_email, title, COALESCE(text, ''), COALESCE(group_id,0), created_at, updated_at, version,
+			(SELECT count(*) FROM comments WHERE note_id = notes.id), encrypted
+		FROM notes WHERE id = $1 AND user_email = $2`,
 		id,
 		email,
-	).Scan(&note.Id, &note.User_email, &note.Title, &note.Text, &note.Group_id)
+	).Scan(&note.Id, &note.User_email, &note.Title, &note.Text, &note.Group_id, &note.Created_at, &note.Updated_at, &note.Version,
+		&note.Comments, &note.Encrypted)
 	if err != nil {
 		return note, err
 	}
@@ -335,6 +398,21 @@ func getRequestAndParams(data map[string]string) (string, []interface{}, error)
 		count++
 	}
 
+	if encrypted_string, ok := data["encrypted"]; ok {
+		encrypted, err := strconv.ParseBool(encrypted_string)
+		if err != nil {
+			logger.NewLog("repo - getRequestAndParams()", 2, err, "Filed to convert string to bool", "string = "+encrypted_string)
+			return "", nil, ErrFiledToConvert
+		}
+
+		if sqlRequest != "" {
+			sqlRequest += ","
+		}
+		sqlRequest += " encrypted = $" + strconv.Itoa(count) + " "
+		params = append(params, encrypted)
+		count++
+	}
+
 	if group_id_stringOK {
 
 		group_id, err := strconv.Atoi(group_id_string)
@@ -359,6 +437,18 @@ func getRequestAndParams(data map[string]string) (string, []interface{}, error)
 	sqlRequest = "UPDATE notes SET" + sqlRequest + "WHERE id = $" + strconv.Itoa(count) + " AND user_email = $" + strconv.Itoa(count+1)
 	params = append(params, id)
 	params = append(params, email)
+	count += 2
+
+	// optimistic locking - the note is changed only if nobody changed it before
+	if version_string, ok := data["version"]; ok {
+		version, err := strconv.Atoi(version_string)
+		if err != nil {
+			logger.NewLog("repo - getRequestAndParams()", 2, err, "Filed to convert string to int", "string = "+version_string)
+			return "", nil, ErrFiledToConvert
+		}
+		sqlRequest += " AND version = $" + strconv.Itoa(count)
+		params = append(params, version)
+	}
 
 	return sqlRequest, params, nil
 }
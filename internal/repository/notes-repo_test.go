@@ -0,0 +1,218 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"noteapp/internal/database"
+	"noteapp/internal/model"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// helperNotesDB connects to the database of configs/config.json, the test
+// is skipped if it is not running
+func helperNotesDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	data, err := os.ReadFile("../../configs/config.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := struct {
+		DataBase struct {
+			Host     string `json:"host"`
+			Username string `json:"username"`
+			Dbname   string `json:"dbname"`
+			Sslmode  string `json:"sslmode"`
+		} `json:"database"`
+	}{}
+	if err = json.Unmarshal(data, &config); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := database.NewPostgresConnection(database.ConnectionInfo{
+		Host:     config.DataBase.Host,
+		Username: config.DataBase.Username,
+		DBName:   config.DataBase.Dbname,
+		SSLMode:  config.DataBase.Sslmode,
+	})
+	if err != nil {
+		t.Skip("database is not available: " + err.Error())
+	}
+	return db
+}
+
+func TestGetNotesList(t *testing.T) {
+	db := helperNotesDB(t)
+	defer db.Close()
+
+	users := []string{"listUser", "listOtherUser"}
+	for _, email := range users {
+		if _, err := db.Exec("INSERT INTO users(email, password) VALUES ($1, 'secretPassword')", email); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer db.Exec("DELETE FROM users WHERE email = ANY($1)", "{listUser,listOtherUser}")
+
+	// every user has a root group with a subgroup, a note in each of them
+	// and a note without a group
+	for _, email := range users {
+		var root, sub int
+		if err := db.QueryRow("INSERT INTO groups(user_email, name) VALUES ($1, 'root') RETURNING id", email).Scan(&root); err != nil {
+			t.Fatal(err)
+		}
+		if err := db.QueryRow("INSERT INTO groups(user_email, name, pid) VALUES ($1, 'sub', $2) RETURNING id", email, root).Scan(&sub); err != nil {
+			t.Fatal(err)
+		}
+		for _, group := range []any{root, sub, nil} {
+			if _, err := db.Exec("INSERT INTO notes(user_email, title, group_id) VALUES ($1, $1, $2)", email, group); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	type group struct {
+		name   string
+		notes  []string
+		groups []group
+	}
+	var tree func(list []model.GroupElement) []group
+	tree = func(list []model.GroupElement) []group {
+		res := []group{}
+		for _, g := range list {
+			e := group{name: g.Name, notes: []string{}, groups: tree(*g.Groups)}
+			for _, n := range g.Notes {
+				e.notes = append(e.notes, n.Title)
+			}
+			res = append(res, e)
+		}
+		return res
+	}
+
+	testCases := []struct {
+		name       string
+		email      string
+		wantGroups []group
+		wantNotes  []string
+	}{
+		{
+			name:  "groups and notes of the user",
+			email: "listUser",
+			wantGroups: []group{{
+				name:   "root",
+				notes:  []string{"listUser"},
+				groups: []group{{name: "sub", notes: []string{"listUser"}, groups: []group{}}},
+			}},
+			wantNotes: []string{"listUser"},
+		},
+		{
+			name:       "user without notes",
+			email:      "unknownUser",
+			wantGroups: []group{},
+			wantNotes:  []string{},
+		},
+	}
+
+	r := NewNotesRepository(db)
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			list, err := r.GetNotesList(tcase.email)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			notes := []string{}
+			for _, n := range list.Notes {
+				notes = append(notes, n.Title)
+			}
+			assert.Equal(t, tcase.wantGroups, tree(list.Groups))
+			assert.Equal(t, tcase.wantNotes, notes)
+		})
+	}
+}
+
+func TestOrderPinned(t *testing.T) {
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	notes := func(ids ...int) []model.NoteElement {
+		list := []model.NoteElement{}
+		for _, id := range ids {
+			list = append(list, model.NoteElement{Id: id})
+		}
+		return list
+	}
+	ids := func(list []model.NoteElement) []int {
+		res := []int{}
+		for _, n := range list {
+			res = append(res, n.Id)
+		}
+		return res
+	}
+
+	testCases := []struct {
+		name     string
+		root     []int
+		group    []int
+		sub      []int
+		pinnedAt map[int]time.Time
+		// order of the notes after
+		wantRoot  []int
+		wantGroup []int
+		wantSub   []int
+	}{
+		{
+			name:      "no pins",
+			root:      []int{1, 2, 3},
+			group:     []int{4, 5},
+			sub:       []int{6, 7},
+			wantRoot:  []int{1, 2, 3},
+			wantGroup: []int{4, 5},
+			wantSub:   []int{6, 7},
+		},
+		{
+			name:      "pinned first",
+			root:      []int{1, 2, 3},
+			group:     []int{4, 5},
+			sub:       []int{6, 7},
+			pinnedAt:  map[int]time.Time{3: day},
+			wantRoot:  []int{3, 1, 2},
+			wantGroup: []int{4, 5},
+			wantSub:   []int{6, 7},
+		},
+		{
+			name:      "last pinned on top",
+			root:      []int{1, 2, 3, 4},
+			pinnedAt:  map[int]time.Time{2: day, 4: day.Add(time.Hour)},
+			wantRoot:  []int{4, 2, 1, 3},
+			wantGroup: []int{},
+			wantSub:   []int{},
+		},
+		{
+			name:      "every group on its own",
+			root:      []int{1, 2},
+			group:     []int{3, 4, 5},
+			sub:       []int{6, 7},
+			pinnedAt:  map[int]time.Time{5: day, 7: day.Add(time.Hour)},
+			wantRoot:  []int{1, 2},
+			wantGroup: []int{5, 3, 4},
+			wantSub:   []int{7, 6},
+		},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			sub := model.GroupElement{Id: 2, Groups: &[]model.GroupElement{}, Notes: notes(tcase.sub...)}
+			group := model.GroupElement{Id: 1, Groups: &[]model.GroupElement{sub}, Notes: notes(tcase.group...)}
+			list := model.NoteList{Groups: []model.GroupElement{group}, Notes: notes(tcase.root...)}
+
+			orderPinned(&list, tcase.pinnedAt)
+
+			assert.Equal(t, tcase.wantRoot, ids(list.Notes))
+			assert.Equal(t, tcase.wantGroup, ids(list.Groups[0].Notes))
+			assert.Equal(t, tcase.wantSub, ids((*list.Groups[0].Groups)[0].Notes))
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"noteapp/internal/model"
+)
+
+var (
+	ErrLinkNotFound = errors.New("link not found")
+)
+
+type PublicLinksRepository struct {
+	db *sql.DB
+}
+
+func NewPublicLinksRepository(db *sql.DB) *PublicLinksRepository {
+	return &PublicLinksRepository{
+		db: db,
+	}
+}
+
+func (r *PublicLinksRepository) AddLink(l *model.PublicLink) error {
+	var noteID, groupID, password interface{}
+	if l.Note_id != 0 {
+		noteID = l.Note_id
+	} else {
+		groupID = l.Group_id
+	}
+	if l.Password != "" {
+		password = l.Password
+	}
+
+	return r.db.QueryRow(
+		`INSERT INTO public_links(token, owner_email, note_id, group_id, password, expires_at, feed)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at`,
+		l.Token, l.Owner_email, noteID, groupID, password, l.Expires_at, l.Feed,
+	).Scan(&l.Id, &l.Created_at)
+}
+
+func (r *PublicLinksRepository) DelLink(id int, ownerEmail string) error {
+	res, err := r.db.Exec("DELETE FROM public_links WHERE id = $1 AND owner_email = $2", id, ownerEmail)
+	if err != nil {
+		return err
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrInvalidData
+	}
+	return nil
+}
+
+func (r *PublicLinksRepository) GetLinks(ownerEmail string) ([]model.PublicLink, error) {
+	res, err := r.db.Query(
+		`SELECT id, token, owner_email, COALESCE(note_id, 0), COALESCE(group_id, 0),
+			COALESCE(password, ''), expires_at, feed, views, created_at
+		FROM public_links WHERE owner_email = $1 ORDER BY id ASC`,
+		ownerEmail,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+
+	links := []model.PublicLink{}
+	for res.Next() {
+		l, err := scanLink(res)
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, *l)
+	}
+
+	if err = res.Err(); err != nil {
+		return nil, err
+	}
+	return links, nil
+}
+
+func (r *PublicLinksRepository) GetLinkByToken(token string) (*model.PublicLink, error) {
+	l, err := scanLink(r.db.QueryRow(
+		`SELECT id, token, owner_email, COALESCE(note_id, 0), COALESCE(group_id, 0),
+			COALESCE(password, ''), expires_at, feed, views, created_at
+		FROM public_links WHERE token = $1`,
+		token,
+	))
+	if err == sql.ErrNoRows {
+		return nil, ErrLinkNotFound
+	}
+	return l, err
+}
+
+func (r *PublicLinksRepository) IncViews(id int) error {
+	_, err := r.db.Exec("UPDATE public_links SET views = views + 1 WHERE id = $1", id)
+	return err
+}
+
+// NoteInGroup reports whether the note lies inside the group subtree
+func (r *PublicLinksRepository) NoteInGroup(noteID int, groupID int) (bool, error) {
+	var ok bool
+	err := r.db.QueryRow(
+		`WITH RECURSIVE a AS (
+			SELECT groups.id, groups.pid
+			FROM groups
+				JOIN notes
+					ON notes.group_id = groups.id
+			WHERE notes.id = $1
+
+			UNION
+
+			SELECT groups.id, groups.pid
+			FROM groups
+				JOIN a
+					ON groups.id = a.pid
+		)
+		SELECT EXISTS (SELECT 1 FROM a WHERE id = $2)`,
+		noteID, groupID,
+	).Scan(&ok)
+	return ok, err
+}
+
+// GetFeedNotes returns the last updated notes of the group subtree,
+// newest first
+func (r *PublicLinksRepository) GetFeedNotes(groupID int, limit int) ([]model.Note, error) {
+	rows, err := r.db.Query(
+		`WITH RECURSIVE subtree AS (
+			SELECT id FROM groups WHERE id = $1
+
+			UNION
+
+			SELECT groups.id
+			FROM groups
+				JOIN subtree
+					ON groups.pid = subtree.id
+		)
+		SELECT id, title, COALESCE(text, ''), COALESCE(group_id, 0), version, created_at, updated_at
+		FROM notes
+		WHERE group_id IN (SELECT id FROM subtree) AND NOT encrypted
+		ORDER BY updated_at DESC, id DESC
+		LIMIT $2`,
+		groupID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notes := []model.Note{}
+	for rows.Next() {
+		n := model.Note{}
+		if err := rows.Scan(&n.Id, &n.Title, &n.Text, &n.Group_id, &n.Version, &n.Created_at, &n.Updated_at); err != nil {
+			return nil, err
+		}
+		notes = append(notes, n)
+	}
+	return notes, rows.Err()
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanLink(row rowScanner) (*model.PublicLink, error) {
+	l := model.PublicLink{}
+	var expires sql.NullTime
+	if err := row.Scan(
+		&l.Id,
+		&l.Token,
+		&l.Owner_email,
+		&l.Note_id,
+		&l.Group_id,
+		&l.Password,
+		&expires,
+		&l.Feed,
+		&l.Views,
+		&l.Created_at,
+	); err != nil {
+		return nil, err
+	}
+	if expires.Valid {
+		l.Expires_at = &expires.Time
+	}
+	l.Protected = l.Password != ""
+	return &l, nil
+}
@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"database/sql"
+	"noteapp/internal/model"
+)
+
+type RecentRepository struct {
+	db *sql.DB
+}
+
+func NewRecentRepository(db *sql.DB) *RecentRepository {
+	return &RecentRepository{
+		db: db,
+	}
+}
+
+// AddActivity stores the views and edits, older times never replace newer
+// ones. Notes deleted in the meantime are skipped.
+func (r *RecentRepository) AddActivity(list []model.NoteActivity) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, a := range list {
+		var viewed, edited interface{}
+		if !a.Viewed_at.IsZero() {
+			viewed = a.Viewed_at
+		}
+		if !a.Edited_at.IsZero() {
+			edited = a.Edited_at
+		}
+
+		_, err = tx.Exec(
+			`INSERT INTO note_activity(user_email, note_id, viewed_at, edited_at)
+			SELECT $1, id, $3::timestamptz, $4::timestamptz FROM notes WHERE id = $2
+			ON CONFLICT (user_email, note_id) DO UPDATE
+			SET viewed_at = GREATEST(note_activity.viewed_at, EXCLUDED.viewed_at),
+				edited_at = GREATEST(note_activity.edited_at, EXCLUDED.edited_at)`,
+			a.User_email, a.Note_id, viewed, edited,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetRecent returns the last viewed or, if edited is true, the last edited
+// notes of the user, newest first. Notes of other users are returned while
+// they are shared with the user, directly or by a group or its parent.
+func (r *RecentRepository) GetRecent(email string, edited bool, limit int) ([]model.RecentNote, error) {
+	column := "a.viewed_at"
+	if edited {
+		column = "a.edited_at"
+	}
+
+	rows, err := r.db.Query(
+		`WITH RECURSIVE shared AS (
+			SELECT group_id AS id
+			FROM shares
+			WHERE user_email = $1 AND group_id IS NOT NULL
+
+			UNION
+
+			SELECT groups.id
+			FROM groups
+				JOIN shared
+					ON groups.pid = shared.id
+		)
+		SELECT n.id, n.title, COALESCE(n.group_id, 0), n.user_email, n.encrypted, `+column+`
+		FROM note_activity a
+			JOIN notes n ON n.id = a.note_id
+		WHERE a.user_email = $1 AND `+column+` IS NOT NULL
+			AND (n.user_email = $1
+				OR n.group_id IN (SELECT id FROM shared)
+				OR EXISTS (SELECT 1 FROM shares WHERE user_email = $1 AND note_id = n.id))
+		ORDER BY `+column+` DESC
+		LIMIT $2`,
+		email, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []model.RecentNote{}
+	for rows.Next() {
+		n := model.RecentNote{}
+		if err := rows.Scan(&n.Note_id, &n.Title, &n.Group_id, &n.Owner_email, &n.Encrypted, &n.At); err != nil {
+			return nil, err
+		}
+		list = append(list, n)
+	}
+	return list, rows.Err()
+}
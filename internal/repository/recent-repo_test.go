@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"noteapp/internal/model"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetRecent(t *testing.T) {
+	db := helperNotesDB(t)
+	defer db.Close()
+
+	const (
+		user  = "recentUser"
+		owner = "recentOwner"
+	)
+	for _, email := range []string{user, owner} {
+		if _, err := db.Exec("INSERT INTO users(email, password) VALUES ($1, 'secretPassword')", email); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer db.Exec("DELETE FROM users WHERE email = ANY($1)", "{recentUser,recentOwner}")
+
+	// the note of the subgroup is read through the shared parent group
+	var shared, sub int
+	if err := db.QueryRow("INSERT INTO groups(user_email, name) VALUES ($1, 'shared') RETURNING id", owner).Scan(&shared); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.QueryRow("INSERT INTO groups(user_email, name, pid) VALUES ($1, 'sub', $2) RETURNING id", owner, shared).Scan(&sub); err != nil {
+		t.Fatal(err)
+	}
+
+	add := func(email string, title string, group any) int {
+		var id int
+		if err := db.QueryRow("INSERT INTO notes(user_email, title, group_id) VALUES ($1, $2, $3) RETURNING id", email, title, group).Scan(&id); err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	own := add(user, "own", nil)
+	direct := add(owner, "direct", nil)
+	inSub := add(owner, "in sub", sub)
+	private := add(owner, "private", nil)
+
+	if _, err := db.Exec("INSERT INTO shares(owner_email, user_email, note_id, permission) VALUES ($1, $2, $3, 'read')", owner, user, direct); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("INSERT INTO shares(owner_email, user_email, group_id, permission) VALUES ($1, $2, $3, 'read')", owner, user, shared); err != nil {
+		t.Fatal(err)
+	}
+
+	// the private note is viewed last, it must not take the place of the others
+	r := NewRecentRepository(db)
+	now := time.Now()
+	activity := []model.NoteActivity{}
+	for i, id := range []int{own, direct, inSub, private} {
+		activity = append(activity, model.NoteActivity{User_email: user, Note_id: id, Viewed_at: now.Add(time.Duration(i) * time.Minute)})
+	}
+	assert.NoError(t, r.AddActivity(activity))
+
+	list, err := r.GetRecent(user, false, 3)
+	assert.NoError(t, err)
+	got := []string{}
+	for _, n := range list {
+		got = append(got, n.Title)
+	}
+	assert.Equal(t, []string{"in sub", "direct", "own"}, got)
+}
@@ -0,0 +1,162 @@
+package repository
+
+import (
+	"database/sql"
+	"noteapp/internal/model"
+)
+
+type RemindersRepository struct {
+	db *sql.DB
+}
+
+func NewRemindersRepository(db *sql.DB) *RemindersRepository {
+	return &RemindersRepository{
+		db: db,
+	}
+}
+
+func (r *RemindersRepository) AddReminder(rem *model.Reminder) error {
+	err := r.db.QueryRow(
+		`INSERT INTO reminders(note_id, user_email, message, due_at, remind_at)
+		SELECT id, $2, $3, $4, $5 FROM notes WHERE id = $1
+		RETURNING id, created_at, (SELECT title FROM notes WHERE id = $1)`,
+		rem.Note_id, rem.User_email, rem.Message, rem.Due_at, rem.Remind_at,
+	).Scan(&rem.Id, &rem.Created_at, &rem.Title)
+	if err == sql.ErrNoRows {
+		return ErrInvalidData
+	}
+	return err
+}
+
+func (r *RemindersRepository) DelReminder(id int, email string) error {
+	res, err := r.db.Exec("DELETE FROM reminders WHERE id = $1 AND user_email = $2", id, email)
+	if err != nil {
+		return err
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrInvalidData
+	}
+	return nil
+}
+
+// GetReminders returns all reminders of the user on the note or, if
+// noteID is 0, the reminders of the user which have not fired yet
+func (r *RemindersRepository) GetReminders(email string, noteID int) ([]model.Reminder, error) {
+	rows, err := r.db.Query(
+		`SELECT r.id, r.note_id, r.user_email, n.title, r.message, r.due_at, r.remind_at, r.fired_at, r.created_at
+		FROM reminders r JOIN notes n ON n.id = r.note_id
+		WHERE r.user_email = $1 AND (CASE WHEN $2 = 0 THEN r.fired_at IS NULL ELSE r.note_id = $2 END)
+		ORDER BY r.remind_at ASC, r.id ASC`,
+		email, noteID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []model.Reminder{}
+	for rows.Next() {
+		rem := model.Reminder{}
+		var fired sql.NullTime
+		err := rows.Scan(&rem.Id, &rem.Note_id, &rem.User_email, &rem.Title, &rem.Message,
+			&rem.Due_at, &rem.Remind_at, &fired, &rem.Created_at)
+		if err != nil {
+			return nil, err
+		}
+		if fired.Valid {
+			rem.Fired_at = &fired.Time
+		}
+		list = append(list, rem)
+	}
+	return list, rows.Err()
+}
+
+// FireDue marks up to limit due reminders as fired and adds them to the
+// notifications in one statement. Rows taken by another instance are
+// skipped, so every reminder fires once however many schedulers run.
+// Notifications leave the app, titles of encrypted notes are not sent.
+func (r *RemindersRepository) FireDue(limit int) ([]model.Notification, error) {
+	rows, err := r.db.Query(
+		`WITH due AS (
+			SELECT id FROM reminders
+			WHERE fired_at IS NULL AND remind_at <= now()
+			ORDER BY remind_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		), fired AS (
+			UPDATE reminders r SET fired_at = now()
+			FROM due WHERE r.id = due.id
+			RETURNING r.id, r.note_id, r.user_email, r.message, r.due_at
+		)
+		INSERT INTO notifications(user_email, note_id, reminder_id, title, message, due_at)
+		SELECT f.user_email, f.note_id, f.id,
+			CASE WHEN n.encrypted THEN 'Encrypted note' ELSE n.title END,
+			f.message, f.due_at
+		FROM fired f JOIN notes n ON n.id = f.note_id
+		RETURNING id, user_email, note_id, reminder_id, title, message, due_at, read, created_at`,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanNotifications(rows)
+}
+
+// GetNotifications returns the last notifications of the user, newest first
+func (r *RemindersRepository) GetNotifications(email string, unread bool, limit int) ([]model.Notification, error) {
+	rows, err := r.db.Query(
+		`SELECT id, user_email, note_id, reminder_id, title, message, due_at, read, created_at
+		FROM notifications
+		WHERE user_email = $1 AND NOT (read AND $2)
+		ORDER BY id DESC
+		LIMIT $3`,
+		email, unread, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanNotifications(rows)
+}
+
+// ReadNotification marks the notification as read, id 0 - all of them
+func (r *RemindersRepository) ReadNotification(id int, email string) error {
+	res, err := r.db.Exec(
+		"UPDATE notifications SET read = true WHERE user_email = $1 AND ($2 = 0 OR id = $2)",
+		email, id,
+	)
+	if err != nil {
+		return err
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 && id != 0 {
+		return ErrInvalidData
+	}
+	return nil
+}
+
+func scanNotifications(rows *sql.Rows) ([]model.Notification, error) {
+	defer rows.Close()
+
+	list := []model.Notification{}
+	for rows.Next() {
+		n := model.Notification{}
+		var reminderID sql.NullInt64
+		err := rows.Scan(&n.Id, &n.User_email, &n.Note_id, &reminderID, &n.Title, &n.Message,
+			&n.Due_at, &n.Read, &n.Created_at)
+		if err != nil {
+			return nil, err
+		}
+		n.Reminder_id = int(reminderID.Int64)
+		list = append(list, n)
+	}
+	return list, rows.Err()
+}
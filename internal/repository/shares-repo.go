@@ -0,0 +1,184 @@
+package repository
+
+import (
+	"database/sql"
+	"noteapp/internal/model"
+)
+
+type SharesRepository struct {
+	db *sql.DB
+}
+
+func NewSharesRepository(db *sql.DB) *SharesRepository {
+	return &SharesRepository{
+		db: db,
+	}
+}
+
+// SHARES
+
+func (r *SharesRepository) AddShare(s *model.Share) error {
+	var err error
+
+	if s.Note_id != 0 {
+		err = r.db.QueryRow(
+			`INSERT INTO shares(owner_email, user_email, note_id, permission) VALUES ($1, $2, $3, $4)
+			ON CONFLICT (user_email, note_id) DO UPDATE SET permission = EXCLUDED.permission
+			RETURNING id, created_at`,
+			s.Owner_email, s.User_email, s.Note_id, s.Permission,
+		).Scan(&s.Id, &s.Created_at)
+	} else {
+		err = r.db.QueryRow(
+			`INSERT INTO shares(owner_email, user_email, group_id, permission) VALUES ($1, $2, $3, $4)
+			ON CONFLICT (user_email, group_id) DO UPDATE SET permission = EXCLUDED.permission
+			RETURNING id, created_at`,
+			s.Owner_email, s.User_email, s.Group_id, s.Permission,
+		).Scan(&s.Id, &s.Created_at)
+	}
+
+	return err
+}
+
+func (r *SharesRepository) DelShare(id int, ownerEmail string) error {
+	res, err := r.db.Exec("DELETE FROM shares WHERE id = $1 AND owner_email = $2", id, ownerEmail)
+	if err != nil {
+		return err
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrInvalidData
+	}
+	return nil
+}
+
+func (r *SharesRepository) GetSharesByOwner(ownerEmail string) ([]model.Share, error) {
+	return r.getShares(
+		`SELECT id, owner_email, user_email, COALESCE(note_id, 0), COALESCE(group_id, 0), permission, created_at
+		FROM shares WHERE owner_email = $1 ORDER BY id ASC`,
+		ownerEmail,
+	)
+}
+
+func (r *SharesRepository) GetSharesForUser(email string) ([]model.Share, error) {
+	return r.getShares(
+		`SELECT id, owner_email, user_email, COALESCE(note_id, 0), COALESCE(group_id, 0), permission, created_at
+		FROM shares WHERE user_email = $1 ORDER BY id ASC`,
+		email,
+	)
+}
+
+func (r *SharesRepository) getShares(query string, email string) ([]model.Share, error) {
+	res, err := r.db.Query(query, email)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+
+	shares := []model.Share{}
+	for res.Next() {
+		s := model.Share{}
+		if err := res.Scan(
+			&s.Id,
+			&s.Owner_email,
+			&s.User_email,
+			&s.Note_id,
+			&s.Group_id,
+			&s.Permission,
+			&s.Created_at,
+		); err != nil {
+			return nil, err
+		}
+		shares = append(shares, s)
+	}
+
+	if err = res.Err(); err != nil {
+		return nil, err
+	}
+	return shares, nil
+}
+
+// ACCESS
+
+func (r *SharesRepository) NoteOwner(noteID int) (string, error) {
+	var email string
+	err := r.db.QueryRow("SELECT user_email FROM notes WHERE id = $1", noteID).Scan(&email)
+	if err == sql.ErrNoRows {
+		return "", ErrInvalidData
+	}
+	return email, err
+}
+
+func (r *SharesRepository) GroupOwner(groupID int) (string, error) {
+	var email string
+	err := r.db.QueryRow("SELECT user_email FROM groups WHERE id = $1", groupID).Scan(&email)
+	if err == sql.ErrNoRows {
+		return "", ErrInvalidData
+	}
+	return email, err
+}
+
+// NotePermission returns the strongest permission the user has on the note,
+// either shared directly or through one of the parent groups.
+// Empty string means the note is not shared with the user.
+func (r *SharesRepository) NotePermission(noteID int, email string) (string, error) {
+	return r.permission(
+		`WITH RECURSIVE a AS (
+			SELECT groups.id, groups.pid
+			FROM groups
+				JOIN notes
+					ON notes.group_id = groups.id
+			WHERE notes.id = $1
+
+			UNION
+
+			SELECT groups.id, groups.pid
+			FROM groups
+				JOIN a
+					ON groups.id = a.pid
+		)
+		SELECT permission
+		FROM shares
+		WHERE user_email = $2 AND (note_id = $1 OR group_id IN (SELECT id FROM a))
+		ORDER BY permission = 'edit' DESC
+		LIMIT 1`,
+		noteID, email,
+	)
+}
+
+// GroupPermission returns the strongest permission the user has on the group
+// or on one of its parents. Empty string means the group is not shared with the user.
+func (r *SharesRepository) GroupPermission(groupID int, email string) (string, error) {
+	return r.permission(
+		`WITH RECURSIVE a AS (
+			SELECT id, pid
+			FROM groups
+			WHERE id = $1
+
+			UNION
+
+			SELECT groups.id, groups.pid
+			FROM groups
+				JOIN a
+					ON groups.id = a.pid
+		)
+		SELECT permission
+		FROM shares
+		WHERE user_email = $2 AND group_id IN (SELECT id FROM a)
+		ORDER BY permission = 'edit' DESC
+		LIMIT 1`,
+		groupID, email,
+	)
+}
+
+func (r *SharesRepository) permission(query string, id int, email string) (string, error) {
+	var permission string
+	err := r.db.QueryRow(query, id, email).Scan(&permission)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return permission, err
+}
@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"database/sql"
+	"noteapp/internal/model"
+	"time"
+)
+
+type SyncRepository struct {
+	db *sql.DB
+}
+
+func NewSyncRepository(db *sql.DB) *SyncRepository {
+	return &SyncRepository{
+		db: db,
+	}
+}
+
+func (r *SyncRepository) GetChanges(email string, cursor int64, limit int) ([]model.Change, error) {
+	res, err := r.db.Query(
+		`SELECT id, entity, entity_id, action
+		FROM changes
+		WHERE user_email = $1 AND id > $2
+		ORDER BY id ASC
+		LIMIT $3`,
+		email, cursor, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+
+	changes := []model.Change{}
+	for res.Next() {
+		c := model.Change{}
+		if err := res.Scan(&c.Id, &c.Entity, &c.Entity_id, &c.Action); err != nil {
+			return nil, err
+		}
+		changes = append(changes, c)
+	}
+
+	if err = res.Err(); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// GetHorizon returns the last pruned change of the user, 0 if nothing was pruned
+func (r *SyncRepository) GetHorizon(email string) (int64, error) {
+	var id int64
+	err := r.db.QueryRow(
+		`SELECT change_id FROM changes_horizon WHERE user_email = $1`,
+		email,
+	).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return id, err
+}
+
+// PruneChanges keeps only the last change of every object and drops the
+// deletes made before the time. A client behind a dropped change only
+// misses deletes, the last dropped change of every user becomes its horizon.
+func (r *SyncRepository) PruneChanges(before time.Time) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		`DELETE FROM changes c
+		USING changes newer
+		WHERE newer.user_email = c.user_email AND newer.entity = c.entity
+			AND newer.entity_id = c.entity_id AND newer.id > c.id`,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		`WITH pruned AS (
+			DELETE FROM changes WHERE action = 'delete' AND created_at < $1
+			RETURNING user_email, id
+		)
+		INSERT INTO changes_horizon(user_email, change_id)
+		SELECT user_email, max(id) FROM pruned GROUP BY user_email
+		ON CONFLICT (user_email) DO UPDATE
+		SET change_id = GREATEST(changes_horizon.change_id, EXCLUDED.change_id)`,
+		before,
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *SyncRepository) GetGroup(id int, email string) (model.Group, error) {
+	g := model.Group{}
+	err := r.db.QueryRow(
+		`SELECT id, name, user_email, COALESCE(pid, 0), created_at, updated_at, version
+		FROM groups WHERE id = $1 AND user_email = $2`,
+		id, email,
+	).Scan(&g.Id, &g.Name, &g.User_email, &g.Pid, &g.Created_at, &g.Updated_at, &g.Version)
+	if err == sql.ErrNoRows {
+		return g, ErrInvalidData
+	}
+	return g, err
+}
@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"noteapp/internal/model"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPruneChanges(t *testing.T) {
+	db := helperNotesDB(t)
+	defer db.Close()
+
+	email := "pruneUser"
+	if _, err := db.Exec("INSERT INTO users(email, password) VALUES ($1, 'secretPassword')", email); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Exec("DELETE FROM users WHERE email = $1", email)
+
+	// the kept note is changed twice, the other one is deleted long ago
+	var kept, deleted int
+	if err := db.QueryRow("INSERT INTO notes(user_email, title) VALUES ($1, 'kept') RETURNING id", email).Scan(&kept); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.QueryRow("INSERT INTO notes(user_email, title) VALUES ($1, 'deleted') RETURNING id", email).Scan(&deleted); err != nil {
+		t.Fatal(err)
+	}
+	for _, query := range []string{
+		"UPDATE notes SET text = 'one' WHERE id = $1",
+		"UPDATE notes SET text = 'two' WHERE id = $1",
+	} {
+		if _, err := db.Exec(query, kept); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := db.Exec("DELETE FROM notes WHERE id = $1", deleted); err != nil {
+		t.Fatal(err)
+	}
+	var deleteID int64
+	err := db.QueryRow(
+		`UPDATE changes SET created_at = now() - interval '40 days'
+		WHERE user_email = $1 AND entity_id = $2 AND action = 'delete' RETURNING id`,
+		email, deleted,
+	).Scan(&deleteID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewSyncRepository(db)
+	horizon, err := r.GetHorizon(email)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), horizon)
+
+	assert.NoError(t, r.PruneChanges(time.Now().Add(-30*24*time.Hour)))
+
+	changes, err := r.GetChanges(email, 0, 100)
+	assert.NoError(t, err)
+	assert.Len(t, changes, 1)
+	if len(changes) == 1 {
+		assert.Equal(t, kept, changes[0].Entity_id)
+		assert.Equal(t, model.ActionUpsert, changes[0].Action)
+	}
+
+	horizon, err = r.GetHorizon(email)
+	assert.NoError(t, err)
+	assert.Equal(t, deleteID, horizon)
+}
@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"database/sql"
+	"noteapp/internal/model"
+)
+
+type TasksRepository struct {
+	db *sql.DB
+}
+
+func NewTasksRepository(db *sql.DB) *TasksRepository {
+	return &TasksRepository{
+		db: db,
+	}
+}
+
+// SetTasks replaces the tasks of the note
+func (r *TasksRepository) SetTasks(noteID int, owner string, list []model.Task) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec("DELETE FROM tasks WHERE note_id = $1", noteID); err != nil {
+		return err
+	}
+
+	for _, t := range list {
+		_, err = tx.Exec(
+			`INSERT INTO tasks(note_id, user_email, line, text, done, due)
+			VALUES ($1, $2, $3, $4, $5, NULLIF($6, '')::date)`,
+			noteID, owner, t.Line, t.Text, t.Done, t.Due,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetTasks returns the open tasks of the user, the nearest due dates first
+func (r *TasksRepository) GetTasks(email string, f model.TaskFilter) ([]model.Task, error) {
+	rows, err := r.db.Query(
+		`WITH RECURSIVE subtree AS (
+			SELECT id FROM groups WHERE id = $2 AND user_email = $1
+			UNION ALL
+			SELECT g.id FROM groups g JOIN subtree s ON g.pid = s.id
+		)
+		SELECT t.id, t.note_id, n.title, COALESCE(n.group_id, 0), t.line, t.text, t.done,
+			COALESCE(to_char(t.due, 'YYYY-MM-DD'), '')
+		FROM tasks t JOIN notes n ON n.id = t.note_id
+		WHERE t.user_email = $1 AND NOT t.done
+			AND ($2 = 0 OR n.group_id IN (SELECT id FROM subtree))
+			AND ($3 = '' OR t.due >= $3::date)
+			AND ($4 = '' OR t.due <= $4::date)
+		ORDER BY t.due ASC NULLS LAST, n.title ASC, t.note_id ASC, t.line ASC`,
+		email, f.Group_id, f.Due_from, f.Due_to,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []model.Task{}
+	for rows.Next() {
+		t := model.Task{}
+		err := rows.Scan(&t.Id, &t.Note_id, &t.Note_title, &t.Group_id, &t.Line, &t.Text, &t.Done, &t.Due)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, t)
+	}
+	return list, rows.Err()
+}
+
+func (r *TasksRepository) GetTask(id int, email string) (model.Task, error) {
+	t := model.Task{}
+	err := r.db.QueryRow(
+		`SELECT t.id, t.note_id, n.title, COALESCE(n.group_id, 0), t.line, t.text, t.done,
+			COALESCE(to_char(t.due, 'YYYY-MM-DD'), '')
+		FROM tasks t JOIN notes n ON n.id = t.note_id
+		WHERE t.id = $1 AND t.user_email = $2`,
+		id, email,
+	).Scan(&t.Id, &t.Note_id, &t.Note_title, &t.Group_id, &t.Line, &t.Text, &t.Done, &t.Due)
+	if err == sql.ErrNoRows {
+		return t, ErrInvalidData
+	}
+	return t, err
+}
@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"database/sql"
+	"noteapp/internal/model"
+)
+
+type TemplatesRepository struct {
+	db *sql.DB
+}
+
+func NewTemplatesRepository(db *sql.DB) *TemplatesRepository {
+	return &TemplatesRepository{
+		db: db,
+	}
+}
+
+// AddTemplate returns ErrInvalidData if the user has a template
+// with the same name
+func (r *TemplatesRepository) AddTemplate(t *model.Template) error {
+	err := r.db.QueryRow(
+		`INSERT INTO templates(user_email, name, title, text)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_email, name) DO NOTHING
+		RETURNING id, created_at, updated_at`,
+		t.User_email, t.Name, t.Title, t.Text,
+	).Scan(&t.Id, &t.Created_at, &t.Updated_at)
+	if err == sql.ErrNoRows {
+		return ErrInvalidData
+	}
+	return err
+}
+
+func (r *TemplatesRepository) UpdateTemplate(t *model.Template) error {
+	err := r.db.QueryRow(
+		`UPDATE templates SET name = $1, title = $2, text = $3, updated_at = now()
+		WHERE id = $4 AND user_email = $5
+			AND NOT EXISTS (SELECT 1 FROM templates WHERE user_email = $5 AND name = $1 AND id <> $4)
+		RETURNING created_at, updated_at`,
+		t.Name, t.Title, t.Text, t.Id, t.User_email,
+	).Scan(&t.Created_at, &t.Updated_at)
+	if err == sql.ErrNoRows {
+		return ErrInvalidData
+	}
+	return err
+}
+
+func (r *TemplatesRepository) DelTemplate(id int, email string) error {
+	res, err := r.db.Exec("DELETE FROM templates WHERE id = $1 AND user_email = $2", id, email)
+	if err != nil {
+		return err
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrInvalidData
+	}
+	return nil
+}
+
+func (r *TemplatesRepository) GetTemplate(id int, email string) (*model.Template, error) {
+	t := &model.Template{}
+	err := r.db.QueryRow(
+		`SELECT id, user_email, name, title, text, created_at, updated_at
+		FROM templates WHERE id = $1 AND user_email = $2`,
+		id, email,
+	).Scan(&t.Id, &t.User_email, &t.Name, &t.Title, &t.Text, &t.Created_at, &t.Updated_at)
+	if err == sql.ErrNoRows {
+		return nil, ErrInvalidData
+	}
+	return t, err
+}
+
+func (r *TemplatesRepository) GetTemplates(email string) ([]model.Template, error) {
+	rows, err := r.db.Query(
+		`SELECT id, user_email, name, title, text, created_at, updated_at
+		FROM templates WHERE user_email = $1 ORDER BY name ASC`,
+		email,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	templates := []model.Template{}
+	for rows.Next() {
+		t := model.Template{}
+		if err := rows.Scan(&t.Id, &t.User_email, &t.Name, &t.Title, &t.Text, &t.Created_at, &t.Updated_at); err != nil {
+			return nil, err
+		}
+		templates = append(templates, t)
+	}
+	return templates, rows.Err()
+}
@@ -17,20 +17,13 @@ func NewTestNotesRepository(db *sql.DB) *TestNotesRepository {
 
 // GROUPS
 
-func (r *TestNotesRepository) AddGroup(email string, nameGroup string, pid int) error {
-	res, err := r.db.Exec("INSERT INTO test_groups(user_email, name) VALUES ($1, $2)", email, nameGroup)
-	if err != nil {
-		return err
-	}
-
-	count, err := res.RowsAffected()
-	if err != nil {
-		return err
+func (r *TestNotesRepository) AddGroup(email string, nameGroup string, pid int) (int, error) {
+	var id int
+	err := r.db.QueryRow("INSERT INTO test_groups(user_email, name) VALUES ($1, $2) RETURNING id", email, nameGroup).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, ErrInvalidData
 	}
-	if count == 0 {
-		return ErrInvalidData
-	}
-	return nil
+	return id, err
 }
 
 func (r *TestNotesRepository) DelGroup(id int, email string) error {
@@ -67,16 +60,18 @@ func (r *TestNotesRepository) UpdateGroup(id int, email string, newNameGroup str
 
 // NOTES
 
-func (r *TestNotesRepository) AddNote(email string, title string, group_id int) error {
+func (r *TestNotesRepository) AddNote(email string, title string, group_id int) (int, error) {
+	var id int
+	var err error
 	if group_id == 0 {
-		_, err := r.db.Exec("INSERT INTO test_notes(user_email, title) VALUES ($1, $2)", email, title)
-		return err
+		err = r.db.QueryRow("INSERT INTO test_notes(user_email, title) VALUES ($1, $2) RETURNING id", email, title).Scan(&id)
 	} else {
-		_, err := r.db.Exec(`INSERT INTO test_notes(user_email, title, group_id) 
-							VALUES ($1, $2, (SELECT id as group_id FROM test_groups WHERE id = $3 AND user_email = $4))`,
-			email, title, group_id, email)
-		return err
+		err = r.db.QueryRow(`INSERT INTO test_notes(user_email, title, group_id) 
+							VALUES ($1, $2, (SELECT id as group_id FROM test_groups WHERE id = $3 AND user_email = $4))
+							RETURNING id`,
+			email, title, group_id, email).Scan(&id)
 	}
+	return id, err
 }
 
 func (r *TestNotesRepository) DelNote(id int, email string) error {
@@ -0,0 +1,117 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"noteapp/internal/events"
+	"noteapp/internal/model"
+	"noteapp/internal/repository"
+	"noteapp/internal/service"
+	"noteapp/internal/site"
+	"noteapp/internal/storage"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// COMMAND LINE
+
+// Backup writes the account to w without starting the server
+func Backup(ctx context.Context, email string, w io.Writer) error {
+	db, backupService, err := newBackupService()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return backupService.Backup(ctx, email, w)
+}
+
+// Restore adds the backup to the account, thumbnails of the restored
+// images are made by the running server
+func Restore(ctx context.Context, email string, r io.Reader) (*model.ImportReport, error) {
+	db, backupService, err := newBackupService()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	return backupService.Restore(ctx, email, r)
+}
+
+// Publish writes the group as a static site to out: a ZIP if the name
+// ends with ".zip", a directory otherwise
+func Publish(ctx context.Context, email string, groupID int, out string) error {
+	_, db, blobStore, err := open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	exportService := service.NewExportService(repository.NewNotesRepository(db), repository.NewAttachmentsRepository(db), blobStore)
+
+	if !strings.EqualFold(filepath.Ext(out), ".zip") {
+		dir, err := site.NewDirOutput(out)
+		if err != nil {
+			return err
+		}
+		return exportService.PublishSite(ctx, email, groupID, dir)
+	}
+
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err = exportService.PublishSite(ctx, email, groupID, site.NewZipOutput(f)); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// open reads the config and opens the database and the blob store
+func open() (*configServer, *sql.DB, storage.BlobStore, error) {
+	config, err := readConfig()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	db, err := openDB(config)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	blobStore, err := newBlobStore(config)
+	if err != nil {
+		db.Close()
+		return nil, nil, nil, err
+	}
+	return config, db, blobStore, nil
+}
+
+func newBackupService() (*sql.DB, *service.BackupService, error) {
+	config, db, blobStore, err := open()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	noteRepo := repository.NewNotesRepository(db)
+	sharesRepo := repository.NewSharesRepository(db)
+	linksRepo := repository.NewLinksRepository(db)
+	tasksRepo := repository.NewTasksRepository(db)
+	attachmentsRepo := repository.NewAttachmentsRepository(db)
+	importRepo := repository.NewImportRepository(db)
+	backupRepo := repository.NewBackupRepository(db)
+
+	// not the bus of the running server, its clients are not notified
+	bus := events.NewBus()
+
+	noteService := service.NewNotesService(noteRepo, sharesRepo, bus, linksRepo, tasksRepo)
+	attachmentsService := service.NewAttachmentsService(attachmentsRepo, blobStore, noteService, service.AttachmentLimits{
+		MaxFileSize: config.Storage.MaxFileSize,
+		MaxUserSize: config.Storage.MaxUserSize,
+	})
+	importService := service.NewImportService(importRepo, noteService, attachmentsService)
+	return db, service.NewBackupService(backupRepo, importService), nil
+}
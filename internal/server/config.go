@@ -1,17 +1,47 @@
 package server
 
+import (
+	"noteapp/internal/api"
+	"noteapp/internal/notify"
+	"noteapp/internal/storage"
+)
+
 type configServer struct {
 	LogLevel int    `json:"log-level"`
 	Port     int    `json:"port"`
 	Addr     string `json:"addr"`
-	DataBase struct {
+	// limit of the JSON request bodies in bytes
+	MaxBodySize int64 `json:"max-body-size"`
+	// origins of the frontend served from another host, e.g.
+	// "https://notes.example.com", allowed to open websockets
+	AllowedOrigins []string `json:"allowed-origins"`
+	DataBase       struct {
 		Host     string `json:"host"`
 		Username string `username:"username"`
 		Dbname   string `json:"dbname"`
 		Sslmode  string `json:"sslmode"`
 	} `json:"database"`
+	Storage struct {
+		// "local" or "s3"
+		Type        string           `json:"type"`
+		Dir         string           `json:"dir"`
+		MaxFileSize int64            `json:"max-file-size"`
+		MaxUserSize int64            `json:"max-user-size"`
+		S3          storage.S3Config `json:"s3"`
+	} `json:"storage"`
+	// notifiers of the reminders, the in-app list is always on
+	Notify struct {
+		Email   notify.EmailConfig   `json:"email"`
+		Webhook notify.WebhookConfig `json:"webhook"`
+	} `json:"notify"`
 }
 
 func NewConfig() *configServer {
-	return &configServer{}
+	c := &configServer{}
+	c.MaxBodySize = api.DefaultMaxBodySize
+	c.Storage.Type = "local"
+	c.Storage.Dir = "../../data/attachments"
+	c.Storage.MaxFileSize = 20 << 20
+	c.Storage.MaxUserSize = 500 << 20
+	return c
 }
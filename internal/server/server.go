@@ -2,41 +2,32 @@ package server
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
 	"noteapp/internal/api"
+	"noteapp/internal/collab"
 	"noteapp/internal/database"
+	"noteapp/internal/events"
+	"noteapp/internal/notify"
 	"noteapp/internal/repository"
 	"noteapp/internal/service"
+	"noteapp/internal/storage"
 	"noteapp/pkg/logger"
 	"os"
 	"time"
 )
 
 func Start(ctx context.Context) error {
-	data, err := os.ReadFile("../../configs/config.json")
+	config, err := readConfig()
 	if err != nil {
-		logger.NewLog("server - Start()", 1, nil, "Filed to read config.json", nil)
 		return err
 	}
 
-	config := NewConfig()
-	if err = json.Unmarshal(data, config); err != nil {
-		logger.NewLog("server - Start()", 1, nil, "Filed to unmarshal JSON", nil)
-		return err
-	}
-
-	info := database.ConnectionInfo{
-		Host: config.DataBase.Host,
-		//Port:     c.Port,
-		Username: config.DataBase.Username,
-		DBName:   config.DataBase.Dbname,
-		SSLMode:  config.DataBase.Sslmode,
-	}
-	db, err := database.NewPostgresConnection(info)
+	db, err := openDB(config)
 	if err != nil {
-		logger.NewLog("server - Start()", 1, err, "Failed to create *sql.DB", info)
 		return err
 	}
 	defer db.Close()
@@ -44,20 +35,86 @@ func Start(ctx context.Context) error {
 	// init deps
 	userRepo := repository.NewUserRepository(db)
 	noteRepo := repository.NewNotesRepository(db)
+	sharesRepo := repository.NewSharesRepository(db)
+	publicLinksRepo := repository.NewPublicLinksRepository(db)
+	syncRepo := repository.NewSyncRepository(db)
+	linksRepo := repository.NewLinksRepository(db)
+	tasksRepo := repository.NewTasksRepository(db)
+	graphRepo := repository.NewGraphRepository(db)
+	attachmentsRepo := repository.NewAttachmentsRepository(db)
+	importRepo := repository.NewImportRepository(db)
+	backupRepo := repository.NewBackupRepository(db)
+	templatesRepo := repository.NewTemplatesRepository(db)
+	journalRepo := repository.NewJournalRepository(db)
+	remindersRepo := repository.NewRemindersRepository(db)
+	favoritesRepo := repository.NewFavoritesRepository(db)
+	recentRepo := repository.NewRecentRepository(db)
+	commentsRepo := repository.NewCommentsRepository(db)
+	keysRepo := repository.NewKeysRepository(db)
+
+	blobStore, err := newBlobStore(config)
+	if err != nil {
+		return err
+	}
+
+	bus := events.NewBus()
 
 	userService := service.NewUserService(userRepo)
-	noteService := service.NewNotesService(noteRepo)
+	noteService := service.NewNotesService(noteRepo, sharesRepo, bus, linksRepo, tasksRepo)
+	sharesService := service.NewSharesService(sharesRepo, userRepo, noteRepo)
+	publicLinksService := service.NewPublicLinksService(publicLinksRepo, sharesRepo, noteRepo)
+	collabHub := collab.NewHub(noteService)
+	syncService := service.NewSyncService(syncRepo, noteService)
+	graphService := service.NewGraphService(graphRepo)
+	exportService := service.NewExportService(noteRepo, attachmentsRepo, blobStore)
+	attachmentsService := service.NewAttachmentsService(attachmentsRepo, blobStore, noteService, service.AttachmentLimits{
+		MaxFileSize: config.Storage.MaxFileSize,
+		MaxUserSize: config.Storage.MaxUserSize,
+	})
+	importService := service.NewImportService(importRepo, noteService, attachmentsService)
+	backupService := service.NewBackupService(backupRepo, importService)
+	templatesService := service.NewTemplatesService(templatesRepo, noteService)
+	journalService := service.NewJournalService(journalRepo, noteService, templatesService)
+	remindersService := service.NewRemindersService(remindersRepo, noteService, bus, newNotifiers(config))
+	favoritesService := service.NewFavoritesService(favoritesRepo, noteService)
+	recentService := service.NewRecentService(recentRepo)
+	commentsService := service.NewCommentsService(commentsRepo, noteService)
+	keysService := service.NewKeysService(keysRepo)
 
-	handler := api.NewHandler(userService, noteService)
+	go bus.RunCleanup(ctx, 10*time.Minute)
+	go attachmentsService.RunCleanup(ctx, 10*time.Minute)
+	go syncService.RunCleanup(ctx, time.Hour)
+	go attachmentsService.RunThumbnails(ctx, 2)
+	go remindersService.RunScheduler(ctx, 30*time.Second)
+	// the last views are written on shutdown, before the db is closed
+	flushed := make(chan struct{})
+	go func() {
+		recentService.RunFlush(ctx, 10*time.Second)
+		close(flushed)
+	}()
+
+	handler := api.NewHandler(userService, noteService, sharesService, publicLinksService, bus, collabHub, syncService,
+		attachmentsService, graphService, exportService, importService, backupService,
+		templatesService, journalService, remindersService, favoritesService,
+		recentService, commentsService, keysService)
+	handler.MaxBodySize = config.MaxBodySize
+	handler.AllowedOrigins = config.AllowedOrigins
+
+	// Shutdown does not wait for the open streams (/events, /ws/note) to end,
+	// their requests are cancelled through the base context
+	streamsCtx, cancelStreams := context.WithCancel(context.Background())
+	defer cancelStreams()
 
 	srv := &http.Server{
-		Addr:    config.Addr,
-		Handler: handler.InitHandler(),
+		Addr:        config.Addr,
+		Handler:     handler.InitHandler(),
+		BaseContext: func(net.Listener) context.Context { return streamsCtx },
 	}
+	srv.RegisterOnShutdown(cancelStreams)
 
 	go func() {
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			logger.NewLog("server - Start()", 1, err, "Filed to start server", info)
+			logger.NewLog("server - Start()", 1, err, "Filed to start server", config.Addr)
 		}
 	}()
 
@@ -67,8 +124,66 @@ func Start(ctx context.Context) error {
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
 	defer cancel()
 
-	if err := srv.Shutdown(shutdownCtx); err != nil {
+	err = srv.Shutdown(shutdownCtx)
+	collabHub.Flush()
+	<-flushed
+	if err != nil {
 		return fmt.Errorf("shutdown: %w", err)
 	}
 	return nil
 }
+
+func readConfig() (*configServer, error) {
+	data, err := os.ReadFile("../../configs/config.json")
+	if err != nil {
+		logger.NewLog("server - readConfig()", 1, nil, "Filed to read config.json", nil)
+		return nil, err
+	}
+
+	config := NewConfig()
+	if err = json.Unmarshal(data, config); err != nil {
+		logger.NewLog("server - readConfig()", 1, nil, "Filed to unmarshal JSON", nil)
+		return nil, err
+	}
+	return config, nil
+}
+
+func openDB(config *configServer) (*sql.DB, error) {
+	info := database.ConnectionInfo{
+		Host: config.DataBase.Host,
+		//Port:     c.Port,
+		Username: config.DataBase.Username,
+		DBName:   config.DataBase.Dbname,
+		SSLMode:  config.DataBase.Sslmode,
+	}
+	db, err := database.NewPostgresConnection(info)
+	if err != nil {
+		logger.NewLog("server - openDB()", 1, err, "Failed to create *sql.DB", info)
+		return nil, err
+	}
+	return db, nil
+}
+
+// newNotifiers returns the notifiers of the reminders turned on in the config
+func newNotifiers(config *configServer) []notify.Notifier {
+	notifiers := []notify.Notifier{}
+	if config.Notify.Email.Host != "" {
+		notifiers = append(notifiers, notify.NewEmail(config.Notify.Email))
+	}
+	if config.Notify.Webhook.URL != "" {
+		notifiers = append(notifiers, notify.NewWebhook(config.Notify.Webhook))
+	}
+	return notifiers
+}
+
+func newBlobStore(config *configServer) (storage.BlobStore, error) {
+	if config.Storage.Type == "s3" {
+		return storage.NewS3Store(config.Storage.S3), nil
+	}
+	blobStore, err := storage.NewLocalStore(config.Storage.Dir)
+	if err != nil {
+		logger.NewLog("server - newBlobStore()", 1, err, "Filed to create blob store", config.Storage.Dir)
+		return nil, err
+	}
+	return blobStore, nil
+}
@@ -0,0 +1,531 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"noteapp/internal/model"
+	"noteapp/internal/repository"
+	"noteapp/internal/storage"
+	"noteapp/internal/thumbnail"
+	"noteapp/pkg/logger"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	attachmentNameMax  = 255
+	orphansCleanupPage = 100
+	thumbnailsQueueLen = 256
+	thumbnailsRescan   = time.Minute
+	// store errors are retried on the next rescans, then the
+	// thumbnails are marked failed
+	thumbnailsRetries = 3
+)
+
+var (
+	ErrFileTooLarge  = errors.New("file is too large")
+	ErrQuotaExceeded = repository.ErrQuotaExceeded
+	ErrNoThumbnail   = errors.New("thumbnail is not available")
+)
+
+type AttachmentsRepository interface {
+	AddAttachment(a *model.Attachment, quota int64) error
+	DelAttachment(id int) error
+	GetAttachment(id int) (*model.Attachment, error)
+	GetAttachments(noteID int) ([]model.Attachment, error)
+	GetOrphans(limit int) ([]model.Attachment, error)
+	UsedSpace(email string) (int64, error)
+	SetThumbnails(id int, state string) error
+	GetPendingThumbnails(limit int) ([]int, error)
+}
+
+// AttachmentLimits - sizes in bytes, the user limit is counted
+// for the owner of the note the file is attached to
+type AttachmentLimits struct {
+	MaxFileSize int64
+	MaxUserSize int64
+}
+
+type AttachmentsService struct {
+	repository AttachmentsRepository
+	store      storage.BlobStore
+	notes      *NotesService
+	limits     AttachmentLimits
+
+	// attachments waiting for thumbnails, see RunThumbnails
+	mu       sync.Mutex
+	queue    chan int
+	queued   map[int]bool
+	attempts map[int]int
+}
+
+func NewAttachmentsService(repo AttachmentsRepository, store storage.BlobStore, notes *NotesService, limits AttachmentLimits) *AttachmentsService {
+	return &AttachmentsService{
+		repository: repo,
+		store:      store,
+		notes:      notes,
+		limits:     limits,
+		queue:      make(chan int, thumbnailsQueueLen),
+		queued:     map[int]bool{},
+		attempts:   map[int]int{},
+	}
+}
+
+// AddAttachment saves the file to the blob store and links it to the note.
+// The upload is spooled to a temp file first: the size must be checked before
+// anything is stored and S3 needs the content length in advance.
+func (s *AttachmentsService) AddAttachment(ctx context.Context, noteID int, email string, name string, r io.Reader) (*model.Attachment, error) {
+	owner, err := s.notes.noteAccess(noteID, email, model.PermissionEdit)
+	if err != nil {
+		return nil, err
+	}
+
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	if name == "" || name == "." || name == "/" || len(name) > attachmentNameMax {
+		return nil, repository.ErrInvalidData
+	}
+
+	// a quick check to not spool the upload in vain, the quota is
+	// enforced by the insert
+	used, err := s.repository.UsedSpace(owner)
+	if err != nil {
+		logger.NewLog("service - AddAttachment()", 2, err, "Filed to get used space in repository", owner)
+		return nil, err
+	}
+	free := s.limits.MaxUserSize - used
+	if free <= 0 {
+		return nil, ErrQuotaExceeded
+	}
+
+	tmp, err := os.CreateTemp("", "attachment-*")
+	if err != nil {
+		logger.NewLog("service - AddAttachment()", 2, err, "Filed to create temp file", nil)
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	size, err := io.Copy(tmp, io.LimitReader(r, min(s.limits.MaxFileSize, free)+1))
+	if err != nil {
+		logger.NewLog("service - AddAttachment()", 3, err, "Filed to read upload", nil)
+		return nil, repository.ErrInvalidData
+	}
+	if size > s.limits.MaxFileSize {
+		return nil, ErrFileTooLarge
+	}
+	if size > free {
+		return nil, ErrQuotaExceeded
+	}
+
+	head := make([]byte, 512)
+	n, err := tmp.ReadAt(head, 0)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if _, err = tmp.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	key, err := newBlobKey()
+	if err != nil {
+		logger.NewLog("service - AddAttachment()", 2, err, "Filed to generate blob key", nil)
+		return nil, err
+	}
+
+	a := &model.Attachment{
+		Owner_email:  owner,
+		Note_id:      noteID,
+		Blob_key:     key,
+		Name:         name,
+		Content_type: detectContentType(head[:n], name),
+		Size:         size,
+		Thumbnails:   model.ThumbnailsNone,
+	}
+
+	var body io.Reader = tmp
+	if thumbnail.Supported(a.Content_type) {
+		a.Thumbnails = model.ThumbnailsPending
+
+		data, err := io.ReadAll(tmp)
+		if err != nil {
+			return nil, err
+		}
+		data = stripMetadata(data, a)
+		body = bytes.NewReader(data)
+		a.Size = int64(len(data))
+	}
+
+	if err = s.store.Put(ctx, key, body, a.Size, a.Content_type); err != nil {
+		logger.NewLog("service - AddAttachment()", 2, err, "Filed to put blob in store", key)
+		return nil, err
+	}
+
+	if err = s.repository.AddAttachment(a, s.limits.MaxUserSize); err != nil {
+		if err != ErrQuotaExceeded {
+			logger.NewLog("service - AddAttachment()", 2, err, "Filed to add attachment in repository", a)
+		}
+		if err := s.store.Delete(ctx, key); err != nil {
+			logger.NewLog("service - AddAttachment()", 2, err, "Filed to delete blob from store", key)
+		}
+		return nil, err
+	}
+
+	if a.Thumbnails == model.ThumbnailsPending {
+		s.enqueueThumbnails(a.Id)
+	}
+	return a, nil
+}
+
+func (s *AttachmentsService) DelAttachment(ctx context.Context, id int, email string) error {
+	a, err := s.repository.GetAttachment(id)
+	if err != nil {
+		logger.NewLog("service - DelAttachment()", 5, err, "Filed to get attachment in repository", id)
+		return err
+	}
+	if a.Note_id == 0 {
+		return repository.ErrInvalidData
+	}
+	if _, err = s.notes.noteAccess(a.Note_id, email, model.PermissionEdit); err != nil {
+		return err
+	}
+
+	return s.remove(ctx, a)
+}
+
+func (s *AttachmentsService) GetAttachments(noteID int, email string) ([]model.Attachment, error) {
+	if _, err := s.notes.noteAccess(noteID, email, model.PermissionRead); err != nil {
+		return nil, err
+	}
+
+	attachments, err := s.repository.GetAttachments(noteID)
+	if err != nil {
+		logger.NewLog("service - GetAttachments()", 2, err, "Filed to get attachments in repository", noteID)
+	}
+	return attachments, err
+}
+
+// GetAttachment returns the attachment with its content, the caller closes the reader
+func (s *AttachmentsService) GetAttachment(ctx context.Context, id int, email string) (*model.Attachment, io.ReadCloser, error) {
+	a, err := s.repository.GetAttachment(id)
+	if err != nil {
+		logger.NewLog("service - GetAttachment()", 5, err, "Filed to get attachment in repository", id)
+		return nil, nil, err
+	}
+	if a.Note_id == 0 {
+		return nil, nil, repository.ErrInvalidData
+	}
+	if _, err = s.notes.noteAccess(a.Note_id, email, model.PermissionRead); err != nil {
+		return nil, nil, err
+	}
+
+	body, err := s.store.Get(ctx, a.Blob_key)
+	if err == storage.ErrBlobNotFound {
+		logger.NewLog("service - GetAttachment()", 2, err, "Blob of attachment is missing", a)
+		return nil, nil, repository.ErrInvalidData
+	}
+	if err != nil {
+		logger.NewLog("service - GetAttachment()", 2, err, "Filed to get blob from store", a.Blob_key)
+		return nil, nil, err
+	}
+	return a, body, nil
+}
+
+// GetThumbnail returns the thumbnail of an image attachment, size is one of thumbnail.Sizes
+func (s *AttachmentsService) GetThumbnail(ctx context.Context, id int, size int, email string) (*model.Attachment, io.ReadCloser, error) {
+	if !validThumbnailSize(size) {
+		return nil, nil, repository.ErrInvalidData
+	}
+
+	a, err := s.repository.GetAttachment(id)
+	if err != nil {
+		logger.NewLog("service - GetThumbnail()", 5, err, "Filed to get attachment in repository", id)
+		return nil, nil, err
+	}
+	if a.Note_id == 0 {
+		return nil, nil, repository.ErrInvalidData
+	}
+	if _, err = s.notes.noteAccess(a.Note_id, email, model.PermissionRead); err != nil {
+		return nil, nil, err
+	}
+	if a.Thumbnails != model.ThumbnailsReady {
+		return nil, nil, ErrNoThumbnail
+	}
+
+	body, err := s.store.Get(ctx, thumbnailKey(a.Blob_key, size))
+	if err == storage.ErrBlobNotFound {
+		logger.NewLog("service - GetThumbnail()", 2, err, "Blob of thumbnail is missing", a)
+		return nil, nil, ErrNoThumbnail
+	}
+	if err != nil {
+		logger.NewLog("service - GetThumbnail()", 2, err, "Filed to get blob from store", a.Blob_key)
+		return nil, nil, err
+	}
+	return a, body, nil
+}
+
+// THUMBNAILS
+
+// RunThumbnails makes thumbnails in the background until ctx is done.
+// New uploads are queued right away, the database is rescanned for
+// attachments left pending after a restart or a full queue.
+func (s *AttachmentsService) RunThumbnails(ctx context.Context, workers int) {
+	for i := 0; i < workers; i++ {
+		go s.thumbnailsWorker(ctx)
+	}
+
+	ticker := time.NewTicker(thumbnailsRescan)
+	defer ticker.Stop()
+
+	for {
+		ids, err := s.repository.GetPendingThumbnails(thumbnailsQueueLen)
+		if err != nil {
+			logger.NewLog("service - RunThumbnails()", 2, err, "Filed to get pending thumbnails in repository", nil)
+		}
+		for _, id := range ids {
+			s.enqueueThumbnails(id)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *AttachmentsService) enqueueThumbnails(id int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.queued[id] {
+		return
+	}
+	select {
+	case s.queue <- id:
+		s.queued[id] = true
+	default:
+		// the queue is full, the next rescan picks it up
+	}
+}
+
+func (s *AttachmentsService) thumbnailsWorker(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case id := <-s.queue:
+			s.makeThumbnails(ctx, id)
+
+			s.mu.Lock()
+			delete(s.queued, id)
+			s.mu.Unlock()
+		}
+	}
+}
+
+func (s *AttachmentsService) makeThumbnails(ctx context.Context, id int) {
+	a, err := s.repository.GetAttachment(id)
+	if err != nil || a.Thumbnails != model.ThumbnailsPending {
+		return
+	}
+
+	body, err := s.store.Get(ctx, a.Blob_key)
+	if err != nil {
+		logger.NewLog("service - makeThumbnails()", 2, err, "Filed to get blob from store", a.Blob_key)
+		s.retryThumbnails(ctx, a)
+		return
+	}
+	data, err := io.ReadAll(body)
+	body.Close()
+	if err != nil {
+		logger.NewLog("service - makeThumbnails()", 2, err, "Filed to read blob", a.Blob_key)
+		s.retryThumbnails(ctx, a)
+		return
+	}
+
+	thumbs, err := thumbnail.Generate(data, a.Content_type)
+	if err != nil {
+		logger.NewLog("service - makeThumbnails()", 3, err, "Filed to generate thumbnails", a.Id)
+		s.setThumbnails(a, model.ThumbnailsFailed)
+		return
+	}
+
+	contentType := thumbnail.ContentType(a.Content_type)
+	for _, th := range thumbs {
+		err = s.store.Put(ctx, thumbnailKey(a.Blob_key, th.Size), bytes.NewReader(th.Data), int64(len(th.Data)), contentType)
+		if err != nil {
+			logger.NewLog("service - makeThumbnails()", 2, err, "Filed to put thumbnail in store", a.Blob_key)
+			s.retryThumbnails(ctx, a)
+			return
+		}
+	}
+
+	s.setThumbnails(a, model.ThumbnailsReady)
+}
+
+// retryThumbnails leaves the attachment pending for the next rescan,
+// after thumbnailsRetries attempts the thumbnails are marked failed
+func (s *AttachmentsService) retryThumbnails(ctx context.Context, a *model.Attachment) {
+	s.mu.Lock()
+	s.attempts[a.Id]++
+	failed := s.attempts[a.Id] >= thumbnailsRetries
+	s.mu.Unlock()
+
+	if failed {
+		// some sizes may have been stored
+		s.removeThumbnails(ctx, a)
+		s.setThumbnails(a, model.ThumbnailsFailed)
+	}
+}
+
+func (s *AttachmentsService) setThumbnails(a *model.Attachment, state string) {
+	s.mu.Lock()
+	delete(s.attempts, a.Id)
+	s.mu.Unlock()
+
+	err := s.repository.SetThumbnails(a.Id, state)
+	if err == repository.ErrInvalidData {
+		// deleted while thumbnails were made
+		s.removeThumbnails(context.Background(), a)
+		return
+	}
+	if err != nil {
+		logger.NewLog("service - setThumbnails()", 2, err, "Filed to set thumbnails in repository", a.Id)
+	}
+}
+
+func (s *AttachmentsService) removeThumbnails(ctx context.Context, a *model.Attachment) error {
+	for _, size := range thumbnail.Sizes {
+		if err := s.store.Delete(ctx, thumbnailKey(a.Blob_key, size)); err != nil {
+			logger.NewLog("service - removeThumbnails()", 2, err, "Filed to delete thumbnail from store", a.Blob_key)
+			return err
+		}
+	}
+	return nil
+}
+
+// CLEANUP
+
+// CleanupOrphans removes attachments left after their notes were deleted
+func (s *AttachmentsService) CleanupOrphans(ctx context.Context) (int, error) {
+	count := 0
+	for {
+		orphans, err := s.repository.GetOrphans(orphansCleanupPage)
+		if err != nil {
+			logger.NewLog("service - CleanupOrphans()", 2, err, "Filed to get orphans in repository", nil)
+			return count, err
+		}
+
+		for i := range orphans {
+			if err = s.remove(ctx, &orphans[i]); err != nil {
+				return count, err
+			}
+			count++
+		}
+
+		if len(orphans) < orphansCleanupPage {
+			return count, nil
+		}
+	}
+}
+
+// RunCleanup calls CleanupOrphans every interval until ctx is done
+func (s *AttachmentsService) RunCleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if count, err := s.CleanupOrphans(ctx); err == nil && count > 0 {
+			logger.NewLog("service - RunCleanup()", 5, nil, "Orphaned attachments removed", count)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// remove deletes the blob before the row, so a failure never leaves
+// a blob without a record pointing to it
+func (s *AttachmentsService) remove(ctx context.Context, a *model.Attachment) error {
+	if a.Thumbnails != model.ThumbnailsNone {
+		if err := s.removeThumbnails(ctx, a); err != nil {
+			return err
+		}
+	}
+	if err := s.store.Delete(ctx, a.Blob_key); err != nil {
+		logger.NewLog("service - remove()", 2, err, "Filed to delete blob from store", a.Blob_key)
+		return err
+	}
+	err := s.repository.DelAttachment(a.Id)
+	if err != nil && err != repository.ErrInvalidData {
+		logger.NewLog("service - remove()", 2, err, "Filed to del attachment in repository", a.Id)
+		return err
+	}
+	return nil
+}
+
+// detectContentType sniffs the content, the extension is used only when
+// the content says nothing. Types which browsers execute are never taken
+// from the extension.
+func detectContentType(head []byte, name string) string {
+	sniffed := http.DetectContentType(head)
+	if sniffed != "application/octet-stream" && !strings.HasPrefix(sniffed, "text/plain") {
+		return sniffed
+	}
+
+	byExt := mime.TypeByExtension(strings.ToLower(filepath.Ext(name)))
+	if byExt == "" || strings.Contains(byExt, "html") || strings.Contains(byExt, "javascript") ||
+		strings.Contains(byExt, "svg") || strings.Contains(byExt, "xml") {
+		return sniffed
+	}
+	if strings.HasPrefix(sniffed, "text/plain") && !strings.HasPrefix(byExt, "text/") {
+		return sniffed
+	}
+	return byExt
+}
+
+// stripMetadata removes metadata of the image, photos often carry
+// GPS position and camera details
+func stripMetadata(data []byte, a *model.Attachment) []byte {
+	stripped, err := thumbnail.StripMetadata(data, a.Content_type)
+	if err != nil {
+		logger.NewLog("service - stripMetadata()", 3, err, "Filed to strip image metadata", a.Name)
+		return data
+	}
+	return stripped
+}
+
+func thumbnailKey(blobKey string, size int) string {
+	return blobKey + "-" + strconv.Itoa(size)
+}
+
+func validThumbnailSize(size int) bool {
+	for _, s := range thumbnail.Sizes {
+		if s == size {
+			return true
+		}
+	}
+	return false
+}
+
+func newBlobKey() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	key := hex.EncodeToString(b)
+	return "attachments/" + key[:2] + "/" + key, nil
+}
@@ -0,0 +1,154 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/png"
+	"io"
+	"noteapp/internal/model"
+	"noteapp/internal/repository"
+	"noteapp/internal/storage"
+	"noteapp/internal/thumbnail"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeAttachments struct {
+	AttachmentsRepository
+	list map[int]*model.Attachment
+}
+
+func (r *fakeAttachments) GetAttachment(id int) (*model.Attachment, error) {
+	a, ok := r.list[id]
+	if !ok {
+		return nil, repository.ErrInvalidData
+	}
+	c := *a
+	return &c, nil
+}
+
+func (r *fakeAttachments) GetAttachments(noteID int) ([]model.Attachment, error) {
+	list := []model.Attachment{}
+	for _, a := range r.list {
+		if a.Note_id == noteID {
+			list = append(list, *a)
+		}
+	}
+	return list, nil
+}
+
+func (r *fakeAttachments) UsedSpace(email string) (int64, error) {
+	var used int64
+	for _, a := range r.list {
+		used += a.Size
+	}
+	return used, nil
+}
+
+func (r *fakeAttachments) SetThumbnails(id int, state string) error {
+	a, ok := r.list[id]
+	if !ok {
+		return repository.ErrInvalidData
+	}
+	a.Thumbnails = state
+	return nil
+}
+
+// fakeStore keeps blobs in memory, Put fails while failPut is set
+type fakeStore struct {
+	blobs   map[string][]byte
+	failPut bool
+}
+
+func (s *fakeStore) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	if s.failPut {
+		return errors.New("store is down")
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	s.blobs[key] = data
+	return nil
+}
+
+func (s *fakeStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	data, ok := s.blobs[key]
+	if !ok {
+		return nil, storage.ErrBlobNotFound
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (s *fakeStore) Delete(ctx context.Context, key string) error {
+	delete(s.blobs, key)
+	return nil
+}
+
+func TestMakeThumbnails(t *testing.T) {
+	img := new(bytes.Buffer)
+	if err := png.Encode(img, image.NewRGBA(image.Rect(0, 0, 300, 200))); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name string
+		// Put fails on these attempts, counted from 1
+		failures []int
+		attempts int
+		want     string
+		wantKeys int
+	}{
+		{
+			name:     "ready",
+			attempts: 1,
+			want:     model.ThumbnailsReady,
+			wantKeys: 1 + len(thumbnail.Sizes),
+		},
+		{
+			name:     "store error stays pending",
+			failures: []int{1},
+			attempts: 1,
+			want:     model.ThumbnailsPending,
+			wantKeys: 1,
+		},
+		{
+			name:     "retried after store error",
+			failures: []int{1, 2},
+			attempts: 3,
+			want:     model.ThumbnailsReady,
+			wantKeys: 1 + len(thumbnail.Sizes),
+		},
+		{
+			name:     "failed after retries",
+			failures: []int{1, 2, 3},
+			attempts: 3,
+			want:     model.ThumbnailsFailed,
+			wantKeys: 1,
+		},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			store := &fakeStore{blobs: map[string][]byte{"blob": img.Bytes()}}
+			repo := &fakeAttachments{list: map[int]*model.Attachment{
+				1: {Id: 1, Blob_key: "blob", Content_type: "image/png", Thumbnails: model.ThumbnailsPending},
+			}}
+			s := NewAttachmentsService(repo, store, nil, AttachmentLimits{})
+
+			for i := 1; i <= tcase.attempts; i++ {
+				store.failPut = false
+				for _, f := range tcase.failures {
+					store.failPut = store.failPut || f == i
+				}
+				s.makeThumbnails(context.Background(), 1)
+			}
+
+			assert.Equal(t, tcase.want, repo.list[1].Thumbnails)
+			assert.Equal(t, tcase.wantKeys, len(store.blobs))
+		})
+	}
+}
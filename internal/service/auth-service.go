@@ -11,6 +11,7 @@ import (
 var (
 	secretKeyRefresh string = "super-secret-key-refresh"
 	secretKeyAccess  string = "super-secret-key-access"
+	secretKeyLink    string = "super-secret-key-link"
 	ErrTokenInvalid         = errors.New("invalid token or token time has expired")
 )
 
@@ -0,0 +1,869 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"noteapp/internal/importer"
+	"noteapp/internal/model"
+	"noteapp/internal/repository"
+	"noteapp/internal/templates"
+	"noteapp/internal/wikilink"
+	"noteapp/pkg/logger"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+type BackupRepository interface {
+	GetGroups(email string) ([]model.BackupGroup, error)
+	EachNote(email string, fn func(n model.BackupNote) error) error
+	GetShares(email string) ([]model.BackupShare, error)
+	GetPublicLinks(email string) ([]model.BackupPublicLink, error)
+	GetKeys(email string) (*model.UserKeys, error)
+	GetAttachments(email string) ([]model.Attachment, error)
+	GetTemplates(email string) ([]model.BackupTemplate, error)
+	GetJournal(email string) (*model.BackupJournal, error)
+	GetJournalDays(email string) ([]model.BackupJournalDay, error)
+	GetComments(email string) ([]model.BackupComment, error)
+	GetReminders(email string) ([]model.BackupReminder, error)
+	GetNotifications(email string) ([]model.BackupNotification, error)
+	GetFavorites(email string) ([]model.BackupFavorite, error)
+	GetPins(email string) ([]model.BackupPin, error)
+}
+
+// BackupService dumps an account to JSON (see model.BackupVersion) and
+// restores it, the restore goes through the import: same transaction,
+// limits and blob handling
+type BackupService struct {
+	repository BackupRepository
+	imports    *ImportService
+}
+
+func NewBackupService(repo BackupRepository, imports *ImportService) *BackupService {
+	return &BackupService{
+		repository: repo,
+		imports:    imports,
+	}
+}
+
+// attachment urls in note texts, see AttachmentsService
+var attachmentURL = regexp.MustCompile(`/get(Attachment|Thumbnail)\?id=(\d+)(&size=\d+)?`)
+
+// Backup writes the account to w. Sections are written one by one and
+// notes and attachments one at a time, so the backup is never held in
+// memory.
+func (s *BackupService) Backup(ctx context.Context, email string, w io.Writer) error {
+	groups, err := s.repository.GetGroups(email)
+	if err != nil {
+		logger.NewLog("service - Backup()", 2, err, "Filed to get groups in repository", email)
+		return err
+	}
+	shares, err := s.repository.GetShares(email)
+	if err != nil {
+		logger.NewLog("service - Backup()", 2, err, "Filed to get shares in repository", email)
+		return err
+	}
+	links, err := s.repository.GetPublicLinks(email)
+	if err != nil {
+		logger.NewLog("service - Backup()", 2, err, "Filed to get public links in repository", email)
+		return err
+	}
+	attachments, err := s.repository.GetAttachments(email)
+	if err != nil {
+		logger.NewLog("service - Backup()", 2, err, "Filed to get attachments in repository", email)
+		return err
+	}
+	keys, err := s.repository.GetKeys(email)
+	if err != nil {
+		logger.NewLog("service - Backup()", 2, err, "Filed to get keys in repository", email)
+		return err
+	}
+	extra, err := s.backupExtra(email)
+	if err != nil {
+		return err
+	}
+
+	bw := &backupWriter{w: w}
+	bw.raw(`{"version":`)
+	bw.value(model.BackupVersion)
+	bw.raw(`,"created_at":`)
+	bw.value(time.Now().UTC())
+	bw.raw(`,"user":`)
+	bw.value(model.BackupUser{Email: email})
+	bw.raw(`,"keys":`)
+	bw.value(keys)
+	bw.raw(`,"groups":`)
+	bw.value(groups)
+
+	bw.raw(`,"notes":[`)
+	first := true
+	err = s.repository.EachNote(email, func(n model.BackupNote) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !first {
+			bw.raw(",")
+		}
+		first = false
+		bw.value(n)
+		return bw.err
+	})
+	if err != nil {
+		logger.NewLog("service - Backup()", 2, err, "Filed to write notes", email)
+		return err
+	}
+	bw.raw(`],"shares":`)
+	bw.value(shares)
+	bw.raw(`,"public_links":`)
+	bw.value(links)
+	bw.raw(`,"templates":`)
+	bw.value(extra.templates)
+	bw.raw(`,"journal":`)
+	bw.value(extra.journal)
+	bw.raw(`,"journal_days":`)
+	bw.value(extra.journalDays)
+	bw.raw(`,"comments":`)
+	bw.value(extra.comments)
+	bw.raw(`,"reminders":`)
+	bw.value(extra.reminders)
+	bw.raw(`,"notifications":`)
+	bw.value(extra.notifications)
+	bw.raw(`,"favorites":`)
+	bw.value(extra.favorites)
+	bw.raw(`,"pins":`)
+	bw.value(extra.pins)
+
+	bw.raw(`,"attachments":[`)
+	for i, a := range attachments {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		data, err := s.readBlob(ctx, a.Blob_key)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			bw.raw(",")
+		}
+		bw.value(model.BackupAttachment{
+			Id:           a.Id,
+			Note_id:      a.Note_id,
+			Name:         a.Name,
+			Content_type: a.Content_type,
+			Created_at:   a.Created_at,
+			Data:         data,
+		})
+	}
+	bw.raw("]}\n")
+
+	if bw.err != nil {
+		logger.NewLog("service - Backup()", 3, bw.err, "Filed to write backup", email)
+	}
+	return bw.err
+}
+
+// backupExtra - sections of the version 2, they are kept by restore too
+type backupExtra struct {
+	templates     []model.BackupTemplate
+	journal       *model.BackupJournal
+	journalDays   []model.BackupJournalDay
+	comments      []model.BackupComment
+	reminders     []model.BackupReminder
+	notifications []model.BackupNotification
+	favorites     []model.BackupFavorite
+	pins          []model.BackupPin
+}
+
+func (s *BackupService) backupExtra(email string) (*backupExtra, error) {
+	e := &backupExtra{}
+	var err error
+	fail := func(err error, what string) (*backupExtra, error) {
+		logger.NewLog("service - backupExtra()", 2, err, "Filed to get "+what+" in repository", email)
+		return nil, err
+	}
+
+	if e.templates, err = s.repository.GetTemplates(email); err != nil {
+		return fail(err, "templates")
+	}
+	if e.journal, err = s.repository.GetJournal(email); err != nil {
+		return fail(err, "journal")
+	}
+	if e.journalDays, err = s.repository.GetJournalDays(email); err != nil {
+		return fail(err, "journal days")
+	}
+	if e.comments, err = s.repository.GetComments(email); err != nil {
+		return fail(err, "comments")
+	}
+	if e.reminders, err = s.repository.GetReminders(email); err != nil {
+		return fail(err, "reminders")
+	}
+	if e.notifications, err = s.repository.GetNotifications(email); err != nil {
+		return fail(err, "notifications")
+	}
+	if e.favorites, err = s.repository.GetFavorites(email); err != nil {
+		return fail(err, "favorites")
+	}
+	if e.pins, err = s.repository.GetPins(email); err != nil {
+		return fail(err, "pins")
+	}
+	return e, nil
+}
+
+func (s *BackupService) readBlob(ctx context.Context, key string) ([]byte, error) {
+	body, err := s.imports.attachments.store.Get(ctx, key)
+	if err != nil {
+		logger.NewLog("service - readBlob()", 2, err, "Filed to get blob from store", key)
+		return nil, err
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		logger.NewLog("service - readBlob()", 2, err, "Filed to read blob from store", key)
+		return nil, err
+	}
+	return data, nil
+}
+
+// backupWriter keeps the first error, the rest of the writes are no-op
+type backupWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (bw *backupWriter) raw(s string) {
+	if bw.err == nil {
+		_, bw.err = io.WriteString(bw.w, s)
+	}
+}
+
+func (bw *backupWriter) value(v any) {
+	if bw.err != nil {
+		return
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		bw.err = err
+		return
+	}
+	_, bw.err = bw.w.Write(data)
+}
+
+// RESTORE
+
+// restoredAttachment - attachment stored in the blob store and waiting
+// for the transaction, ids are of the backup
+type restoredAttachment struct {
+	a      *model.Attachment
+	id     int
+	noteID int
+}
+
+type backupContent struct {
+	groups      []model.BackupGroup
+	notes       []model.BackupNote
+	shares      []model.BackupShare
+	links       []model.BackupPublicLink
+	attachments []restoredAttachment
+	keys        *model.UserKeys
+	backupExtra
+}
+
+// Restore recreates the backup in the account of the user, existing groups
+// and notes are kept. Ids are new, links between notes, attachment urls,
+// shares and public links are remapped to them. Public links get new
+// tokens, shares with users missing on this instance are skipped.
+func (s *BackupService) Restore(ctx context.Context, email string, r io.Reader) (*model.ImportReport, error) {
+	report := &model.ImportReport{Errors: []model.ImportError{}}
+
+	lr := &io.LimitedReader{R: r, N: importMaxSize + 1}
+	content, err := s.decode(ctx, email, lr, report)
+	if lr.N == 0 {
+		// decoding of the cut stream fails, the size is the cause
+		err = ErrFileTooLarge
+	}
+	if err == nil {
+		err = s.save(email, content, report)
+	}
+	if err != nil {
+		// the blobs are put before the transaction, rows are rolled back
+		for _, ra := range content.attachments {
+			if err := s.imports.attachments.store.Delete(ctx, ra.a.Blob_key); err != nil {
+				logger.NewLog("service - Restore()", 2, err, "Filed to delete blob from store", ra.a.Blob_key)
+			}
+		}
+		return nil, err
+	}
+
+	for _, ra := range content.attachments {
+		if ra.a.Id == 0 {
+			if err := s.imports.attachments.store.Delete(ctx, ra.a.Blob_key); err != nil {
+				logger.NewLog("service - Restore()", 2, err, "Filed to delete blob from store", ra.a.Blob_key)
+			}
+		}
+	}
+
+	// links are indexed when all notes exist, so they resolve to each other
+	notes := s.imports.notes
+	for _, n := range content.notes {
+		if n.Id != 0 && !n.Encrypted {
+			notes.indexText(n.Id, email, n.Text)
+		}
+	}
+	for _, n := range content.notes {
+		if n.Id == 0 || n.Encrypted {
+			continue
+		}
+		if err = notes.links.ResolveLinks(email, n.Id, n.Title); err != nil {
+			logger.NewLog("service - Restore()", 2, err, "Filed to resolve links in repository", n.Id)
+		}
+	}
+	for _, ra := range content.attachments {
+		if ra.a.Id != 0 && ra.a.Thumbnails == model.ThumbnailsPending {
+			s.imports.attachments.enqueueThumbnails(ra.a.Id)
+		}
+	}
+
+	notes.events.Publish(email, model.Event{Type: model.EventResync})
+	return report, nil
+}
+
+// decode reads the backup, attachments are put to the blob store as they
+// come, the rest is kept until save. The stored blobs are returned on
+// error too, to be removed.
+func (s *BackupService) decode(ctx context.Context, email string, r io.Reader, report *model.ImportReport) (*backupContent, error) {
+	content := &backupContent{}
+	dec := json.NewDecoder(r)
+
+	invalid := func(err error) (*backupContent, error) {
+		logger.NewLog("service - decode()", 3, err, "Filed to decode backup", nil)
+		return content, repository.ErrInvalidData
+	}
+
+	if t, err := dec.Token(); err != nil || t != json.Delim('{') {
+		return invalid(err)
+	}
+	version := 0
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return invalid(err)
+		}
+		key, _ := t.(string)
+
+		// everything but the version needs to know the schema
+		if key != "version" && version == 0 {
+			return invalid(nil)
+		}
+
+		switch key {
+		case "version":
+			if err = dec.Decode(&version); err != nil || version < 1 || version > model.BackupVersion {
+				return invalid(err)
+			}
+		case "groups":
+			err = dec.Decode(&content.groups)
+		case "notes":
+			err = dec.Decode(&content.notes)
+		case "shares":
+			err = dec.Decode(&content.shares)
+		case "public_links":
+			err = dec.Decode(&content.links)
+		case "keys":
+			err = dec.Decode(&content.keys)
+		case "templates":
+			err = dec.Decode(&content.templates)
+		case "journal":
+			err = dec.Decode(&content.journal)
+		case "journal_days":
+			err = dec.Decode(&content.journalDays)
+		case "comments":
+			err = dec.Decode(&content.comments)
+		case "reminders":
+			err = dec.Decode(&content.reminders)
+		case "notifications":
+			err = dec.Decode(&content.notifications)
+		case "favorites":
+			err = dec.Decode(&content.favorites)
+		case "pins":
+			err = dec.Decode(&content.pins)
+		case "attachments":
+			// the errors of the stream are ErrInvalidData already
+			if err = s.decodeAttachments(ctx, email, dec, content, report); err != nil {
+				return content, err
+			}
+		default:
+			// "created_at", "user" and fields of newer minor versions
+			var skip json.RawMessage
+			err = dec.Decode(&skip)
+		}
+		if err != nil {
+			return invalid(err)
+		}
+	}
+	if _, err := dec.Token(); err != nil {
+		return invalid(err)
+	}
+	if version == 0 {
+		return invalid(nil)
+	}
+	return content, nil
+}
+
+// decodeAttachments stores the attachments one at a time, files over the
+// limits are reported and skipped
+func (s *BackupService) decodeAttachments(ctx context.Context, email string, dec *json.Decoder, content *backupContent, report *model.ImportReport) error {
+	used, err := s.imports.attachments.repository.UsedSpace(email)
+	if err != nil {
+		logger.NewLog("service - decodeAttachments()", 2, err, "Filed to get used space in repository", email)
+		return err
+	}
+	free := s.imports.attachments.limits.MaxUserSize - used
+
+	invalid := func(err error) error {
+		logger.NewLog("service - decodeAttachments()", 3, err, "Filed to decode backup", nil)
+		return repository.ErrInvalidData
+	}
+
+	if t, err := dec.Token(); err != nil || t != json.Delim('[') {
+		return invalid(err)
+	}
+	for dec.More() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		var ba model.BackupAttachment
+		if err := dec.Decode(&ba); err != nil {
+			return invalid(err)
+		}
+		asset := &importer.Asset{
+			Source: ba.Name,
+			Name:   ba.Name,
+			Size:   int64(len(ba.Data)),
+			Open: func() (io.ReadCloser, error) {
+				return io.NopCloser(bytes.NewReader(ba.Data)), nil
+			},
+		}
+		a, err := s.imports.storeAsset(ctx, email, asset, free)
+		if err == ErrFileTooLarge || err == ErrQuotaExceeded || err == repository.ErrInvalidData {
+			report.Errors = append(report.Errors, model.ImportError{File: ba.Name, Error: err.Error()})
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if !ba.Created_at.IsZero() {
+			a.Created_at = ba.Created_at
+		}
+		content.attachments = append(content.attachments, restoredAttachment{a: a, id: ba.Id, noteID: ba.Note_id})
+		free -= a.Size
+	}
+	if _, err = dec.Token(); err != nil {
+		return invalid(err)
+	}
+	return nil
+}
+
+// save creates the rows in one transaction. Ids are reserved first, so
+// the texts are rewritten before the insert. Ids of the content are
+// replaced by the new ones, 0 - the row was skipped.
+func (s *BackupService) save(email string, c *backupContent, report *model.ImportReport) error {
+	tx, err := s.imports.repository.Begin()
+	if err != nil {
+		logger.NewLog("service - save()", 2, err, "Filed to begin transaction", nil)
+		return err
+	}
+	if err = s.restore(tx, email, c, report); err != nil {
+		tx.Rollback()
+		return err
+	}
+	// parallel uploads could take the space counted as free in decodeAttachments
+	if len(c.attachments) > 0 {
+		if err = tx.CheckQuota(email, s.imports.attachments.limits.MaxUserSize); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		logger.NewLog("service - save()", 2, err, "Filed to commit transaction", nil)
+		return err
+	}
+	return nil
+}
+
+func (s *BackupService) restore(tx *repository.ImportTx, email string, c *backupContent, report *model.ImportReport) error {
+	groupIDs, err := reserveIDs(tx, "groups", len(c.groups), func(i int) int { return c.groups[i].Id })
+	if err != nil {
+		return err
+	}
+	noteIDs, err := reserveIDs(tx, "notes", len(c.notes), func(i int) int { return c.notes[i].Id })
+	if err != nil {
+		return err
+	}
+	attachmentIDs, err := reserveIDs(tx, "attachments", len(c.attachments), func(i int) int { return c.attachments[i].id })
+	if err != nil {
+		return err
+	}
+
+	// GROUPS: parents first, groups of a cycle or with a missing parent
+	// become root groups
+	restored := map[int]int{}
+	byID := map[int]int{}
+	for i, g := range c.groups {
+		byID[g.Id] = i
+	}
+	var add func(i int, seen map[int]bool) error
+	add = func(i int, seen map[int]bool) error {
+		g := &c.groups[i]
+		if _, ok := restored[g.Id]; ok {
+			return nil
+		}
+		seen[g.Id] = true
+		if p, ok := byID[g.Pid]; ok && g.Pid != 0 && !seen[g.Pid] {
+			if err := add(p, seen); err != nil {
+				return err
+			}
+		}
+		old := g.Id
+		g.Id = groupIDs[old]
+		g.Pid = restored[g.Pid]
+		g.Name = truncate(g.Name, importer.MaxName)
+		if err := tx.RestoreGroup(email, *g); err != nil {
+			logger.NewLog("service - restore()", 2, err, "Filed to restore group in repository", g.Name)
+			return err
+		}
+		restored[old] = g.Id
+		report.Groups++
+		return nil
+	}
+	for i := range c.groups {
+		if err := add(i, map[int]bool{}); err != nil {
+			return err
+		}
+	}
+
+	// KEYS: without them the encrypted notes can not be read
+	if c.keys != nil {
+		if err := c.keys.Validate(); err != nil {
+			report.Errors = append(report.Errors, model.ImportError{File: "keys", Error: err.Error()})
+		} else {
+			ok, err := tx.RestoreKeys(email, *c.keys)
+			if err != nil {
+				logger.NewLog("service - restore()", 2, err, "Filed to restore keys in repository", nil)
+				return err
+			}
+			if !ok {
+				report.Errors = append(report.Errors, model.ImportError{File: "keys", Error: "the account has other keys, encrypted notes of the backup need the keys of the backup"})
+			}
+		}
+	}
+
+	// NOTES
+	encrypted := map[int]bool{}
+	for i := range c.notes {
+		n := &c.notes[i]
+		// ciphertext can not be cut or rewritten
+		tooLarge := len(n.Text) > importer.MaxText || n.Encrypted && len(n.Title) > model.MaxEncryptedTitle
+		if tooLarge {
+			report.Errors = append(report.Errors, model.ImportError{File: n.Title, Error: ErrFileTooLarge.Error()})
+			delete(noteIDs, n.Id)
+			n.Id = 0
+			continue
+		}
+		n.Id = noteIDs[n.Id]
+		n.Group_id = restored[n.Group_id]
+		if n.Encrypted {
+			encrypted[n.Id] = true
+		} else {
+			n.Title = truncate(n.Title, wikilink.MaxTitle)
+			n.Text = remapAttachments(wikilink.Renumber(n.Text, noteIDs), attachmentIDs)
+		}
+		if err := tx.RestoreNote(email, *n); err != nil {
+			logger.NewLog("service - restore()", 2, err, "Filed to restore note in repository", n.Title)
+			return err
+		}
+		report.Notes++
+	}
+
+	// ATTACHMENTS: the note may be skipped above
+	for _, ra := range c.attachments {
+		noteID := noteIDs[ra.noteID]
+		if noteID == 0 {
+			report.Errors = append(report.Errors, model.ImportError{File: ra.a.Name, Error: "note of the attachment is missing"})
+			continue
+		}
+		ra.a.Id = attachmentIDs[ra.id]
+		ra.a.Note_id = noteID
+		if err := tx.RestoreAttachment(ra.a); err != nil {
+			logger.NewLog("service - restore()", 2, err, "Filed to restore attachment in repository", ra.a.Name)
+			return err
+		}
+		report.Attachments++
+	}
+
+	// SHARES
+	for _, sh := range c.shares {
+		sh.Note_id, sh.Group_id = noteIDs[sh.Note_id], restored[sh.Group_id]
+		if (sh.Note_id == 0) == (sh.Group_id == 0) {
+			continue
+		}
+		if sh.Permission != model.PermissionRead && sh.Permission != model.PermissionEdit {
+			report.Errors = append(report.Errors, model.ImportError{File: sh.User_email, Error: model.ErrValidationPermission.Error()})
+			continue
+		}
+		exists, err := tx.UserExists(sh.User_email)
+		if err != nil {
+			logger.NewLog("service - restore()", 2, err, "Filed to check user in repository", sh.User_email)
+			return err
+		}
+		if !exists || sh.User_email == email {
+			report.Errors = append(report.Errors, model.ImportError{File: sh.User_email, Error: "user of the share is missing"})
+			continue
+		}
+		if err = tx.RestoreShare(email, sh); err != nil {
+			logger.NewLog("service - restore()", 2, err, "Filed to restore share in repository", sh.User_email)
+			return err
+		}
+		report.Shares++
+	}
+
+	// PUBLIC LINKS
+	for _, l := range c.links {
+		l.Note_id, l.Group_id = noteIDs[l.Note_id], restored[l.Group_id]
+		if (l.Note_id == 0) == (l.Group_id == 0) || encrypted[l.Note_id] {
+			continue
+		}
+		token, err := newLinkToken()
+		if err != nil {
+			logger.NewLog("service - restore()", 2, err, "Filed to generate link token", nil)
+			return err
+		}
+		if err = tx.RestorePublicLink(email, token, l); err != nil {
+			logger.NewLog("service - restore()", 2, err, "Filed to restore public link in repository", nil)
+			return err
+		}
+		report.Public_links++
+	}
+
+	if err := s.restoreJournal(tx, email, c, report, restored, noteIDs, attachmentIDs); err != nil {
+		return err
+	}
+	return s.restoreNoteData(tx, email, c, report, restored, noteIDs)
+}
+
+// restoreJournal restores the templates and the journal, the journal
+// refers to the templates. Templates with the name of an existing
+// template are skipped.
+func (s *BackupService) restoreJournal(tx *repository.ImportTx, email string, c *backupContent, report *model.ImportReport, groupIDs map[int]int, noteIDs map[int]int, attachmentIDs map[int]int) error {
+	templateIDs := map[int]int{}
+	for _, tm := range c.templates {
+		t := model.Template{Name: tm.Name, Title: tm.Title, Text: tm.Text}
+		if err := t.Validate(); err != nil {
+			report.Errors = append(report.Errors, model.ImportError{File: tm.Name, Error: err.Error()})
+			continue
+		}
+		tm.Text = remapAttachments(wikilink.Renumber(tm.Text, noteIDs), attachmentIDs)
+		id, err := tx.RestoreTemplate(email, tm)
+		if err != nil {
+			logger.NewLog("service - restoreJournal()", 2, err, "Filed to restore template in repository", tm.Name)
+			return err
+		}
+		if id == 0 {
+			report.Errors = append(report.Errors, model.ImportError{File: tm.Name, Error: "template with this name exists"})
+			continue
+		}
+		templateIDs[tm.Id] = id
+		report.Templates++
+	}
+
+	if c.journal != nil {
+		j := *c.journal
+		j.Group_id, j.Template_id = groupIDs[j.Group_id], templateIDs[j.Template_id]
+		if _, ok := templates.FindBuiltin(j.Builtin); !ok {
+			j.Builtin = "daily"
+		}
+		if err := tx.RestoreJournal(email, j); err != nil {
+			logger.NewLog("service - restoreJournal()", 2, err, "Filed to restore journal in repository", nil)
+			return err
+		}
+	}
+
+	for _, d := range c.journalDays {
+		d.Note_id = noteIDs[d.Note_id]
+		if _, err := time.Parse(time.DateOnly, d.Day); err != nil || d.Note_id == 0 {
+			continue
+		}
+		if err := tx.RestoreJournalDay(email, d); err != nil {
+			logger.NewLog("service - restoreJournal()", 2, err, "Filed to restore journal day in repository", d.Day)
+			return err
+		}
+	}
+	return nil
+}
+
+// restoreNoteData restores what the user keeps on the notes: comments,
+// reminders with their notifications, favorites and pins. The rows of
+// skipped notes are skipped.
+func (s *BackupService) restoreNoteData(tx *repository.ImportTx, email string, c *backupContent, report *model.ImportReport, groupIDs map[int]int, noteIDs map[int]int) error {
+	// COMMENTS: authors missing on this instance are skipped with
+	// their threads
+	users := map[string]bool{email: true}
+	userExists := func(u string) (bool, error) {
+		exists, ok := users[u]
+		if !ok {
+			var err error
+			if exists, err = tx.UserExists(u); err != nil {
+				logger.NewLog("service - restoreNoteData()", 2, err, "Filed to check user in repository", u)
+				return false, err
+			}
+			users[u] = exists
+		}
+		return exists, nil
+	}
+	commentIDs := map[int]int{}
+	for _, cm := range c.comments {
+		cm.Note_id = noteIDs[cm.Note_id]
+		if cm.Note_id == 0 || cm.Parent_id != 0 && commentIDs[cm.Parent_id] == 0 {
+			continue
+		}
+		cm.Parent_id = commentIDs[cm.Parent_id]
+		if !validBackupComment(cm) {
+			report.Errors = append(report.Errors, model.ImportError{File: cm.Author_email, Error: model.ErrValidationComment.Error()})
+			continue
+		}
+		exists, err := userExists(cm.Author_email)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			report.Errors = append(report.Errors, model.ImportError{File: cm.Author_email, Error: "author of the comment is missing"})
+			continue
+		}
+		if cm.Resolved_by != "" {
+			if exists, err = userExists(cm.Resolved_by); err != nil {
+				return err
+			}
+			if !exists {
+				cm.Resolved_by = ""
+			}
+		}
+		id, err := tx.RestoreComment(cm)
+		if err != nil {
+			logger.NewLog("service - restoreNoteData()", 2, err, "Filed to restore comment in repository", cm.Id)
+			return err
+		}
+		commentIDs[cm.Id] = id
+		report.Comments++
+	}
+
+	// REMINDERS
+	reminderIDs := map[int]int{}
+	for _, rem := range c.reminders {
+		rem.Note_id = noteIDs[rem.Note_id]
+		if rem.Note_id == 0 {
+			continue
+		}
+		check := model.Reminder{Message: rem.Message, Due_at: rem.Due_at, Remind_at: rem.Remind_at}
+		if err := check.Validate(); err != nil {
+			report.Errors = append(report.Errors, model.ImportError{File: rem.Message, Error: err.Error()})
+			continue
+		}
+		rem.Remind_at = check.Remind_at
+		id, err := tx.RestoreReminder(email, rem)
+		if err != nil {
+			logger.NewLog("service - restoreNoteData()", 2, err, "Filed to restore reminder in repository", rem.Id)
+			return err
+		}
+		reminderIDs[rem.Id] = id
+		report.Reminders++
+	}
+	for _, n := range c.notifications {
+		n.Note_id, n.Reminder_id = noteIDs[n.Note_id], reminderIDs[n.Reminder_id]
+		if n.Note_id == 0 || n.Due_at.IsZero() {
+			continue
+		}
+		n.Title = truncate(n.Title, wikilink.MaxTitle)
+		n.Message = truncate(n.Message, 500)
+		if err := tx.RestoreNotification(email, n); err != nil {
+			logger.NewLog("service - restoreNoteData()", 2, err, "Filed to restore notification in repository", n.Note_id)
+			return err
+		}
+	}
+
+	// FAVORITES and PINS
+	for _, f := range c.favorites {
+		f.Note_id, f.Group_id = noteIDs[f.Note_id], groupIDs[f.Group_id]
+		if (f.Note_id == 0) == (f.Group_id == 0) {
+			continue
+		}
+		if err := tx.RestoreFavorite(email, f); err != nil {
+			logger.NewLog("service - restoreNoteData()", 2, err, "Filed to restore favorite in repository", nil)
+			return err
+		}
+	}
+	for _, p := range c.pins {
+		if p.Note_id = noteIDs[p.Note_id]; p.Note_id == 0 {
+			continue
+		}
+		if err := tx.RestorePin(email, p); err != nil {
+			logger.NewLog("service - restoreNoteData()", 2, err, "Filed to restore pin in repository", p.Note_id)
+			return err
+		}
+	}
+	return nil
+}
+
+// validBackupComment checks the comment as CommentsService does,
+// only threads have anchors
+func validBackupComment(c model.BackupComment) bool {
+	check := model.Comment{Text: c.Text}
+	if check.Validate() != nil || len(c.Anchor_text) > anchorTextMax {
+		return false
+	}
+	if (c.Anchor_start == nil) != (c.Anchor_end == nil) {
+		return false
+	}
+	if c.Anchor_start != nil && (c.Parent_id != 0 || *c.Anchor_start < 0 || *c.Anchor_start >= *c.Anchor_end) {
+		return false
+	}
+	return true
+}
+
+// reserveIDs maps the backup ids to new ids of the table, the backup ids
+// must be positive and unique
+func reserveIDs(tx *repository.ImportTx, table string, n int, id func(i int) int) (map[int]int, error) {
+	ids := map[int]int{}
+	if n == 0 {
+		return ids, nil
+	}
+	for i := 0; i < n; i++ {
+		if _, ok := ids[id(i)]; ok || id(i) <= 0 {
+			logger.NewLog("service - reserveIDs()", 3, nil, "Invalid id in backup", table)
+			return nil, repository.ErrInvalidData
+		}
+		ids[id(i)] = 0
+	}
+	next, err := tx.NextIDs(table, n)
+	if err != nil {
+		logger.NewLog("service - reserveIDs()", 2, err, "Filed to reserve ids in repository", table)
+		return nil, err
+	}
+	for i, newID := range next {
+		ids[id(i)] = newID
+	}
+	return ids, nil
+}
+
+func remapAttachments(text string, ids map[int]int) string {
+	return attachmentURL.ReplaceAllStringFunc(text, func(m string) string {
+		sub := attachmentURL.FindStringSubmatch(m)
+		old, _ := strconv.Atoi(sub[2])
+		if id, ok := ids[old]; ok {
+			return "/get" + sub[1] + "?id=" + strconv.Itoa(id) + sub[3]
+		}
+		return m
+	})
+}
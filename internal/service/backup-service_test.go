@@ -0,0 +1,193 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"noteapp/internal/model"
+	"noteapp/internal/repository"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeBackup returns one row of every section
+type fakeBackup struct{}
+
+func (fakeBackup) GetGroups(email string) ([]model.BackupGroup, error) {
+	return []model.BackupGroup{{Id: 1, Name: "group"}}, nil
+}
+
+func (fakeBackup) EachNote(email string, fn func(n model.BackupNote) error) error {
+	return fn(model.BackupNote{Id: 2, Group_id: 1, Title: "note"})
+}
+
+func (fakeBackup) GetShares(email string) ([]model.BackupShare, error) {
+	return []model.BackupShare{}, nil
+}
+
+func (fakeBackup) GetPublicLinks(email string) ([]model.BackupPublicLink, error) {
+	return []model.BackupPublicLink{}, nil
+}
+
+func (fakeBackup) GetKeys(email string) (*model.UserKeys, error) {
+	return nil, nil
+}
+
+func (fakeBackup) GetAttachments(email string) ([]model.Attachment, error) {
+	return []model.Attachment{}, nil
+}
+
+func (fakeBackup) GetTemplates(email string) ([]model.BackupTemplate, error) {
+	return []model.BackupTemplate{{Id: 3, Name: "template"}}, nil
+}
+
+func (fakeBackup) GetJournal(email string) (*model.BackupJournal, error) {
+	return &model.BackupJournal{Group_id: 1, Template_id: 3, Builtin: "daily"}, nil
+}
+
+func (fakeBackup) GetJournalDays(email string) ([]model.BackupJournalDay, error) {
+	return []model.BackupJournalDay{{Day: "2024-03-01", Note_id: 2}}, nil
+}
+
+func (fakeBackup) GetComments(email string) ([]model.BackupComment, error) {
+	return []model.BackupComment{{Id: 4, Note_id: 2, Author_email: email, Text: "comment"}}, nil
+}
+
+func (fakeBackup) GetReminders(email string) ([]model.BackupReminder, error) {
+	return []model.BackupReminder{{Id: 5, Note_id: 2}}, nil
+}
+
+func (fakeBackup) GetNotifications(email string) ([]model.BackupNotification, error) {
+	return []model.BackupNotification{{Note_id: 2, Reminder_id: 5, Title: "note"}}, nil
+}
+
+func (fakeBackup) GetFavorites(email string) ([]model.BackupFavorite, error) {
+	return []model.BackupFavorite{{Group_id: 1}}, nil
+}
+
+func (fakeBackup) GetPins(email string) ([]model.BackupPin, error) {
+	return []model.BackupPin{{Note_id: 2}}, nil
+}
+
+func newTestBackupService() *BackupService {
+	attachments := NewAttachmentsService(&fakeAttachments{}, &fakeStore{blobs: map[string][]byte{}}, nil, AttachmentLimits{
+		MaxFileSize: 1 << 20,
+		MaxUserSize: 4 << 20,
+	})
+	return NewBackupService(fakeBackup{}, NewImportService(nil, nil, attachments))
+}
+
+func TestBackupSections(t *testing.T) {
+	s := newTestBackupService()
+
+	out := new(bytes.Buffer)
+	if err := s.Backup(context.Background(), "user", out); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := s.decode(context.Background(), "user", out, &model.ImportReport{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 2, model.BackupVersion)
+	assert.Len(t, content.groups, 1)
+	assert.Len(t, content.notes, 1)
+	assert.Equal(t, []model.BackupTemplate{{Id: 3, Name: "template"}}, content.templates)
+	assert.Equal(t, &model.BackupJournal{Group_id: 1, Template_id: 3, Builtin: "daily"}, content.journal)
+	assert.Equal(t, []model.BackupJournalDay{{Day: "2024-03-01", Note_id: 2}}, content.journalDays)
+	assert.Equal(t, []model.BackupComment{{Id: 4, Note_id: 2, Author_email: "user", Text: "comment"}}, content.comments)
+	assert.Equal(t, []model.BackupReminder{{Id: 5, Note_id: 2}}, content.reminders)
+	assert.Equal(t, []model.BackupNotification{{Note_id: 2, Reminder_id: 5, Title: "note"}}, content.notifications)
+	assert.Equal(t, []model.BackupFavorite{{Group_id: 1}}, content.favorites)
+	assert.Equal(t, []model.BackupPin{{Note_id: 2}}, content.pins)
+}
+
+func TestBackupDecodeVersion(t *testing.T) {
+	testCases := []struct {
+		name    string
+		backup  string
+		want    error
+		wantLen int
+	}{
+		{
+			name:    "version 1",
+			backup:  `{"version":1,"user":{"email":"user"},"keys":null,"groups":[],"notes":[{"id":1,"title":"note"}],"shares":[],"public_links":[]}`,
+			wantLen: 1,
+		},
+		{
+			name:    "version 2",
+			backup:  `{"version":2,"groups":[],"notes":[{"id":1,"title":"note"}],"templates":[],"journal":null,"pins":[{"note_id":1}]}`,
+			wantLen: 1,
+		},
+		{
+			name:   "newer version",
+			backup: `{"version":3,"notes":[]}`,
+			want:   repository.ErrInvalidData,
+		},
+		{
+			name:   "no version",
+			backup: `{"notes":[]}`,
+			want:   repository.ErrInvalidData,
+		},
+	}
+
+	s := newTestBackupService()
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			content, err := s.decode(context.Background(), "user", strings.NewReader(tcase.backup), &model.ImportReport{})
+			assert.Equal(t, tcase.want, err)
+			if err == nil {
+				assert.Len(t, content.notes, tcase.wantLen)
+			}
+		})
+	}
+}
+
+func TestValidBackupComment(t *testing.T) {
+	at := func(n int) *int { return &n }
+
+	testCases := []struct {
+		name    string
+		comment model.BackupComment
+		want    bool
+	}{
+		{
+			name:    "thread",
+			comment: model.BackupComment{Text: "text", Anchor_start: at(0), Anchor_end: at(4), Anchor_text: "text"},
+			want:    true,
+		},
+		{
+			name:    "reply",
+			comment: model.BackupComment{Parent_id: 1, Text: "text"},
+			want:    true,
+		},
+		{
+			name:    "empty text",
+			comment: model.BackupComment{Text: ""},
+		},
+		{
+			name:    "anchored reply",
+			comment: model.BackupComment{Parent_id: 1, Text: "text", Anchor_start: at(0), Anchor_end: at(4)},
+		},
+		{
+			name:    "half anchor",
+			comment: model.BackupComment{Text: "text", Anchor_start: at(0)},
+		},
+		{
+			name:    "empty anchor",
+			comment: model.BackupComment{Text: "text", Anchor_start: at(4), Anchor_end: at(4)},
+		},
+		{
+			name:    "long anchor text",
+			comment: model.BackupComment{Text: "text", Anchor_start: at(0), Anchor_end: at(4), Anchor_text: strings.Repeat("a", anchorTextMax+1)},
+		},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			assert.Equal(t, tcase.want, validBackupComment(tcase.comment))
+		})
+	}
+}
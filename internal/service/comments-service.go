@@ -0,0 +1,188 @@
+package service
+
+import (
+	"noteapp/internal/model"
+	"noteapp/internal/repository"
+	"noteapp/pkg/logger"
+)
+
+const anchorTextMax = 1000
+
+type CommentsRepository interface {
+	AddComment(c *model.Comment) error
+	GetComment(id int) (model.Comment, error)
+	GetComments(noteID int) ([]model.Comment, error)
+	UpdateComment(id int, text string) error
+	DelComment(id int) error
+	ResolveComment(id int, email string, resolved bool) error
+}
+
+// CommentsService - discussion threads on notes. Everyone who can read
+// the note can comment, authors edit their comments, the note owner and
+// the authors delete them. Threads are resolved by their authors and by
+// users who can edit the note.
+type CommentsService struct {
+	repository CommentsRepository
+	notes      *NotesService
+}
+
+func NewCommentsService(repo CommentsRepository, notes *NotesService) *CommentsService {
+	return &CommentsService{
+		repository: repo,
+		notes:      notes,
+	}
+}
+
+// AddComment starts a thread or, with Parent_id, replies to one. A reply
+// to a reply goes to the same thread. Threads may be anchored to a range
+// of the note text, replies are not.
+func (s *CommentsService) AddComment(c *model.Comment) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	if _, err := s.notes.noteAccess(c.Note_id, c.Author_email, model.PermissionRead); err != nil {
+		return err
+	}
+
+	if c.Parent_id != 0 {
+		parent, err := s.comment(c.Parent_id)
+		if err != nil {
+			return err
+		}
+		if parent.Note_id != c.Note_id {
+			return repository.ErrInvalidData
+		}
+		if parent.Parent_id != 0 {
+			c.Parent_id = parent.Parent_id
+		}
+		c.Anchor_start, c.Anchor_end = nil, nil
+	}
+
+	c.Anchor_text = ""
+	if c.Anchor_start != nil || c.Anchor_end != nil {
+		if c.Anchor_start == nil || c.Anchor_end == nil {
+			return model.ErrValidationComment
+		}
+		note, err := s.notes.GetNote(c.Note_id, c.Author_email)
+		if err != nil {
+			return err
+		}
+		// an anchor would quote the ciphertext
+		if note.Encrypted {
+			return model.ErrEncryptedNote
+		}
+		text := []rune(note.Text)
+		start, end := *c.Anchor_start, *c.Anchor_end
+		if start < 0 || start >= end || end > len(text) {
+			return model.ErrValidationComment
+		}
+		c.Anchor_text = truncate(string(text[start:end]), anchorTextMax)
+	}
+
+	err := s.repository.AddComment(c)
+	if err != nil && err != repository.ErrInvalidData {
+		logger.NewLog("service - AddComment()", 2, err, "Filed to add comment in repository", c.Note_id)
+	}
+	return err
+}
+
+// GetComments returns the threads of the note with their replies
+func (s *CommentsService) GetComments(noteID int, email string) ([]model.Comment, error) {
+	if _, err := s.notes.noteAccess(noteID, email, model.PermissionRead); err != nil {
+		return nil, err
+	}
+
+	list, err := s.repository.GetComments(noteID)
+	if err != nil {
+		logger.NewLog("service - GetComments()", 2, err, "Filed to get comments in repository", noteID)
+		return nil, err
+	}
+
+	threads := []model.Comment{}
+	index := map[int]int{}
+	for _, c := range list {
+		if c.Parent_id == 0 {
+			index[c.Id] = len(threads)
+			threads = append(threads, c)
+		} else if i, ok := index[c.Parent_id]; ok {
+			threads[i].Replies = append(threads[i].Replies, c)
+		}
+	}
+	return threads, nil
+}
+
+func (s *CommentsService) UpdateComment(id int, email string, text string) error {
+	c, err := s.comment(id)
+	if err != nil {
+		return err
+	}
+	if _, err := s.notes.noteAccess(c.Note_id, email, model.PermissionRead); err != nil {
+		return err
+	}
+	if c.Author_email != email {
+		return ErrAccessDenied
+	}
+
+	c.Text = text
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	err = s.repository.UpdateComment(id, text)
+	if err != nil && err != repository.ErrInvalidData {
+		logger.NewLog("service - UpdateComment()", 2, err, "Filed to update comment in repository", id)
+	}
+	return err
+}
+
+func (s *CommentsService) DelComment(id int, email string) error {
+	c, err := s.comment(id)
+	if err != nil {
+		return err
+	}
+	owner, err := s.notes.noteAccess(c.Note_id, email, model.PermissionRead)
+	if err != nil {
+		return err
+	}
+	if c.Author_email != email && owner != email {
+		return ErrAccessDenied
+	}
+
+	err = s.repository.DelComment(id)
+	if err != nil && err != repository.ErrInvalidData {
+		logger.NewLog("service - DelComment()", 2, err, "Filed to del comment in repository", id)
+	}
+	return err
+}
+
+// ResolveComment resolves or reopens the thread
+func (s *CommentsService) ResolveComment(id int, email string, resolved bool) error {
+	c, err := s.comment(id)
+	if err != nil {
+		return err
+	}
+	if c.Parent_id != 0 {
+		return repository.ErrInvalidData
+	}
+	permission := model.PermissionEdit
+	if c.Author_email == email {
+		permission = model.PermissionRead
+	}
+	if _, err := s.notes.noteAccess(c.Note_id, email, permission); err != nil {
+		return err
+	}
+
+	err = s.repository.ResolveComment(id, email, resolved)
+	if err != nil && err != repository.ErrInvalidData {
+		logger.NewLog("service - ResolveComment()", 2, err, "Filed to resolve comment in repository", id)
+	}
+	return err
+}
+
+func (s *CommentsService) comment(id int) (model.Comment, error) {
+	c, err := s.repository.GetComment(id)
+	if err != nil && err != repository.ErrInvalidData {
+		logger.NewLog("service - comment()", 2, err, "Filed to get comment in repository", id)
+	}
+	return c, err
+}
@@ -0,0 +1,270 @@
+package service
+
+import (
+	"context"
+	"io"
+	"noteapp/internal/export"
+	"noteapp/internal/hashtag"
+	"noteapp/internal/model"
+	"noteapp/internal/render"
+	"noteapp/internal/repository"
+	"noteapp/internal/site"
+	"noteapp/internal/storage"
+	"noteapp/internal/wikilink"
+	"noteapp/pkg/logger"
+	"path"
+	"strconv"
+)
+
+type ExportService struct {
+	notes       NotesRepository
+	attachments AttachmentsRepository
+	store       storage.BlobStore
+}
+
+func NewExportService(notes NotesRepository, attachments AttachmentsRepository, store storage.BlobStore) *ExportService {
+	return &ExportService{
+		notes:       notes,
+		attachments: attachments,
+		store:       store,
+	}
+}
+
+// ExportMarkdown writes all notes of the user to w as a ZIP: groups become
+// directories, notes "<title>.md" files with front matter and attachments
+// are put to "attachments" next to the note. Encrypted notes are exported
+// as their ciphertext, marked "encrypted: true". Notes and files are read
+// one at a time, so the archive is never held in memory.
+func (s *ExportService) ExportMarkdown(ctx context.Context, email string, w io.Writer) error {
+	list, err := s.notes.GetNotesList(email)
+	if err != nil {
+		logger.NewLog("service - ExportMarkdown()", 2, err, "Filed to get notes list in repository", email)
+		return err
+	}
+
+	a := export.NewArchive(w)
+	if err = s.exportNotes(ctx, a, "", list.Notes, email); err != nil {
+		return err
+	}
+	if err = s.exportGroups(ctx, a, "", list.Groups, email); err != nil {
+		return err
+	}
+	return a.Close()
+}
+
+// ExportOPML writes the groups and notes of the user as an OPML outline,
+// encrypted notes as their ciphertext
+func (s *ExportService) ExportOPML(email string, w io.Writer) error {
+	list, err := s.notes.GetNotesList(email)
+	if err != nil {
+		logger.NewLog("service - ExportOPML()", 2, err, "Filed to get notes list in repository", email)
+		return err
+	}
+	return export.WriteOPML(w, "Notes", list, func(id int) (string, error) {
+		note, err := s.notes.GetNote(id, email)
+		if err != nil {
+			logger.NewLog("service - ExportOPML()", 2, err, "Filed to get note in repository", id)
+		}
+		return note.Text, err
+	})
+}
+
+func (s *ExportService) exportGroups(ctx context.Context, a *export.Archive, parent string, groups []model.GroupElement, email string) error {
+	for _, g := range groups {
+		dir := a.Dir(parent, g.Name)
+		if err := s.exportNotes(ctx, a, dir, g.Notes, email); err != nil {
+			return err
+		}
+		if g.Groups != nil {
+			if err := s.exportGroups(ctx, a, dir, *g.Groups, email); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *ExportService) exportNotes(ctx context.Context, a *export.Archive, dir string, notes []model.NoteElement, email string) error {
+	for _, n := range notes {
+		// the client is gone, no reason to read the rest
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := s.exportNote(ctx, a, dir, n.Id, email); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *ExportService) exportNote(ctx context.Context, a *export.Archive, dir string, id int, email string) error {
+	note, err := s.notes.GetNote(id, email)
+	if err != nil {
+		logger.NewLog("service - exportNote()", 2, err, "Filed to get note in repository", id)
+		return err
+	}
+
+	attachments, err := s.attachments.GetAttachments(id)
+	if err != nil {
+		logger.NewLog("service - exportNote()", 2, err, "Filed to get attachments in repository", id)
+		return err
+	}
+
+	attDir := path.Join(dir, "attachments")
+	paths := make([]string, len(attachments))
+	relative := make([]string, len(attachments))
+	for i, att := range attachments {
+		paths[i] = a.Path(attDir, att.Name)
+		relative[i] = path.Join("attachments", path.Base(paths[i]))
+	}
+
+	var tags []string
+	if !note.Encrypted {
+		tags = hashtag.Parse(note.Text)
+	}
+	if err = a.AddNote(dir, note, tags, relative); err != nil {
+		logger.NewLog("service - exportNote()", 2, err, "Filed to write note to archive", id)
+		return err
+	}
+
+	for i, att := range attachments {
+		body, err := s.store.Get(ctx, att.Blob_key)
+		if err == storage.ErrBlobNotFound {
+			logger.NewLog("service - exportNote()", 2, err, "Blob of attachment is missing", att)
+			continue
+		}
+		if err != nil {
+			logger.NewLog("service - exportNote()", 2, err, "Filed to get blob from store", att.Blob_key)
+			return err
+		}
+
+		err = a.AddFile(paths[i], att.Created_at, body)
+		body.Close()
+		if err != nil {
+			logger.NewLog("service - exportNote()", 2, err, "Filed to write attachment to archive", att.Id)
+			return err
+		}
+	}
+	return nil
+}
+
+// SITE
+
+// PublishSite writes the group subtree of the user to out as a static
+// HTML site (see site.Site), groupID = 0 publishes all notes. Wiki links
+// and attachment urls are resolved to the pages and files of the site,
+// links to notes outside of the subtree are shown as broken.
+func (s *ExportService) PublishSite(ctx context.Context, email string, groupID int, out site.Output) error {
+	list, err := s.notes.GetNotesList(email)
+	if err != nil {
+		logger.NewLog("service - PublishSite()", 2, err, "Filed to get notes list in repository", email)
+		return err
+	}
+
+	root := &model.GroupElement{Name: "Notes", Notes: list.Notes, Groups: &list.Groups}
+	if groupID != 0 {
+		if root = findGroup(list.Groups, groupID); root == nil {
+			return repository.ErrInvalidData
+		}
+	}
+	dropEncrypted(root)
+
+	notes := []model.NoteElement{}
+	var collect func(g *model.GroupElement)
+	collect = func(g *model.GroupElement) {
+		notes = append(notes, g.Notes...)
+		if g.Groups != nil {
+			for i := range *g.Groups {
+				collect(&(*g.Groups)[i])
+			}
+		}
+	}
+	collect(root)
+
+	st := site.New(out, *root)
+	targets := map[string]int{}
+	files := map[int]string{}
+	for _, n := range notes {
+		if key := linkKey(n.Title, n.Id); targets[key] == 0 {
+			targets[key] = n.Id
+		}
+		targets[linkKey("", n.Id)] = n.Id
+
+		if err = s.publishAttachments(ctx, st, n.Id, files); err != nil {
+			return err
+		}
+	}
+
+	for _, n := range notes {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		note, err := s.notes.GetNote(n.Id, email)
+		if err != nil {
+			logger.NewLog("service - PublishSite()", 2, err, "Filed to get note in repository", n.Id)
+			return err
+		}
+
+		page := st.NotePath(n.Id)
+		text := attachmentURL.ReplaceAllStringFunc(note.Text, func(m string) string {
+			id, _ := strconv.Atoi(attachmentURL.FindStringSubmatch(m)[2])
+			if file, ok := files[id]; ok {
+				return site.Rel(page, file)
+			}
+			return m
+		})
+		html, err := render.HTML(text, func(l wikilink.Link) string {
+			if id := targets[linkKey(l.Title, l.Id)]; id != 0 {
+				return site.Rel(page, st.NotePath(id))
+			}
+			return ""
+		})
+		if err != nil {
+			logger.NewLog("service - PublishSite()", 2, err, "Filed to render note", n.Id)
+			return err
+		}
+
+		if err = st.WriteNote(note, html); err != nil {
+			logger.NewLog("service - PublishSite()", 2, err, "Filed to write page", n.Id)
+			return err
+		}
+	}
+
+	if err = st.Close(); err != nil {
+		logger.NewLog("service - PublishSite()", 2, err, "Filed to write site", nil)
+	}
+	return err
+}
+
+// publishAttachments copies the attachments of the note to the site,
+// files maps their ids to the paths
+func (s *ExportService) publishAttachments(ctx context.Context, st *site.Site, noteID int, files map[int]string) error {
+	attachments, err := s.attachments.GetAttachments(noteID)
+	if err != nil {
+		logger.NewLog("service - publishAttachments()", 2, err, "Filed to get attachments in repository", noteID)
+		return err
+	}
+
+	for _, att := range attachments {
+		body, err := s.store.Get(ctx, att.Blob_key)
+		if err == storage.ErrBlobNotFound {
+			logger.NewLog("service - publishAttachments()", 2, err, "Blob of attachment is missing", att)
+			continue
+		}
+		if err != nil {
+			logger.NewLog("service - publishAttachments()", 2, err, "Filed to get blob from store", att.Blob_key)
+			return err
+		}
+
+		name := st.AttachmentPath(att.Name)
+		err = st.AddFile(name, body)
+		body.Close()
+		if err != nil {
+			logger.NewLog("service - publishAttachments()", 2, err, "Filed to write attachment to site", att.Id)
+			return err
+		}
+		files[att.Id] = name
+	}
+	return nil
+}
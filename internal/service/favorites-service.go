@@ -0,0 +1,90 @@
+package service
+
+import (
+	"noteapp/internal/model"
+	"noteapp/pkg/logger"
+)
+
+type FavoritesRepository interface {
+	SetPin(email string, noteID int, pinned bool) error
+	SetFavorite(email string, noteID int, groupID int, favorite bool) error
+	GetFavorites(email string) (model.Favorites, error)
+}
+
+// FavoritesService keeps pins and favorites of every user. Users can mark
+// every note and group they can read, shared ones too.
+type FavoritesService struct {
+	repository FavoritesRepository
+	notes      *NotesService
+}
+
+func NewFavoritesService(repo FavoritesRepository, notes *NotesService) *FavoritesService {
+	return &FavoritesService{
+		repository: repo,
+		notes:      notes,
+	}
+}
+
+// PinNote pins the note to the top of its group
+func (s *FavoritesService) PinNote(id int, email string, pinned bool) error {
+	if _, err := s.notes.noteAccess(id, email, model.PermissionRead); err != nil {
+		return err
+	}
+
+	err := s.repository.SetPin(email, id, pinned)
+	if err != nil {
+		logger.NewLog("service - PinNote()", 2, err, "Filed to set pin in repository", id)
+	}
+	return err
+}
+
+func (s *FavoritesService) FavoriteNote(id int, email string, favorite bool) error {
+	if _, err := s.notes.noteAccess(id, email, model.PermissionRead); err != nil {
+		return err
+	}
+
+	err := s.repository.SetFavorite(email, id, 0, favorite)
+	if err != nil {
+		logger.NewLog("service - FavoriteNote()", 2, err, "Filed to set favorite in repository", id)
+	}
+	return err
+}
+
+func (s *FavoritesService) FavoriteGroup(id int, email string, favorite bool) error {
+	if _, err := s.notes.groupAccess(id, email, model.PermissionRead); err != nil {
+		return err
+	}
+
+	err := s.repository.SetFavorite(email, 0, id, favorite)
+	if err != nil {
+		logger.NewLog("service - FavoriteGroup()", 2, err, "Filed to set favorite in repository", id)
+	}
+	return err
+}
+
+// GetFavorites returns the favorites which the user can still read,
+// shares may have been removed since they were marked
+func (s *FavoritesService) GetFavorites(email string) (model.Favorites, error) {
+	all, err := s.repository.GetFavorites(email)
+	if err != nil {
+		logger.NewLog("service - GetFavorites()", 2, err, "Filed to get favorites in repository", email)
+		return all, err
+	}
+
+	favorites := model.Favorites{Notes: []model.FavoriteNote{}, Groups: []model.FavoriteGroup{}}
+	for _, n := range all.Notes {
+		if n.Owner_email == email {
+			favorites.Notes = append(favorites.Notes, n)
+		} else if _, err := s.notes.noteAccess(n.Note_id, email, model.PermissionRead); err == nil {
+			favorites.Notes = append(favorites.Notes, n)
+		}
+	}
+	for _, g := range all.Groups {
+		if g.Owner_email == email {
+			favorites.Groups = append(favorites.Groups, g)
+		} else if _, err := s.notes.groupAccess(g.Group_id, email, model.PermissionRead); err == nil {
+			favorites.Groups = append(favorites.Groups, g)
+		}
+	}
+	return favorites, nil
+}
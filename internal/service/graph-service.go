@@ -0,0 +1,322 @@
+package service
+
+import (
+	"noteapp/internal/hashtag"
+	"noteapp/internal/model"
+	"noteapp/internal/repository"
+	"noteapp/pkg/logger"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	graphDefaultLimit = 300
+	graphMaxLimit     = 2000
+	graphDefaultDepth = 2
+	graphMaxDepth     = 5
+)
+
+type GraphRepository interface {
+	GetGroups(email string) ([]model.Group, error)
+	GetNotes(email string) ([]model.Note, error)
+	GetLinks(email string) ([]model.GraphLink, error)
+	GetTexts(email string, ids []int) (map[int]string, error)
+	GetTags(email string) ([]model.NoteTags, error)
+	SetTags(list []model.NoteTags) error
+}
+
+type GraphService struct {
+	repository GraphRepository
+}
+
+func NewGraphService(repo GraphRepository) *GraphService {
+	return &GraphService{
+		repository: repo,
+	}
+}
+
+// graph - all nodes and edges of the account before filtering by neighborhood and limit
+type graph struct {
+	nodes map[string]*model.GraphNode
+	order []string
+	edges []model.GraphEdge
+	adj   map[string][]string
+}
+
+func (g *graph) addNode(id string, t string, label string) {
+	if _, ok := g.nodes[id]; ok {
+		return
+	}
+	g.nodes[id] = &model.GraphNode{Id: id, Type: t, Label: label}
+	g.order = append(g.order, id)
+}
+
+func (g *graph) addEdge(source string, target string, t string) {
+	s, ok1 := g.nodes[source]
+	d, ok2 := g.nodes[target]
+	if !ok1 || !ok2 {
+		return
+	}
+	s.Degree++
+	d.Degree++
+	g.edges = append(g.edges, model.GraphEdge{Source: source, Target: target, Type: t})
+	g.adj[source] = append(g.adj[source], target)
+	g.adj[target] = append(g.adj[target], source)
+}
+
+// GetGraph builds the graph of notes, groups and #tags of the user
+func (s *GraphService) GetGraph(email string, q model.GraphQuery) (model.Graph, error) {
+	if q.Limit <= 0 {
+		q.Limit = graphDefaultLimit
+	}
+	q.Limit = min(q.Limit, graphMaxLimit)
+	if q.Depth <= 0 {
+		q.Depth = graphDefaultDepth
+	}
+	q.Depth = min(q.Depth, graphMaxDepth)
+	q.Tag = strings.ToLower(strings.TrimPrefix(q.Tag, "#"))
+
+	types := map[string]bool{}
+	for _, t := range q.Types {
+		if t != model.GraphNote && t != model.GraphGroup && t != model.GraphTag {
+			return model.Graph{}, repository.ErrInvalidData
+		}
+		types[t] = true
+	}
+	if len(types) == 0 {
+		types = map[string]bool{model.GraphNote: true, model.GraphGroup: true, model.GraphTag: true}
+	}
+
+	groups, err := s.repository.GetGroups(email)
+	if err != nil {
+		logger.NewLog("service - GetGraph()", 2, err, "Filed to get groups in repository", email)
+		return model.Graph{}, err
+	}
+	notes, err := s.repository.GetNotes(email)
+	if err != nil {
+		logger.NewLog("service - GetGraph()", 2, err, "Filed to get notes in repository", email)
+		return model.Graph{}, err
+	}
+	links, err := s.repository.GetLinks(email)
+	if err != nil {
+		logger.NewLog("service - GetGraph()", 2, err, "Filed to get links in repository", email)
+		return model.Graph{}, err
+	}
+	tags, err := s.noteTags(email, notes)
+	if err != nil {
+		return model.Graph{}, err
+	}
+
+	parent := map[int]int{}
+	for _, gr := range groups {
+		parent[gr.Id] = gr.Pid
+	}
+	if q.Group_id != 0 {
+		if _, ok := parent[q.Group_id]; !ok {
+			return model.Graph{}, repository.ErrInvalidData
+		}
+	}
+
+	// notes left after the filters, groups and tags are shown
+	// only around them when a filter is set
+	filtered := q.Tag != "" || q.Group_id != 0
+	keptGroups := map[int]bool{}
+	keptNotes := []model.Note{}
+	for _, n := range notes {
+		if q.Tag != "" && !containsString(tags[n.Id], q.Tag) {
+			continue
+		}
+		if q.Group_id != 0 && !inGroup(n.Group_id, q.Group_id, parent) {
+			continue
+		}
+		keptNotes = append(keptNotes, n)
+
+		for gid := n.Group_id; gid != 0 && !keptGroups[gid]; gid = parent[gid] {
+			keptGroups[gid] = true
+		}
+	}
+
+	g := &graph{
+		nodes: map[string]*model.GraphNode{},
+		adj:   map[string][]string{},
+	}
+
+	if types[model.GraphGroup] {
+		for _, gr := range groups {
+			if !filtered || keptGroups[gr.Id] {
+				g.addNode(groupNodeID(gr.Id), model.GraphGroup, gr.Name)
+			}
+		}
+		for _, gr := range groups {
+			if gr.Pid != 0 {
+				g.addEdge(groupNodeID(gr.Pid), groupNodeID(gr.Id), model.GraphEdgeContains)
+			}
+		}
+	}
+
+	for _, n := range keptNotes {
+		if types[model.GraphNote] {
+			g.addNode(noteNodeID(n.Id), model.GraphNote, n.Title)
+			g.nodes[noteNodeID(n.Id)].Encrypted = n.Encrypted
+		}
+		if types[model.GraphTag] {
+			for _, tag := range tags[n.Id] {
+				g.addNode(tagNodeID(tag), model.GraphTag, "#"+tag)
+			}
+		}
+	}
+
+	for _, n := range keptNotes {
+		if n.Group_id != 0 {
+			g.addEdge(groupNodeID(n.Group_id), noteNodeID(n.Id), model.GraphEdgeContains)
+		}
+		for _, tag := range tags[n.Id] {
+			g.addEdge(noteNodeID(n.Id), tagNodeID(tag), model.GraphEdgeTagged)
+		}
+	}
+	for _, l := range links {
+		g.addEdge(noteNodeID(l.Source_id), noteNodeID(l.Target_id), model.GraphEdgeLink)
+	}
+
+	var order []string
+	if q.Note_id != 0 {
+		start := noteNodeID(q.Note_id)
+		if _, ok := g.nodes[start]; !ok {
+			return model.Graph{}, repository.ErrInvalidData
+		}
+		order = g.neighborhood(start, q.Depth)
+	} else {
+		// the most connected nodes survive the limit
+		order = append(order, g.order...)
+		sort.SliceStable(order, func(i, j int) bool {
+			return g.nodes[order[i]].Degree > g.nodes[order[j]].Degree
+		})
+	}
+
+	res := model.Graph{
+		Nodes: []model.GraphNode{},
+		Edges: []model.GraphEdge{},
+	}
+	if len(order) > q.Limit {
+		order = order[:q.Limit]
+		res.Truncated = true
+	}
+
+	kept := map[string]bool{}
+	for _, id := range order {
+		kept[id] = true
+		res.Nodes = append(res.Nodes, *g.nodes[id])
+	}
+	for _, e := range g.edges {
+		if kept[e.Source] && kept[e.Target] {
+			res.Edges = append(res.Edges, e)
+		}
+	}
+	return res, nil
+}
+
+// noteTags returns the #tags of the notes. They are stored with the
+// version of the note they were parsed from, only the texts of the notes
+// changed since then are read and parsed again.
+func (s *GraphService) noteTags(email string, notes []model.Note) (map[int][]string, error) {
+	stored, err := s.repository.GetTags(email)
+	if err != nil {
+		logger.NewLog("service - noteTags()", 2, err, "Filed to get tags in repository", email)
+		return nil, err
+	}
+	version := map[int]int{}
+	tags := map[int][]string{}
+	for _, t := range stored {
+		version[t.Note_id] = t.Version
+		tags[t.Note_id] = t.Tags
+	}
+
+	stale := []int{}
+	for _, n := range notes {
+		// hashtags of encrypted notes are hidden in the ciphertext
+		if n.Encrypted {
+			delete(tags, n.Id)
+			continue
+		}
+		if v, ok := version[n.Id]; !ok || v != n.Version {
+			stale = append(stale, n.Id)
+		}
+	}
+	if len(stale) == 0 {
+		return tags, nil
+	}
+
+	texts, err := s.repository.GetTexts(email, stale)
+	if err != nil {
+		logger.NewLog("service - noteTags()", 2, err, "Filed to get texts in repository", email)
+		return nil, err
+	}
+	parsed := make([]model.NoteTags, 0, len(stale))
+	for _, n := range notes {
+		text, ok := texts[n.Id]
+		if !ok || n.Encrypted {
+			continue
+		}
+		t := model.NoteTags{Note_id: n.Id, Version: n.Version, Tags: hashtag.Parse(text)}
+		tags[n.Id] = t.Tags
+		parsed = append(parsed, t)
+	}
+
+	// the graph is built anyway, the tags are parsed again next time
+	if err = s.repository.SetTags(parsed); err != nil {
+		logger.NewLog("service - noteTags()", 3, err, "Filed to set tags in repository", email)
+	}
+	return tags, nil
+}
+
+// neighborhood returns nodes not further than depth hops from start, nearest first
+func (g *graph) neighborhood(start string, depth int) []string {
+	dist := map[string]int{start: 0}
+	order := []string{start}
+	for i := 0; i < len(order); i++ {
+		cur := order[i]
+		if dist[cur] == depth {
+			continue
+		}
+		for _, next := range g.adj[cur] {
+			if _, ok := dist[next]; !ok {
+				dist[next] = dist[cur] + 1
+				order = append(order, next)
+			}
+		}
+	}
+	return order
+}
+
+func inGroup(groupID int, root int, parent map[int]int) bool {
+	// depth guard against broken trees with cycles
+	for i := 0; groupID != 0 && i < len(parent)+1; i++ {
+		if groupID == root {
+			return true
+		}
+		groupID = parent[groupID]
+	}
+	return false
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func noteNodeID(id int) string {
+	return model.GraphNote + ":" + strconv.Itoa(id)
+}
+
+func groupNodeID(id int) string {
+	return model.GraphGroup + ":" + strconv.Itoa(id)
+}
+
+func tagNodeID(tag string) string {
+	return model.GraphTag + ":" + tag
+}
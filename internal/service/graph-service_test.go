@@ -0,0 +1,299 @@
+package service
+
+import (
+	"noteapp/internal/model"
+	"noteapp/internal/repository"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeGraph keeps the texts of the notes apart from the notes, as the
+// graph reads them, and records the ids of every text read
+type fakeGraph struct {
+	groups []model.Group
+	notes  []model.Note
+	texts  map[int]string
+	links  []model.GraphLink
+	tags   map[int]model.NoteTags
+	reads  [][]int
+}
+
+// newFakeGraph returns the notes of "user":
+//
+//	work (1)
+//	  project (2): a (10) #go #db, b (11) #go
+//	home (3): c (12) #home, secret (14) encrypted
+//	d (13)
+//
+// with links a -> b -> c -> d
+func newFakeGraph() *fakeGraph {
+	return &fakeGraph{
+		groups: []model.Group{
+			{Id: 1, Name: "work"},
+			{Id: 2, Name: "project", Pid: 1},
+			{Id: 3, Name: "home"},
+		},
+		notes: []model.Note{
+			{Id: 10, Title: "a", Group_id: 2, Version: 1},
+			{Id: 11, Title: "b", Group_id: 2, Version: 1},
+			{Id: 12, Title: "c", Group_id: 3, Version: 1},
+			{Id: 13, Title: "d", Version: 1},
+			{Id: 14, Title: "secret", Group_id: 3, Version: 1, Encrypted: true},
+		},
+		texts: map[int]string{
+			10: "#go #db",
+			11: "#go",
+			12: "#home",
+			13: "",
+			14: "#go",
+		},
+		links: []model.GraphLink{
+			{Source_id: 10, Target_id: 11},
+			{Source_id: 11, Target_id: 12},
+			{Source_id: 12, Target_id: 13},
+		},
+		tags: map[int]model.NoteTags{},
+	}
+}
+
+func (r *fakeGraph) GetGroups(email string) ([]model.Group, error) {
+	return r.groups, nil
+}
+
+func (r *fakeGraph) GetNotes(email string) ([]model.Note, error) {
+	return append([]model.Note{}, r.notes...), nil
+}
+
+func (r *fakeGraph) GetLinks(email string) ([]model.GraphLink, error) {
+	return r.links, nil
+}
+
+func (r *fakeGraph) GetTexts(email string, ids []int) (map[int]string, error) {
+	r.reads = append(r.reads, ids)
+	texts := map[int]string{}
+	for _, id := range ids {
+		texts[id] = r.texts[id]
+	}
+	return texts, nil
+}
+
+func (r *fakeGraph) GetTags(email string) ([]model.NoteTags, error) {
+	list := []model.NoteTags{}
+	for _, t := range r.tags {
+		list = append(list, t)
+	}
+	return list, nil
+}
+
+func (r *fakeGraph) SetTags(list []model.NoteTags) error {
+	for _, t := range list {
+		r.tags[t.Note_id] = t
+	}
+	return nil
+}
+
+func TestGetGraph(t *testing.T) {
+	all := []string{
+		"group:1", "group:2", "group:3",
+		"note:10", "note:11", "note:12", "note:13", "note:14",
+		"tag:db", "tag:go", "tag:home",
+	}
+
+	testCases := []struct {
+		name          string
+		q             model.GraphQuery
+		want          error
+		wantNodes     []string
+		wantEdges     int
+		wantTruncated bool
+	}{
+		{
+			name:      "all",
+			wantNodes: all,
+			wantEdges: 12,
+		},
+		{
+			name:      "notes only",
+			q:         model.GraphQuery{Types: []string{model.GraphNote}},
+			wantNodes: []string{"note:10", "note:11", "note:12", "note:13", "note:14"},
+			wantEdges: 3,
+		},
+		{
+			name:      "groups and tags",
+			q:         model.GraphQuery{Types: []string{model.GraphGroup, model.GraphTag}},
+			wantNodes: []string{"group:1", "group:2", "group:3", "tag:db", "tag:go", "tag:home"},
+			wantEdges: 1,
+		},
+		{
+			name: "unknown type",
+			q:    model.GraphQuery{Types: []string{"user"}},
+			want: repository.ErrInvalidData,
+		},
+		{
+			// the encrypted note has #go in its ciphertext
+			name:      "tag",
+			q:         model.GraphQuery{Tag: "#GO"},
+			wantNodes: []string{"group:1", "group:2", "note:10", "note:11", "tag:db", "tag:go"},
+			wantEdges: 7,
+		},
+		{
+			name:      "tag of no note",
+			q:         model.GraphQuery{Tag: "rust"},
+			wantNodes: []string{},
+		},
+		{
+			name:      "group",
+			q:         model.GraphQuery{Group_id: 3},
+			wantNodes: []string{"group:3", "note:12", "note:14", "tag:home"},
+			wantEdges: 3,
+		},
+		{
+			name:      "group with subgroups",
+			q:         model.GraphQuery{Group_id: 1},
+			wantNodes: []string{"group:1", "group:2", "note:10", "note:11", "tag:db", "tag:go"},
+			wantEdges: 7,
+		},
+		{
+			name: "unknown group",
+			q:    model.GraphQuery{Group_id: 99},
+			want: repository.ErrInvalidData,
+		},
+		{
+			name:      "neighborhood",
+			q:         model.GraphQuery{Note_id: 11, Depth: 1},
+			wantNodes: []string{"group:2", "note:10", "note:11", "note:12", "tag:go"},
+			wantEdges: 6,
+		},
+		{
+			name:      "neighborhood of depth 2",
+			q:         model.GraphQuery{Note_id: 13, Depth: 2},
+			wantNodes: []string{"group:3", "note:11", "note:12", "note:13", "tag:home"},
+			wantEdges: 4,
+		},
+		{
+			name:      "neighborhood of the default depth",
+			q:         model.GraphQuery{Note_id: 13},
+			wantNodes: []string{"group:3", "note:11", "note:12", "note:13", "tag:home"},
+			wantEdges: 4,
+		},
+		{
+			name: "unknown note",
+			q:    model.GraphQuery{Note_id: 99},
+			want: repository.ErrInvalidData,
+		},
+		{
+			// a, b and c have 4 edges each
+			name:          "limit keeps the most connected",
+			q:             model.GraphQuery{Limit: 3},
+			wantNodes:     []string{"note:10", "note:11", "note:12"},
+			wantEdges:     2,
+			wantTruncated: true,
+		},
+		{
+			name:          "limit of the neighborhood keeps the nearest",
+			q:             model.GraphQuery{Note_id: 13, Depth: 2, Limit: 2},
+			wantNodes:     []string{"note:12", "note:13"},
+			wantEdges:     1,
+			wantTruncated: true,
+		},
+		{
+			name:      "limit above the graph",
+			q:         model.GraphQuery{Limit: graphMaxLimit + 1},
+			wantNodes: all,
+			wantEdges: 12,
+		},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			s := NewGraphService(newFakeGraph())
+
+			g, err := s.GetGraph("user", tcase.q)
+			assert.Equal(t, tcase.want, err)
+			if err != nil {
+				return
+			}
+
+			nodes := []string{}
+			for _, n := range g.Nodes {
+				nodes = append(nodes, n.Id)
+				assert.Equal(t, n.Id == "note:14", n.Encrypted, n.Id)
+			}
+			sort.Strings(nodes)
+
+			assert.Equal(t, tcase.wantNodes, nodes)
+			assert.Len(t, g.Edges, tcase.wantEdges)
+			assert.Equal(t, tcase.wantTruncated, g.Truncated)
+		})
+	}
+}
+
+func TestGraphTags(t *testing.T) {
+	repo := newFakeGraph()
+	s := NewGraphService(repo)
+
+	tagsOf := func(g model.Graph) []string {
+		tags := []string{}
+		for _, n := range g.Nodes {
+			if n.Type == model.GraphTag {
+				tags = append(tags, n.Id)
+			}
+		}
+		sort.Strings(tags)
+		return tags
+	}
+
+	testCases := []struct {
+		name string
+		// changes made before the request
+		change    func()
+		wantReads [][]int
+		wantTags  []string
+	}{
+		{
+			name:      "first graph parses the texts",
+			wantReads: [][]int{{10, 11, 12, 13}},
+			wantTags:  []string{"tag:db", "tag:go", "tag:home"},
+		},
+		{
+			name:     "stored tags are used",
+			wantTags: []string{"tag:db", "tag:go", "tag:home"},
+		},
+		{
+			name: "changed note is parsed again",
+			change: func() {
+				repo.notes[1].Version++
+				repo.texts[11] = "#rust"
+			},
+			wantReads: [][]int{{11}},
+			wantTags:  []string{"tag:db", "tag:go", "tag:home", "tag:rust"},
+		},
+		{
+			name: "encrypted note is not parsed",
+			change: func() {
+				repo.notes[0].Version++
+				repo.notes[0].Encrypted = true
+			},
+			wantTags: []string{"tag:home", "tag:rust"},
+		},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			if tcase.change != nil {
+				tcase.change()
+			}
+			repo.reads = nil
+
+			g, err := s.GetGraph("user", model.GraphQuery{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, tcase.wantReads, repo.reads)
+			assert.Equal(t, tcase.wantTags, tagsOf(g))
+		})
+	}
+}
@@ -0,0 +1,359 @@
+package service
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"noteapp/internal/importer"
+	"noteapp/internal/model"
+	"noteapp/internal/repository"
+	"noteapp/internal/thumbnail"
+	"noteapp/pkg/logger"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// importMaxSize - limit of an uploaded archive
+const importMaxSize = 1 << 30
+
+type ImportRepository interface {
+	Begin() (*repository.ImportTx, error)
+}
+
+type ImportService struct {
+	repository  ImportRepository
+	notes       *NotesService
+	attachments *AttachmentsService
+}
+
+func NewImportService(repo ImportRepository, notes *NotesService, attachments *AttachmentsService) *ImportService {
+	return &ImportService{
+		repository:  repo,
+		notes:       notes,
+		attachments: attachments,
+	}
+}
+
+// ImportMarkdown imports a ZIP of Markdown files (see importer.ReadMarkdown)
+// into the notes of the user. Groups and notes are created in one
+// transaction: either everything is imported or nothing.
+func (s *ImportService) ImportMarkdown(ctx context.Context, email string, r io.Reader) (*model.ImportReport, error) {
+	zr, tmp, err := spoolZip(r)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	root, errs := importer.ReadMarkdown(zr)
+	return s.importTree(ctx, email, root, errs)
+}
+
+// ImportENEX imports an Evernote export or a ZIP of them, every export
+// is a notebook and becomes a group named after the file
+func (s *ImportService) ImportENEX(ctx context.Context, email string, name string, r io.Reader) (*model.ImportReport, error) {
+	// decoded resources wait here until the blob store
+	spoolFile, err := os.CreateTemp("", "import-*")
+	if err != nil {
+		logger.NewLog("service - ImportENEX()", 2, err, "Filed to create temp file", nil)
+		return nil, err
+	}
+	defer os.Remove(spoolFile.Name())
+	defer spoolFile.Close()
+	spool := importer.NewSpool(spoolFile)
+
+	br := bufio.NewReader(r)
+	if head, _ := br.Peek(4); string(head) == "PK\x03\x04" {
+		zr, tmp, err := spoolZip(br)
+		if err != nil {
+			return nil, err
+		}
+		defer os.Remove(tmp.Name())
+		defer tmp.Close()
+
+		root, errs := importer.ReadENEXArchive(zr, spool)
+		return s.importTree(ctx, email, root, errs)
+	}
+
+	lr := &io.LimitedReader{R: br, N: importMaxSize + 1}
+	notebook, errs := importer.ReadENEX(lr, strings.TrimSuffix(filepath.Base(name), filepath.Ext(name)), spool)
+	if lr.N == 0 {
+		return nil, ErrFileTooLarge
+	}
+	return s.importTree(ctx, email, &importer.Folder{Folders: []*importer.Folder{notebook}}, errs)
+}
+
+// ImportOPML imports an OPML outline, see importer.ReadOPML
+func (s *ImportService) ImportOPML(ctx context.Context, email string, name string, r io.Reader) (*model.ImportReport, error) {
+	lr := &io.LimitedReader{R: r, N: importMaxSize + 1}
+	root, errs := importer.ReadOPML(lr, name)
+	if lr.N == 0 {
+		return nil, ErrFileTooLarge
+	}
+	return s.importTree(ctx, email, root, errs)
+}
+
+// spoolZip saves the upload to a temp file, ZIP is read from the end.
+// The caller removes the file.
+func spoolZip(r io.Reader) (*zip.Reader, *os.File, error) {
+	tmp, err := os.CreateTemp("", "import-*")
+	if err != nil {
+		logger.NewLog("service - spoolZip()", 2, err, "Filed to create temp file", nil)
+		return nil, nil, err
+	}
+	fail := func(err error) (*zip.Reader, *os.File, error) {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return nil, nil, err
+	}
+
+	size, err := io.Copy(tmp, io.LimitReader(r, importMaxSize+1))
+	if err != nil {
+		logger.NewLog("service - spoolZip()", 3, err, "Filed to read upload", nil)
+		return fail(repository.ErrInvalidData)
+	}
+	if size > importMaxSize {
+		return fail(ErrFileTooLarge)
+	}
+
+	zr, err := zip.NewReader(tmp, size)
+	if err != nil {
+		logger.NewLog("service - spoolZip()", 3, err, "Filed to open ZIP", nil)
+		return fail(repository.ErrInvalidData)
+	}
+	return zr, tmp, nil
+}
+
+// importTree stores the assets, creates the folders and notes of the tree
+// and indexes their links
+func (s *ImportService) importTree(ctx context.Context, email string, root *importer.Folder, errs []model.ImportError) (*model.ImportReport, error) {
+	report := &model.ImportReport{Errors: errs}
+
+	stored, err := s.storeAssets(ctx, email, root, report)
+	if err != nil {
+		return nil, err
+	}
+
+	notes, err := s.save(email, root, stored, report)
+	if err != nil {
+		// the rows are rolled back, the blobs have to be removed by hand
+		for _, a := range stored {
+			if err := s.attachments.store.Delete(ctx, a.Blob_key); err != nil {
+				logger.NewLog("service - importTree()", 2, err, "Filed to delete blob from store", a.Blob_key)
+			}
+		}
+		return nil, err
+	}
+
+	// links are indexed when all notes exist, so they resolve to each other
+	for _, n := range notes {
+		s.notes.indexText(n.Id, email, n.Text)
+	}
+	for _, n := range notes {
+		if err = s.notes.links.ResolveLinks(email, n.Id, n.Title); err != nil {
+			logger.NewLog("service - importTree()", 2, err, "Filed to resolve links in repository", n.Id)
+		}
+	}
+	for _, a := range stored {
+		if a.Thumbnails == model.ThumbnailsPending {
+			s.attachments.enqueueThumbnails(a.Id)
+		}
+	}
+
+	// one event instead of one per note, clients reload the list
+	s.notes.events.Publish(email, model.Event{Type: model.EventResync})
+	return report, nil
+}
+
+// storeAssets puts the files referenced by the notes to the blob store,
+// files over the limits are reported and skipped
+func (s *ImportService) storeAssets(ctx context.Context, email string, root *importer.Folder, report *model.ImportReport) (map[*importer.Asset]*model.Attachment, error) {
+	stored := map[*importer.Asset]*model.Attachment{}
+	seen := map[*importer.Asset]bool{}
+	assets := []*importer.Asset{}
+	root.Walk(func(n *importer.Note) {
+		for _, a := range n.Assets {
+			if !seen[a] {
+				seen[a] = true
+				assets = append(assets, a)
+			}
+		}
+	})
+	if len(assets) == 0 {
+		return stored, nil
+	}
+
+	used, err := s.attachments.repository.UsedSpace(email)
+	if err != nil {
+		logger.NewLog("service - storeAssets()", 2, err, "Filed to get used space in repository", email)
+		return nil, err
+	}
+	free := s.attachments.limits.MaxUserSize - used
+
+	for _, asset := range assets {
+		a, err := s.storeAsset(ctx, email, asset, free)
+		if err == ErrFileTooLarge || err == ErrQuotaExceeded || err == repository.ErrInvalidData {
+			report.Errors = append(report.Errors, model.ImportError{File: asset.Source, Error: err.Error()})
+			continue
+		}
+		if err != nil {
+			for _, a := range stored {
+				if err := s.attachments.store.Delete(ctx, a.Blob_key); err != nil {
+					logger.NewLog("service - storeAssets()", 2, err, "Filed to delete blob from store", a.Blob_key)
+				}
+			}
+			return nil, err
+		}
+		stored[asset] = a
+		free -= a.Size
+	}
+	return stored, nil
+}
+
+func (s *ImportService) storeAsset(ctx context.Context, email string, asset *importer.Asset, free int64) (*model.Attachment, error) {
+	limit := s.attachments.limits.MaxFileSize
+	if asset.Size > limit {
+		return nil, ErrFileTooLarge
+	}
+	if asset.Size > free {
+		return nil, ErrQuotaExceeded
+	}
+
+	r, err := asset.Open()
+	if err != nil {
+		logger.NewLog("service - storeAsset()", 3, err, "Filed to open file of archive", asset.Source)
+		return nil, repository.ErrInvalidData
+	}
+	defer r.Close()
+
+	// the size in the archive may lie
+	data, err := io.ReadAll(io.LimitReader(r, min(limit, free)+1))
+	if err != nil {
+		logger.NewLog("service - storeAsset()", 3, err, "Filed to read file of archive", asset.Source)
+		return nil, repository.ErrInvalidData
+	}
+	if int64(len(data)) > limit {
+		return nil, ErrFileTooLarge
+	}
+	if int64(len(data)) > free {
+		return nil, ErrQuotaExceeded
+	}
+
+	key, err := newBlobKey()
+	if err != nil {
+		logger.NewLog("service - storeAsset()", 2, err, "Filed to generate blob key", nil)
+		return nil, err
+	}
+
+	a := &model.Attachment{
+		Owner_email:  email,
+		Blob_key:     key,
+		Name:         asset.Name,
+		Content_type: detectContentType(data[:min(len(data), 512)], asset.Name),
+		Thumbnails:   model.ThumbnailsNone,
+	}
+	a.Name = truncate(a.Name, attachmentNameMax)
+	if thumbnail.Supported(a.Content_type) {
+		a.Thumbnails = model.ThumbnailsPending
+		data = stripMetadata(data, a)
+	}
+	a.Size = int64(len(data))
+
+	if err = s.attachments.store.Put(ctx, key, bytes.NewReader(data), a.Size, a.Content_type); err != nil {
+		logger.NewLog("service - storeAsset()", 2, err, "Filed to put blob in store", key)
+		return nil, err
+	}
+	return a, nil
+}
+
+// save creates the groups, notes and attachments rows in one transaction
+func (s *ImportService) save(email string, root *importer.Folder, stored map[*importer.Asset]*model.Attachment, report *model.ImportReport) ([]model.Note, error) {
+	tx, err := s.repository.Begin()
+	if err != nil {
+		logger.NewLog("service - save()", 2, err, "Filed to begin transaction", nil)
+		return nil, err
+	}
+
+	notes := []model.Note{}
+	url := func(asset *importer.Asset) string {
+		if a := stored[asset]; a != nil && a.Id != 0 {
+			return "/getAttachment?id=" + strconv.Itoa(a.Id)
+		}
+		return ""
+	}
+
+	var walk func(f *importer.Folder, groupID int) error
+	walk = func(f *importer.Folder, groupID int) error {
+		for _, n := range f.Notes {
+			// attachments rows go first, the text refers to their ids
+			owned := []*model.Attachment{}
+			for _, asset := range n.Assets {
+				if a := stored[asset]; a != nil && a.Id == 0 {
+					if err := tx.AddAttachment(a); err != nil {
+						logger.NewLog("service - save()", 2, err, "Filed to add attachment in repository", a.Name)
+						return err
+					}
+					owned = append(owned, a)
+				}
+			}
+
+			note := model.Note{
+				User_email: email,
+				Title:      n.Title,
+				Text:       n.Resolve(url),
+				Group_id:   groupID,
+				Created_at: n.Created_at,
+				Updated_at: n.Updated_at,
+			}
+			if err := tx.AddNote(&note); err != nil {
+				logger.NewLog("service - save()", 2, err, "Filed to add note in repository", n.Source)
+				return err
+			}
+			for _, a := range owned {
+				if err := tx.SetAttachmentNote(a.Id, note.Id); err != nil {
+					logger.NewLog("service - save()", 2, err, "Filed to set note of attachment in repository", a.Id)
+					return err
+				}
+				a.Note_id = note.Id
+			}
+			notes = append(notes, note)
+			report.Attachments += len(owned)
+		}
+
+		for _, sub := range f.Folders {
+			id, err := tx.AddGroup(email, sub.Name, groupID)
+			if err != nil {
+				logger.NewLog("service - save()", 2, err, "Filed to add group in repository", sub.Name)
+				return err
+			}
+			report.Groups++
+			if err = walk(sub, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err = walk(root, 0); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	// parallel uploads could take the space counted as free in storeAssets
+	if len(stored) > 0 {
+		if err = tx.CheckQuota(email, s.attachments.limits.MaxUserSize); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		logger.NewLog("service - save()", 2, err, "Filed to commit transaction", nil)
+		return nil, err
+	}
+
+	report.Notes = len(notes)
+	return notes, nil
+}
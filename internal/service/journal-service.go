@@ -0,0 +1,146 @@
+package service
+
+import (
+	"noteapp/internal/model"
+	"noteapp/internal/repository"
+	"noteapp/internal/templates"
+	"noteapp/pkg/logger"
+	"time"
+)
+
+// journalGroup - name of the group made for the journal if the user
+// has not chosen one
+const journalGroup = "Journal"
+
+type JournalRepository interface {
+	GetJournal(email string) (model.Journal, error)
+	SetJournal(email string, j model.Journal) error
+	FindGroup(email string, name string, pid int) (int, error)
+	GetDay(email string, day string) (int, error)
+	AddDay(email string, day string, noteID int) (int, error)
+	Neighbours(email string, day string) (string, string, error)
+}
+
+type JournalService struct {
+	repository JournalRepository
+	notes      *NotesService
+	templates  *TemplatesService
+}
+
+func NewJournalService(repo JournalRepository, notes *NotesService, templates *TemplatesService) *JournalService {
+	return &JournalService{
+		repository: repo,
+		notes:      notes,
+		templates:  templates,
+	}
+}
+
+func (s *JournalService) GetJournal(email string) (model.Journal, error) {
+	j, err := s.repository.GetJournal(email)
+	if err != nil {
+		logger.NewLog("service - GetJournal()", 2, err, "Filed to get journal in repository", email)
+	}
+	return j, err
+}
+
+func (s *JournalService) UpdateJournal(email string, j model.Journal) error {
+	if j.Builtin == "" {
+		j.Builtin = "daily"
+	}
+	if _, ok := templates.FindBuiltin(j.Builtin); !ok {
+		return repository.ErrInvalidData
+	}
+
+	err := s.repository.SetJournal(email, j)
+	if err != nil && err != repository.ErrInvalidData {
+		logger.NewLog("service - UpdateJournal()", 2, err, "Filed to set journal in repository", email)
+	}
+	return err
+}
+
+// GetDailyNote returns the note of the day, on first access it is made
+// from the journal template in the Year/Month subgroups of the journal
+// group. now is the time of the user, day is a date in its location.
+func (s *JournalService) GetDailyNote(email string, day time.Time, now time.Time) (model.DailyNote, error) {
+	daily := model.DailyNote{Date: day.Format(model.DateLayout)}
+
+	id, err := s.repository.GetDay(email, daily.Date)
+	if err != nil {
+		logger.NewLog("service - GetDailyNote()", 2, err, "Filed to get day in repository", daily.Date)
+		return daily, err
+	}
+	if id == 0 {
+		if id, err = s.addDay(email, day, now); err != nil {
+			return daily, err
+		}
+	}
+
+	if daily.Note, err = s.notes.GetNote(id, email); err != nil {
+		return daily, err
+	}
+
+	daily.Previous, daily.Next, err = s.repository.Neighbours(email, daily.Date)
+	if err != nil {
+		logger.NewLog("service - GetDailyNote()", 2, err, "Filed to get neighbours in repository", daily.Date)
+	}
+	return daily, err
+}
+
+func (s *JournalService) addDay(email string, day time.Time, now time.Time) (int, error) {
+	j, err := s.GetJournal(email)
+	if err != nil {
+		return 0, err
+	}
+
+	groupID := j.Group_id
+	if groupID == 0 {
+		if groupID, err = s.group(email, journalGroup, 0); err != nil {
+			return 0, err
+		}
+		j.Group_id = groupID
+		if err = s.repository.SetJournal(email, j); err != nil {
+			logger.NewLog("service - addDay()", 2, err, "Filed to set journal in repository", email)
+			return 0, err
+		}
+	}
+	if groupID, err = s.group(email, day.Format("2006"), groupID); err != nil {
+		return 0, err
+	}
+	if groupID, err = s.group(email, day.Format("01 January"), groupID); err != nil {
+		return 0, err
+	}
+
+	// placeholders get the day of the note with the time of the user
+	at := time.Date(day.Year(), day.Month(), day.Day(), now.Hour(), now.Minute(), now.Second(), 0, now.Location())
+	date := day.Format(model.DateLayout)
+	id, err := s.templates.AddNoteFromTemplate(email, j.Template_id, j.Builtin, groupID, date, at)
+	if err != nil {
+		return 0, err
+	}
+
+	stored, err := s.repository.AddDay(email, date, id)
+	if err != nil {
+		logger.NewLog("service - addDay()", 2, err, "Filed to add day in repository", date)
+	}
+	if stored != id {
+		// the note was made by a parallel request or the day was not saved
+		if err := s.notes.DelNote(id, email); err != nil {
+			logger.NewLog("service - addDay()", 2, err, "Filed to del note", id)
+		}
+	}
+	return stored, err
+}
+
+// group returns the group of the user with the name inside pid,
+// creating it if there is none
+func (s *JournalService) group(email string, name string, pid int) (int, error) {
+	id, err := s.repository.FindGroup(email, name, pid)
+	if err != nil {
+		logger.NewLog("service - group()", 2, err, "Filed to find group in repository", name)
+		return 0, err
+	}
+	if id != 0 {
+		return id, nil
+	}
+	return s.notes.AddGroup(email, name, pid)
+}
@@ -0,0 +1,247 @@
+package service
+
+import (
+	"noteapp/internal/model"
+	"noteapp/internal/repository"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeJournal keeps the journal settings and the days, the groups are
+// the groups of fakeNotes. AddDay stores parallel instead of the note if
+// it is set, as if another request had made the day first.
+type fakeJournal struct {
+	*fakeNotes
+	journal  model.Journal
+	days     map[string]int
+	parallel int
+}
+
+func (r *fakeJournal) GetJournal(email string) (model.Journal, error) {
+	return r.journal, nil
+}
+
+func (r *fakeJournal) SetJournal(email string, j model.Journal) error {
+	r.journal = j
+	return nil
+}
+
+func (r *fakeJournal) FindGroup(email string, name string, pid int) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, g := range r.groups {
+		if g.User_email == email && g.Name == name && g.Pid == pid {
+			return id, nil
+		}
+	}
+	return 0, nil
+}
+
+func (r *fakeJournal) GetDay(email string, day string) (int, error) {
+	return r.days[day], nil
+}
+
+func (r *fakeJournal) AddDay(email string, day string, noteID int) (int, error) {
+	if r.parallel != 0 {
+		noteID = r.parallel
+	}
+	if _, ok := r.days[day]; !ok {
+		r.days[day] = noteID
+	}
+	return r.days[day], nil
+}
+
+func (r *fakeJournal) Neighbours(email string, day string) (string, string, error) {
+	previous, next := "", ""
+	for d := range r.days {
+		if d < day && d > previous {
+			previous = d
+		}
+		if d > day && (next == "" || d < next) {
+			next = d
+		}
+	}
+	return previous, next, nil
+}
+
+// fakeTemplates has one template of the user, id 7
+type fakeTemplates struct {
+	TemplatesRepository
+}
+
+func (fakeTemplates) GetTemplate(id int, email string) (*model.Template, error) {
+	if id != 7 {
+		return nil, repository.ErrInvalidData
+	}
+	return &model.Template{Id: 7, User_email: email, Name: "Day", Title: "Day {{date}}", Text: "{{datetime}} {{weekday}}"}, nil
+}
+
+func TestGetDailyNote(t *testing.T) {
+	// the user is in UTC+10, it is already the 2nd of March there
+	loc := time.FixedZone("UTC+10", 10*60*60)
+	now := time.Date(2024, 3, 2, 1, 30, 0, 0, loc)
+	date := func(s string) time.Time {
+		d, err := time.ParseInLocation(model.DateLayout, s, loc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+
+	type daily struct {
+		title string
+		// groups from the root to the group of the note
+		path     []string
+		previous string
+		next     string
+	}
+
+	testCases := []struct {
+		name string
+		// days opened before the request
+		before []string
+		// the user has chosen group "Diary" for the journal
+		chosen   bool
+		template int
+		parallel bool
+		day      string
+		want     daily
+		// text of the note, not checked if empty
+		wantText   string
+		wantGroups int
+		wantNotes  int
+	}{
+		{
+			name:       "first day",
+			day:        "2024-03-02",
+			want:       daily{title: "2024-03-02", path: []string{"Journal", "2024", "03 March"}},
+			wantText:   "# Saturday, 2024-03-02\n\n## Tasks\n\n- [ ] \n\n## Notes\n\n",
+			wantGroups: 3,
+			wantNotes:  1,
+		},
+		{
+			name:       "month is reused",
+			before:     []string{"2024-03-01"},
+			day:        "2024-03-02",
+			want:       daily{title: "2024-03-02", path: []string{"Journal", "2024", "03 March"}, previous: "2024-03-01"},
+			wantGroups: 3,
+			wantNotes:  2,
+		},
+		{
+			name:       "new month in the year",
+			before:     []string{"2024-02-10"},
+			day:        "2024-03-02",
+			want:       daily{title: "2024-03-02", path: []string{"Journal", "2024", "03 March"}, previous: "2024-02-10"},
+			wantGroups: 4,
+			wantNotes:  2,
+		},
+		{
+			name:       "new year",
+			before:     []string{"2024-03-01"},
+			day:        "2023-12-31",
+			want:       daily{title: "2023-12-31", path: []string{"Journal", "2023", "12 December"}, next: "2024-03-01"},
+			wantGroups: 5,
+			wantNotes:  2,
+		},
+		{
+			name:       "opened day",
+			before:     []string{"2024-02-10", "2024-03-02", "2024-03-05"},
+			day:        "2024-03-02",
+			want:       daily{title: "2024-03-02", path: []string{"Journal", "2024", "03 March"}, previous: "2024-02-10", next: "2024-03-05"},
+			wantGroups: 4,
+			wantNotes:  3,
+		},
+		{
+			name:       "chosen group",
+			chosen:     true,
+			day:        "2024-03-02",
+			want:       daily{title: "2024-03-02", path: []string{"Diary", "2024", "03 March"}},
+			wantGroups: 3,
+			wantNotes:  1,
+		},
+		{
+			name:       "template of the user",
+			template:   7,
+			day:        "2024-03-02",
+			want:       daily{title: "2024-03-02", path: []string{"Journal", "2024", "03 March"}},
+			wantText:   "2024-03-02 01:30 Saturday",
+			wantGroups: 3,
+			wantNotes:  1,
+		},
+		{
+			name:       "past day gets the time of the user",
+			template:   7,
+			day:        "2024-02-10",
+			want:       daily{title: "2024-02-10", path: []string{"Journal", "2024", "02 February"}},
+			wantText:   "2024-02-10 01:30 Saturday",
+			wantGroups: 3,
+			wantNotes:  1,
+		},
+		{
+			name:       "day made by a parallel request",
+			parallel:   true,
+			day:        "2024-03-02",
+			want:       daily{title: "parallel", path: []string{"Journal", "2024", "03 March"}},
+			wantGroups: 3,
+			wantNotes:  1,
+		},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			notes, notesRepo := newFakeNotesService()
+			repo := &fakeJournal{fakeNotes: notesRepo, days: map[string]int{}}
+			s := NewJournalService(repo, notes, NewTemplatesService(fakeTemplates{}, notes))
+
+			if tcase.chosen {
+				id, err := notes.AddGroup("user", "Diary", 0)
+				if err != nil {
+					t.Fatal(err)
+				}
+				repo.journal.Group_id = id
+			}
+			repo.journal.Template_id = tcase.template
+			repo.journal.Builtin = "daily"
+
+			for _, d := range tcase.before {
+				if _, err := s.GetDailyNote("user", date(d), now); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tcase.parallel {
+				// the day is in the journal group made by the other request
+				pid, _ := s.group("user", journalGroup, 0)
+				pid, _ = s.group("user", "2024", pid)
+				pid, _ = s.group("user", "03 March", pid)
+				id, err := notes.AddNote("user", "parallel", pid)
+				if err != nil {
+					t.Fatal(err)
+				}
+				repo.journal.Group_id = 1
+				repo.parallel = id
+			}
+
+			res, err := s.GetDailyNote("user", date(tcase.day), now)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := daily{title: res.Note.Title, previous: res.Previous, next: res.Next}
+			for id := res.Note.Group_id; id != 0; id = repo.groups[id].Pid {
+				got.path = append([]string{repo.groups[id].Name}, got.path...)
+			}
+
+			assert.Equal(t, tcase.day, res.Date)
+			assert.Equal(t, tcase.want, got)
+			if tcase.wantText != "" {
+				assert.Equal(t, tcase.wantText, res.Note.Text)
+			}
+			assert.Len(t, repo.groups, tcase.wantGroups)
+			assert.Len(t, repo.notes, tcase.wantNotes)
+			assert.Equal(t, res.Note.Id, repo.days[tcase.day])
+			assert.NotZero(t, repo.journal.Group_id)
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"database/sql"
+	"noteapp/internal/model"
+	"noteapp/pkg/logger"
+)
+
+type KeysRepository interface {
+	GetKeys(email string) (model.UserKeys, error)
+	SetKeys(k *model.UserKeys) error
+}
+
+// KeysService keeps the key material of the end-to-end encrypted notes.
+// Keys are derived and unwrapped by the client, the server only stores
+// the wrapped key and what is needed to derive the wrapping key again.
+type KeysService struct {
+	repository KeysRepository
+}
+
+func NewKeysService(repo KeysRepository) *KeysService {
+	return &KeysService{
+		repository: repo,
+	}
+}
+
+// GetKeys returns model.ErrNoKeys if the user has not set up encryption
+func (s *KeysService) GetKeys(email string) (model.UserKeys, error) {
+	k, err := s.repository.GetKeys(email)
+	if err == sql.ErrNoRows {
+		return k, model.ErrNoKeys
+	}
+	if err != nil {
+		logger.NewLog("service - GetKeys()", 2, err, "Filed to get keys in repository", email)
+	}
+	return k, err
+}
+
+// SetKeys creates the keys (k.Version = 0) or replaces them after the
+// passphrase or the recovery key was changed. The note key itself must
+// stay the same, the server can not re-encrypt the notes.
+func (s *KeysService) SetKeys(k *model.UserKeys) error {
+	if err := k.Validate(); err != nil {
+		return err
+	}
+
+	err := s.repository.SetKeys(k)
+	if err != nil && err != model.ErrKeysVersion {
+		logger.NewLog("service - SetKeys()", 2, err, "Filed to set keys in repository", k.User_email)
+	}
+	return err
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"noteapp/internal/model"
+	"noteapp/internal/wikilink"
+	"noteapp/pkg/logger"
+	"strconv"
+	"strings"
+)
+
+const linkTextMax = 200
+
+type LinksRepository interface {
+	SetLinks(sourceID int, owner string, links []model.NoteLink) error
+	ResolveLinks(owner string, noteID int, title string) error
+	GetLinks(sourceID int) ([]model.NoteLink, error)
+	GetBacklinks(targetID int) ([]model.Backlink, error)
+	GetTitleLinkSources(targetID int) ([]int, error)
+}
+
+// LINKS
+
+// GetLinks returns [[links]] from the note, broken ones are flagged
+func (s *NotesService) GetLinks(id int, email string) ([]model.NoteLink, error) {
+	if _, err := s.noteAccess(id, email, model.PermissionRead); err != nil {
+		return nil, err
+	}
+
+	links, err := s.links.GetLinks(id)
+	if err != nil {
+		logger.NewLog("service - GetLinks()", 2, err, "Filed to get links in repository", id)
+	}
+	return links, err
+}
+
+// GetBacklinks returns notes linking to the note, which the user can read
+func (s *NotesService) GetBacklinks(id int, email string) ([]model.Backlink, error) {
+	owner, err := s.noteAccess(id, email, model.PermissionRead)
+	if err != nil {
+		return nil, err
+	}
+
+	backlinks, err := s.links.GetBacklinks(id)
+	if err != nil {
+		logger.NewLog("service - GetBacklinks()", 2, err, "Filed to get backlinks in repository", id)
+		return nil, err
+	}
+	if owner == email {
+		return backlinks, nil
+	}
+
+	visible := []model.Backlink{}
+	for _, b := range backlinks {
+		if _, err := s.noteAccess(b.Note_id, email, model.PermissionRead); err == nil {
+			visible = append(visible, b)
+		}
+	}
+	return visible, nil
+}
+
+// updateLinks parses the text of the note and stores its links
+func (s *NotesService) updateLinks(id int, owner string, text string) {
+	parsed := wikilink.Parse(text)
+	links := make([]model.NoteLink, 0, len(parsed))
+	for _, p := range parsed {
+		links = append(links, model.NoteLink{
+			Source_id: id,
+			Title:     p.Title,
+			Ref_id:    p.Id,
+			Heading:   truncate(p.Heading, linkTextMax),
+			Alias:     truncate(p.Alias, linkTextMax),
+		})
+	}
+
+	if err := s.links.SetLinks(id, owner, links); err != nil {
+		logger.NewLog("service - updateLinks()", 2, err, "Filed to set links in repository", id)
+	}
+}
+
+// renameLinks rewrites [[Old Title]] in the notes linking to the renamed
+// note, so the links stay valid after the next edit of those notes
+func (s *NotesService) renameLinks(id int, owner string, actor string, oldTitle string, newTitle string) {
+	sources, err := s.links.GetTitleLinkSources(id)
+	if err != nil {
+		logger.NewLog("service - renameLinks()", 2, err, "Filed to get link sources in repository", id)
+		return
+	}
+
+	for _, sourceID := range sources {
+		note, err := s.repository.GetNote(sourceID, owner)
+		if err != nil {
+			logger.NewLog("service - renameLinks()", 2, err, "Filed to get note in repository", sourceID)
+			continue
+		}
+
+		text := wikilink.Rename(note.Text, oldTitle, newTitle)
+		if text == note.Text {
+			continue
+		}
+
+		data := map[string]string{
+			"id":    strconv.Itoa(sourceID),
+			"email": owner,
+			"text":  text,
+		}
+		if err = s.repository.UpdateNote(data); err != nil {
+			logger.NewLog("service - renameLinks()", 2, err, "Filed to update note in repository", sourceID)
+			continue
+		}
+		s.indexText(sourceID, owner, text)
+		s.publish(owner, actor, model.Event{Type: model.EventNoteUpdated, Note_id: sourceID})
+	}
+
+	if err = s.links.ResolveLinks(owner, id, newTitle); err != nil {
+		logger.NewLog("service - renameLinks()", 2, err, "Filed to resolve links in repository", id)
+	}
+}
+
+// linkKey identifies a link target the same way for parsed and stored links
+func linkKey(title string, id int) string {
+	if title != "" {
+		return "t:" + strings.ToLower(title)
+	}
+	return "i:" + strconv.Itoa(id)
+}
+
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	// do not cut a multibyte rune
+	for max > 0 && s[max]&0xC0 == 0x80 {
+		max--
+	}
+	return s[:max]
+}
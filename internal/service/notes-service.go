@@ -1,95 +1,309 @@
 package service
 
 import (
+	"database/sql"
+	"errors"
 	"noteapp/internal/model"
+	"noteapp/internal/render"
+	"noteapp/internal/repository"
+	"noteapp/internal/wikilink"
 	"noteapp/pkg/logger"
 	"strconv"
 )
 
+var (
+	ErrAccessDenied = errors.New("access denied")
+)
+
 type NotesRepository interface {
 	// GROUPS
-	AddGroup(email string, nameGroup string, pid int) error
+	AddGroup(email string, nameGroup string, pid int) (int, error)
 	DelGroup(id int, email string) error
 	UpdateGroup(id int, email string, newNameGroup string, pid int) error
 	// NOTES
-	AddNote(email string, title string, group_id int) error
+	AddNote(email string, title string, group_id int) (int, error)
 	DelNote(id int, email string) error
 	UpdateNote(data map[string]string) error
 	GetNotesList(email string) (model.NoteList, error)
 	GetNote(id int, email string) (model.Note, error)
 }
 
+type AccessRepository interface {
+	NoteOwner(noteID int) (string, error)
+	GroupOwner(groupID int) (string, error)
+	NotePermission(noteID int, email string) (string, error)
+	GroupPermission(groupID int, email string) (string, error)
+}
+
+type EventPublisher interface {
+	Publish(email string, e model.Event)
+}
+
 type NotesService struct {
 	repository NotesRepository
+	access     AccessRepository
+	events     EventPublisher
+	links      LinksRepository
+	tasks      TasksRepository
 }
 
-func NewNotesService(repo NotesRepository) *NotesService {
+func NewNotesService(repo NotesRepository, access AccessRepository, events EventPublisher, links LinksRepository, tasks TasksRepository) *NotesService {
 	return &NotesService{
 		repository: repo,
+		access:     access,
+		events:     events,
+		links:      links,
+		tasks:      tasks,
 	}
 }
 
 // GROUPS
 
-func (s *NotesService) AddGroup(email string, nameGroup string, pid int) error {
+func (s *NotesService) AddGroup(email string, nameGroup string, pid int) (int, error) {
+
+	owner := email
+	if pid != 0 {
+		var err error
+		owner, err = s.groupAccess(pid, email, model.PermissionEdit)
+		if err != nil {
+			return 0, err
+		}
+	}
 
-	err := s.repository.AddGroup(email, nameGroup, pid)
+	id, err := s.repository.AddGroup(owner, nameGroup, pid)
 	if err != nil {
 		logger.NewLog("service - AddGroup()", 2, err, "Filed to add group in repository", nil)
+		return 0, err
 	}
-	return err
+
+	s.publish(owner, email, model.Event{Type: model.EventGroupCreated, Group_id: id, Pid: pid, Title: nameGroup})
+	return id, nil
 }
 
 func (s *NotesService) DelGroup(id int, email string) error {
-	err := s.repository.DelGroup(id, email)
+	owner, err := s.groupAccess(id, email, model.PermissionEdit)
+	if err != nil {
+		return err
+	}
+
+	err = s.repository.DelGroup(id, owner)
 	if err != nil {
 		logger.NewLog("service - DelGroup()", 2, err, "Filed to del group in repository", nil)
+		return err
 	}
-	return err
+
+	s.publish(owner, email, model.Event{Type: model.EventGroupDeleted, Group_id: id})
+	return nil
 }
 
 func (s *NotesService) UpdateGroup(id int, email string, newNameGroup string, pid int) error {
-	err := s.repository.UpdateGroup(id, email, newNameGroup, pid)
+	owner, err := s.groupAccess(id, email, model.PermissionEdit)
+	if err != nil {
+		return err
+	}
+
+	// group can be moved only inside the tree of the same owner,
+	// to the root only by the owner
+	if pid == 0 && owner != email {
+		return ErrAccessDenied
+	}
+	if pid > 0 {
+		pidOwner, err := s.groupAccess(pid, email, model.PermissionEdit)
+		if err != nil {
+			return err
+		}
+		if pidOwner != owner {
+			return repository.ErrInvalidData
+		}
+	}
+
+	err = s.repository.UpdateGroup(id, owner, newNameGroup, pid)
 	if err != nil {
 		logger.NewLog("service - UpdateGroup()", 2, err, "Filed to update group in repository", nil)
+		return err
 	}
-	return err
+
+	if newNameGroup != "" {
+		s.publish(owner, email, model.Event{Type: model.EventGroupUpdated, Group_id: id, Title: newNameGroup})
+	}
+	if pid != -1 {
+		s.publish(owner, email, model.Event{Type: model.EventGroupMoved, Group_id: id, Pid: pid})
+	}
+	return nil
 }
 
 // NOTES
 
-func (s *NotesService) AddNote(email string, title string, group_id int) error {
-	err := s.repository.AddNote(email, title, group_id)
+func (s *NotesService) AddNote(email string, title string, group_id int) (int, error) {
+	return s.AddNoteWithText(email, title, "", group_id)
+}
+
+// AddNoteWithText creates the note with its text, notes from templates
+// start with one
+func (s *NotesService) AddNoteWithText(email string, title string, text string, group_id int) (int, error) {
+	return s.addNote(email, title, text, group_id, false)
+}
+
+// AddEncryptedNote creates the note whose title and text are ciphertext
+// of the client, they are stored as they are and never parsed
+func (s *NotesService) AddEncryptedNote(email string, title string, text string, group_id int) (int, error) {
+	return s.addNote(email, title, text, group_id, true)
+}
+
+func (s *NotesService) addNote(email string, title string, text string, group_id int, encrypted bool) (int, error) {
+	if !validTitle(title, encrypted) {
+		return 0, repository.ErrInvalidData
+	}
+
+	owner := email
+	if group_id != -1 {
+		var err error
+		owner, err = s.groupAccess(group_id, email, model.PermissionEdit)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	id, err := s.repository.AddNote(owner, title, group_id)
 	if err != nil {
 		gID := strconv.Itoa(group_id)
 		m := map[string]string{
-			"email":    email,
+			"email":    owner,
 			"title":    title,
 			"group_id": gID,
 		}
-		logger.NewLog("service - AddNote()", 2, err, "Filed to add note in repository", m)
+		logger.NewLog("service - AddNoteWithText()", 2, err, "Filed to add note in repository", m)
+		return 0, err
+	}
+
+	if text != "" || encrypted {
+		data := map[string]string{
+			"id":    strconv.Itoa(id),
+			"email": owner,
+			"text":  text,
+		}
+		if encrypted {
+			data["encrypted"] = "true"
+		}
+		if err = s.repository.UpdateNote(data); err != nil {
+			logger.NewLog("service - AddNoteWithText()", 2, err, "Filed to update note in repository", id)
+			// an empty note is not what was asked for
+			if err := s.repository.DelNote(id, owner); err != nil {
+				logger.NewLog("service - AddNoteWithText()", 2, err, "Filed to del note in repository", id)
+			}
+			return 0, err
+		}
+	}
+
+	// the server can not read encrypted notes, links to them stay unresolved
+	if !encrypted {
+		s.indexText(id, owner, text)
+		if err = s.links.ResolveLinks(owner, id, title); err != nil {
+			logger.NewLog("service - AddNoteWithText()", 2, err, "Filed to resolve links in repository", id)
+		}
 	}
-	return err
+
+	s.publish(owner, email, model.Event{Type: model.EventNoteCreated, Note_id: id, Group_id: max(group_id, 0), Title: title})
+	return id, nil
 }
 
 func (s *NotesService) DelNote(id int, email string) error {
-	err := s.repository.DelNote(id, email)
+	owner, err := s.noteAccess(id, email, model.PermissionEdit)
+	if err != nil {
+		return err
+	}
+
+	err = s.repository.DelNote(id, owner)
 	if err != nil {
 		nID := strconv.Itoa(id)
 		m := map[string]string{
 			"note_id": nID,
 		}
 		logger.NewLog("service - DelNote()", 2, err, "Filed to del note in repository", m)
+		return err
 	}
-	return err
+
+	s.publish(owner, email, model.Event{Type: model.EventNoteDeleted, Note_id: id})
+	return nil
 }
 
 func (s *NotesService) UpdateNote(data map[string]string) error {
-	err := s.repository.UpdateNote(data)
+	id, err := strconv.Atoi(data["id"])
+	if err != nil {
+		logger.NewLog("service - UpdateNote()", 2, err, "Filed to convert string to int", data)
+		return repository.ErrFiledToConvert
+	}
+
+	email := data["email"]
+	owner, err := s.noteAccess(id, email, model.PermissionEdit)
+	if err != nil {
+		return err
+	}
+	if err = s.moveAccess(data, owner, email); err != nil {
+		return err
+	}
+	data["email"] = owner
+
+	// the old title is needed to rewrite [[links]] to the note,
+	// the encryption state to know if the note may be parsed
+	note, err := s.repository.GetNote(id, owner)
+	if err == sql.ErrNoRows {
+		return repository.ErrInvalidData
+	}
+	if err != nil {
+		logger.NewLog("service - UpdateNote()", 2, err, "Filed to get note in repository", id)
+		return err
+	}
+
+	encrypted := note.Encrypted
+	if encrypted_string, ok := data["encrypted"]; ok {
+		encrypted, err = strconv.ParseBool(encrypted_string)
+		if err != nil {
+			return repository.ErrInvalidData
+		}
+		// plaintext left in either field would be mixed with ciphertext
+		_, titleOK := data["title"]
+		_, textOK := data["text"]
+		if encrypted != note.Encrypted && !(titleOK && textOK) {
+			return repository.ErrInvalidData
+		}
+	}
+	if title, ok := data["title"]; ok && !validTitle(title, encrypted) {
+		return repository.ErrInvalidData
+	}
+
+	err = s.repository.UpdateNote(data)
 	if err != nil {
 		logger.NewLog("service - UpdateNote()", 2, err, "Filed to update note in repository", data)
+		return err
 	}
-	return err
+
+	if encrypted {
+		if !note.Encrypted {
+			// links and tasks parsed from the plain text would leak it
+			s.indexText(id, owner, "")
+		}
+	} else {
+		if text, ok := data["text"]; ok {
+			s.indexText(id, owner, text)
+		}
+		title, ok := data["title"]
+		if ok && note.Encrypted {
+			// the ciphertext title never resolved [[links]] to the note
+			if err := s.links.ResolveLinks(owner, id, title); err != nil {
+				logger.NewLog("service - UpdateNote()", 2, err, "Filed to resolve links in repository", id)
+			}
+		} else if ok && title != note.Title {
+			s.renameLinks(id, owner, email, note.Title, title)
+		}
+	}
+
+	e := model.Event{Type: model.EventNoteUpdated, Note_id: id, Title: data["title"]}
+	if group_id, err := strconv.Atoi(data["group_id"]); err == nil {
+		e.Group_id = group_id
+	}
+	s.publish(owner, email, e)
+	return nil
 }
 
 func (s *NotesService) GetNotesList(email string) (model.NoteList, error) {
@@ -101,7 +315,12 @@ func (s *NotesService) GetNotesList(email string) (model.NoteList, error) {
 }
 
 func (s *NotesService) GetNote(id int, email string) (model.Note, error) {
-	note, err := s.repository.GetNote(id, email)
+	owner, err := s.noteAccess(id, email, model.PermissionRead)
+	if err != nil {
+		return model.Note{}, err
+	}
+
+	note, err := s.repository.GetNote(id, owner)
 	if err != nil {
 		nID := strconv.Itoa(id)
 		m := map[string]string{
@@ -111,3 +330,169 @@ func (s *NotesService) GetNote(id int, email string) (model.Note, error) {
 	}
 	return note, err
 }
+
+// GetNoteHTML returns the note with its Markdown rendered to sanitized HTML,
+// wiki links lead to the linked notes
+func (s *NotesService) GetNoteHTML(id int, email string) (model.Note, error) {
+	note, err := s.GetNote(id, email)
+	if err != nil || note.Encrypted {
+		// only the client can render the ciphertext
+		return note, err
+	}
+
+	links, err := s.links.GetLinks(id)
+	if err != nil {
+		logger.NewLog("service - GetNoteHTML()", 2, err, "Filed to get links in repository", id)
+		return note, err
+	}
+
+	targets := map[string]int{}
+	for _, l := range links {
+		targets[linkKey(l.Title, l.Ref_id)] = l.Target_id
+	}
+
+	note.Html, err = render.HTML(note.Text, func(l wikilink.Link) string {
+		if target := targets[linkKey(l.Title, l.Id)]; target != 0 {
+			return "/getNote?id=" + strconv.Itoa(target) + "&format=html"
+		}
+		return ""
+	})
+	if err != nil {
+		logger.NewLog("service - GetNoteHTML()", 2, err, "Filed to render note", id)
+	}
+	return note, err
+}
+
+// validTitle checks the length of the title, encrypted titles are
+// ciphertext and longer
+func validTitle(title string, encrypted bool) bool {
+	if encrypted {
+		return len(title) <= model.MaxEncryptedTitle
+	}
+	return len(title) <= wikilink.MaxTitle
+}
+
+// indexText stores what is parsed from the text of the note:
+// its links and tasks
+func (s *NotesService) indexText(id int, owner string, text string) {
+	s.updateLinks(id, owner, text)
+	s.updateTasks(id, owner, text)
+}
+
+// EVENTS
+
+// publish sends the event to the owner of the changed data and,
+// if the change was made through a share, to the user who made it
+func (s *NotesService) publish(owner string, actor string, e model.Event) {
+	e.Actor = actor
+	s.events.Publish(owner, e)
+	if actor != owner {
+		s.events.Publish(actor, e)
+	}
+}
+
+// ACCESS
+
+// GetNotePermission returns the permission of the user on the note,
+// the owner always has edit permission
+func (s *NotesService) GetNotePermission(id int, email string) (string, error) {
+	owner, err := s.noteAccess(id, email, model.PermissionRead)
+	if err != nil {
+		return "", err
+	}
+	if owner == email {
+		return model.PermissionEdit, nil
+	}
+	if _, err = s.noteAccess(id, email, model.PermissionEdit); err == ErrAccessDenied {
+		return model.PermissionRead, nil
+	} else if err != nil {
+		return "", err
+	}
+	return model.PermissionEdit, nil
+}
+
+// noteAccess checks that the user may work with the note and returns
+// the email of the note owner, which is used for all repository calls
+func (s *NotesService) noteAccess(id int, email string, permission string) (string, error) {
+	owner, err := s.access.NoteOwner(id)
+	if err != nil {
+		logger.NewLog("service - noteAccess()", 5, err, "Filed to get note owner", id)
+		return "", err
+	}
+	if owner == email {
+		return owner, nil
+	}
+
+	p, err := s.access.NotePermission(id, email)
+	if err != nil {
+		logger.NewLog("service - noteAccess()", 2, err, "Filed to get note permission", id)
+		return "", err
+	}
+	if !allowed(p, permission) {
+		logger.NewLog("service - noteAccess()", 5, ErrAccessDenied, "Note not shared with user", email)
+		return "", ErrAccessDenied
+	}
+	return owner, nil
+}
+
+// groupAccess checks that the user may work with the group and returns
+// the email of the group owner
+func (s *NotesService) groupAccess(id int, email string, permission string) (string, error) {
+	owner, err := s.access.GroupOwner(id)
+	if err != nil {
+		logger.NewLog("service - groupAccess()", 5, err, "Filed to get group owner", id)
+		return "", err
+	}
+	if owner == email {
+		return owner, nil
+	}
+
+	p, err := s.access.GroupPermission(id, email)
+	if err != nil {
+		logger.NewLog("service - groupAccess()", 2, err, "Filed to get group permission", id)
+		return "", err
+	}
+	if !allowed(p, permission) {
+		logger.NewLog("service - groupAccess()", 5, ErrAccessDenied, "Group not shared with user", email)
+		return "", ErrAccessDenied
+	}
+	return owner, nil
+}
+
+// moveAccess checks the group the note is moved to: it must be the owner's
+// and the user must be able to edit it. Only the owner moves notes to the
+// root, shared users would lose the note there.
+func (s *NotesService) moveAccess(data map[string]string, owner string, email string) error {
+	group_id_string, ok := data["group_id"]
+	if !ok {
+		return nil
+	}
+	group_id, err := strconv.Atoi(group_id_string)
+	if err != nil {
+		logger.NewLog("service - moveAccess()", 2, err, "Filed to convert string to int", "string = "+group_id_string)
+		return repository.ErrFiledToConvert
+	}
+
+	if group_id <= 0 {
+		if email != owner {
+			return ErrAccessDenied
+		}
+		return nil
+	}
+
+	groupOwner, err := s.groupAccess(group_id, email, model.PermissionEdit)
+	if err != nil {
+		return err
+	}
+	if groupOwner != owner {
+		return repository.ErrInvalidData
+	}
+	return nil
+}
+
+func allowed(have string, want string) bool {
+	if have == model.PermissionEdit {
+		return true
+	}
+	return have == model.PermissionRead && want == model.PermissionRead
+}
@@ -0,0 +1,502 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"io"
+	"noteapp/internal/events"
+	"noteapp/internal/model"
+	"noteapp/internal/repository"
+	"noteapp/internal/wikilink"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeNotes keeps the groups and notes of the users in memory with their
+// links and tasks, only the owners have access
+type fakeNotes struct {
+	mu     sync.Mutex
+	lastID int
+	groups map[int]*model.Group
+	notes  map[int]*model.Note
+	links  map[int][]model.NoteLink
+	tasks  map[int][]model.Task
+	// notes passed to ResolveLinks
+	resolved map[int]bool
+}
+
+func newFakeNotes() *fakeNotes {
+	return &fakeNotes{
+		groups:   map[int]*model.Group{},
+		notes:    map[int]*model.Note{},
+		links:    map[int][]model.NoteLink{},
+		tasks:    map[int][]model.Task{},
+		resolved: map[int]bool{},
+	}
+}
+
+func newFakeNotesService() (*NotesService, *fakeNotes) {
+	repo := newFakeNotes()
+	return NewNotesService(repo, repo, events.NewBus(), repo, repo), repo
+}
+
+// NOTES
+
+func (r *fakeNotes) AddGroup(email string, nameGroup string, pid int) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lastID++
+	r.groups[r.lastID] = &model.Group{Id: r.lastID, User_email: email, Name: nameGroup, Pid: max(pid, 0), Version: 1}
+	return r.lastID, nil
+}
+
+func (r *fakeNotes) DelGroup(id int, email string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.groups, id)
+	return nil
+}
+
+func (r *fakeNotes) UpdateGroup(id int, email string, newNameGroup string, pid int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	g, ok := r.groups[id]
+	if !ok || g.User_email != email {
+		return repository.ErrInvalidData
+	}
+	if newNameGroup != "" {
+		g.Name = newNameGroup
+	}
+	if pid != -1 {
+		g.Pid = pid
+	}
+	g.Version++
+	return nil
+}
+
+func (r *fakeNotes) AddNote(email string, title string, group_id int) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lastID++
+	r.notes[r.lastID] = &model.Note{Id: r.lastID, User_email: email, Title: title, Group_id: max(group_id, 0), Version: 1}
+	return r.lastID, nil
+}
+
+func (r *fakeNotes) DelNote(id int, email string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.notes, id)
+	return nil
+}
+
+func (r *fakeNotes) UpdateNote(data map[string]string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	id, _ := strconv.Atoi(data["id"])
+	n, ok := r.notes[id]
+	if !ok || n.User_email != data["email"] {
+		return repository.ErrInvalidData
+	}
+	if v, ok := data["version"]; ok && v != strconv.Itoa(n.Version) {
+		return repository.ErrInvalidData
+	}
+	if title, ok := data["title"]; ok {
+		n.Title = title
+	}
+	if text, ok := data["text"]; ok {
+		n.Text = text
+	}
+	if encrypted, ok := data["encrypted"]; ok {
+		n.Encrypted, _ = strconv.ParseBool(encrypted)
+	}
+	if group_id, ok := data["group_id"]; ok {
+		n.Group_id, _ = strconv.Atoi(group_id)
+	}
+	n.Version++
+	return nil
+}
+
+// GetNotesList returns the tree of the user ordered by id
+func (r *fakeNotes) GetNotesList(email string) (model.NoteList, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	root := r.subtree(email, 0)
+	return model.NoteList{Groups: *root.Groups, Notes: root.Notes}, nil
+}
+
+func (r *fakeNotes) subtree(email string, groupID int) model.GroupElement {
+	ids := []int{}
+	for id := range r.notes {
+		ids = append(ids, id)
+	}
+	for id := range r.groups {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	g := model.GroupElement{Id: groupID, Groups: &[]model.GroupElement{}, Notes: []model.NoteElement{}}
+	for _, id := range ids {
+		if n, ok := r.notes[id]; ok && n.User_email == email && n.Group_id == groupID {
+			g.Notes = append(g.Notes, model.NoteElement{Id: n.Id, Title: n.Title, Encrypted: n.Encrypted})
+		}
+		if sub, ok := r.groups[id]; ok && sub.User_email == email && sub.Pid == groupID {
+			e := r.subtree(email, id)
+			e.Name = sub.Name
+			*g.Groups = append(*g.Groups, e)
+		}
+	}
+	return g
+}
+
+func (r *fakeNotes) GetNote(id int, email string) (model.Note, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n, ok := r.notes[id]
+	if !ok || n.User_email != email {
+		return model.Note{}, repository.ErrInvalidData
+	}
+	return *n, nil
+}
+
+// ACCESS
+
+func (r *fakeNotes) NoteOwner(noteID int) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n, ok := r.notes[noteID]
+	if !ok {
+		return "", repository.ErrInvalidData
+	}
+	return n.User_email, nil
+}
+
+func (r *fakeNotes) GroupOwner(groupID int) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	g, ok := r.groups[groupID]
+	if !ok {
+		return "", repository.ErrInvalidData
+	}
+	return g.User_email, nil
+}
+
+func (r *fakeNotes) NotePermission(noteID int, email string) (string, error) {
+	return "", nil
+}
+
+func (r *fakeNotes) GroupPermission(groupID int, email string) (string, error) {
+	return "", nil
+}
+
+// LINKS
+
+func (r *fakeNotes) SetLinks(sourceID int, owner string, links []model.NoteLink) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.links[sourceID] = links
+	return nil
+}
+
+func (r *fakeNotes) ResolveLinks(owner string, noteID int, title string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.resolved[noteID] = true
+	return nil
+}
+
+func (r *fakeNotes) GetLinks(sourceID int) ([]model.NoteLink, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.links[sourceID], nil
+}
+
+func (r *fakeNotes) GetBacklinks(targetID int) ([]model.Backlink, error) {
+	return nil, nil
+}
+
+func (r *fakeNotes) GetTitleLinkSources(targetID int) ([]int, error) {
+	return nil, nil
+}
+
+// TASKS
+
+func (r *fakeNotes) SetTasks(noteID int, owner string, list []model.Task) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tasks[noteID] = list
+	return nil
+}
+
+func (r *fakeNotes) GetTasks(email string, f model.TaskFilter) ([]model.Task, error) {
+	return nil, nil
+}
+
+func (r *fakeNotes) GetTask(id int, email string) (model.Task, error) {
+	return model.Task{}, repository.ErrInvalidData
+}
+
+// fakePublicLinks has one link to group 1 of "user"
+type fakePublicLinks struct {
+	PublicLinksRepository
+}
+
+func (fakePublicLinks) AddLink(l *model.PublicLink) error {
+	return nil
+}
+
+func (fakePublicLinks) GetLinkByToken(token string) (*model.PublicLink, error) {
+	return &model.PublicLink{Id: 1, Token: token, Owner_email: "user", Group_id: 1}, nil
+}
+
+func (fakePublicLinks) NoteInGroup(noteID int, groupID int) (bool, error) {
+	return true, nil
+}
+
+func (fakePublicLinks) IncViews(id int) error {
+	return nil
+}
+
+const (
+	plainText = "[[Other]]\n- [ ] task"
+	// ciphertext of the client, not parsed even though it looks like text
+	cipherText = "Y2lwaGVy"
+)
+
+func TestUpdateNoteEncryption(t *testing.T) {
+	testCases := []struct {
+		name      string
+		encrypted bool
+		data      map[string]string
+		want      error
+		// state after the update
+		wantEncrypted bool
+		wantLinks     int
+		wantTasks     int
+		wantResolved  bool
+	}{
+		{
+			name:          "plain to encrypted",
+			data:          map[string]string{"encrypted": "true", "title": cipherText, "text": cipherText},
+			wantEncrypted: true,
+		},
+		{
+			name:      "plain to encrypted without text",
+			data:      map[string]string{"encrypted": "true", "title": cipherText},
+			want:      repository.ErrInvalidData,
+			wantLinks: 1,
+			wantTasks: 1,
+		},
+		{
+			name:         "encrypted to plain",
+			encrypted:    true,
+			data:         map[string]string{"encrypted": "false", "title": "Title", "text": plainText},
+			wantLinks:    1,
+			wantTasks:    1,
+			wantResolved: true,
+		},
+		{
+			name:          "encrypted to plain without title",
+			encrypted:     true,
+			data:          map[string]string{"encrypted": "false", "text": plainText},
+			want:          repository.ErrInvalidData,
+			wantEncrypted: true,
+		},
+		{
+			name:          "ciphertext update without the flag",
+			encrypted:     true,
+			data:          map[string]string{"text": plainText},
+			wantEncrypted: true,
+		},
+		{
+			name:          "ciphertext update with the same flag",
+			encrypted:     true,
+			data:          map[string]string{"encrypted": "true", "text": plainText},
+			wantEncrypted: true,
+		},
+		{
+			name:          "long ciphertext title",
+			encrypted:     true,
+			data:          map[string]string{"title": strings.Repeat("a", wikilink.MaxTitle+1)},
+			wantEncrypted: true,
+		},
+		{
+			name:          "too long ciphertext title",
+			encrypted:     true,
+			data:          map[string]string{"title": strings.Repeat("a", model.MaxEncryptedTitle+1)},
+			want:          repository.ErrInvalidData,
+			wantEncrypted: true,
+		},
+		{
+			name:      "long plain title",
+			data:      map[string]string{"title": strings.Repeat("a", wikilink.MaxTitle+1)},
+			want:      repository.ErrInvalidData,
+			wantLinks: 1,
+			wantTasks: 1,
+		},
+		{
+			name:      "invalid flag",
+			data:      map[string]string{"encrypted": "yes", "title": cipherText, "text": cipherText},
+			want:      repository.ErrInvalidData,
+			wantLinks: 1,
+			wantTasks: 1,
+		},
+		{
+			name: "plain update",
+			data: map[string]string{"text": "no links"},
+		},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			s, repo := newFakeNotesService()
+
+			var id int
+			var err error
+			if tcase.encrypted {
+				id, err = s.AddEncryptedNote("user", cipherText, cipherText, -1)
+			} else {
+				id, err = s.AddNoteWithText("user", "Title", plainText, -1)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			delete(repo.resolved, id)
+
+			data := map[string]string{"id": strconv.Itoa(id), "email": "user"}
+			for k, v := range tcase.data {
+				data[k] = v
+			}
+
+			assert.Equal(t, tcase.want, s.UpdateNote(data))
+			assert.Equal(t, tcase.wantEncrypted, repo.notes[id].Encrypted)
+			assert.Len(t, repo.links[id], tcase.wantLinks)
+			assert.Len(t, repo.tasks[id], tcase.wantTasks)
+			assert.Equal(t, tcase.wantResolved, repo.resolved[id])
+		})
+	}
+}
+
+func TestEncryptedNoteExcluded(t *testing.T) {
+	notes, repo := newFakeNotesService()
+	public := NewPublicLinksService(fakePublicLinks{}, repo, repo)
+
+	groupID, err := notes.AddGroup("user", "group", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plainID, err := notes.AddNoteWithText("user", "Plain", plainText, groupID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encryptedID, err := notes.AddEncryptedNote("user", cipherText, plainText, groupID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// shown reports if the note is seen by the feature, the plain note
+	// must be, the encrypted one must not
+	testCases := []struct {
+		name  string
+		shown func(t *testing.T, id int) bool
+	}{
+		{
+			name: "render",
+			shown: func(t *testing.T, id int) bool {
+				note, err := notes.GetNoteHTML(id, "user")
+				assert.NoError(t, err)
+				return note.Html != ""
+			},
+		},
+		{
+			name: "links",
+			shown: func(t *testing.T, id int) bool {
+				return len(repo.links[id]) != 0 || repo.resolved[id]
+			},
+		},
+		{
+			name: "tasks",
+			shown: func(t *testing.T, id int) bool {
+				return len(repo.tasks[id]) != 0
+			},
+		},
+		{
+			name: "public link to the note",
+			shown: func(t *testing.T, id int) bool {
+				err := public.AddLink(&model.PublicLink{Owner_email: "user", Note_id: id})
+				return err == nil
+			},
+		},
+		{
+			name: "public note of the group",
+			shown: func(t *testing.T, id int) bool {
+				content, err := public.GetPublicContent("token", "", "", id)
+				return err == nil && content.Note != nil
+			},
+		},
+		{
+			name: "public group",
+			shown: func(t *testing.T, id int) bool {
+				content, err := public.GetPublicContent("token", "", "", 0)
+				assert.NoError(t, err)
+				for _, n := range content.Group.Notes {
+					if n.Id == id {
+						return true
+					}
+				}
+				return false
+			},
+		},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			assert.True(t, tcase.shown(t, plainID), "plain note")
+			assert.False(t, tcase.shown(t, encryptedID), "encrypted note")
+		})
+	}
+}
+
+// encrypted notes are exported as their ciphertext, not dropped
+func TestExportEncrypted(t *testing.T) {
+	notes, repo := newFakeNotesService()
+	export := NewExportService(repo, &fakeAttachments{}, &fakeStore{blobs: map[string][]byte{}})
+
+	groupID, err := notes.AddGroup("user", "group", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := notes.AddEncryptedNote("user", cipherText, cipherText+"text", groupID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := new(bytes.Buffer)
+	assert.NoError(t, export.ExportMarkdown(context.Background(), "user", out))
+	archive, err := zip.NewReader(bytes.NewReader(out.Bytes()), int64(out.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{}
+	for _, f := range archive.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(r)
+		files[f.Name] = string(b)
+	}
+	md := files["group/Encrypted "+strconv.Itoa(id)+".md"]
+	assert.Contains(t, md, "title: \""+cipherText+"\"\n")
+	assert.Contains(t, md, "encrypted: true\n")
+	assert.True(t, strings.HasSuffix(md, "---\n\n"+cipherText+"text"))
+
+	out.Reset()
+	assert.NoError(t, export.ExportOPML("user", out))
+	assert.Contains(t, out.String(), `<outline text="`+cipherText+`" _note="`+cipherText+`text" _encrypted="true"></outline>`)
+}
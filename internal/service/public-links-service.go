@@ -0,0 +1,351 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"noteapp/internal/feed"
+	"noteapp/internal/model"
+	"noteapp/internal/render"
+	"noteapp/internal/repository"
+	"noteapp/internal/wikilink"
+	"noteapp/pkg/logger"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrLinkExpired = errors.New("link has expired")
+)
+
+// LinkUnlockTTL - how long a visitor who gave the password of a link can
+// open its notes without giving it again
+const LinkUnlockTTL = time.Hour
+
+type PublicLinksRepository interface {
+	AddLink(l *model.PublicLink) error
+	DelLink(id int, ownerEmail string) error
+	GetLinks(ownerEmail string) ([]model.PublicLink, error)
+	GetLinkByToken(token string) (*model.PublicLink, error)
+	IncViews(id int) error
+	NoteInGroup(noteID int, groupID int) (bool, error)
+	GetFeedNotes(groupID int, limit int) ([]model.Note, error)
+}
+
+type PublicLinksService struct {
+	repository      PublicLinksRepository
+	access          AccessRepository
+	notesRepository NotesRepository
+}
+
+func NewPublicLinksService(repo PublicLinksRepository, access AccessRepository, notesRepo NotesRepository) *PublicLinksService {
+	return &PublicLinksService{
+		repository:      repo,
+		access:          access,
+		notesRepository: notesRepo,
+	}
+}
+
+func (s *PublicLinksService) AddLink(l *model.PublicLink) error {
+	var owner string
+	var err error
+	if l.Note_id != 0 {
+		owner, err = s.access.NoteOwner(l.Note_id)
+	} else {
+		owner, err = s.access.GroupOwner(l.Group_id)
+	}
+	if err != nil {
+		logger.NewLog("service - AddLink()", 5, err, "Filed to get owner", l)
+		return err
+	}
+	if owner != l.Owner_email {
+		return ErrAccessDenied
+	}
+	if l.Feed && l.Group_id == 0 {
+		return repository.ErrInvalidData
+	}
+	// visitors have no key, encrypted notes of a shared group are skipped
+	if l.Note_id != 0 {
+		note, err := s.notesRepository.GetNote(l.Note_id, owner)
+		if err != nil {
+			logger.NewLog("service - AddLink()", 2, err, "Filed to get note in repository", l.Note_id)
+			return err
+		}
+		if note.Encrypted {
+			return model.ErrEncryptedNote
+		}
+	}
+
+	if l.Token, err = newLinkToken(); err != nil {
+		logger.NewLog("service - AddLink()", 2, err, "Filed to generate token", nil)
+		return err
+	}
+
+	if err = l.EncryptPassword(); err != nil {
+		logger.NewLog("service - AddLink()", 2, err, "Filed to encrypt password", nil)
+		return err
+	}
+
+	err = s.repository.AddLink(l)
+	if err != nil {
+		logger.NewLog("service - AddLink()", 2, err, "Filed to add link in repository", l)
+	}
+	return err
+}
+
+func (s *PublicLinksService) DelLink(id int, ownerEmail string) error {
+	err := s.repository.DelLink(id, ownerEmail)
+	if err != nil {
+		logger.NewLog("service - DelLink()", 2, err, "Filed to del link in repository", id)
+	}
+	return err
+}
+
+func (s *PublicLinksService) GetLinks(ownerEmail string) ([]model.PublicLink, error) {
+	links, err := s.repository.GetLinks(ownerEmail)
+	if err != nil {
+		logger.NewLog("service - GetLinks()", 2, err, "Filed to get links in repository", ownerEmail)
+	}
+	return links, err
+}
+
+// GetPublicContent returns the content available by the link. For a group link
+// noteID selects a note inside the group subtree, 0 returns the subtree itself.
+// unlock made by CreateLinkUnlock for the same link replaces the password.
+func (s *PublicLinksService) GetPublicContent(token string, password string, unlock string, noteID int) (model.PublicContent, error) {
+	content := model.PublicContent{}
+
+	l, err := s.repository.GetLinkByToken(token)
+	if err != nil {
+		logger.NewLog("service - GetPublicContent()", 5, err, "Filed to get link in repository", nil)
+		return content, err
+	}
+	if l.Expired() {
+		return content, ErrLinkExpired
+	}
+	if unlock == "" || !VerifyLinkUnlock(unlock, token) {
+		if err = l.ComparePassword(password); err != nil {
+			return content, err
+		}
+	}
+
+	if l.Note_id != 0 || noteID != 0 {
+		if l.Note_id != 0 {
+			noteID = l.Note_id
+		} else {
+			ok, err := s.repository.NoteInGroup(noteID, l.Group_id)
+			if err != nil {
+				logger.NewLog("service - GetPublicContent()", 2, err, "Filed to check note in group", noteID)
+				return content, err
+			}
+			if !ok {
+				return content, repository.ErrInvalidData
+			}
+		}
+
+		note, err := s.notesRepository.GetNote(noteID, l.Owner_email)
+		if err != nil {
+			logger.NewLog("service - GetPublicContent()", 2, err, "Filed to get note in repository", noteID)
+			return content, err
+		}
+		// the note was encrypted after the link was made
+		if note.Encrypted {
+			return content, repository.ErrInvalidData
+		}
+		note.User_email = ""
+
+		// wiki links lead only to the notes shared by the same link
+		var resolve render.LinkResolver
+		if l.Group_id != 0 {
+			resolve, err = s.publicResolver(token, l)
+			if err != nil {
+				return content, err
+			}
+		}
+		if note.Html, err = render.HTML(note.Text, resolve); err != nil {
+			logger.NewLog("service - GetPublicContent()", 2, err, "Filed to render note", noteID)
+			return content, err
+		}
+		content.Note = &note
+	} else {
+		list, err := s.notesRepository.GetNotesList(l.Owner_email)
+		if err != nil {
+			logger.NewLog("service - GetPublicContent()", 2, err, "Filed to get notes list in repository", nil)
+			return content, err
+		}
+		content.Group = findGroup(list.Groups, l.Group_id)
+		if content.Group == nil {
+			return content, repository.ErrInvalidData
+		}
+		dropEncrypted(content.Group)
+	}
+
+	if err = s.repository.IncViews(l.Id); err != nil {
+		logger.NewLog("service - GetPublicContent()", 3, err, "Filed to increment views", l.Id)
+	}
+
+	return content, nil
+}
+
+// publicResolver resolves wiki links to the notes of the shared group subtree
+func (s *PublicLinksService) publicResolver(token string, l *model.PublicLink) (render.LinkResolver, error) {
+	list, err := s.notesRepository.GetNotesList(l.Owner_email)
+	if err != nil {
+		logger.NewLog("service - publicResolver()", 2, err, "Filed to get notes list in repository", nil)
+		return nil, err
+	}
+	group := findGroup(list.Groups, l.Group_id)
+	if group != nil {
+		dropEncrypted(group)
+	}
+	return groupResolver(token, group), nil
+}
+
+// dropEncrypted removes the encrypted notes from the group subtree,
+// they are never shown publicly
+func dropEncrypted(g *model.GroupElement) {
+	notes := g.Notes[:0]
+	for _, n := range g.Notes {
+		if !n.Encrypted {
+			notes = append(notes, n)
+		}
+	}
+	g.Notes = notes
+	if g.Groups != nil {
+		for i := range *g.Groups {
+			dropEncrypted(&(*g.Groups)[i])
+		}
+	}
+}
+
+// groupResolver resolves wiki links to the notes of the group subtree,
+// urls are relative to /public
+func groupResolver(token string, group *model.GroupElement) render.LinkResolver {
+	targets := map[string]int{}
+	var collect func(g *model.GroupElement)
+	collect = func(g *model.GroupElement) {
+		for _, n := range g.Notes {
+			if key := linkKey(n.Title, n.Id); targets[key] == 0 {
+				targets[key] = n.Id
+			}
+			targets[linkKey("", n.Id)] = n.Id
+		}
+		if g.Groups != nil {
+			for i := range *g.Groups {
+				collect(&(*g.Groups)[i])
+			}
+		}
+	}
+	if group != nil {
+		collect(group)
+	}
+
+	return func(wl wikilink.Link) string {
+		if id := targets[linkKey(wl.Title, wl.Id)]; id != 0 {
+			return publicNoteURL(token, id)
+		}
+		return ""
+	}
+}
+
+func publicNoteURL(token string, noteID int) string {
+	return "?token=" + url.QueryEscape(token) + "&note_id=" + strconv.Itoa(noteID) + "&format=html"
+}
+
+// FEEDS
+
+// feedEntries - length of a feed
+const feedEntries = 50
+
+// GetFeed returns the last updated notes of the group shared by the link,
+// the link must have the feed enabled. base is the absolute url of /public,
+// feed readers need absolute links.
+func (s *PublicLinksService) GetFeed(token string, password string, base string) (feed.Feed, error) {
+	f := feed.Feed{}
+
+	l, err := s.repository.GetLinkByToken(token)
+	if err != nil {
+		logger.NewLog("service - GetFeed()", 5, err, "Filed to get link in repository", nil)
+		return f, err
+	}
+	// links without a feed don't reveal that they exist
+	if l.Group_id == 0 || !l.Feed {
+		return f, repository.ErrLinkNotFound
+	}
+	if l.Expired() {
+		return f, ErrLinkExpired
+	}
+	if err = l.ComparePassword(password); err != nil {
+		return f, err
+	}
+
+	list, err := s.notesRepository.GetNotesList(l.Owner_email)
+	if err != nil {
+		logger.NewLog("service - GetFeed()", 2, err, "Filed to get notes list in repository", nil)
+		return f, err
+	}
+	group := findGroup(list.Groups, l.Group_id)
+	if group == nil {
+		return f, repository.ErrInvalidData
+	}
+	dropEncrypted(group)
+
+	notes, err := s.repository.GetFeedNotes(l.Group_id, feedEntries)
+	if err != nil {
+		logger.NewLog("service - GetFeed()", 2, err, "Filed to get feed notes in repository", l.Group_id)
+		return f, err
+	}
+
+	f.Title = group.Name
+	f.Link = base + "?token=" + url.QueryEscape(token) + "&format=html"
+	f.Updated = l.Created_at
+	resolve := groupResolver(token, group)
+	absolute := func(wl wikilink.Link) string {
+		if u := resolve(wl); u != "" {
+			return base + u
+		}
+		return ""
+	}
+
+	for _, n := range notes {
+		html, err := render.HTML(n.Text, absolute)
+		if err != nil {
+			logger.NewLog("service - GetFeed()", 2, err, "Filed to render note", n.Id)
+			return f, err
+		}
+		link := base + publicNoteURL(token, n.Id)
+		f.Entries = append(f.Entries, feed.Entry{
+			Id:        link,
+			Title:     n.Title,
+			Link:      link,
+			Html:      html,
+			Published: n.Created_at,
+			Updated:   n.Updated_at,
+		})
+		if n.Updated_at.After(f.Updated) {
+			f.Updated = n.Updated_at
+		}
+	}
+	return f, nil
+}
+
+// CreateLinkUnlock returns the proof that the visitor gave the password of
+// the link, it is valid for LinkUnlockTTL
+func CreateLinkUnlock(token string) (string, error) {
+	return CreateJWTToken(token, time.Now().Add(LinkUnlockTTL), secretKeyLink)
+}
+
+// VerifyLinkUnlock checks that unlock was made for the link of the token
+func VerifyLinkUnlock(unlock string, token string) bool {
+	subject, err := VerifyToken(unlock, secretKeyLink)
+	return err == nil && subject == token
+}
+
+func newLinkToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
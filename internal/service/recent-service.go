@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"noteapp/internal/model"
+	"noteapp/pkg/logger"
+	"sync"
+	"time"
+)
+
+const (
+	// recentMax - longest list of recent notes
+	recentMax = 50
+	// recentPendingMax - activity kept in memory before it is written
+	// without waiting for the next flush
+	recentPendingMax = 10000
+)
+
+type RecentRepository interface {
+	AddActivity(list []model.NoteActivity) error
+	GetRecent(email string, edited bool, limit int) ([]model.RecentNote, error)
+}
+
+type activityKey struct {
+	email  string
+	noteID int
+}
+
+// RecentService records views and edits of notes. They are collected in
+// memory and written by RunFlush, so requests do not wait for the database
+// and repeated views of a note become one write.
+type RecentService struct {
+	repository RecentRepository
+
+	mu      sync.Mutex
+	pending map[activityKey]*model.NoteActivity
+	full    chan struct{}
+}
+
+func NewRecentService(repo RecentRepository) *RecentService {
+	return &RecentService{
+		repository: repo,
+		pending:    map[activityKey]*model.NoteActivity{},
+		full:       make(chan struct{}, 1),
+	}
+}
+
+func (s *RecentService) NoteViewed(id int, email string) {
+	s.record(id, email, func(a *model.NoteActivity, now time.Time) { a.Viewed_at = now })
+}
+
+func (s *RecentService) NoteEdited(id int, email string) {
+	s.record(id, email, func(a *model.NoteActivity, now time.Time) { a.Edited_at = now })
+}
+
+func (s *RecentService) record(id int, email string, set func(a *model.NoteActivity, now time.Time)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := activityKey{email: email, noteID: id}
+	a := s.pending[key]
+	if a == nil {
+		a = &model.NoteActivity{User_email: email, Note_id: id}
+		s.pending[key] = a
+	}
+	set(a, time.Now())
+
+	if len(s.pending) >= recentPendingMax {
+		select {
+		case s.full <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// RunFlush writes the recorded activity every interval and once more
+// when ctx is done
+func (s *RecentService) RunFlush(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			s.flush("")
+			return
+		case <-ticker.C:
+		case <-s.full:
+		}
+		s.flush("")
+	}
+}
+
+// flush writes the activity of the user, "" - of all users
+func (s *RecentService) flush(email string) {
+	s.mu.Lock()
+	list := []model.NoteActivity{}
+	for key, a := range s.pending {
+		if email == "" || key.email == email {
+			list = append(list, *a)
+			delete(s.pending, key)
+		}
+	}
+	s.mu.Unlock()
+
+	if len(list) == 0 {
+		return
+	}
+	if err := s.repository.AddActivity(list); err != nil {
+		logger.NewLog("service - flush()", 2, err, "Filed to add activity in repository", len(list))
+	}
+}
+
+// GetRecent returns up to limit notes the user viewed or, if edited is
+// true, edited last. Notes which are no longer shared with the user
+// are left out by the repository, so they do not take up the limit.
+func (s *RecentService) GetRecent(email string, edited bool, limit int) ([]model.RecentNote, error) {
+	if limit <= 0 || limit > recentMax {
+		limit = recentMax
+	}
+	s.flush(email)
+
+	list, err := s.repository.GetRecent(email, edited, limit)
+	if err != nil {
+		logger.NewLog("service - GetRecent()", 2, err, "Filed to get recent notes in repository", email)
+		return nil, err
+	}
+	return list, nil
+}
@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"noteapp/internal/model"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeRecent keeps every batch of activity written
+type fakeRecent struct {
+	writes [][]model.NoteActivity
+}
+
+func (r *fakeRecent) AddActivity(list []model.NoteActivity) error {
+	r.writes = append(r.writes, list)
+	return nil
+}
+
+func (r *fakeRecent) GetRecent(email string, edited bool, limit int) ([]model.RecentNote, error) {
+	return []model.RecentNote{}, nil
+}
+
+func TestRecentActivity(t *testing.T) {
+	type step struct {
+		do    string
+		id    int
+		email string
+	}
+	view := func(id int, email string) step { return step{do: "view", id: id, email: email} }
+	edit := func(id int, email string) step { return step{do: "edit", id: id, email: email} }
+	flush := step{do: "flush"}
+	get := func(email string) step { return step{do: "get", email: email} }
+
+	type write struct {
+		email  string
+		noteID int
+		viewed bool
+		edited bool
+	}
+
+	testCases := []struct {
+		name  string
+		steps []step
+		// entries of every write
+		want [][]write
+		// activity left in memory
+		wantPending int
+	}{
+		{
+			name:  "repeated views are one entry",
+			steps: []step{view(1, "user"), view(1, "user"), view(1, "user"), flush},
+			want:  [][]write{{{email: "user", noteID: 1, viewed: true}}},
+		},
+		{
+			name:  "view and edit are one entry",
+			steps: []step{view(1, "user"), edit(1, "user"), view(1, "user"), flush},
+			want:  [][]write{{{email: "user", noteID: 1, viewed: true, edited: true}}},
+		},
+		{
+			name:  "every note has its entry",
+			steps: []step{view(1, "user"), view(2, "user"), view(1, "user"), flush},
+			want:  [][]write{{{email: "user", noteID: 1, viewed: true}, {email: "user", noteID: 2, viewed: true}}},
+		},
+		{
+			name:  "every user has its entry",
+			steps: []step{view(1, "user"), view(1, "other"), flush},
+			want:  [][]write{{{email: "other", noteID: 1, viewed: true}, {email: "user", noteID: 1, viewed: true}}},
+		},
+		{
+			name:  "views after the flush",
+			steps: []step{view(1, "user"), flush, view(1, "user"), view(1, "user"), flush},
+			want: [][]write{
+				{{email: "user", noteID: 1, viewed: true}},
+				{{email: "user", noteID: 1, viewed: true}},
+			},
+		},
+		{
+			name:  "nothing to flush",
+			steps: []step{flush},
+			want:  [][]write{},
+		},
+		{
+			name:        "list flushes the user only",
+			steps:       []step{view(1, "user"), edit(2, "other"), get("user")},
+			want:        [][]write{{{email: "user", noteID: 1, viewed: true}}},
+			wantPending: 1,
+		},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			repo := &fakeRecent{}
+			s := NewRecentService(repo)
+
+			for _, st := range tcase.steps {
+				switch st.do {
+				case "view":
+					s.NoteViewed(st.id, st.email)
+				case "edit":
+					s.NoteEdited(st.id, st.email)
+				case "flush":
+					s.flush("")
+				case "get":
+					if _, err := s.GetRecent(st.email, false, 0); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+
+			got := [][]write{}
+			for _, list := range repo.writes {
+				entries := []write{}
+				for _, a := range list {
+					entries = append(entries, write{
+						email:  a.User_email,
+						noteID: a.Note_id,
+						viewed: !a.Viewed_at.IsZero(),
+						edited: !a.Edited_at.IsZero(),
+					})
+				}
+				// pending activity is a map, its order is random
+				sort.Slice(entries, func(i, j int) bool {
+					if entries[i].email != entries[j].email {
+						return entries[i].email < entries[j].email
+					}
+					return entries[i].noteID < entries[j].noteID
+				})
+				got = append(got, entries)
+			}
+
+			assert.Equal(t, tcase.want, got)
+			assert.Len(t, s.pending, tcase.wantPending)
+		})
+	}
+}
+
+func TestRecentRunFlush(t *testing.T) {
+	repo := &fakeRecent{}
+	s := NewRecentService(repo)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.RunFlush(ctx, time.Hour)
+		close(done)
+	}()
+
+	s.NoteViewed(1, "user")
+	s.NoteViewed(1, "user")
+	cancel()
+	<-done
+
+	// the activity is written once when the service stops
+	assert.Len(t, repo.writes, 1)
+	assert.Len(t, repo.writes[0], 1)
+	assert.Empty(t, s.pending)
+}
@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"noteapp/internal/model"
+	"noteapp/internal/notify"
+	"noteapp/internal/repository"
+	"noteapp/pkg/logger"
+	"time"
+)
+
+const (
+	// remindersBatch - reminders fired by one statement
+	remindersBatch = 100
+	// notificationsLimit - length of the in-app list
+	notificationsLimit = 100
+	notifyTimeout      = 30 * time.Second
+)
+
+type RemindersRepository interface {
+	AddReminder(rem *model.Reminder) error
+	DelReminder(id int, email string) error
+	GetReminders(email string, noteID int) ([]model.Reminder, error)
+	FireDue(limit int) ([]model.Notification, error)
+	GetNotifications(email string, unread bool, limit int) ([]model.Notification, error)
+	ReadNotification(id int, email string) error
+}
+
+type RemindersService struct {
+	repository RemindersRepository
+	notes      *NotesService
+	events     EventPublisher
+	notifiers  []notify.Notifier
+}
+
+func NewRemindersService(repo RemindersRepository, notes *NotesService, events EventPublisher, notifiers []notify.Notifier) *RemindersService {
+	return &RemindersService{
+		repository: repo,
+		notes:      notes,
+		events:     events,
+		notifiers:  notifiers,
+	}
+}
+
+// AddReminder sets a reminder of the user on a note the user can read
+func (s *RemindersService) AddReminder(rem *model.Reminder) error {
+	if err := rem.Validate(); err != nil {
+		return err
+	}
+	if _, err := s.notes.noteAccess(rem.Note_id, rem.User_email, model.PermissionRead); err != nil {
+		return err
+	}
+
+	err := s.repository.AddReminder(rem)
+	if err != nil && err != repository.ErrInvalidData {
+		logger.NewLog("service - AddReminder()", 2, err, "Filed to add reminder in repository", rem.Note_id)
+	}
+	return err
+}
+
+func (s *RemindersService) DelReminder(id int, email string) error {
+	err := s.repository.DelReminder(id, email)
+	if err != nil && err != repository.ErrInvalidData {
+		logger.NewLog("service - DelReminder()", 2, err, "Filed to del reminder in repository", id)
+	}
+	return err
+}
+
+// GetReminders returns the reminders of the user on the note, noteID 0 -
+// the reminders which have not fired yet
+func (s *RemindersService) GetReminders(email string, noteID int) ([]model.Reminder, error) {
+	list, err := s.repository.GetReminders(email, noteID)
+	if err != nil {
+		logger.NewLog("service - GetReminders()", 2, err, "Filed to get reminders in repository", email)
+	}
+	return list, err
+}
+
+func (s *RemindersService) GetNotifications(email string, unread bool) ([]model.Notification, error) {
+	list, err := s.repository.GetNotifications(email, unread, notificationsLimit)
+	if err != nil {
+		logger.NewLog("service - GetNotifications()", 2, err, "Filed to get notifications in repository", email)
+	}
+	return list, err
+}
+
+// ReadNotification marks the notification as read, id 0 - all of them
+func (s *RemindersService) ReadNotification(id int, email string) error {
+	err := s.repository.ReadNotification(id, email)
+	if err != nil && err != repository.ErrInvalidData {
+		logger.NewLog("service - ReadNotification()", 2, err, "Filed to read notification in repository", id)
+	}
+	return err
+}
+
+// RunScheduler fires due reminders every interval until ctx is done.
+// The fired state is kept in the database, so reminders due while the
+// server was down fire on start and none fires twice, also with several
+// instances running.
+func (s *RemindersService) RunScheduler(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for ctx.Err() == nil {
+			list, err := s.repository.FireDue(remindersBatch)
+			if err != nil {
+				logger.NewLog("service - RunScheduler()", 2, err, "Filed to fire reminders in repository", nil)
+				break
+			}
+			for _, n := range list {
+				s.deliver(ctx, n)
+			}
+			if len(list) < remindersBatch {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// deliver sends the fired reminder to the open clients and the notifiers.
+// It is already in the in-app list, a failed notifier is not retried:
+// the reminder is marked as fired before it is sent.
+func (s *RemindersService) deliver(ctx context.Context, n model.Notification) {
+	s.events.Publish(n.User_email, model.Event{Type: model.EventReminder, Note_id: n.Note_id, Title: n.Title})
+
+	for _, notifier := range s.notifiers {
+		nctx, cancel := context.WithTimeout(ctx, notifyTimeout)
+		if err := notifier.Notify(nctx, n); err != nil {
+			logger.NewLog("service - deliver()", 2, err, "Filed to notify", n.Id)
+		}
+		cancel()
+	}
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"errors"
+	"noteapp/internal/model"
+	"noteapp/internal/repository"
+	"noteapp/pkg/logger"
+)
+
+var (
+	ErrShareYourself = errors.New("you can not share with yourself")
+)
+
+type SharesRepository interface {
+	AddShare(s *model.Share) error
+	DelShare(id int, ownerEmail string) error
+	GetSharesByOwner(ownerEmail string) ([]model.Share, error)
+	GetSharesForUser(email string) ([]model.Share, error)
+	NoteOwner(noteID int) (string, error)
+	GroupOwner(groupID int) (string, error)
+}
+
+type SharesService struct {
+	repository      SharesRepository
+	userRepository  UserRepository
+	notesRepository NotesRepository
+}
+
+func NewSharesService(repo SharesRepository, userRepo UserRepository, notesRepo NotesRepository) *SharesService {
+	return &SharesService{
+		repository:      repo,
+		userRepository:  userRepo,
+		notesRepository: notesRepo,
+	}
+}
+
+func (s *SharesService) AddShare(share *model.Share) error {
+	if err := share.ValidatePermission(); err != nil {
+		return err
+	}
+
+	if share.Owner_email == share.User_email {
+		return ErrShareYourself
+	}
+
+	if _, err := s.userRepository.FindByLogin(share.User_email); err != nil {
+		logger.NewLog("service - AddShare()", 5, err, "Filed to find user", share.User_email)
+		return err
+	}
+
+	// only the owner can share a note or a group
+	var owner string
+	var err error
+	if share.Note_id != 0 {
+		owner, err = s.repository.NoteOwner(share.Note_id)
+	} else {
+		owner, err = s.repository.GroupOwner(share.Group_id)
+	}
+	if err != nil {
+		logger.NewLog("service - AddShare()", 5, err, "Filed to get owner", share)
+		return err
+	}
+	if owner != share.Owner_email {
+		return ErrAccessDenied
+	}
+
+	err = s.repository.AddShare(share)
+	if err != nil {
+		logger.NewLog("service - AddShare()", 2, err, "Filed to add share in repository", share)
+	}
+	return err
+}
+
+func (s *SharesService) DelShare(id int, ownerEmail string) error {
+	err := s.repository.DelShare(id, ownerEmail)
+	if err != nil {
+		logger.NewLog("service - DelShare()", 2, err, "Filed to del share in repository", id)
+	}
+	return err
+}
+
+func (s *SharesService) GetShares(ownerEmail string) ([]model.Share, error) {
+	shares, err := s.repository.GetSharesByOwner(ownerEmail)
+	if err != nil {
+		logger.NewLog("service - GetShares()", 2, err, "Filed to get shares in repository", ownerEmail)
+	}
+	return shares, err
+}
+
+func (s *SharesService) GetSharedList(email string) (model.SharedList, error) {
+	list := model.SharedList{Elements: []model.SharedElement{}}
+
+	shares, err := s.repository.GetSharesForUser(email)
+	if err != nil {
+		logger.NewLog("service - GetSharedList()", 2, err, "Filed to get shares in repository", email)
+		return list, err
+	}
+
+	// owners trees are loaded once per owner
+	trees := map[string]model.NoteList{}
+
+	for _, share := range shares {
+		el := model.SharedElement{
+			Share_id:    share.Id,
+			Owner_email: share.Owner_email,
+			Permission:  share.Permission,
+		}
+
+		if share.Note_id != 0 {
+			note, err := s.notesRepository.GetNote(share.Note_id, share.Owner_email)
+			if err != nil {
+				logger.NewLog("service - GetSharedList()", 2, err, "Filed to get note in repository", share)
+				return list, err
+			}
+			el.Note = &model.NoteElement{
+				Id:        note.Id,
+				Title:     note.Title,
+				Comments:  note.Comments,
+				Encrypted: note.Encrypted,
+			}
+		} else {
+			tree, ok := trees[share.Owner_email]
+			if !ok {
+				tree, err = s.notesRepository.GetNotesList(share.Owner_email)
+				if err != nil {
+					logger.NewLog("service - GetSharedList()", 2, err, "Filed to get notes list in repository", share)
+					return list, err
+				}
+				trees[share.Owner_email] = tree
+			}
+			el.Group = findGroup(tree.Groups, share.Group_id)
+			if el.Group == nil {
+				logger.NewLog("service - GetSharedList()", 3, repository.ErrInvalidData, "Shared group not found in tree", share)
+				continue
+			}
+		}
+
+		list.Elements = append(list.Elements, el)
+	}
+
+	return list, nil
+}
+
+func findGroup(groups []model.GroupElement, id int) *model.GroupElement {
+	for i := range groups {
+		if groups[i].Id == id {
+			return &groups[i]
+		}
+		if groups[i].Groups != nil {
+			if g := findGroup(*groups[i].Groups, id); g != nil {
+				return g
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"noteapp/internal/model"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeShares returns its shares for every user
+type fakeShares struct {
+	SharesRepository
+	shares []model.Share
+}
+
+func (r *fakeShares) GetSharesForUser(email string) ([]model.Share, error) {
+	return r.shares, nil
+}
+
+func TestGetSharedList(t *testing.T) {
+	type element struct {
+		note  string
+		group string
+		// notes and subgroups of the shared group
+		notes  []string
+		groups []string
+	}
+
+	testCases := []struct {
+		name   string
+		shares []model.Share
+		want   []element
+	}{
+		{
+			name:   "nothing shared",
+			shares: []model.Share{},
+			want:   []element{},
+		},
+		{
+			name:   "note",
+			shares: []model.Share{{Id: 1, Owner_email: "owner", Note_id: 4, Permission: model.PermissionRead}},
+			want:   []element{{note: "root note"}},
+		},
+		{
+			name:   "root group",
+			shares: []model.Share{{Id: 1, Owner_email: "owner", Group_id: 1, Permission: model.PermissionRead}},
+			want:   []element{{group: "work", notes: []string{}, groups: []string{"project"}}},
+		},
+		{
+			name:   "subgroup",
+			shares: []model.Share{{Id: 1, Owner_email: "owner", Group_id: 2, Permission: model.PermissionEdit}},
+			want:   []element{{group: "project", notes: []string{"plan"}, groups: []string{}}},
+		},
+		{
+			name: "groups and a note",
+			shares: []model.Share{
+				{Id: 1, Owner_email: "owner", Group_id: 2, Permission: model.PermissionRead},
+				{Id: 2, Owner_email: "owner", Note_id: 4, Permission: model.PermissionRead},
+				{Id: 3, Owner_email: "owner", Group_id: 1, Permission: model.PermissionRead},
+			},
+			want: []element{
+				{group: "project", notes: []string{"plan"}, groups: []string{}},
+				{note: "root note"},
+				{group: "work", notes: []string{}, groups: []string{"project"}},
+			},
+		},
+		{
+			name:   "deleted group",
+			shares: []model.Share{{Id: 1, Owner_email: "owner", Group_id: 99, Permission: model.PermissionRead}},
+			want:   []element{},
+		},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			// work (1) > project (2) > plan (3), root note (4)
+			notes, notesRepo := newFakeNotesService()
+			for _, step := range []func() (int, error){
+				func() (int, error) { return notes.AddGroup("owner", "work", 0) },
+				func() (int, error) { return notes.AddGroup("owner", "project", 1) },
+				func() (int, error) { return notes.AddNote("owner", "plan", 2) },
+				func() (int, error) { return notes.AddNote("owner", "root note", -1) },
+			} {
+				if _, err := step(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			s := NewSharesService(&fakeShares{shares: tcase.shares}, nil, notesRepo)
+
+			list, err := s.GetSharedList("user")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := []element{}
+			for _, el := range list.Elements {
+				e := element{}
+				if el.Note != nil {
+					e.note = el.Note.Title
+				}
+				if el.Group != nil {
+					e.group = el.Group.Name
+					e.notes = []string{}
+					for _, n := range el.Group.Notes {
+						e.notes = append(e.notes, n.Title)
+					}
+					e.groups = []string{}
+					for _, g := range *el.Group.Groups {
+						e.groups = append(e.groups, g.Name)
+					}
+				}
+				got = append(got, e)
+			}
+			assert.Equal(t, tcase.want, got)
+		})
+	}
+}
@@ -0,0 +1,434 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"noteapp/internal/model"
+	"noteapp/internal/repository"
+	"noteapp/pkg/logger"
+	"strconv"
+	"time"
+)
+
+const (
+	syncPullLimit = 500
+	syncPushLimit = 500
+	// syncRetention - the deletes are kept in the change log this long
+	syncRetention = 30 * 24 * time.Hour
+)
+
+var (
+	ErrUnknownChange = errors.New("unknown entity or action")
+	ErrUnknownRef    = errors.New("referenced client_id not found in the batch")
+	ErrTooManyItems  = errors.New("too many items in one request")
+)
+
+type SyncRepository interface {
+	GetChanges(email string, cursor int64, limit int) ([]model.Change, error)
+	GetHorizon(email string) (int64, error)
+	PruneChanges(before time.Time) error
+	GetGroup(id int, email string) (model.Group, error)
+}
+
+type SyncService struct {
+	repository SyncRepository
+	notes      *NotesService
+}
+
+func NewSyncService(repo SyncRepository, notes *NotesService) *SyncService {
+	return &SyncService{
+		repository: repo,
+		notes:      notes,
+	}
+}
+
+// Pull returns the current state of notes and groups changed after the cursor.
+// Only the last change of every object matters, so the page is collapsed.
+// A cursor behind the pruned changes gets Resync, the log keeps the last
+// change of every object, so pulling from 0 gives the whole state.
+func (s *SyncService) Pull(email string, cursor int64) (model.SyncPull, error) {
+	pull := model.SyncPull{
+		Cursor:         cursor,
+		Notes:          []model.Note{},
+		Groups:         []model.Group{},
+		Deleted_notes:  []int{},
+		Deleted_groups: []int{},
+	}
+
+	if cursor > 0 {
+		horizon, err := s.repository.GetHorizon(email)
+		if err != nil {
+			logger.NewLog("service - Pull()", 2, err, "Filed to get horizon in repository", email)
+			return pull, err
+		}
+		if cursor < horizon {
+			pull.Cursor = 0
+			pull.Resync = true
+			return pull, nil
+		}
+	}
+
+	changes, err := s.repository.GetChanges(email, cursor, syncPullLimit+1)
+	if err != nil {
+		logger.NewLog("service - Pull()", 2, err, "Filed to get changes in repository", email)
+		return pull, err
+	}
+	if len(changes) > syncPullLimit {
+		changes = changes[:syncPullLimit]
+		pull.Has_more = true
+	}
+	if len(changes) == 0 {
+		return pull, nil
+	}
+	pull.Cursor = changes[len(changes)-1].Id
+
+	type key struct {
+		entity string
+		id     int
+	}
+	last := map[key]string{}
+	order := []key{}
+	for _, c := range changes {
+		k := key{c.Entity, c.Entity_id}
+		if _, ok := last[k]; !ok {
+			order = append(order, k)
+		}
+		last[k] = c.Action
+	}
+
+	for _, k := range order {
+		deleted := last[k] == model.ActionDelete
+
+		if k.entity == model.EntityGroup {
+			if !deleted {
+				g, err := s.repository.GetGroup(k.id, email)
+				if err == nil {
+					pull.Groups = append(pull.Groups, g)
+					continue
+				}
+				if err != repository.ErrInvalidData {
+					logger.NewLog("service - Pull()", 2, err, "Filed to get group in repository", k.id)
+					return pull, err
+				}
+			}
+			pull.Deleted_groups = append(pull.Deleted_groups, k.id)
+			continue
+		}
+
+		if !deleted {
+			note, err := s.notes.GetNote(k.id, email)
+			if err == nil {
+				pull.Notes = append(pull.Notes, note)
+				continue
+			}
+			if err != repository.ErrInvalidData && err != ErrAccessDenied {
+				return pull, err
+			}
+		}
+		pull.Deleted_notes = append(pull.Deleted_notes, k.id)
+	}
+
+	return pull, nil
+}
+
+// RunCleanup compacts the change log every interval until ctx is done,
+// the deletes are dropped after syncRetention
+func (s *SyncService) RunCleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.repository.PruneChanges(time.Now().Add(-syncRetention)); err != nil {
+			logger.NewLog("service - RunCleanup()", 2, err, "Filed to prune changes in repository", nil)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Push applies changes made by the client offline, every change gets its own result
+func (s *SyncService) Push(email string, changes []model.SyncChange) ([]model.SyncResult, error) {
+	if len(changes) > syncPushLimit {
+		return nil, ErrTooManyItems
+	}
+
+	results := make([]model.SyncResult, 0, len(changes))
+	// server ids of the objects created in this batch
+	created := map[string]int{}
+
+	for _, c := range changes {
+		res := model.SyncResult{
+			Client_id: c.Client_id,
+			Entity:    c.Entity,
+			Id:        c.Id,
+		}
+
+		var err error
+		switch {
+		case c.Entity == model.EntityNote && c.Action == model.ActionCreate:
+			err = s.createNote(email, c, created, &res)
+		case c.Entity == model.EntityNote && c.Action == model.ActionUpdate:
+			err = s.updateNote(email, c, created, &res)
+		case c.Entity == model.EntityNote && c.Action == model.ActionDelete:
+			err = s.deleteNote(email, c, &res)
+		case c.Entity == model.EntityGroup && c.Action == model.ActionCreate:
+			err = s.createGroup(email, c, created, &res)
+		case c.Entity == model.EntityGroup && c.Action == model.ActionUpdate:
+			err = s.updateGroup(email, c, created, &res)
+		case c.Entity == model.EntityGroup && c.Action == model.ActionDelete:
+			err = s.deleteGroup(email, c, &res)
+		default:
+			err = ErrUnknownChange
+		}
+
+		if err != nil {
+			logger.NewLog("service - Push()", 3, err, "Filed to apply client change", c)
+			res.Status = model.SyncError
+			res.Error = err.Error()
+		}
+		results = append(results, res)
+	}
+
+	return results, nil
+}
+
+// NOTES
+
+func (s *SyncService) createNote(email string, c model.SyncChange, created map[string]int, res *model.SyncResult) error {
+	if c.Title == nil || *c.Title == "" {
+		return repository.ErrInvalidData
+	}
+
+	groupID, err := resolveRef(c.Group_id, c.Group_client_id, created, -1)
+	if err != nil {
+		return err
+	}
+
+	// encrypted notes are created with their text at once
+	encrypted := c.Encrypted != nil && *c.Encrypted
+	var id int
+	if encrypted {
+		text := ""
+		if c.Text != nil {
+			text = *c.Text
+		}
+		id, err = s.notes.AddEncryptedNote(email, *c.Title, text, groupID)
+	} else {
+		id, err = s.notes.AddNote(email, *c.Title, groupID)
+	}
+	if err != nil {
+		return err
+	}
+	if c.Client_id != "" {
+		created[c.Client_id] = id
+	}
+	res.Id = id
+
+	if c.Text != nil && !encrypted {
+		data := map[string]string{
+			"id":    strconv.Itoa(id),
+			"email": email,
+			"text":  *c.Text,
+		}
+		if err = s.notes.UpdateNote(data); err != nil {
+			return err
+		}
+	}
+
+	note, err := s.notes.GetNote(id, email)
+	if err != nil {
+		return err
+	}
+	res.Status = model.SyncApplied
+	res.Note = &note
+	return nil
+}
+
+func (s *SyncService) updateNote(email string, c model.SyncChange, created map[string]int, res *model.SyncResult) error {
+	data := map[string]string{
+		"id":      strconv.Itoa(c.Id),
+		"email":   email,
+		"version": strconv.Itoa(c.Version),
+	}
+	if c.Title != nil {
+		data["title"] = *c.Title
+	}
+	if c.Text != nil {
+		data["text"] = *c.Text
+	}
+	if c.Encrypted != nil {
+		data["encrypted"] = strconv.FormatBool(*c.Encrypted)
+	}
+	if c.Group_id != nil || c.Group_client_id != "" {
+		groupID, err := resolveRef(c.Group_id, c.Group_client_id, created, 0)
+		if err != nil {
+			return err
+		}
+		data["group_id"] = strconv.Itoa(groupID)
+	}
+
+	err := s.notes.UpdateNote(data)
+	if err == repository.ErrInvalidData {
+		// changed or deleted on the server
+		return s.noteConflict(email, c.Id, res)
+	}
+	if err != nil {
+		return err
+	}
+
+	note, err := s.notes.GetNote(c.Id, email)
+	if err != nil {
+		return err
+	}
+	res.Status = model.SyncApplied
+	res.Note = &note
+	return nil
+}
+
+func (s *SyncService) deleteNote(email string, c model.SyncChange, res *model.SyncResult) error {
+	note, err := s.notes.GetNote(c.Id, email)
+	if err == repository.ErrInvalidData {
+		// already deleted
+		res.Status = model.SyncApplied
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if c.Version != 0 && c.Version != note.Version {
+		res.Status = model.SyncConflict
+		res.Note = &note
+		return nil
+	}
+
+	if err = s.notes.DelNote(c.Id, email); err != nil {
+		return err
+	}
+	res.Status = model.SyncApplied
+	return nil
+}
+
+func (s *SyncService) noteConflict(email string, id int, res *model.SyncResult) error {
+	res.Status = model.SyncConflict
+	note, err := s.notes.GetNote(id, email)
+	if err == repository.ErrInvalidData {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	res.Note = &note
+	return nil
+}
+
+// GROUPS
+
+func (s *SyncService) createGroup(email string, c model.SyncChange, created map[string]int, res *model.SyncResult) error {
+	if c.Name == nil || *c.Name == "" {
+		return repository.ErrInvalidData
+	}
+
+	pid, err := resolveRef(c.Pid, c.Pid_client_id, created, 0)
+	if err != nil {
+		return err
+	}
+
+	id, err := s.notes.AddGroup(email, *c.Name, pid)
+	if err != nil {
+		return err
+	}
+	if c.Client_id != "" {
+		created[c.Client_id] = id
+	}
+	res.Id = id
+
+	g, err := s.repository.GetGroup(id, email)
+	if err != nil {
+		return err
+	}
+	res.Status = model.SyncApplied
+	res.Group = &g
+	return nil
+}
+
+func (s *SyncService) updateGroup(email string, c model.SyncChange, created map[string]int, res *model.SyncResult) error {
+	g, err := s.repository.GetGroup(c.Id, email)
+	if err == repository.ErrInvalidData {
+		res.Status = model.SyncConflict
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if c.Version != g.Version {
+		res.Status = model.SyncConflict
+		res.Group = &g
+		return nil
+	}
+
+	name := ""
+	if c.Name != nil {
+		name = *c.Name
+	}
+	pid := -1
+	if c.Pid != nil || c.Pid_client_id != "" {
+		pid, err = resolveRef(c.Pid, c.Pid_client_id, created, 0)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err = s.notes.UpdateGroup(c.Id, email, name, pid); err != nil {
+		return err
+	}
+
+	g, err = s.repository.GetGroup(c.Id, email)
+	if err != nil {
+		return err
+	}
+	res.Status = model.SyncApplied
+	res.Group = &g
+	return nil
+}
+
+func (s *SyncService) deleteGroup(email string, c model.SyncChange, res *model.SyncResult) error {
+	g, err := s.repository.GetGroup(c.Id, email)
+	if err == repository.ErrInvalidData {
+		res.Status = model.SyncApplied
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if c.Version != 0 && c.Version != g.Version {
+		res.Status = model.SyncConflict
+		res.Group = &g
+		return nil
+	}
+
+	if err = s.notes.DelGroup(c.Id, email); err != nil {
+		return err
+	}
+	res.Status = model.SyncApplied
+	return nil
+}
+
+// resolveRef returns the server id given directly or through the client_id
+// of an object created earlier in the batch
+func resolveRef(id *int, clientID string, created map[string]int, def int) (int, error) {
+	if clientID != "" {
+		serverID, ok := created[clientID]
+		if !ok {
+			return 0, ErrUnknownRef
+		}
+		return serverID, nil
+	}
+	if id != nil {
+		return *id, nil
+	}
+	return def, nil
+}
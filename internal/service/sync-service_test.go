@@ -0,0 +1,352 @@
+package service
+
+import (
+	"errors"
+	"noteapp/internal/model"
+	"noteapp/internal/repository"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSync reads the groups of fakeNotes and returns the changes of the
+// log after the cursor, GetGroup fails with failGroup if it is set
+type fakeSync struct {
+	*fakeNotes
+	changes   []model.Change
+	horizon   int64
+	failGroup error
+}
+
+func (r *fakeSync) GetHorizon(email string) (int64, error) {
+	return r.horizon, nil
+}
+
+func (r *fakeSync) PruneChanges(before time.Time) error {
+	return nil
+}
+
+func (r *fakeSync) GetChanges(email string, cursor int64, limit int) ([]model.Change, error) {
+	list := []model.Change{}
+	for _, c := range r.changes {
+		if c.Id > cursor && len(list) < limit {
+			list = append(list, c)
+		}
+	}
+	return list, nil
+}
+
+func (r *fakeSync) GetGroup(id int, email string) (model.Group, error) {
+	if r.failGroup != nil {
+		return model.Group{}, r.failGroup
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	g, ok := r.groups[id]
+	if !ok || g.User_email != email {
+		return model.Group{}, repository.ErrInvalidData
+	}
+	return *g, nil
+}
+
+// newFakeSyncService returns the service with group 1 and note 2 in it
+// of "user", both of version 1
+func newFakeSyncService(t *testing.T) (*SyncService, *fakeSync) {
+	t.Helper()
+
+	notes, notesRepo := newFakeNotesService()
+	if _, err := notes.AddGroup("user", "group", 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := notes.AddNote("user", "note", 1); err != nil {
+		t.Fatal(err)
+	}
+	repo := &fakeSync{fakeNotes: notesRepo}
+	return NewSyncService(repo, notes), repo
+}
+
+func TestSyncPush(t *testing.T) {
+	str := func(s string) *string { return &s }
+	num := func(n int) *int { return &n }
+
+	type result struct {
+		status string
+		id     int
+		err    string
+		// the server state is returned
+		server bool
+		// group of the returned note
+		group_id int
+	}
+
+	testCases := []struct {
+		name    string
+		changes []model.SyncChange
+		want    []result
+		wantErr error
+	}{
+		{
+			name:    "create note",
+			changes: []model.SyncChange{{Client_id: "a", Entity: model.EntityNote, Action: model.ActionCreate, Title: str("new"), Text: str("text")}},
+			want:    []result{{status: model.SyncApplied, id: 3, server: true}},
+		},
+		{
+			name:    "create note without title",
+			changes: []model.SyncChange{{Client_id: "a", Entity: model.EntityNote, Action: model.ActionCreate}},
+			want:    []result{{status: model.SyncError, err: repository.ErrInvalidData.Error()}},
+		},
+		{
+			name: "create note in group of the batch",
+			changes: []model.SyncChange{
+				{Client_id: "a", Entity: model.EntityGroup, Action: model.ActionCreate, Name: str("sub"), Pid: num(1)},
+				{Client_id: "b", Entity: model.EntityNote, Action: model.ActionCreate, Title: str("new"), Group_client_id: "a"},
+			},
+			want: []result{
+				{status: model.SyncApplied, id: 3, server: true},
+				{status: model.SyncApplied, id: 4, server: true, group_id: 3},
+			},
+		},
+		{
+			name:    "unknown client_id",
+			changes: []model.SyncChange{{Client_id: "b", Entity: model.EntityNote, Action: model.ActionCreate, Title: str("new"), Group_client_id: "a"}},
+			want:    []result{{status: model.SyncError, err: ErrUnknownRef.Error()}},
+		},
+		{
+			name:    "update note",
+			changes: []model.SyncChange{{Entity: model.EntityNote, Action: model.ActionUpdate, Id: 2, Version: 1, Text: str("text")}},
+			want:    []result{{status: model.SyncApplied, id: 2, server: true, group_id: 1}},
+		},
+		{
+			name:    "update changed note",
+			changes: []model.SyncChange{{Entity: model.EntityNote, Action: model.ActionUpdate, Id: 2, Version: 5, Text: str("text")}},
+			want:    []result{{status: model.SyncConflict, id: 2, server: true, group_id: 1}},
+		},
+		{
+			name:    "update deleted note",
+			changes: []model.SyncChange{{Entity: model.EntityNote, Action: model.ActionUpdate, Id: 99, Version: 1, Text: str("text")}},
+			want:    []result{{status: model.SyncConflict, id: 99}},
+		},
+		{
+			name:    "delete note",
+			changes: []model.SyncChange{{Entity: model.EntityNote, Action: model.ActionDelete, Id: 2, Version: 1}},
+			want:    []result{{status: model.SyncApplied, id: 2}},
+		},
+		{
+			name:    "delete changed note",
+			changes: []model.SyncChange{{Entity: model.EntityNote, Action: model.ActionDelete, Id: 2, Version: 5}},
+			want:    []result{{status: model.SyncConflict, id: 2, server: true, group_id: 1}},
+		},
+		{
+			name:    "delete deleted note",
+			changes: []model.SyncChange{{Entity: model.EntityNote, Action: model.ActionDelete, Id: 99}},
+			want:    []result{{status: model.SyncApplied, id: 99}},
+		},
+		{
+			name:    "update group",
+			changes: []model.SyncChange{{Entity: model.EntityGroup, Action: model.ActionUpdate, Id: 1, Version: 1, Name: str("renamed")}},
+			want:    []result{{status: model.SyncApplied, id: 1, server: true}},
+		},
+		{
+			name:    "update changed group",
+			changes: []model.SyncChange{{Entity: model.EntityGroup, Action: model.ActionUpdate, Id: 1, Version: 5, Name: str("renamed")}},
+			want:    []result{{status: model.SyncConflict, id: 1, server: true}},
+		},
+		{
+			name:    "update deleted group",
+			changes: []model.SyncChange{{Entity: model.EntityGroup, Action: model.ActionUpdate, Id: 99, Version: 1, Name: str("renamed")}},
+			want:    []result{{status: model.SyncConflict, id: 99}},
+		},
+		{
+			name:    "delete group",
+			changes: []model.SyncChange{{Entity: model.EntityGroup, Action: model.ActionDelete, Id: 1, Version: 1}},
+			want:    []result{{status: model.SyncApplied, id: 1}},
+		},
+		{
+			name:    "unknown action",
+			changes: []model.SyncChange{{Entity: model.EntityNote, Action: "move", Id: 2}},
+			want:    []result{{status: model.SyncError, id: 2, err: ErrUnknownChange.Error()}},
+		},
+		{
+			name: "error does not stop the batch",
+			changes: []model.SyncChange{
+				{Entity: "tag", Action: model.ActionCreate},
+				{Entity: model.EntityNote, Action: model.ActionUpdate, Id: 2, Version: 1, Title: str("renamed")},
+			},
+			want: []result{
+				{status: model.SyncError, err: ErrUnknownChange.Error()},
+				{status: model.SyncApplied, id: 2, server: true, group_id: 1},
+			},
+		},
+		{
+			name:    "too many changes",
+			changes: make([]model.SyncChange, syncPushLimit+1),
+			wantErr: ErrTooManyItems,
+		},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			s, _ := newFakeSyncService(t)
+
+			results, err := s.Push("user", tcase.changes)
+			assert.Equal(t, tcase.wantErr, err)
+
+			got := make([]result, 0, len(results))
+			for _, res := range results {
+				r := result{status: res.Status, id: res.Id, err: res.Error, server: res.Note != nil || res.Group != nil}
+				if res.Note != nil {
+					r.group_id = res.Note.Group_id
+				}
+				got = append(got, r)
+			}
+			if tcase.want == nil {
+				tcase.want = []result{}
+			}
+			assert.Equal(t, tcase.want, got)
+		})
+	}
+}
+
+func TestSyncPull(t *testing.T) {
+	change := func(id int64, entity string, entityID int, action string) model.Change {
+		return model.Change{Id: id, Entity: entity, Entity_id: entityID, Action: action}
+	}
+
+	// every change of the page is the same note
+	page := make([]model.Change, syncPullLimit+5)
+	for i := range page {
+		page[i] = change(int64(i+1), model.EntityNote, 2, model.ActionUpsert)
+	}
+
+	type pull struct {
+		cursor         int64
+		has_more       bool
+		resync         bool
+		notes          []int
+		groups         []int
+		deleted_notes  []int
+		deleted_groups []int
+	}
+
+	testCases := []struct {
+		name      string
+		changes   []model.Change
+		cursor    int64
+		horizon   int64
+		failGroup error
+		want      pull
+		wantErr   error
+	}{
+		{
+			name:   "no changes",
+			cursor: 7,
+			want:   pull{cursor: 7},
+		},
+		{
+			name: "changes collapsed",
+			changes: []model.Change{
+				change(1, model.EntityNote, 2, model.ActionUpsert),
+				change(2, model.EntityGroup, 1, model.ActionUpsert),
+				change(3, model.EntityNote, 2, model.ActionUpsert),
+				change(4, model.EntityGroup, 1, model.ActionUpsert),
+			},
+			want: pull{cursor: 4, notes: []int{2}, groups: []int{1}},
+		},
+		{
+			name: "last change wins",
+			changes: []model.Change{
+				change(1, model.EntityNote, 2, model.ActionUpsert),
+				change(2, model.EntityNote, 2, model.ActionDelete),
+				change(3, model.EntityGroup, 1, model.ActionDelete),
+				change(4, model.EntityGroup, 1, model.ActionUpsert),
+			},
+			want: pull{cursor: 4, groups: []int{1}, deleted_notes: []int{2}},
+		},
+		{
+			name: "deleted after the change",
+			changes: []model.Change{
+				change(1, model.EntityNote, 99, model.ActionUpsert),
+				change(2, model.EntityGroup, 98, model.ActionUpsert),
+			},
+			want: pull{cursor: 2, deleted_notes: []int{99}, deleted_groups: []int{98}},
+		},
+		{
+			name: "after the cursor",
+			changes: []model.Change{
+				change(1, model.EntityNote, 99, model.ActionDelete),
+				change(2, model.EntityGroup, 1, model.ActionUpsert),
+			},
+			cursor: 1,
+			want:   pull{cursor: 2, groups: []int{1}},
+		},
+		{
+			name:    "more than a page",
+			changes: page,
+			want:    pull{cursor: syncPullLimit, has_more: true, notes: []int{2}},
+		},
+		{
+			name:    "cursor behind the pruned changes",
+			changes: []model.Change{change(5, model.EntityNote, 2, model.ActionUpsert)},
+			cursor:  2,
+			horizon: 3,
+			want:    pull{cursor: 0, resync: true},
+		},
+		{
+			name:    "cursor at the horizon",
+			changes: []model.Change{change(5, model.EntityNote, 2, model.ActionUpsert)},
+			cursor:  3,
+			horizon: 3,
+			want:    pull{cursor: 5, notes: []int{2}},
+		},
+		{
+			name:    "first pull after pruning",
+			changes: []model.Change{change(5, model.EntityNote, 2, model.ActionUpsert)},
+			horizon: 3,
+			want:    pull{cursor: 5, notes: []int{2}},
+		},
+		{
+			name:      "repository error",
+			changes:   []model.Change{change(1, model.EntityGroup, 1, model.ActionUpsert)},
+			failGroup: errors.New("db is down"),
+			wantErr:   errors.New("db is down"),
+		},
+	}
+
+	ids := func(list []int) []int {
+		if len(list) == 0 {
+			return nil
+		}
+		return list
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			s, repo := newFakeSyncService(t)
+			repo.changes = tcase.changes
+			repo.horizon = tcase.horizon
+			repo.failGroup = tcase.failGroup
+
+			res, err := s.Pull("user", tcase.cursor)
+			assert.Equal(t, tcase.wantErr, err)
+			if err != nil {
+				return
+			}
+
+			got := pull{
+				cursor:         res.Cursor,
+				has_more:       res.Has_more,
+				resync:         res.Resync,
+				deleted_notes:  ids(res.Deleted_notes),
+				deleted_groups: ids(res.Deleted_groups),
+			}
+			for _, n := range res.Notes {
+				got.notes = append(got.notes, n.Id)
+			}
+			for _, g := range res.Groups {
+				got.groups = append(got.groups, g.Id)
+			}
+			assert.Equal(t, tcase.want, got)
+		})
+	}
+}
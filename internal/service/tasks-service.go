@@ -0,0 +1,98 @@
+package service
+
+import (
+	"errors"
+	"noteapp/internal/model"
+	"noteapp/internal/repository"
+	"noteapp/internal/tasks"
+	"noteapp/pkg/logger"
+	"strconv"
+)
+
+const taskTextMax = 500
+
+var (
+	ErrTaskChanged = errors.New("the task was changed, reload the tasks")
+)
+
+type TasksRepository interface {
+	SetTasks(noteID int, owner string, list []model.Task) error
+	GetTasks(email string, f model.TaskFilter) ([]model.Task, error)
+	GetTask(id int, email string) (model.Task, error)
+}
+
+// TASKS
+
+// GetTasks returns the open tasks from the notes of the user
+func (s *NotesService) GetTasks(email string, f model.TaskFilter) ([]model.Task, error) {
+	list, err := s.tasks.GetTasks(email, f)
+	if err != nil {
+		logger.NewLog("service - GetTasks()", 2, err, "Filed to get tasks in repository", email)
+	}
+	return list, err
+}
+
+// ToggleTask checks or unchecks the task in the text of its note. The
+// task must be on its line yet, otherwise ErrTaskChanged is returned.
+func (s *NotesService) ToggleTask(id int, email string, done bool) error {
+	t, err := s.tasks.GetTask(id, email)
+	if err == repository.ErrInvalidData {
+		return err
+	}
+	if err != nil {
+		logger.NewLog("service - ToggleTask()", 2, err, "Filed to get task in repository", id)
+		return err
+	}
+
+	note, err := s.repository.GetNote(t.Note_id, email)
+	if err != nil {
+		logger.NewLog("service - ToggleTask()", 2, err, "Filed to get note in repository", t.Note_id)
+		return err
+	}
+
+	current := false
+	for _, p := range tasks.Parse(note.Text) {
+		if p.Line == t.Line && truncate(p.Text, taskTextMax) == t.Text {
+			current = true
+			break
+		}
+	}
+	if !current {
+		return ErrTaskChanged
+	}
+
+	text, err := tasks.Toggle(note.Text, t.Line, done)
+	if err != nil {
+		return ErrTaskChanged
+	}
+	if text == note.Text {
+		return nil
+	}
+
+	return s.UpdateNote(map[string]string{
+		"id":    strconv.Itoa(t.Note_id),
+		"email": email,
+		"text":  text,
+	})
+}
+
+// updateTasks parses the text of the note and stores its tasks
+func (s *NotesService) updateTasks(id int, owner string, text string) {
+	parsed := tasks.Parse(text)
+	list := make([]model.Task, 0, len(parsed))
+	for _, p := range parsed {
+		t := model.Task{
+			Line: p.Line,
+			Text: truncate(p.Text, taskTextMax),
+			Done: p.Done,
+		}
+		if !p.Due.IsZero() {
+			t.Due = p.Due.Format(model.DateLayout)
+		}
+		list = append(list, t)
+	}
+
+	if err := s.tasks.SetTasks(id, owner, list); err != nil {
+		logger.NewLog("service - updateTasks()", 2, err, "Filed to set tasks in repository", id)
+	}
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"noteapp/internal/model"
+	"noteapp/internal/repository"
+	"noteapp/internal/templates"
+	"noteapp/internal/wikilink"
+	"noteapp/pkg/logger"
+	"time"
+)
+
+type TemplatesRepository interface {
+	AddTemplate(t *model.Template) error
+	UpdateTemplate(t *model.Template) error
+	DelTemplate(id int, email string) error
+	GetTemplate(id int, email string) (*model.Template, error)
+	GetTemplates(email string) ([]model.Template, error)
+}
+
+type TemplatesService struct {
+	repository TemplatesRepository
+	notes      *NotesService
+}
+
+func NewTemplatesService(repo TemplatesRepository, notes *NotesService) *TemplatesService {
+	return &TemplatesService{
+		repository: repo,
+		notes:      notes,
+	}
+}
+
+func (s *TemplatesService) AddTemplate(t *model.Template) error {
+	if err := t.Validate(); err != nil {
+		return err
+	}
+
+	err := s.repository.AddTemplate(t)
+	if err != nil && err != repository.ErrInvalidData {
+		logger.NewLog("service - AddTemplate()", 2, err, "Filed to add template in repository", t.Name)
+	}
+	return err
+}
+
+func (s *TemplatesService) UpdateTemplate(t *model.Template) error {
+	if err := t.Validate(); err != nil {
+		return err
+	}
+
+	err := s.repository.UpdateTemplate(t)
+	if err != nil && err != repository.ErrInvalidData {
+		logger.NewLog("service - UpdateTemplate()", 2, err, "Filed to update template in repository", t.Id)
+	}
+	return err
+}
+
+func (s *TemplatesService) DelTemplate(id int, email string) error {
+	err := s.repository.DelTemplate(id, email)
+	if err != nil && err != repository.ErrInvalidData {
+		logger.NewLog("service - DelTemplate()", 2, err, "Filed to del template in repository", id)
+	}
+	return err
+}
+
+// GetTemplates returns the built-in templates followed by the user's ones
+func (s *TemplatesService) GetTemplates(email string) ([]model.Template, error) {
+	list, err := s.repository.GetTemplates(email)
+	if err != nil {
+		logger.NewLog("service - GetTemplates()", 2, err, "Filed to get templates in repository", email)
+		return nil, err
+	}
+	return append(append([]model.Template{}, templates.Builtin...), list...), nil
+}
+
+// AddNoteFromTemplate creates a note in the group (-1 - without a group)
+// from the user's template templateID or, if it is 0, from the built-in
+// one. An empty title is taken from the template. now is the time of the
+// user, placeholders are expanded with it.
+func (s *TemplatesService) AddNoteFromTemplate(email string, templateID int, builtin string, groupID int, title string, now time.Time) (int, error) {
+	var t model.Template
+	if templateID != 0 {
+		found, err := s.repository.GetTemplate(templateID, email)
+		if err == repository.ErrInvalidData {
+			return 0, err
+		}
+		if err != nil {
+			logger.NewLog("service - AddNoteFromTemplate()", 2, err, "Filed to get template in repository", templateID)
+			return 0, err
+		}
+		t = *found
+	} else {
+		var ok bool
+		if t, ok = templates.FindBuiltin(builtin); !ok {
+			return 0, repository.ErrInvalidData
+		}
+	}
+
+	vars := templates.Vars{Title: t.Name, User: email, Now: now}
+	if title == "" {
+		title = templates.Expand(t.Title, vars)
+	}
+	vars.Title = truncate(title, wikilink.MaxTitle)
+	if vars.Title == "" {
+		vars.Title = truncate(t.Name, wikilink.MaxTitle)
+	}
+
+	return s.notes.AddNoteWithText(email, vars.Title, templates.Expand(t.Text, vars), groupID)
+}
@@ -0,0 +1,81 @@
+package site
+
+import (
+	"archive/zip"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Output - destination of the site files, names are slash separated paths
+// made by Site. A writer is valid until the next Create.
+type Output interface {
+	Create(name string) (io.Writer, error)
+	Close() error
+}
+
+type zipOutput struct {
+	zw *zip.Writer
+}
+
+// NewZipOutput packs the site to a ZIP, the underlying writer is not closed
+func NewZipOutput(w io.Writer) Output {
+	return &zipOutput{zw: zip.NewWriter(w)}
+}
+
+func (o *zipOutput) Create(name string) (io.Writer, error) {
+	return o.zw.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: time.Now(),
+	})
+}
+
+func (o *zipOutput) Close() error {
+	return o.zw.Close()
+}
+
+type dirOutput struct {
+	dir  string
+	file *os.File
+}
+
+// NewDirOutput writes the site to the directory, existing files with the
+// same names are overwritten
+func NewDirOutput(dir string) (Output, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &dirOutput{dir: dir}, nil
+}
+
+func (o *dirOutput) Create(name string) (io.Writer, error) {
+	if err := o.closeFile(); err != nil {
+		return nil, err
+	}
+	// names come from export.CleanName, they can't leave the directory
+	p := filepath.Join(o.dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.Create(p)
+	if err != nil {
+		return nil, err
+	}
+	o.file = f
+	return f, nil
+}
+
+func (o *dirOutput) Close() error {
+	return o.closeFile()
+}
+
+func (o *dirOutput) closeFile() error {
+	if o.file == nil {
+		return nil
+	}
+	err := o.file.Close()
+	o.file = nil
+	return err
+}
@@ -0,0 +1,215 @@
+package site
+
+import (
+	"html/template"
+	"io"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"noteapp/internal/export"
+	"noteapp/internal/model"
+)
+
+// AttachmentsDir - directory of the copied attachments at the site root
+const AttachmentsDir = "attachments"
+
+// Site writes a group subtree as static HTML: index.html per group, one
+// page per note and a navigation sidebar built from the tree on every page.
+// Paths are assigned by New, so pages can link to each other before they
+// are written.
+type Site struct {
+	out   Output
+	title string
+	root  *group
+	notes map[int]*page
+	used  map[string]bool
+}
+
+type group struct {
+	name   string
+	index  string
+	notes  []*page
+	groups []*group
+}
+
+type page struct {
+	title string
+	path  string
+}
+
+func New(out Output, root model.GroupElement) *Site {
+	s := &Site{
+		out:   out,
+		title: root.Name,
+		notes: map[int]*page{},
+		used:  map[string]bool{},
+	}
+	s.reserve(AttachmentsDir)
+	s.reserve("style.css")
+	s.root = s.addGroup("", root)
+	return s
+}
+
+func (s *Site) addGroup(dir string, g model.GroupElement) *group {
+	node := &group{name: g.Name, index: s.reserve(path.Join(dir, "index.html"))}
+	for _, n := range g.Notes {
+		p := &page{
+			title: n.Title,
+			path:  s.unique(dir, export.CleanName(n.Title, "Untitled"), ".html"),
+		}
+		s.notes[n.Id] = p
+		node.notes = append(node.notes, p)
+	}
+	if g.Groups != nil {
+		for _, sub := range *g.Groups {
+			subDir := s.unique(dir, export.CleanName(sub.Name, "Untitled"), "")
+			node.groups = append(node.groups, s.addGroup(subDir, sub))
+		}
+	}
+	return node
+}
+
+// NotePath returns the page of the note, "" - the note is not published
+func (s *Site) NotePath(id int) string {
+	if p := s.notes[id]; p != nil {
+		return p.path
+	}
+	return ""
+}
+
+// AttachmentPath reserves a file name for an attachment
+func (s *Site) AttachmentPath(name string) string {
+	ext := path.Ext(name)
+	if len(ext) > 10 || ext == name {
+		ext = ""
+	}
+	return s.unique(AttachmentsDir, export.CleanName(strings.TrimSuffix(name, ext), "file"), export.CleanName(ext, ""))
+}
+
+// Rel returns the url of the target relative to the page, both are
+// paths inside the site
+func Rel(from string, to string) string {
+	segments := strings.Split(to, "/")
+	for i, seg := range segments {
+		segments[i] = url.PathEscape(seg)
+	}
+	return strings.Repeat("../", strings.Count(from, "/")) + strings.Join(segments, "/")
+}
+
+// AddFile copies r to the site under the given path
+func (s *Site) AddFile(name string, r io.Reader) error {
+	w, err := s.out.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, r)
+	return err
+}
+
+// WriteNote writes the page of the note, content is sanitized HTML
+// with urls relative to the page
+func (s *Site) WriteNote(note model.Note, content string) error {
+	p := s.notes[note.Id]
+	if p == nil {
+		return nil
+	}
+	return s.write(p.path, layout{
+		Title:   note.Title,
+		Updated: note.Updated_at.UTC().Format("2006-01-02"),
+		Content: template.HTML(content),
+	})
+}
+
+// Close writes the index pages and the style sheet and closes the output
+func (s *Site) Close() error {
+	if err := s.writeIndexes(s.root); err != nil {
+		return err
+	}
+	w, err := s.out.Create("style.css")
+	if err != nil {
+		return err
+	}
+	if _, err = io.WriteString(w, style); err != nil {
+		return err
+	}
+	return s.out.Close()
+}
+
+func (s *Site) writeIndexes(g *group) error {
+	entries := []navItem{}
+	for _, sub := range g.groups {
+		entries = append(entries, navItem{Title: sub.name, Href: Rel(g.index, sub.index), Group: true})
+	}
+	for _, n := range g.notes {
+		entries = append(entries, navItem{Title: n.title, Href: Rel(g.index, n.path)})
+	}
+	if err := s.write(g.index, layout{Title: g.name, Entries: entries}); err != nil {
+		return err
+	}
+
+	for _, sub := range g.groups {
+		if err := s.writeIndexes(sub); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type layout struct {
+	Site    string
+	Title   string
+	Updated string
+	Base    string
+	Nav     navItem
+	Content template.HTML
+	// index pages: subgroups and notes
+	Entries []navItem
+}
+
+type navItem struct {
+	Title    string
+	Href     string
+	Group    bool
+	Current  bool
+	Children []navItem
+}
+
+func (s *Site) write(name string, l layout) error {
+	l.Site = s.title
+	l.Base = strings.Repeat("../", strings.Count(name, "/"))
+	l.Nav = s.nav(s.root, name)
+
+	w, err := s.out.Create(name)
+	if err != nil {
+		return err
+	}
+	return pageTemplate.Execute(w, l)
+}
+
+// nav builds the sidebar as seen from the page
+func (s *Site) nav(g *group, from string) navItem {
+	item := navItem{Title: g.name, Href: Rel(from, g.index), Group: true, Current: g.index == from}
+	for _, sub := range g.groups {
+		item.Children = append(item.Children, s.nav(sub, from))
+	}
+	for _, n := range g.notes {
+		item.Children = append(item.Children, navItem{Title: n.title, Href: Rel(from, n.path), Current: n.path == from})
+	}
+	return item
+}
+
+func (s *Site) reserve(p string) string {
+	s.used[strings.ToLower(p)] = true
+	return p
+}
+
+// unique reserves a path inside dir, taken names get a " (2)" suffix
+func (s *Site) unique(dir string, name string, ext string) string {
+	p := path.Join(dir, name+ext)
+	for i := 2; s.used[strings.ToLower(p)]; i++ {
+		p = path.Join(dir, name+" ("+strconv.Itoa(i)+")"+ext)
+	}
+	return s.reserve(p)
+}
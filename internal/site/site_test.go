@@ -0,0 +1,87 @@
+package site
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"noteapp/internal/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRel(t *testing.T) {
+	testCases := []struct {
+		name string
+		from string
+		to   string
+		want string
+	}{
+		{name: "same dir", from: "index.html", to: "Plan.html", want: "Plan.html"},
+		{name: "up", from: "Work/Team/a.html", to: "attachments/b.png", want: "../../attachments/b.png"},
+		{name: "escaped", from: "a.html", to: "My group/Plan #1.html", want: "My%20group/Plan%20%231.html"},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			assert.Equal(t, tcase.want, Rel(tcase.from, tcase.to))
+		})
+	}
+}
+
+func TestSite(t *testing.T) {
+	root := model.GroupElement{
+		Id:    1,
+		Name:  "Handbook",
+		Notes: []model.NoteElement{{Id: 1, Title: "index"}, {Id: 2, Title: "Intro"}},
+		Groups: &[]model.GroupElement{
+			{Id: 2, Name: "Team", Notes: []model.NoteElement{{Id: 3, Title: "Intro"}}},
+		},
+	}
+
+	buf := &bytes.Buffer{}
+	s := New(NewZipOutput(buf), root)
+
+	assert.Equal(t, "index (2).html", s.NotePath(1))
+	assert.Equal(t, "Intro.html", s.NotePath(2))
+	assert.Equal(t, "Team/Intro.html", s.NotePath(3))
+	assert.Equal(t, "", s.NotePath(4))
+	assert.Equal(t, "attachments/a.png", s.AttachmentPath("a.png"))
+	assert.Equal(t, "attachments/a (2).png", s.AttachmentPath("a.png"))
+
+	updated := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	assert.NoError(t, s.WriteNote(model.Note{Id: 3, Title: "Intro", Updated_at: updated},
+		`<p><a href="`+Rel(s.NotePath(3), s.NotePath(2))+`">back</a></p>`))
+	assert.NoError(t, s.AddFile("attachments/a.png", strings.NewReader("png")))
+	assert.NoError(t, s.Close())
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	assert.NoError(t, err)
+	files := map[string]string{}
+	for _, f := range zr.File {
+		r, err := f.Open()
+		assert.NoError(t, err)
+		b, _ := io.ReadAll(r)
+		files[f.Name] = string(b)
+	}
+
+	assert.Contains(t, files, "index.html")
+	assert.Contains(t, files, "Team/index.html")
+	assert.Contains(t, files, "style.css")
+	assert.Equal(t, "png", files["attachments/a.png"])
+
+	page := files["Team/Intro.html"]
+	assert.Contains(t, page, `<title>Intro - Handbook</title>`)
+	assert.Contains(t, page, `href="../style.css"`)
+	assert.Contains(t, page, `<p class="updated">Updated 2024-01-02</p>`)
+	assert.Contains(t, page, `<p><a href="../Intro.html">back</a></p>`)
+	assert.Contains(t, page, `<a href="../Team/Intro.html" class="current">Intro</a>`)
+	assert.Contains(t, page, `<a href="../index%20%282%29.html">index</a>`)
+
+	index := files["index.html"]
+	assert.Contains(t, index, `<li class="group"><a href="Team/index.html">Team</a></li>`)
+	assert.Contains(t, index, `<li><a href="Intro.html">Intro</a></li>`)
+}
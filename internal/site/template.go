@@ -0,0 +1,53 @@
+package site
+
+import "html/template"
+
+var pageTemplate = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}} - {{.Site}}</title>
+<link rel="stylesheet" href="{{.Base}}style.css">
+</head>
+<body>
+<nav>
+<ul>{{template "item" .Nav}}</ul>
+</nav>
+<main>
+<h1>{{.Title}}</h1>
+{{- if .Updated}}
+<p class="updated">Updated {{.Updated}}</p>
+{{- end}}
+{{- if .Entries}}
+<ul class="entries">
+{{- range .Entries}}
+<li{{if .Group}} class="group"{{end}}><a href="{{.Href}}">{{.Title}}</a></li>
+{{- end}}
+</ul>
+{{- end}}
+{{.Content}}
+</main>
+</body>
+</html>
+{{define "item"}}
+<li{{if .Group}} class="group"{{end}}><a href="{{.Href}}"{{if .Current}} class="current"{{end}}>{{.Title}}</a>
+{{- if .Children}}<ul>{{range .Children}}{{template "item" .}}{{end}}</ul>{{end}}</li>
+{{- end}}`))
+
+const style = `body { display: flex; margin: 0; font: 16px/1.6 system-ui, sans-serif; color: #222; }
+nav { flex: 0 0 260px; min-height: 100vh; padding: 16px; background: #f5f5f5; box-sizing: border-box; }
+nav ul { list-style: none; margin: 0; padding-left: 14px; }
+nav > ul { padding-left: 0; }
+nav a { color: #333; text-decoration: none; }
+nav a.current { font-weight: bold; }
+.group > a { font-weight: 600; }
+main { flex: 1; max-width: 800px; padding: 16px 32px; }
+.updated { color: #888; font-size: 14px; }
+.wikilink-broken { color: #b00; }
+img { max-width: 100%; }
+pre { padding: 12px; overflow-x: auto; background: #f5f5f5; }
+table { border-collapse: collapse; }
+th, td { padding: 4px 8px; border: 1px solid #ddd; }
+@media (max-width: 700px) { body { display: block; } nav { min-height: 0; } }
+`
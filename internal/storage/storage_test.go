@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeS3 - minimal stand-in for an S3 server, keeps objects in memory
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string]string
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=key/") ||
+		!strings.Contains(auth, "SignedHeaders=host;x-amz-content-sha256;x-amz-date") ||
+		r.Header.Get("X-Amz-Date") == "" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch r.Method {
+	case http.MethodPut:
+		if r.ContentLength < 0 {
+			w.WriteHeader(http.StatusLengthRequired)
+			return
+		}
+		data, _ := io.ReadAll(r.Body)
+		f.objects[r.URL.Path] = string(data)
+	case http.MethodGet:
+		data, ok := f.objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		io.WriteString(w, data)
+	case http.MethodDelete:
+		delete(f.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestBlobStores(t *testing.T) {
+	fake := &fakeS3{objects: map[string]string{}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	local, err := NewLocalStore(t.TempDir())
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		name  string
+		store BlobStore
+	}{
+		{
+			name:  "local",
+			store: local,
+		},
+		{
+			name: "s3",
+			store: NewS3Store(S3Config{
+				Endpoint:  srv.URL,
+				Bucket:    "notes",
+				AccessKey: "key",
+				SecretKey: "secret",
+			}),
+		},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			ctx := context.Background()
+			key := "attachments/ab/abcdef"
+			content := "hello attachment"
+
+			err := tcase.store.Put(ctx, key, strings.NewReader(content), int64(len(content)), "text/plain")
+			assert.NoError(t, err)
+
+			body, err := tcase.store.Get(ctx, key)
+			assert.NoError(t, err)
+			data, _ := io.ReadAll(body)
+			body.Close()
+			assert.Equal(t, content, string(data))
+
+			assert.NoError(t, tcase.store.Delete(ctx, key))
+			assert.NoError(t, tcase.store.Delete(ctx, key))
+
+			_, err = tcase.store.Get(ctx, key)
+			assert.Equal(t, ErrBlobNotFound, err)
+		})
+	}
+
+	assert.Empty(t, fake.objects)
+}
+
+func TestLocalStoreInvalidKey(t *testing.T) {
+	store, err := NewLocalStore(t.TempDir())
+	assert.NoError(t, err)
+
+	for _, key := range []string{"", "../secret", "/etc/passwd"} {
+		_, err = store.Get(context.Background(), key)
+		assert.Equal(t, ErrInvalidKey, err, key)
+	}
+}
@@ -0,0 +1,98 @@
+package tasks
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var ErrNotTask = errors.New("the line is not a task")
+
+// Task - "- [ ] text" item of a Markdown task list
+type Task struct {
+	// number of the line in the text, from 1
+	Line int
+	Text string
+	Done bool
+	// zero - no due date
+	Due time.Time
+}
+
+// item - indent, list marker, box and the text of a task list item
+var item = regexp.MustCompile(`^(\s*(?:[-*+]|\d{1,9}[.)])\s+\[)([ xX])(\](?:\s+|$))(.*)$`)
+
+// due dates as the common apps write them: "due:2024-05-01",
+// "@due(2024-05-01)" of TaskPaper and "📅 2024-05-01" of Obsidian Tasks
+var due = regexp.MustCompile(`(?:^|\s)(?:due:|@due\(|📅\s*)(\d{4}-\d{2}-\d{2})\)?`)
+
+// Parse returns the task list items of the text, items inside code
+// blocks are ignored
+func Parse(text string) []Task {
+	list := []Task{}
+	forEachLine(text, func(n int, line string) {
+		m := item.FindStringSubmatch(strings.TrimRight(line, "\r"))
+		if m == nil {
+			return
+		}
+		t := Task{Line: n, Text: strings.TrimSpace(m[4]), Done: m[2] != " "}
+		if d := due.FindStringSubmatch(t.Text); d != nil {
+			t.Due, _ = time.Parse("2006-01-02", d[1])
+		}
+		list = append(list, t)
+	})
+	return list
+}
+
+// Toggle checks or unchecks the task on the line, the rest of the text
+// is kept byte for byte
+func Toggle(text string, line int, done bool) (string, error) {
+	result := ""
+	forEachLine(text, func(n int, l string) {
+		if n != line {
+			return
+		}
+		loc := item.FindStringSubmatchIndex(strings.TrimRight(l, "\r"))
+		if loc == nil {
+			return
+		}
+		box := " "
+		if done {
+			box = "x"
+		}
+		offset := lineOffset(text, line)
+		result = text[:offset+loc[4]] + box + text[offset+loc[5]:]
+	})
+	if result == "" {
+		return "", ErrNotTask
+	}
+	return result, nil
+}
+
+// forEachLine calls fn with the lines outside code blocks
+func forEachLine(text string, fn func(n int, line string)) {
+	fence := ""
+	for i, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case fence != "":
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+		case strings.HasPrefix(trimmed, "```"):
+			fence = "```"
+		case strings.HasPrefix(trimmed, "~~~"):
+			fence = "~~~"
+		default:
+			fn(i+1, line)
+		}
+	}
+}
+
+func lineOffset(text string, line int) int {
+	offset := 0
+	for n := 1; n < line; n++ {
+		offset += strings.IndexByte(text[offset:], '\n') + 1
+	}
+	return offset
+}
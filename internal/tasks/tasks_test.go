@@ -0,0 +1,52 @@
+package tasks
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	text := "# Plan\n" +
+		"- [ ] write tests due:2024-05-01\n" +
+		"  * [x] review @due(2024-04-30)\n" +
+		"1. [X] release 📅 2024-06-01\n" +
+		"- [] not a task\n" +
+		"```\n- [ ] code\n```\n" +
+		"+ [ ]\n"
+
+	got := Parse(text)
+	assert.Equal(t, []Task{
+		{Line: 2, Text: "write tests due:2024-05-01", Due: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)},
+		{Line: 3, Text: "review @due(2024-04-30)", Done: true, Due: time.Date(2024, 4, 30, 0, 0, 0, 0, time.UTC)},
+		{Line: 4, Text: "release 📅 2024-06-01", Done: true, Due: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)},
+		{Line: 9, Text: ""},
+	}, got)
+}
+
+func TestToggle(t *testing.T) {
+	text := "intro\r\n- [ ] one\r\n  - [x] two\r\n"
+
+	testCases := []struct {
+		name    string
+		line    int
+		done    bool
+		want    string
+		wantErr error
+	}{
+		{name: "check", line: 2, done: true, want: "intro\r\n- [x] one\r\n  - [x] two\r\n"},
+		{name: "uncheck", line: 3, done: false, want: "intro\r\n- [ ] one\r\n  - [ ] two\r\n"},
+		{name: "same state", line: 3, done: true, want: text},
+		{name: "not a task", line: 1, wantErr: ErrNotTask},
+		{name: "out of range", line: 10, wantErr: ErrNotTask},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			got, err := Toggle(text, tcase.line, tcase.done)
+			assert.Equal(t, tcase.wantErr, err)
+			assert.Equal(t, tcase.want, got)
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package templates
+
+import (
+	"regexp"
+	"time"
+
+	"noteapp/internal/model"
+)
+
+// Vars - values of the placeholders
+type Vars struct {
+	// title of the created note
+	Title string
+	User  string
+	// the time of the user, its location gives the date
+	Now time.Time
+}
+
+var placeholder = regexp.MustCompile(`\{\{\s*(\w+)\s*\}\}`)
+
+// Expand replaces the placeholders in the text:
+//
+//	{{date}} 2024-01-02, {{time}} 15:04, {{datetime}} 2024-01-02 15:04,
+//	{{weekday}} Tuesday, {{title}} and {{user}}
+//
+// Unknown placeholders are kept as they are.
+func Expand(text string, v Vars) string {
+	return placeholder.ReplaceAllStringFunc(text, func(m string) string {
+		switch placeholder.FindStringSubmatch(m)[1] {
+		case "date":
+			return v.Now.Format("2006-01-02")
+		case "time":
+			return v.Now.Format("15:04")
+		case "datetime":
+			return v.Now.Format("2006-01-02 15:04")
+		case "weekday":
+			return v.Now.Weekday().String()
+		case "title":
+			return v.Title
+		case "user":
+			return v.User
+		}
+		return m
+	})
+}
+
+// Builtin - templates available to every user
+var Builtin = []model.Template{
+	{
+		Builtin: "meeting",
+		Name:    "Meeting notes",
+		Title:   "Meeting {{date}}",
+		Text: "# {{title}}\n\n" +
+			"**Date:** {{weekday}}, {{date}} {{time}}\n" +
+			"**Author:** {{user}}\n\n" +
+			"## Attendees\n\n- \n\n" +
+			"## Agenda\n\n1. \n\n" +
+			"## Notes\n\n\n" +
+			"## Action items\n\n- [ ] \n",
+	},
+	{
+		Builtin: "incident",
+		Name:    "Incident report",
+		Title:   "Incident {{date}}",
+		Text: "# {{title}}\n\n" +
+			"**Reported:** {{datetime}} by {{user}}\n" +
+			"**Severity:** \n" +
+			"**Status:** investigating\n\n" +
+			"## Summary\n\n\n" +
+			"## Impact\n\n\n" +
+			"## Timeline\n\n- {{time}} \n\n" +
+			"## Root cause\n\n\n" +
+			"## Follow-up\n\n- [ ] \n",
+	},
+	{
+		Builtin: "daily",
+		Name:    "Daily note",
+		Title:   "{{date}}",
+		Text: "# {{weekday}}, {{date}}\n\n" +
+			"## Tasks\n\n- [ ] \n\n" +
+			"## Notes\n\n",
+	},
+}
+
+// FindBuiltin returns the built-in template by its key
+func FindBuiltin(key string) (model.Template, bool) {
+	for _, t := range Builtin {
+		if t.Builtin == key {
+			return t, true
+		}
+	}
+	return model.Template{}, false
+}
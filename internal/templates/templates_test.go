@@ -0,0 +1,41 @@
+package templates
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpand(t *testing.T) {
+	vars := Vars{
+		Title: "Standup",
+		User:  "user@mail.com",
+		Now:   time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC),
+	}
+
+	testCases := []struct {
+		name string
+		text string
+		want string
+	}{
+		{name: "date and time", text: "{{date}} {{time}} {{datetime}} {{weekday}}", want: "2024-01-02 15:04 2024-01-02 15:04 Tuesday"},
+		{name: "title and user", text: "# {{ title }} by {{user}}", want: "# Standup by user@mail.com"},
+		{name: "unknown kept", text: "{{unknown}} {{ date", want: "{{unknown}} {{ date"},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			assert.Equal(t, tcase.want, Expand(tcase.text, vars))
+		})
+	}
+}
+
+func TestFindBuiltin(t *testing.T) {
+	tpl, ok := FindBuiltin("meeting")
+	assert.True(t, ok)
+	assert.Equal(t, "Meeting {{date}}", tpl.Title)
+
+	_, ok = FindBuiltin("missing")
+	assert.False(t, ok)
+}
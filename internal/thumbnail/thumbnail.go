@@ -0,0 +1,162 @@
+package thumbnail
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+
+	xdraw "golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// maxPixels protects from decompression bombs: a small file
+// may declare a huge image
+const maxPixels = 50_000_000
+
+// Sizes - the longest side of generated thumbnails in pixels
+var Sizes = []int{64, 256, 1024}
+
+var (
+	ErrUnsupported = errors.New("unsupported image format")
+	ErrTooLarge    = errors.New("image dimensions are too large")
+)
+
+type Thumbnail struct {
+	Size   int
+	Width  int
+	Height int
+	Data   []byte
+}
+
+// Supported reports whether thumbnails can be made for the content type
+func Supported(contentType string) bool {
+	switch contentType {
+	case "image/png", "image/jpeg", "image/gif", "image/webp":
+		return true
+	}
+	return false
+}
+
+// ContentType of the thumbnails made from the original, photos stay JPEG,
+// everything else becomes PNG to keep transparency
+func ContentType(original string) string {
+	if original == "image/jpeg" {
+		return "image/jpeg"
+	}
+	return "image/png"
+}
+
+// Generate makes a thumbnail of every size in Sizes. Images are never
+// upscaled and the EXIF orientation of JPEG is applied. Encoders do not
+// write any metadata, so thumbnails carry no EXIF.
+func Generate(data []byte, contentType string) ([]Thumbnail, error) {
+	if !Supported(contentType) {
+		return nil, ErrUnsupported
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if config.Width*config.Height > maxPixels {
+		return nil, ErrTooLarge
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if contentType == "image/jpeg" {
+		src = orient(src, jpegOrientation(data))
+	}
+
+	thumbs := make([]Thumbnail, 0, len(Sizes))
+	for _, size := range Sizes {
+		img := resize(src, size)
+
+		buf := &bytes.Buffer{}
+		if ContentType(contentType) == "image/jpeg" {
+			err = jpeg.Encode(buf, img, &jpeg.Options{Quality: 82})
+		} else {
+			err = png.Encode(buf, img)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		thumbs = append(thumbs, Thumbnail{
+			Size:   size,
+			Width:  img.Bounds().Dx(),
+			Height: img.Bounds().Dy(),
+			Data:   buf.Bytes(),
+		})
+	}
+	return thumbs, nil
+}
+
+// resize scales the image to fit into size x size keeping the aspect ratio
+func resize(src image.Image, size int) image.Image {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= size && h <= size {
+		dst := image.NewRGBA(image.Rect(0, 0, w, h))
+		draw.Draw(dst, dst.Bounds(), src, b.Min, draw.Src)
+		return dst
+	}
+
+	if w >= h {
+		h = max(1, h*size/w)
+		w = size
+	} else {
+		w = max(1, w*size/h)
+		h = size
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	xdraw.CatmullRom.Scale(dst, dst.Bounds(), src, b, xdraw.Src, nil)
+	return dst
+}
+
+// orient applies the EXIF orientation (1-8), the result is always upright
+func orient(src image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return src
+	}
+
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	// orientations 5-8 swap width and height
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2:
+				dx, dy = w-1-x, y
+			case 3:
+				dx, dy = w-1-x, h-1-y
+			case 4:
+				dx, dy = x, h-1-y
+			case 5:
+				dx, dy = y, x
+			case 6:
+				dx, dy = h-1-y, x
+			case 7:
+				dx, dy = h-1-y, w-1-x
+			case 8:
+				dx, dy = y, w-1-x
+			}
+			dst.Set(dx, dy, src.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+	return dst
+}
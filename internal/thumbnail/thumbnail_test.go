@@ -0,0 +1,126 @@
+package thumbnail
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testImage(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 128, 255})
+		}
+	}
+	return img
+}
+
+// withExif inserts an EXIF segment with the orientation and a fake GPS
+// string right after SOI
+func withExif(data []byte, orientation int) []byte {
+	seg := orientationSegment(orientation)
+	seg = append(seg[:len(seg):len(seg)], []byte("GPS 55.7558 37.6173")...)
+	seg[2], seg[3] = byte((len(seg)-2)>>8), byte(len(seg)-2)
+
+	res := append([]byte{}, data[:2]...)
+	res = append(res, seg...)
+	return append(res, data[2:]...)
+}
+
+func TestGenerate(t *testing.T) {
+	jpegBuf := &bytes.Buffer{}
+	assert.NoError(t, jpeg.Encode(jpegBuf, testImage(2000, 1000), nil))
+	pngBuf := &bytes.Buffer{}
+	assert.NoError(t, png.Encode(pngBuf, testImage(100, 300)))
+
+	testCases := []struct {
+		name        string
+		data        []byte
+		contentType string
+		want        [][2]int
+	}{
+		{
+			name:        "landscape jpeg",
+			data:        jpegBuf.Bytes(),
+			contentType: "image/jpeg",
+			want:        [][2]int{{64, 32}, {256, 128}, {1024, 512}},
+		},
+		{
+			name:        "rotated jpeg",
+			data:        withExif(jpegBuf.Bytes(), 6),
+			contentType: "image/jpeg",
+			want:        [][2]int{{32, 64}, {128, 256}, {512, 1024}},
+		},
+		{
+			name:        "small png is not upscaled",
+			data:        pngBuf.Bytes(),
+			contentType: "image/png",
+			want:        [][2]int{{21, 64}, {85, 256}, {100, 300}},
+		},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			thumbs, err := Generate(tcase.data, tcase.contentType)
+			assert.NoError(t, err)
+			assert.Len(t, thumbs, len(Sizes))
+
+			for i, th := range thumbs {
+				assert.Equal(t, tcase.want[i], [2]int{th.Width, th.Height})
+				assert.Equal(t, 0, jpegOrientation(th.Data))
+
+				_, format, err := image.DecodeConfig(bytes.NewReader(th.Data))
+				assert.NoError(t, err)
+				assert.Equal(t, ContentType(tcase.contentType), "image/"+format)
+			}
+		})
+	}
+
+	_, err := Generate([]byte("%PDF-1.4"), "application/pdf")
+	assert.Equal(t, ErrUnsupported, err)
+}
+
+func TestStripJPEG(t *testing.T) {
+	buf := &bytes.Buffer{}
+	assert.NoError(t, jpeg.Encode(buf, testImage(40, 20), nil))
+
+	testCases := []struct {
+		name        string
+		orientation int
+	}{
+		{
+			name:        "orientation kept",
+			orientation: 6,
+		},
+		{
+			name:        "default orientation dropped",
+			orientation: 1,
+		},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			data := withExif(buf.Bytes(), tcase.orientation)
+			assert.True(t, bytes.Contains(data, []byte("GPS")))
+
+			res, err := StripMetadata(data, "image/jpeg")
+			assert.NoError(t, err)
+			assert.False(t, bytes.Contains(res, []byte("GPS")))
+
+			want := tcase.orientation
+			if want == 1 {
+				want = 0
+			}
+			assert.Equal(t, want, jpegOrientation(res))
+
+			_, err = jpeg.Decode(bytes.NewReader(res))
+			assert.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package wikilink
+
+import (
+	"strconv"
+	"strings"
+)
+
+// MaxTitle - length limit of notes.title
+const MaxTitle = 100
+
+// Link - reference to another note inside the note text:
+//
+//	[[Note Title]], [[Note Title|shown text]], [[Note Title#Heading]]
+//	[[note:123]], [[note:123|shown text]]
+type Link struct {
+	Title   string
+	Id      int
+	Heading string
+	Alias   string
+	// position of the whole link in the text
+	Start int
+	End   int
+	// position of the title inside the text, used to rename
+	titleStart int
+	titleEnd   int
+}
+
+// Parse finds all links in the text. Links inside code blocks
+// and inline code are ignored.
+func Parse(text string) []Link {
+	links := []Link{}
+
+	fence := ""
+	offset := 0
+	for _, line := range strings.SplitAfter(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case fence != "":
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+		case strings.HasPrefix(trimmed, "```"):
+			fence = "```"
+		case strings.HasPrefix(trimmed, "~~~"):
+			fence = "~~~"
+		default:
+			links = append(links, parseLine(line, offset)...)
+		}
+		offset += len(line)
+	}
+	return links
+}
+
+func parseLine(line string, offset int) []Link {
+	links := []Link{}
+
+	for i := 0; i < len(line); i++ {
+		// inline code
+		if line[i] == '`' {
+			ticks := 1
+			for i+ticks < len(line) && line[i+ticks] == '`' {
+				ticks++
+			}
+			closing := strings.Index(line[i+ticks:], strings.Repeat("`", ticks))
+			if closing == -1 {
+				i += ticks - 1
+				continue
+			}
+			i += ticks + closing + ticks - 1
+			continue
+		}
+
+		if !strings.HasPrefix(line[i:], "[[") {
+			continue
+		}
+		end := strings.Index(line[i+2:], "]]")
+		if end == -1 {
+			return links
+		}
+		inner := line[i+2 : i+2+end]
+		// "[[a [[b]]" - the link starts at the last brackets
+		if nested := strings.LastIndex(inner, "[["); nested != -1 {
+			i += nested + 1
+			continue
+		}
+
+		if l, ok := parseLink(inner); ok {
+			l.Start = offset + i
+			l.End = offset + i + 2 + end + 2
+			l.titleStart += offset + i + 2
+			l.titleEnd += offset + i + 2
+			links = append(links, l)
+		}
+		i += 2 + end + 1
+	}
+	return links
+}
+
+func parseLink(inner string) (Link, bool) {
+	l := Link{}
+
+	target := inner
+	if pipe := strings.Index(inner, "|"); pipe != -1 {
+		target = inner[:pipe]
+		l.Alias = strings.TrimSpace(inner[pipe+1:])
+	}
+	if hash := strings.Index(target, "#"); hash != -1 {
+		l.Heading = strings.TrimSpace(target[hash+1:])
+		target = target[:hash]
+	}
+
+	// title position inside inner without surrounding spaces
+	l.titleStart = len(target) - len(strings.TrimLeft(target, " \t"))
+	title := strings.TrimSpace(target)
+	l.titleEnd = l.titleStart + len(title)
+
+	if title == "" || len(title) > MaxTitle {
+		return l, false
+	}
+
+	if rest, ok := strings.CutPrefix(title, "note:"); ok {
+		id, err := strconv.Atoi(rest)
+		if err != nil || id <= 0 {
+			return l, false
+		}
+		l.Id = id
+		return l, true
+	}
+
+	l.Title = title
+	return l, true
+}
+
+// Rename replaces the title in all links to the note with oldTitle,
+// headings and aliases are kept. Titles are compared case-insensitively.
+func Rename(text string, oldTitle string, newTitle string) string {
+	var b strings.Builder
+	last := 0
+	for _, l := range Parse(text) {
+		if l.Title == "" || !strings.EqualFold(l.Title, oldTitle) {
+			continue
+		}
+		b.WriteString(text[last:l.titleStart])
+		b.WriteString(newTitle)
+		last = l.titleEnd
+	}
+	if last == 0 {
+		return text
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// Renumber replaces the ids in [[note:N]] links by ids[N], links to
+// notes missing in ids are kept
+func Renumber(text string, ids map[int]int) string {
+	var b strings.Builder
+	last := 0
+	for _, l := range Parse(text) {
+		id, ok := ids[l.Id]
+		if l.Id == 0 || !ok {
+			continue
+		}
+		b.WriteString(text[last:l.titleStart])
+		b.WriteString("note:" + strconv.Itoa(id))
+		last = l.titleEnd
+	}
+	if last == 0 {
+		return text
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
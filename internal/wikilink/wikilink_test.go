@@ -0,0 +1,101 @@
+package wikilink
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		name string
+		text string
+		want []Link
+	}{
+		{
+			name: "title and id",
+			text: "see [[Shopping list]] and [[note:42]]",
+			want: []Link{{Title: "Shopping list"}, {Id: 42}},
+		},
+		{
+			name: "alias and heading",
+			text: "[[ Plans #2024 | this year ]]",
+			want: []Link{{Title: "Plans", Heading: "2024", Alias: "this year"}},
+		},
+		{
+			name: "code is ignored",
+			text: "`[[Not a link]]`\n```\n[[Neither]]\n```\n[[Link]]",
+			want: []Link{{Title: "Link"}},
+		},
+		{
+			name: "broken syntax",
+			text: "[[]] [[note:abc]] [[unclosed [[Inner]]",
+			want: []Link{{Title: "Inner"}},
+		},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			got := Parse(tcase.text)
+			assert.Len(t, got, len(tcase.want))
+			for i := range got {
+				assert.Equal(t, tcase.want[i].Title, got[i].Title)
+				assert.Equal(t, tcase.want[i].Id, got[i].Id)
+				assert.Equal(t, tcase.want[i].Heading, got[i].Heading)
+				assert.Equal(t, tcase.want[i].Alias, got[i].Alias)
+				assert.Equal(t, "[[", tcase.text[got[i].Start:got[i].Start+2])
+				assert.Equal(t, "]]", tcase.text[got[i].End-2:got[i].End])
+			}
+		})
+	}
+}
+
+func TestRename(t *testing.T) {
+	testCases := []struct {
+		name string
+		text string
+		want string
+	}{
+		{
+			name: "all forms",
+			text: "[[Old]], [[old|alias]], [[ Old #top ]]",
+			want: "[[New name]], [[New name|alias]], [[ New name #top ]]",
+		},
+		{
+			name: "other links and code untouched",
+			text: "[[Older]] `[[Old]]` [[note:1]]",
+			want: "[[Older]] `[[Old]]` [[note:1]]",
+		},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			assert.Equal(t, tcase.want, Rename(tcase.text, "Old", "New name"))
+		})
+	}
+}
+
+func TestRenumber(t *testing.T) {
+	testCases := []struct {
+		name string
+		text string
+		want string
+	}{
+		{
+			name: "all forms",
+			text: "[[note:1]], [[note:2|alias]], [[ note:1 #top ]]",
+			want: "[[note:10]], [[note:20|alias]], [[ note:10 #top ]]",
+		},
+		{
+			name: "unknown ids, titles and code untouched",
+			text: "[[note:3]] [[Title]] `[[note:1]]`",
+			want: "[[note:3]] [[Title]] `[[note:1]]`",
+		},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			assert.Equal(t, tcase.want, Renumber(tcase.text, map[int]int{1: 10, 2: 20}))
+		})
+	}
+}
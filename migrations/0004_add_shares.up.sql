@@ -0,0 +1,16 @@
+CREATE TABLE shares(
+    id SERIAL PRIMARY KEY,
+    owner_email VARCHAR(100) NOT NULL REFERENCES users(email) ON UPDATE CASCADE ON DELETE CASCADE,
+    user_email VARCHAR(100) NOT NULL REFERENCES users(email) ON UPDATE CASCADE ON DELETE CASCADE,
+    note_id INT REFERENCES notes(id) ON UPDATE CASCADE ON DELETE CASCADE,
+    group_id INT REFERENCES groups(id) ON UPDATE CASCADE ON DELETE CASCADE,
+    permission VARCHAR(10) NOT NULL CHECK (permission IN ('read', 'edit')),
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+    CHECK ((note_id IS NULL) <> (group_id IS NULL)),
+    UNIQUE (user_email, note_id),
+    UNIQUE (user_email, group_id)
+);
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON shares TO notesapp;
+
+GRANT USAGE, SELECT ON shares_id_seq TO notesapp;
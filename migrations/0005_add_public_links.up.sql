@@ -0,0 +1,16 @@
+CREATE TABLE public_links(
+    id SERIAL PRIMARY KEY,
+    token VARCHAR(64) NOT NULL UNIQUE,
+    owner_email VARCHAR(100) NOT NULL REFERENCES users(email) ON UPDATE CASCADE ON DELETE CASCADE,
+    note_id INT REFERENCES notes(id) ON UPDATE CASCADE ON DELETE CASCADE,
+    group_id INT REFERENCES groups(id) ON UPDATE CASCADE ON DELETE CASCADE,
+    password VARCHAR(500),
+    expires_at TIMESTAMP,
+    views INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+    CHECK ((note_id IS NULL) <> (group_id IS NULL))
+);
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON public_links TO notesapp;
+
+GRANT USAGE, SELECT ON public_links_id_seq TO notesapp;
@@ -0,0 +1,23 @@
+DROP TRIGGER IF EXISTS groups_log_change ON groups;
+
+DROP TRIGGER IF EXISTS notes_log_change ON notes;
+
+DROP TRIGGER IF EXISTS groups_touch_version ON groups;
+
+DROP TRIGGER IF EXISTS notes_touch_version ON notes;
+
+DROP FUNCTION IF EXISTS log_change;
+
+DROP FUNCTION IF EXISTS touch_version;
+
+DROP TABLE IF EXISTS changes;
+
+ALTER TABLE groups
+    DROP COLUMN version,
+    DROP COLUMN updated_at,
+    DROP COLUMN created_at;
+
+ALTER TABLE notes
+    DROP COLUMN version,
+    DROP COLUMN updated_at,
+    DROP COLUMN created_at;
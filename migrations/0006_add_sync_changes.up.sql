@@ -0,0 +1,68 @@
+ALTER TABLE notes
+    ADD created_at TIMESTAMP NOT NULL DEFAULT now(),
+    ADD updated_at TIMESTAMP NOT NULL DEFAULT now(),
+    ADD version INT NOT NULL DEFAULT 1;
+
+ALTER TABLE groups
+    ADD created_at TIMESTAMP NOT NULL DEFAULT now(),
+    ADD updated_at TIMESTAMP NOT NULL DEFAULT now(),
+    ADD version INT NOT NULL DEFAULT 1;
+
+CREATE TABLE changes(
+    id BIGSERIAL PRIMARY KEY,
+    user_email VARCHAR(100) NOT NULL REFERENCES users(email) ON UPDATE CASCADE ON DELETE CASCADE,
+    entity VARCHAR(10) NOT NULL CHECK (entity IN ('note', 'group')),
+    entity_id INT NOT NULL,
+    action VARCHAR(10) NOT NULL CHECK (action IN ('upsert', 'delete')),
+    created_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE INDEX changes_user_email_id_idx ON changes(user_email, id);
+
+-- every change of notes and groups (cascade deletes included) gets into the log
+
+CREATE FUNCTION touch_version() RETURNS trigger AS $$
+BEGIN
+    NEW.updated_at := now();
+    NEW.version := OLD.version + 1;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE FUNCTION log_change() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        INSERT INTO changes(user_email, entity, entity_id, action) VALUES (OLD.user_email, TG_ARGV[0], OLD.id, 'delete');
+        RETURN OLD;
+    END IF;
+    IF TG_OP = 'UPDATE' AND OLD.user_email <> NEW.user_email THEN
+        INSERT INTO changes(user_email, entity, entity_id, action) VALUES (OLD.user_email, TG_ARGV[0], OLD.id, 'delete');
+    END IF;
+    INSERT INTO changes(user_email, entity, entity_id, action) VALUES (NEW.user_email, TG_ARGV[0], NEW.id, 'upsert');
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER notes_touch_version BEFORE UPDATE ON notes
+    FOR EACH ROW EXECUTE FUNCTION touch_version();
+
+CREATE TRIGGER groups_touch_version BEFORE UPDATE ON groups
+    FOR EACH ROW EXECUTE FUNCTION touch_version();
+
+CREATE TRIGGER notes_log_change AFTER INSERT OR UPDATE OR DELETE ON notes
+    FOR EACH ROW EXECUTE FUNCTION log_change('note');
+
+CREATE TRIGGER groups_log_change AFTER INSERT OR UPDATE OR DELETE ON groups
+    FOR EACH ROW EXECUTE FUNCTION log_change('group');
+
+-- existing data is the first state of the log
+
+INSERT INTO changes(user_email, entity, entity_id, action)
+    SELECT user_email, 'group', id, 'upsert' FROM groups ORDER BY id;
+
+INSERT INTO changes(user_email, entity, entity_id, action)
+    SELECT user_email, 'note', id, 'upsert' FROM notes ORDER BY id;
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON changes TO notesapp;
+
+GRANT USAGE, SELECT ON changes_id_seq TO notesapp;
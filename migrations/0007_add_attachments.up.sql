@@ -0,0 +1,18 @@
+CREATE TABLE attachments(
+    id SERIAL PRIMARY KEY,
+    owner_email VARCHAR(100) NOT NULL REFERENCES users(email) ON UPDATE CASCADE ON DELETE CASCADE,
+    -- NULL after the note is deleted, such rows are removed with their blobs by the cleanup
+    note_id INT REFERENCES notes(id) ON UPDATE CASCADE ON DELETE SET NULL,
+    blob_key VARCHAR(200) NOT NULL UNIQUE,
+    name VARCHAR(255) NOT NULL,
+    content_type VARCHAR(100) NOT NULL,
+    size BIGINT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE INDEX attachments_note_id_idx ON attachments(note_id);
+CREATE INDEX attachments_owner_email_idx ON attachments(owner_email);
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON attachments TO notesapp;
+
+GRANT USAGE, SELECT ON attachments_id_seq TO notesapp;
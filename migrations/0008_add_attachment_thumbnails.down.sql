@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS attachments_thumbnails_pending_idx;
+
+ALTER TABLE attachments DROP COLUMN IF EXISTS thumbnails;
@@ -0,0 +1,6 @@
+ALTER TABLE attachments ADD COLUMN thumbnails VARCHAR(10) NOT NULL DEFAULT 'none';
+
+UPDATE attachments SET thumbnails = 'pending'
+WHERE content_type IN ('image/png', 'image/jpeg', 'image/gif', 'image/webp');
+
+CREATE INDEX attachments_thumbnails_pending_idx ON attachments(id) WHERE thumbnails = 'pending';
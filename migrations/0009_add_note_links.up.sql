@@ -0,0 +1,19 @@
+CREATE TABLE note_links(
+    id SERIAL PRIMARY KEY,
+    source_id INT NOT NULL REFERENCES notes(id) ON UPDATE CASCADE ON DELETE CASCADE,
+    -- NULL while the link is broken
+    target_id INT REFERENCES notes(id) ON UPDATE CASCADE ON DELETE SET NULL,
+    -- [[Title]] links keep the title, [[note:123]] links keep the id
+    title VARCHAR(100) NOT NULL DEFAULT '',
+    ref_id INT,
+    heading VARCHAR(200) NOT NULL DEFAULT '',
+    alias VARCHAR(200) NOT NULL DEFAULT ''
+);
+
+CREATE INDEX note_links_source_id_idx ON note_links(source_id);
+CREATE INDEX note_links_target_id_idx ON note_links(target_id);
+CREATE INDEX note_links_broken_title_idx ON note_links(lower(title)) WHERE target_id IS NULL;
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON note_links TO notesapp;
+
+GRANT USAGE, SELECT ON note_links_id_seq TO notesapp;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS notes_group_id_updated_at_idx;
+
+ALTER TABLE public_links DROP COLUMN IF EXISTS feed;
@@ -0,0 +1,4 @@
+-- opt-in Atom/RSS feed of a group link
+ALTER TABLE public_links ADD COLUMN feed BOOLEAN NOT NULL DEFAULT false;
+
+CREATE INDEX notes_group_id_updated_at_idx ON notes(group_id, updated_at DESC);
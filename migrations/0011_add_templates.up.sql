@@ -0,0 +1,14 @@
+CREATE TABLE templates(
+    id SERIAL PRIMARY KEY,
+    user_email VARCHAR(100) NOT NULL REFERENCES users(email) ON UPDATE CASCADE ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    title VARCHAR(100) NOT NULL DEFAULT '',
+    text VARCHAR(10485760) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+    updated_at TIMESTAMP NOT NULL DEFAULT now(),
+    UNIQUE (user_email, name)
+);
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON templates TO notesapp;
+
+GRANT USAGE, SELECT ON templates_id_seq TO notesapp;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS journal_days;
+DROP TABLE IF EXISTS journal_settings;
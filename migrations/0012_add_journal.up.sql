@@ -0,0 +1,15 @@
+CREATE TABLE journal_settings(
+    user_email VARCHAR(100) PRIMARY KEY REFERENCES users(email) ON UPDATE CASCADE ON DELETE CASCADE,
+    group_id INT REFERENCES groups(id) ON UPDATE CASCADE ON DELETE SET NULL,
+    template_id INT REFERENCES templates(id) ON UPDATE CASCADE ON DELETE SET NULL,
+    builtin VARCHAR(50) NOT NULL DEFAULT 'daily'
+);
+
+CREATE TABLE journal_days(
+    user_email VARCHAR(100) NOT NULL REFERENCES users(email) ON UPDATE CASCADE ON DELETE CASCADE,
+    day DATE NOT NULL,
+    note_id INT NOT NULL REFERENCES notes(id) ON UPDATE CASCADE ON DELETE CASCADE,
+    PRIMARY KEY (user_email, day)
+);
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON journal_settings, journal_days TO notesapp;
@@ -0,0 +1,32 @@
+CREATE TABLE reminders(
+    id SERIAL PRIMARY KEY,
+    note_id INT NOT NULL REFERENCES notes(id) ON UPDATE CASCADE ON DELETE CASCADE,
+    user_email VARCHAR(100) NOT NULL REFERENCES users(email) ON UPDATE CASCADE ON DELETE CASCADE,
+    message VARCHAR(500) NOT NULL DEFAULT '',
+    due_at TIMESTAMPTZ NOT NULL,
+    remind_at TIMESTAMPTZ NOT NULL,
+    fired_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- the scheduler looks only at reminders which have not fired yet
+CREATE INDEX reminders_due_idx ON reminders(remind_at) WHERE fired_at IS NULL;
+CREATE INDEX reminders_note_idx ON reminders(note_id);
+
+CREATE TABLE notifications(
+    id SERIAL PRIMARY KEY,
+    user_email VARCHAR(100) NOT NULL REFERENCES users(email) ON UPDATE CASCADE ON DELETE CASCADE,
+    note_id INT NOT NULL REFERENCES notes(id) ON UPDATE CASCADE ON DELETE CASCADE,
+    reminder_id INT REFERENCES reminders(id) ON UPDATE CASCADE ON DELETE SET NULL,
+    title VARCHAR(100) NOT NULL,
+    message VARCHAR(500) NOT NULL DEFAULT '',
+    due_at TIMESTAMPTZ NOT NULL,
+    read BOOLEAN NOT NULL DEFAULT false,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX notifications_user_idx ON notifications(user_email, id DESC);
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON reminders, notifications TO notesapp;
+
+GRANT USAGE, SELECT ON reminders_id_seq, notifications_id_seq TO notesapp;
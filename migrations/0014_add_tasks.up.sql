@@ -0,0 +1,16 @@
+CREATE TABLE tasks(
+    id SERIAL PRIMARY KEY,
+    note_id INT NOT NULL REFERENCES notes(id) ON UPDATE CASCADE ON DELETE CASCADE,
+    user_email VARCHAR(100) NOT NULL REFERENCES users(email) ON UPDATE CASCADE ON DELETE CASCADE,
+    line INT NOT NULL,
+    text VARCHAR(500) NOT NULL,
+    done BOOLEAN NOT NULL DEFAULT false,
+    due DATE
+);
+
+CREATE INDEX tasks_note_idx ON tasks(note_id);
+CREATE INDEX tasks_open_idx ON tasks(user_email, due) WHERE NOT done;
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON tasks TO notesapp;
+
+GRANT USAGE, SELECT ON tasks_id_seq TO notesapp;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS pins;
+DROP TABLE IF EXISTS favorites;
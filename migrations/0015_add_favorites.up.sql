@@ -0,0 +1,21 @@
+CREATE TABLE favorites(
+    id SERIAL PRIMARY KEY,
+    user_email VARCHAR(100) NOT NULL REFERENCES users(email) ON UPDATE CASCADE ON DELETE CASCADE,
+    note_id INT REFERENCES notes(id) ON UPDATE CASCADE ON DELETE CASCADE,
+    group_id INT REFERENCES groups(id) ON UPDATE CASCADE ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+    CHECK ((note_id IS NULL) <> (group_id IS NULL)),
+    UNIQUE (user_email, note_id),
+    UNIQUE (user_email, group_id)
+);
+
+CREATE TABLE pins(
+    user_email VARCHAR(100) NOT NULL REFERENCES users(email) ON UPDATE CASCADE ON DELETE CASCADE,
+    note_id INT NOT NULL REFERENCES notes(id) ON UPDATE CASCADE ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_email, note_id)
+);
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON favorites, pins TO notesapp;
+
+GRANT USAGE, SELECT ON favorites_id_seq TO notesapp;
@@ -0,0 +1,12 @@
+CREATE TABLE note_activity(
+    user_email VARCHAR(100) NOT NULL REFERENCES users(email) ON UPDATE CASCADE ON DELETE CASCADE,
+    note_id INT NOT NULL REFERENCES notes(id) ON UPDATE CASCADE ON DELETE CASCADE,
+    viewed_at TIMESTAMPTZ,
+    edited_at TIMESTAMPTZ,
+    PRIMARY KEY (user_email, note_id)
+);
+
+CREATE INDEX note_activity_viewed_idx ON note_activity(user_email, viewed_at DESC) WHERE viewed_at IS NOT NULL;
+CREATE INDEX note_activity_edited_idx ON note_activity(user_email, edited_at DESC) WHERE edited_at IS NOT NULL;
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON note_activity TO notesapp;
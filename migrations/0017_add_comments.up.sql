@@ -0,0 +1,24 @@
+CREATE TABLE comments(
+    id SERIAL PRIMARY KEY,
+    note_id INT NOT NULL REFERENCES notes(id) ON UPDATE CASCADE ON DELETE CASCADE,
+    -- NULL - the comment starts a thread
+    parent_id INT REFERENCES comments(id) ON UPDATE CASCADE ON DELETE CASCADE,
+    author_email VARCHAR(100) NOT NULL REFERENCES users(email) ON UPDATE CASCADE ON DELETE CASCADE,
+    text VARCHAR(10000) NOT NULL,
+    -- range of the note text the thread is about, in characters
+    anchor_start INT,
+    anchor_end INT,
+    anchor_text VARCHAR(1000) NOT NULL DEFAULT '',
+    resolved BOOLEAN NOT NULL DEFAULT false,
+    resolved_by VARCHAR(100) REFERENCES users(email) ON UPDATE CASCADE ON DELETE SET NULL,
+    resolved_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+    updated_at TIMESTAMP NOT NULL DEFAULT now(),
+    CHECK ((anchor_start IS NULL) = (anchor_end IS NULL))
+);
+
+CREATE INDEX comments_note_idx ON comments(note_id, id);
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON comments TO notesapp;
+
+GRANT USAGE, SELECT ON comments_id_seq TO notesapp;
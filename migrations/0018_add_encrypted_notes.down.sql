@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS user_keys;
+
+-- ciphertext is useless without the flag
+DELETE FROM notes WHERE encrypted;
+
+ALTER TABLE notes
+    DROP COLUMN encrypted,
+    ALTER COLUMN title TYPE VARCHAR(100);
@@ -0,0 +1,22 @@
+-- title and text of encrypted notes are ciphertext of the client,
+-- encrypted titles are longer than the plain ones
+ALTER TABLE notes
+    ADD encrypted BOOLEAN NOT NULL DEFAULT false,
+    ALTER COLUMN title TYPE VARCHAR(1000);
+
+-- key material of the user, the server never sees the keys themselves:
+-- the note key of the user is wrapped by a key derived from the passphrase
+-- and once more by the recovery key
+CREATE TABLE user_keys(
+    user_email VARCHAR(100) PRIMARY KEY REFERENCES users(email) ON UPDATE CASCADE ON DELETE CASCADE,
+    kdf VARCHAR(20) NOT NULL,
+    kdf_params VARCHAR(500) NOT NULL,
+    salt VARCHAR(200) NOT NULL,
+    wrapped_key VARCHAR(2000) NOT NULL,
+    recovery_wrapped_key VARCHAR(2000) NOT NULL DEFAULT '',
+    version INT NOT NULL DEFAULT 1,
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+    updated_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON user_keys TO notesapp;
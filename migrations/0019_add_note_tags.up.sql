@@ -0,0 +1,9 @@
+-- #tags of the notes for the graph, parsed from the text of the note
+-- version; rows older than the note are parsed again
+CREATE TABLE note_tags(
+    note_id INT PRIMARY KEY REFERENCES notes(id) ON UPDATE CASCADE ON DELETE CASCADE,
+    version INT NOT NULL,
+    tags TEXT[] NOT NULL DEFAULT '{}'
+);
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON note_tags TO notesapp;
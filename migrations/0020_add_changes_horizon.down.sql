@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS changes_entity_idx;
+DROP TABLE IF EXISTS changes_horizon;
@@ -0,0 +1,11 @@
+-- the change log is compacted to the last change of every object and the
+-- deletes are dropped after a retention period. The horizon is the last
+-- dropped change of the user, a client behind it syncs from the start.
+CREATE TABLE changes_horizon(
+    user_email VARCHAR(100) PRIMARY KEY REFERENCES users(email) ON UPDATE CASCADE ON DELETE CASCADE,
+    change_id BIGINT NOT NULL
+);
+
+CREATE INDEX changes_entity_idx ON changes(user_email, entity, entity_id, id);
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON changes_horizon TO notesapp;
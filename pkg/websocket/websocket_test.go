@@ -0,0 +1,86 @@
+package websocket
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckOrigin(t *testing.T) {
+	testCases := []struct {
+		name    string
+		origin  string
+		origins []string
+		want    bool
+	}{
+		{
+			name: "no origin",
+			want: true,
+		},
+		{
+			name:   "same host",
+			origin: "http://notes.local:8080",
+			want:   true,
+		},
+		{
+			name:   "same host in other case",
+			origin: "https://NOTES.local:8080",
+			want:   true,
+		},
+		{
+			name:   "other site",
+			origin: "https://evil.example.com",
+			want:   false,
+		},
+		{
+			name:   "other port",
+			origin: "http://notes.local:9090",
+			want:   false,
+		},
+		{
+			name:    "allowed origin",
+			origin:  "https://app.example.com",
+			origins: []string{"https://other.example.com", "https://app.example.com"},
+			want:    true,
+		},
+		{
+			name:    "allowed host with other scheme",
+			origin:  "http://app.example.com",
+			origins: []string{"https://app.example.com"},
+			want:    false,
+		},
+		{
+			name:   "broken origin",
+			origin: "://notes.local:8080",
+			want:   false,
+		},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "http://notes.local:8080/ws/note", nil)
+			if tcase.origin != "" {
+				r.Header.Set("Origin", tcase.origin)
+			}
+			assert.Equal(t, tcase.want, CheckOrigin(r, tcase.origins))
+		})
+	}
+}
+
+func TestUpgradeBadOrigin(t *testing.T) {
+	r := httptest.NewRequest("GET", "http://notes.local:8080/ws/note", nil)
+	r.Header.Set("Connection", "Upgrade")
+	r.Header.Set("Upgrade", "websocket")
+	r.Header.Set("Sec-Websocket-Version", "13")
+	r.Header.Set("Sec-Websocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	r.Header.Set("Origin", "https://evil.example.com")
+
+	conn, err := Upgrade(httptest.NewRecorder(), r, nil)
+	assert.Nil(t, conn)
+	assert.Equal(t, ErrBadOrigin, err)
+}
+
+func TestFormatCloseMessage(t *testing.T) {
+	assert.Equal(t, []byte{0x03, 0xe9}, FormatCloseMessage(CloseGoingAway))
+}